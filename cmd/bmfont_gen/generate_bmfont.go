@@ -1,10 +1,43 @@
+// Generates an Angelcode bitmap font descriptor (.fnt) from a glyph sheet laid out in a grid.
+//
+// Usage: bmfont_gen -image <sheet.png> -chars <chars.txt> [flags]
+//
+// The character set file has one line for each row of cells in the sheet, so the Nth character of a line
+// is the glyph in the Nth column of that row. Rows can be left short, but not skipped.
+//
+// The optional glyph file adjusts individual glyphs, one per line:
+//
+//	<char> key=value...
+//
+// where <char> is a single character, a code point like U+0020, or 'default' to change every glyph.
+// The keys are x, y, width, and height for the glyph's rectangle relative to the top left of its cell,
+// along with xoffset, yoffset, and xadvance, which are written to the descriptor as is.
+// By default, each glyph fills its cell and advances the cursor by its width.
+//
+// The optional kerning file adds the given amount of pixels between pairs of characters, one pair per line:
+//
+//	<first char> <second char> <amount>
+//
+// Lines starting with # are ignored in the glyph and kerning files.
+//
+// The game's fonts are generated with:
+//
+//	go run ./cmd/bmfont_gen -image assets/textures/ui/font.png -chars assets/textures/ui/font_chars.txt -name "Total Invasion 22 Font" -size 16 -cell 16x24
+//	go run ./cmd/bmfont_gen -image assets/textures/ui/hud_counter_font.png -chars assets/textures/ui/hud_counter_font_chars.txt -glyphs assets/textures/ui/hud_counter_font_glyphs.txt -name "Total Invasion 22 HUD Counter Font" -size 12 -cell 12x12 -line-height 16 -spacing 2,1
 package main
 
 import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
 	_ "image/png"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
+	"unicode/utf8"
 )
 
 const TEMPLATE = `info face="{{.FontName}}" size={{.FontSize}} bold=0 italic=0 charset="" unicode=1 stretchH=100 smooth=0 aa=1 padding=0,0,0,0 spacing={{index .Spacing 0}},{{index .Spacing 1}} outline=0
@@ -12,8 +45,14 @@ common lineHeight={{.LineHeight}} base={{.Base}} scaleW={{.ImageWidth}} scaleH={
 page id=0 file="{{.ImagePath}}"
 chars count={{len .Chars}}
 {{range .Chars -}}
-char id={{.ID}} x={{.X}} y={{.Y}} width={{.Width}} height={{.Height}} xoffset=0 yoffset=0 xadvance={{.Width}} page=0 chnl=15
+char id={{.ID}} x={{.X}} y={{.Y}} width={{.Width}} height={{.Height}} xoffset={{.XOffset}} yoffset={{.YOffset}} xadvance={{.XAdvance}} page=0 chnl=15
 {{end}}
+{{- if .Kernings -}}
+kernings count={{len .Kernings}}
+{{range .Kernings -}}
+kerning first={{.First}} second={{.Second}} amount={{.Amount}}
+{{end}}
+{{- end}}
 {{- /* Delete trailing whitespice or the parser will complain */ -}}
 `
 
@@ -23,111 +62,296 @@ type TemplParams struct {
 	LineHeight, Base, FontSize int
 	Spacing                    [2]int
 	Chars                      []Char
+	Kernings                   []Kerning
 }
 
 type Char struct {
 	ID                  uint
 	X, Y, Width, Height int
+	XOffset, YOffset    int
+	XAdvance            int
+
+	cell                 image.Rectangle
+	charsetLine          int  // Line of the character set file that the character is on.
+	relativeX, relativeY int  // Position of the glyph's rectangle within its cell.
+	hasWidth, hasHeight  bool // Whether the values were set by the glyph file, instead of being taken from the cell.
+	hasXAdvance          bool
 }
 
-func main() {
-	generateMainFont()
-	generateHudFont()
+type Kerning struct {
+	First, Second uint
+	Amount        int
 }
 
-func generateMainFont() {
-	imgWidth := 256
+// Holds a pair of integers given as a flag in the form "<a><separator><b>".
+type intPair struct {
+	values    [2]int
+	separator string
+}
 
-	chars := make([]Char, 0)
+func (pair *intPair) String() string {
+	return fmt.Sprintf("%d%v%d", pair.values[0], pair.separator, pair.values[1])
+}
 
-	// Include all ASCII characters
-	for i := range 95 {
-		c := i + 32
-		chars = append(chars, Char{
-			ID:     uint(c),
-			X:      ((i * 16) % int(imgWidth)),
-			Y:      ((i * 16) / int(imgWidth)) * 24,
-			Width:  16,
-			Height: 24,
-		})
+func (pair *intPair) Set(text string) error {
+	first, second, ok := strings.Cut(text, pair.separator)
+	if !ok {
+		return fmt.Errorf("expected two numbers separated by '%v'", pair.separator)
 	}
-
-	// Include all Cyrillic characters
-	cyrillicRunes := []rune{
-		'А', 'Б', 'В', 'Г', 'Д', 'Е', 'Ё', 'Ж', 'З', 'И', 'Й', 'К', 'Л', 'М', 'Н', 'О', 'П', 'Р', 'С', 'Т', 'У', 'Ф', 'Х', 'Ц', 'Ч', 'Ш', 'Щ', 'Ъ', 'Ы', 'Ь', 'Э', 'Ю', 'Я',
-		'а', 'б', 'в', 'г', 'д', 'е', 'ё', 'ж', 'з', 'и', 'й', 'к', 'л', 'м', 'н', 'о', 'п', 'р', 'с', 'т', 'у', 'ф', 'х', 'ц', 'ч', 'ш', 'щ', 'ъ', 'ы', 'ь', 'э', 'ю', 'я',
+	var err error
+	if pair.values[0], err = strconv.Atoi(first); err != nil {
+		return err
 	}
-	for i, r := range cyrillicRunes {
-		chars = append(chars, Char{
-			ID:     uint(r),
-			X:      ((i * 16) % int(imgWidth)),
-			Y:      144 + ((i*16)/int(imgWidth))*24,
-			Width:  16,
-			Height: 24,
-		})
+	if pair.values[1], err = strconv.Atoi(second); err != nil {
+		return err
 	}
+	return nil
+}
 
-	tpl := template.Must(template.New("BMFont").Parse(TEMPLATE))
-	builder := &strings.Builder{}
-	err := tpl.Execute(builder, TemplParams{
-		FontName:   "Total Invasion 22 Font",
-		ImagePath:  "font.png",
-		ImageWidth: 256, ImageHeight: 288,
-		LineHeight: 24, Base: 0, FontSize: 16,
-		Spacing: [2]int{2, 2},
-		Chars:   chars,
-	})
-	if err != nil {
-		panic(err)
+// Parses a character given either literally or as a code point like U+0020.
+func parseRune(text string) (rune, error) {
+	if hex, ok := strings.CutPrefix(strings.ToUpper(text), "U+"); ok && len(hex) > 0 {
+		code, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid code point %v", text)
+		}
+		return rune(code), nil
+	}
+	if utf8.RuneCountInString(text) != 1 {
+		return 0, fmt.Errorf("expected a single character or code point, but got '%v'", text)
 	}
+	r, _ := utf8.DecodeRuneInString(text)
+	return r, nil
+}
 
-	// Write
-	file, err := os.Create("assets/textures/ui/font.fnt")
+// Reads the lines of a text file, leaving out blank lines and comments if skipComments is true.
+// Calls the function with the 1-based line number and contents of each line.
+func readLines(filePath string, skipComments bool, fn func(lineNum int, line string) error) error {
+	file, err := os.Open(filePath)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer file.Close()
 
-	_, _ = file.WriteString(builder.String())
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if skipComments {
+			line = strings.TrimSpace(line)
+			if len(line) == 0 || strings.HasPrefix(line, "#") {
+				continue
+			}
+		}
+		if err := fn(lineNum, line); err != nil {
+			return fmt.Errorf("%v:%v: %v", filePath, lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Assigns each character in the character set file to a cell in the grid.
+func readCharset(filePath string, cellWidth, cellHeight int) ([]Char, map[rune]int, error) {
+	chars := make([]Char, 0, 128)
+	charIndices := make(map[rune]int)
+	row := 0
+	err := readLines(filePath, false, func(lineNum int, line string) error {
+		column := 0
+		for _, r := range line {
+			if _, exists := charIndices[r]; exists {
+				return fmt.Errorf("character '%c' (U+%04X) appears more than once", r, r)
+			}
+			charIndices[r] = len(chars)
+			chars = append(chars, Char{
+				ID:          uint(r),
+				cell:        image.Rect(column*cellWidth, row*cellHeight, (column+1)*cellWidth, (row+1)*cellHeight),
+				charsetLine: lineNum,
+			})
+			column++
+		}
+		row++
+		return nil
+	})
+	return chars, charIndices, err
+}
+
+// Applies the glyph file's settings to the matching characters.
+func readGlyphs(filePath string, chars []Char, charIndices map[rune]int) error {
+	return readLines(filePath, true, func(lineNum int, line string) error {
+		fields := strings.Fields(line)
+		targets := chars
+		if fields[0] != "default" {
+			r, err := parseRune(fields[0])
+			if err != nil {
+				return err
+			}
+			index, ok := charIndices[r]
+			if !ok {
+				return fmt.Errorf("character '%c' (U+%04X) is not in the character set", r, r)
+			}
+			targets = chars[index : index+1]
+		}
+
+		for _, field := range fields[1:] {
+			key, valueText, ok := strings.Cut(field, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value, but got '%v'", field)
+			}
+			value, err := strconv.Atoi(valueText)
+			if err != nil {
+				return fmt.Errorf("invalid value for %v: %v", key, err)
+			}
+			for c := range targets {
+				char := &targets[c]
+				switch key {
+				case "x":
+					char.relativeX = value
+				case "y":
+					char.relativeY = value
+				case "width":
+					char.Width, char.hasWidth = value, true
+				case "height":
+					char.Height, char.hasHeight = value, true
+				case "xoffset":
+					char.XOffset = value
+				case "yoffset":
+					char.YOffset = value
+				case "xadvance":
+					char.XAdvance, char.hasXAdvance = value, true
+				default:
+					return fmt.Errorf("unknown key %v", key)
+				}
+			}
+		}
+		return nil
+	})
 }
 
-func generateHudFont() {
-	chars := make([]Char, 0)
-	// Digits
-	for i := range 10 {
-		chars = append(chars, Char{
-			ID:    uint('0' + i),
-			X:     i*12 + 2,
-			Y:     0,
-			Width: 9, Height: 12,
-		})
-	}
-	// Infinity
-	chars = append(chars, Char{
-		ID: uint('∞'),
-		X:  0, Y: 12, Width: 24, Height: 12,
+func readKerning(filePath string, charIndices map[rune]int) ([]Kerning, error) {
+	kernings := make([]Kerning, 0)
+	err := readLines(filePath, true, func(lineNum int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("expected two characters and an amount")
+		}
+		var pair [2]rune
+		for i := range pair {
+			r, err := parseRune(fields[i])
+			if err != nil {
+				return err
+			}
+			if _, ok := charIndices[r]; !ok {
+				return fmt.Errorf("character '%c' (U+%04X) is not in the character set", r, r)
+			}
+			pair[i] = r
+		}
+		amount, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("invalid amount: %v", err)
+		}
+		kernings = append(kernings, Kerning{First: uint(pair[0]), Second: uint(pair[1]), Amount: amount})
+		return nil
 	})
+	return kernings, err
+}
+
+func run() error {
+	imagePath := flag.String("image", "", "Path to the glyph sheet (required). The descriptor refers to it by its file name.")
+	charsPath := flag.String("chars", "", "Path to the character set file, with one line per row of cells (required).")
+	glyphsPath := flag.String("glyphs", "", "Path to a file with per-glyph rectangles and offsets.")
+	kerningPath := flag.String("kerning", "", "Path to a file with kerning pairs.")
+	outPath := flag.String("o", "", "Path to write the descriptor to. Defaults to the image path with the .fnt extension.")
+	fontName := flag.String("name", "", "Name of the font's face.")
+	fontSize := flag.Int("size", 16, "Size of the font in points.")
+	lineHeight := flag.Int("line-height", 0, "Distance between lines in pixels. Defaults to the cell height.")
+	base := flag.Int("base", 0, "Distance from the top of a line to the base of the characters.")
+	cellSize := &intPair{values: [2]int{16, 24}, separator: "x"}
+	flag.Var(cellSize, "cell", "Size of each cell in the grid, as <width>x<height>.")
+	spacing := &intPair{values: [2]int{2, 2}, separator: ","}
+	flag.Var(spacing, "spacing", "Spacing between characters, as <horizontal>,<vertical>.")
+	flag.Parse()
+
+	if len(*imagePath) == 0 || len(*charsPath) == 0 {
+		flag.Usage()
+		return fmt.Errorf("the -image and -chars flags are required")
+	}
+	if cellSize.values[0] <= 0 || cellSize.values[1] <= 0 {
+		return fmt.Errorf("cell size must be positive")
+	}
+	if *lineHeight == 0 {
+		*lineHeight = cellSize.values[1]
+	}
+	if len(*outPath) == 0 {
+		*outPath = strings.TrimSuffix(*imagePath, filepath.Ext(*imagePath)) + ".fnt"
+	}
+
+	// Read the image's size
+	imageFile, err := os.Open(*imagePath)
+	if err != nil {
+		return err
+	}
+	imageConfig, _, err := image.DecodeConfig(imageFile)
+	imageFile.Close()
+	if err != nil {
+		return fmt.Errorf("could not read %v: %v", *imagePath, err)
+	}
+
+	chars, charIndices, err := readCharset(*charsPath, cellSize.values[0], cellSize.values[1])
+	if err != nil {
+		return err
+	}
+	if len(*glyphsPath) > 0 {
+		if err := readGlyphs(*glyphsPath, chars, charIndices); err != nil {
+			return err
+		}
+	}
+	var kernings []Kerning
+	if len(*kerningPath) > 0 {
+		if kernings, err = readKerning(*kerningPath, charIndices); err != nil {
+			return err
+		}
+	}
+
+	// Place the glyphs within their cells
+	imageBounds := image.Rect(0, 0, imageConfig.Width, imageConfig.Height)
+	for c := range chars {
+		char := &chars[c]
+		char.X, char.Y = char.cell.Min.X+char.relativeX, char.cell.Min.Y+char.relativeY
+		if !char.hasWidth {
+			char.Width = char.cell.Dx()
+		}
+		if !char.hasHeight {
+			char.Height = char.cell.Dy()
+		}
+		if !char.hasXAdvance {
+			char.XAdvance = char.Width
+		}
+		if !image.Rect(char.X, char.Y, char.X+char.Width, char.Y+char.Height).In(imageBounds) {
+			return fmt.Errorf("glyph for '%c' (U+%04X) from line %v of %v is outside of the image", rune(char.ID), char.ID, char.charsetLine, *charsPath)
+		}
+	}
 
 	tpl := template.Must(template.New("BMFont").Parse(TEMPLATE))
 	builder := &strings.Builder{}
-	err := tpl.Execute(builder, TemplParams{
-		FontName:   "Total Invasion 22 HUD Counter Font",
-		ImagePath:  "hud_counter_font.png",
-		ImageWidth: 120, ImageHeight: 24,
-		LineHeight: 16, Base: 0, FontSize: 12,
-		Spacing: [2]int{2, 1},
-		Chars:   chars,
+	err = tpl.Execute(builder, TemplParams{
+		FontName:   *fontName,
+		ImagePath:  filepath.Base(*imagePath),
+		ImageWidth: imageConfig.Width, ImageHeight: imageConfig.Height,
+		LineHeight: *lineHeight, Base: *base, FontSize: *fontSize,
+		Spacing:  spacing.values,
+		Chars:    chars,
+		Kernings: kernings,
 	})
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	// Write
-	file, err := os.Create("assets/textures/ui/hud_counter_font.fnt")
-	if err != nil {
-		panic(err)
-	}
-	defer file.Close()
+	return os.WriteFile(*outPath, []byte(builder.String()), 0o644)
+}
 
-	_, _ = file.WriteString(builder.String())
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
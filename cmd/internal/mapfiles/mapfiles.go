@@ -0,0 +1,36 @@
+// Finds the map files that the command line tools work on.
+package mapfiles
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const MAPS_DIR = "assets/maps"
+
+// Finds the map files given as command line arguments, where directories stand for every map file in them.
+// With no arguments, every map in MAPS_DIR is used.
+func FromArgs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{MAPS_DIR}
+	}
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, filepath.ToSlash(arg))
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.te3"))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			paths = append(paths, filepath.ToSlash(match))
+		}
+	}
+	return paths, nil
+}
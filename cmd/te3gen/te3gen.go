@@ -0,0 +1,418 @@
+// Generates maps made of rooms connected by corridors, with doors, enemies, items, keycard locks, a player start and an exit.
+// Usage: te3gen [flags] <output map file>
+// The same flags and seed always give the same map, so the maps can be used for repeatable tests.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/game"
+)
+
+const (
+	CELL_SIZE     = 12 // Width and length in tiles of the part of the grid that each room is placed in.
+	MIN_ROOM_SIZE = 4  // Smallest width or length of a room in tiles. The largest leaves one tile of wall on each side of its cell.
+	MAP_HEIGHT    = 3  // Tiles of floor, open space, and ceiling.
+	FLOOR_Y       = 1  // Grid height of the open space that the rooms and corridors are carved out of.
+)
+
+const (
+	SHAPE_CUBE   = "assets/models/shapes/cube.obj"
+	SHAPE_DOOR   = "assets/models/shapes/door.obj"
+	TEX_WALL     = "assets/textures/tiles/brickwall.png"
+	TEX_FLOOR    = "assets/textures/tiles/concrete.png"
+	TEX_CEILING  = "assets/textures/tiles/sheetrock.png"
+	TEX_DOOR     = "assets/textures/tiles/door.png"
+	TEX_CORRIDOR = "assets/textures/tiles/stone_path.png"
+)
+
+// Enemies are picked from this list, so repeated types are more common.
+var enemyTypes = []game.EnemyType{
+	game.ENEMY_TYPE_WRAITH, game.ENEMY_TYPE_WRAITH, game.ENEMY_TYPE_WRAITH,
+	game.ENEMY_TYPE_FIRE_WRAITH, game.ENEMY_TYPE_FIRE_WRAITH,
+	game.ENEMY_TYPE_DUMMKOPF, game.ENEMY_TYPE_DUMMKOPF,
+	game.ENEMY_TYPE_MOTHER_WRAITH,
+}
+
+// Keycards in the order that their locks appear on the way to the exit.
+var keyTypes = []game.KeyType{game.KEY_TYPE_BLUE, game.KEY_TYPE_BROWN, game.KEY_TYPE_YELLOW, game.KEY_TYPE_GRAY}
+
+type genOptions struct {
+	seed       uint64
+	cols, rows int // Number of rooms along the X and Z axes.
+	enemies    int
+	items      int
+	keys       int    // Number of locked doors on the way to the exit.
+	nextLevel  string // Level that the exit leads to, relative to assets/maps and without the extension.
+}
+
+// Part of the map that holds one room, given in tiles along the X and Z axes.
+type room struct {
+	cell     [2]int
+	min, max [2]int // Open area of the room. The maximum is exclusive.
+	parent   int    // Room that the corridor to this one comes from, or -1 for the starting room.
+	depth    int    // Number of corridors between this room and the starting room.
+	zone     int    // Number of locked doors between this room and the starting room.
+	free     [][2]int
+}
+
+type generator struct {
+	opts  genOptions
+	rng   *rand.Rand
+	tiles *te3.Tiles
+	rooms []room
+	ents  []te3.Ent
+	doors map[int]int // Index of the door ent on the corridor leading to each room.
+}
+
+// Generates a map from the options. Returns the map and the number of locked doors in it,
+// which can be less than requested when there aren't enough corridors on the way to the exit.
+func generateMap(opts genOptions) (*te3.TE3File, int, error) {
+	if opts.cols <= 0 || opts.rows <= 0 {
+		return nil, 0, fmt.Errorf("invalid number of rooms %vx%v", opts.cols, opts.rows)
+	}
+	if opts.keys < 0 || opts.keys > len(keyTypes) {
+		return nil, 0, fmt.Errorf("can have from 0 to %v keys, not %v", len(keyTypes), opts.keys)
+	}
+	width, length := opts.cols*CELL_SIZE, opts.rows*CELL_SIZE
+	if width*MAP_HEIGHT*length > te3.MAX_TILE_COUNT {
+		return nil, 0, fmt.Errorf("map of %vx%v rooms is too large", opts.cols, opts.rows)
+	}
+
+	gen := &generator{
+		opts:  opts,
+		doors: make(map[int]int),
+		rng:   rand.New(rand.NewPCG(opts.seed, 0)),
+		tiles: &te3.Tiles{
+			Width: width, Height: MAP_HEIGHT, Length: length,
+			Data:     make([]te3.Tile, width*MAP_HEIGHT*length),
+			Shapes:   []string{SHAPE_CUBE},
+			Textures: []string{TEX_WALL, TEX_FLOOR, TEX_CEILING, TEX_CORRIDOR},
+		},
+	}
+
+	// Start out solid, with the floor and ceiling above and below the walls.
+	for t := range gen.tiles.Data {
+		_, y, _ := gen.tiles.UnflattenGridPos(t)
+		tex := te3.TextureID(0)
+		switch {
+		case y < FLOOR_Y:
+			tex = 1
+		case y > FLOOR_Y:
+			tex = 2
+		}
+		gen.tiles.Data[t] = te3.Tile{ShapeID: 0, TextureIDs: [2]te3.TextureID{tex, tex}}
+	}
+
+	gen.placeRooms()
+	exitRoom := gen.connectRooms()
+	lockCount := gen.lockPath(exitRoom)
+
+	start := &gen.rooms[0]
+	gen.addEnt(start, te3.Ent{
+		Color:      [3]uint8{104, 55, 55},
+		Radius:     0.7,
+		Properties: map[string]string{"type": "player"},
+	})
+	gen.addEnt(&gen.rooms[exitRoom], te3.Ent{
+		Color:  [3]uint8{255, 0, 255},
+		Radius: 1.0,
+		Properties: map[string]string{
+			"type":   "trigger",
+			"action": "end level",
+			"level":  opts.nextLevel,
+		},
+	})
+	for k := range lockCount {
+		gen.addEnt(gen.randomRoom(func(r *room) bool { return r.zone == k }), te3.Ent{
+			Color:      [3]uint8{0, 128, 255},
+			Radius:     0.25,
+			Properties: map[string]string{"type": "item", "item": game.KeycardNames[keyTypes[k]] + "card"},
+		})
+	}
+	for range opts.enemies {
+		r := gen.randomRoom(func(r *room) bool { return r.parent >= 0 || len(gen.rooms) == 1 })
+		if r == nil {
+			break
+		}
+		gen.addEnt(r, te3.Ent{
+			Angles:     [3]float32{0, float32(gen.rng.IntN(4) * 90), 0},
+			Color:      [3]uint8{58, 0, 255},
+			Radius:     0.7,
+			Properties: map[string]string{"type": "enemy", "enemy": game.EnemyNames[enemyTypes[gen.rng.IntN(len(enemyTypes))]]},
+		})
+	}
+	// Keycards are only placed along with the locks that need them.
+	var itemNames []string
+	for itemType, names := range game.ItemNames {
+		if game.ItemKeys[itemType] == game.KEY_TYPE_INVALID {
+			itemNames = append(itemNames, names[0])
+		}
+	}
+	for range opts.items {
+		r := gen.randomRoom(func(*room) bool { return true })
+		if r == nil {
+			break
+		}
+		gen.addEnt(r, te3.Ent{
+			Color:      [3]uint8{255, 255, 0},
+			Radius:     0.5,
+			Properties: map[string]string{"type": "item", "item": itemNames[gen.rng.IntN(len(itemNames))]},
+		})
+	}
+
+	te3File := &te3.TE3File{Tiles: *gen.tiles, Ents: gen.ents}
+	te3File.Meta.Editor = "te3gen"
+	te3File.Meta.Version = te3.CurrentVersion.String()
+	return te3File, lockCount, nil
+}
+
+// Places a room of random size in each cell and carves it out of the grid. The first room is where the player starts.
+func (gen *generator) placeRooms() {
+	startCell := [2]int{gen.rng.IntN(gen.opts.cols), gen.rng.IntN(gen.opts.rows)}
+	for cz := range gen.opts.rows {
+		for cx := range gen.opts.cols {
+			r := room{cell: [2]int{cx, cz}, parent: -1}
+			for i := range 2 {
+				size := MIN_ROOM_SIZE + gen.rng.IntN(CELL_SIZE-2-MIN_ROOM_SIZE+1)
+				r.min[i] = r.cell[i]*CELL_SIZE + 1 + gen.rng.IntN(CELL_SIZE-2-size+1)
+				r.max[i] = r.min[i] + size
+			}
+			for z := r.min[1]; z < r.max[1]; z++ {
+				for x := r.min[0]; x < r.max[0]; x++ {
+					gen.carve([2]int{x, z}, false)
+					r.free = append(r.free, [2]int{x, z})
+				}
+			}
+			if r.cell == startCell {
+				gen.rooms = append([]room{r}, gen.rooms...)
+			} else {
+				gen.rooms = append(gen.rooms, r)
+			}
+		}
+	}
+}
+
+// Connects the rooms with corridors along a random spanning tree, so that there is exactly one way between any two rooms.
+// Returns the room farthest from the start, which is where the exit goes.
+func (gen *generator) connectRooms() int {
+	roomAt := make(map[[2]int]int, len(gen.rooms))
+	for r := range gen.rooms {
+		roomAt[gen.rooms[r].cell] = r
+	}
+	visited := make([]bool, len(gen.rooms))
+	visited[0] = true
+	stack := []int{0}
+	farthest := 0
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		var neighbors []int
+		for _, dir := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			cell := gen.rooms[current].cell
+			if n, ok := roomAt[[2]int{cell[0] + dir[0], cell[1] + dir[1]}]; ok && !visited[n] {
+				neighbors = append(neighbors, n)
+			}
+		}
+		if len(neighbors) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		next := neighbors[gen.rng.IntN(len(neighbors))]
+		visited[next] = true
+		gen.rooms[next].parent = current
+		gen.rooms[next].depth = gen.rooms[current].depth + 1
+		if gen.rooms[next].depth > gen.rooms[farthest].depth {
+			farthest = next
+		}
+		gen.connect(current, next)
+		stack = append(stack, next)
+	}
+	return farthest
+}
+
+// Carves a corridor between the rooms in neighboring cells, with a door at the child room's end.
+func (gen *generator) connect(parent, child int) {
+	lower, upper := &gen.rooms[parent], &gen.rooms[child]
+	if lower.cell[0] > upper.cell[0] || lower.cell[1] > upper.cell[1] {
+		lower, upper = upper, lower
+	}
+	i := 0 // Axis that the rooms are next to each other on.
+	if lower.cell[0] == upper.cell[0] {
+		i = 1
+	}
+	j := 1 - i
+	lowerLane := lower.min[j] + gen.rng.IntN(lower.max[j]-lower.min[j])
+	upperLane := upper.min[j] + gen.rng.IntN(upper.max[j]-upper.min[j])
+
+	// The corridor turns in the wall between the cells. The turn is kept away from the door, so that the door is on a straight part.
+	border := upper.cell[i] * CELL_SIZE
+	childIsUpper := upper == &gen.rooms[child]
+	bend := border
+	if childIsUpper {
+		bend = border - 1
+	}
+	tile := func(along, lane int) [2]int {
+		var pos [2]int
+		pos[i], pos[j] = along, lane
+		return pos
+	}
+	for a := lower.max[i]; a <= bend; a++ {
+		gen.carve(tile(a, lowerLane), true)
+	}
+	for l := min(lowerLane, upperLane); l <= max(lowerLane, upperLane); l++ {
+		gen.carve(tile(bend, l), true)
+	}
+	for a := bend; a < upper.min[i]; a++ {
+		gen.carve(tile(a, upperLane), true)
+	}
+
+	doorPos := tile(lower.max[i], lowerLane)
+	if childIsUpper {
+		doorPos = tile(upper.min[i]-1, upperLane)
+	}
+	// The door's model is thin along the Z axis, so it is turned to block corridors along the X axis.
+	var yaw float32
+	if i == 0 {
+		yaw = 90
+	}
+	gen.doors[child] = len(gen.ents)
+	gen.ents = append(gen.ents, te3.Ent{
+		Angles:   [3]float32{0, yaw, 0},
+		Color:    [3]uint8{255, 255, 255},
+		Position: gen.entPosition(doorPos),
+		Radius:   1.0,
+		Texture:  TEX_DOOR,
+		Model:    SHAPE_DOOR,
+		Display:  te3.ENT_DISPLAY_MODEL,
+		Properties: map[string]string{
+			"type":      "door",
+			"direction": "up",
+		},
+	})
+}
+
+// Locks doors on the way from the start to the exit room, and sets the zone of each room.
+// Returns the number of doors that were locked.
+func (gen *generator) lockPath(exitRoom int) int {
+	var path []int // Rooms on the way to the exit, not including the starting room.
+	for r := exitRoom; gen.rooms[r].parent >= 0; r = gen.rooms[r].parent {
+		path = append([]int{r}, path...)
+	}
+	lockCount := min(gen.opts.keys, len(path))
+
+	// Pick which rooms on the path are entered through locked doors, keeping them in order.
+	locked := make(map[int]bool, lockCount)
+	picks := gen.rng.Perm(len(path))[:lockCount]
+	slices.Sort(picks)
+	for k, p := range picks {
+		locked[path[p]] = true
+		gen.ents[gen.doors[path[p]]].Properties["key"] = game.KeycardNames[keyTypes[k]]
+	}
+
+	// Each room is in the zone of its parent, or the next one if the door to it is locked.
+	var zoneOf func(r int) int
+	zoneOf = func(r int) int {
+		if gen.rooms[r].parent < 0 {
+			return 0
+		}
+		zone := zoneOf(gen.rooms[r].parent)
+		if locked[r] {
+			zone++
+		}
+		return zone
+	}
+	for r := range gen.rooms {
+		gen.rooms[r].zone = zoneOf(r)
+	}
+	return lockCount
+}
+
+// Returns a random room that the filter accepts and that has space left for an ent, or nil if there are none.
+func (gen *generator) randomRoom(filter func(r *room) bool) *room {
+	var candidates []*room
+	for r := range gen.rooms {
+		if len(gen.rooms[r].free) > 0 && filter(&gen.rooms[r]) {
+			candidates = append(candidates, &gen.rooms[r])
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[gen.rng.IntN(len(candidates))]
+}
+
+// Adds the ent at a random free tile in the room.
+func (gen *generator) addEnt(r *room, ent te3.Ent) {
+	f := gen.rng.IntN(len(r.free))
+	ent.Position = gen.entPosition(r.free[f])
+	r.free[f] = r.free[len(r.free)-1]
+	r.free = r.free[:len(r.free)-1]
+	gen.ents = append(gen.ents, ent)
+}
+
+// Returns the position of the center of the open tile.
+func (gen *generator) entPosition(pos [2]int) [3]float32 {
+	return gen.tiles.GridToWorldPos(pos[0], FLOOR_Y, pos[1], true)
+}
+
+// Removes the wall at the tile, giving the floor a corridor texture if asked.
+func (gen *generator) carve(pos [2]int, corridor bool) {
+	gen.tiles.EraseTile(gen.tiles.FlattenGridPos(pos[0], FLOOR_Y, pos[1]))
+	if corridor {
+		floor := &gen.tiles.Data[gen.tiles.FlattenGridPos(pos[0], FLOOR_Y-1, pos[1])]
+		floor.TextureIDs = [2]te3.TextureID{3, 3}
+	}
+}
+
+func main() {
+	var opts genOptions
+	flag.Uint64Var(&opts.seed, "seed", 1, "Seed for the random layout and placement.")
+	size := flag.String("size", "4x4", "Number of rooms along the X and Z axes.")
+	flag.IntVar(&opts.enemies, "enemies", 12, "Number of enemies.")
+	flag.IntVar(&opts.items, "items", 12, "Number of items, not counting keycards.")
+	flag.IntVar(&opts.keys, "keys", 2, fmt.Sprintf("Number of locked doors on the way to the exit, up to %v.", len(keyTypes)))
+	flag.StringVar(&opts.nextLevel, "next", "", "Level that the exit leads to, relative to assets/maps. Defaults to the generated map itself.")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: te3gen [flags] <output map file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	outPath := flag.Arg(0)
+
+	var err error
+	if _, err = fmt.Sscanf(*size, "%dx%d", &opts.cols, &opts.rows); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -size %q: %v\n", *size, err)
+		os.Exit(2)
+	}
+	if len(opts.nextLevel) == 0 {
+		opts.nextLevel = strings.TrimSuffix(filepath.Base(outPath), filepath.Ext(outPath))
+	}
+
+	te3File, lockCount, err := generateMap(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	data, err := json.Marshal(te3File)
+	if err == nil {
+		err = os.WriteFile(outPath, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Generated %v: %vx%v tiles, %v rooms, %v ents, %v locked doors\n",
+		outPath, te3File.Tiles.Width, te3File.Tiles.Length, opts.cols*opts.rows, len(te3File.Ents), lockCount)
+}
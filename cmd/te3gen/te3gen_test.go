@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/game"
+)
+
+func testOptions(seed uint64) genOptions {
+	return genOptions{seed: seed, cols: 4, rows: 3, enemies: 10, items: 10, keys: 3, nextLevel: "test"}
+}
+
+func TestGenerateMapIsReproducible(t *testing.T) {
+	first, _, err := generateMap(testOptions(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, _ := generateMap(testOptions(7))
+	other, _, _ := generateMap(testOptions(8))
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("The same seed made different maps")
+	}
+	if reflect.DeepEqual(first, other) {
+		t.Errorf("Different seeds made the same map")
+	}
+
+	// The map survives saving and loading.
+	data, err := json.Marshal(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := te3.ParseTE3File(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Tiles, first.Tiles) || !reflect.DeepEqual(loaded.Ents, first.Ents) {
+		t.Errorf("Map changed after saving and loading")
+	}
+}
+
+func TestGeneratedLocksCanBeOpened(t *testing.T) {
+	for seed := range uint64(20) {
+		te3File, lockCount, err := generateMap(testOptions(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tiles := &te3File.Tiles
+
+		var start, exit [2]int
+		keys := make(map[game.KeyType][2]int)
+		doors := make(map[[2]int]game.KeyType)
+		for _, ent := range te3File.Ents {
+			gridPos := ent.GridPosition()
+			pos := [2]int{gridPos[0], gridPos[2]}
+			if tile := tiles.Data[tiles.FlattenGridPos(gridPos[0], FLOOR_Y, gridPos[2])]; tile.ShapeID >= 0 {
+				t.Errorf("Seed %v: %v ent at %v is inside of a wall", seed, ent.Properties["type"], gridPos)
+			}
+			switch ent.Properties["type"] {
+			case "player":
+				start = pos
+			case "trigger":
+				exit = pos
+			case "door":
+				doors[pos] = game.KeyTypeFromName(ent.Properties["key"])
+			case "item":
+				for _, key := range keyTypes {
+					if ent.Properties["item"] == game.KeycardNames[key]+"card" {
+						keys[key] = pos
+					}
+				}
+			}
+		}
+		if len(keys) != lockCount {
+			t.Fatalf("Seed %v: %v keys for %v locks", seed, len(keys), lockCount)
+		}
+
+		// Collect the keys in order, only going through the doors that the player can open.
+		var held game.KeyType
+		for k := range lockCount + 1 {
+			reached := make(map[[2]int]bool)
+			queue := [][2]int{start}
+			reached[start] = true
+			for len(queue) > 0 {
+				pos := queue[0]
+				queue = queue[1:]
+				for _, dir := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					next := [2]int{pos[0] + dir[0], pos[1] + dir[1]}
+					if reached[next] || tiles.OutOfBounds(next[0], FLOOR_Y, next[1]) ||
+						tiles.Data[tiles.FlattenGridPos(next[0], FLOOR_Y, next[1])].ShapeID >= 0 {
+						continue
+					}
+					if key, isDoor := doors[next]; isDoor && key != game.KEY_TYPE_INVALID && held&key == 0 {
+						continue
+					}
+					reached[next] = true
+					queue = append(queue, next)
+				}
+			}
+			if k == lockCount {
+				if !reached[exit] {
+					t.Errorf("Seed %v: exit can't be reached with every key", seed)
+				}
+				break
+			}
+			if lockCount > 0 && k == 0 && reached[exit] {
+				t.Errorf("Seed %v: exit can be reached without any keys", seed)
+			}
+			if !reached[keys[keyTypes[k]]] {
+				t.Fatalf("Seed %v: %v key is behind a door that it opens", seed, game.KeycardNames[keyTypes[k]])
+			}
+			held |= keyTypes[k]
+		}
+	}
+}
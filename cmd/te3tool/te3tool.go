@@ -0,0 +1,356 @@
+// Makes bulk changes to Total Editor 3 maps and prints information about them.
+// Usage: te3tool <command> [flags] [map files or directories...]
+// With no map arguments, every map in assets/maps is used. Commands that change maps save them in place.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"tophatdemon.com/total-invasion-ii/cmd/internal/mapfiles"
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+)
+
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"stats":          {"Prints the grid size and the number of tiles, ents, enemies, items, and secrets in each map.", runStats},
+		"list-ents":      {"Lists the ents in each map, optionally only those of one type.", runListEnts},
+		"rename-texture": {"Replaces a texture path in the tiles and ents of each map.", runRenameTexture},
+		"strip-ents":     {"Removes debug ents from each map, or every ent of one type.", runStripEnts},
+		"resize":         {"Changes the size of each map's grid, optionally moving its contents.", runResize},
+	}
+}
+
+// Loads a map from the file system, rather than from the game's asset directories.
+func loadMap(mapPath string) (*te3.TE3File, error) {
+	data, err := os.ReadFile(mapPath)
+	if err != nil {
+		return nil, err
+	}
+	te3File, err := te3.ParseTE3File(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", mapPath, err)
+	}
+	return te3File, nil
+}
+
+func saveMap(mapPath string, te3File *te3.TE3File) error {
+	data, err := json.Marshal(te3File)
+	if err != nil {
+		return fmt.Errorf("%v: %w", mapPath, err)
+	}
+	return os.WriteFile(mapPath, data, 0o644)
+}
+
+// Calls the function on each of the maps given on the command line, saving the map if the function returns true.
+func forEachMap(args []string, edit func(mapPath string, te3File *te3.TE3File) (bool, error)) error {
+	paths, err := mapfiles.FromArgs(args)
+	if err != nil {
+		return err
+	}
+	for _, mapPath := range paths {
+		te3File, err := loadMap(mapPath)
+		if err != nil {
+			return err
+		}
+		changed, err := edit(mapPath, te3File)
+		if err != nil {
+			return fmt.Errorf("%v: %w", mapPath, err)
+		}
+		if changed {
+			if err := saveMap(mapPath, te3File); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Creates the flags for a command, with a usage message that lists them.
+func newFlagSet(name, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: te3tool %v %v[map files or directories...]\n", name, args)
+		fmt.Fprintln(flags.Output(), commands[name].summary)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+type mapStats struct {
+	tiles, ents, enemies, items, secrets int
+	textures, shapes                     int // Number of textures and shapes used by the tiles.
+}
+
+func countStats(te3File *te3.TE3File) mapStats {
+	var stats mapStats
+	textures := make(map[te3.TextureID]struct{})
+	shapes := make(map[te3.ShapeID]struct{})
+	for _, tile := range te3File.Tiles.Data {
+		if tile.ShapeID < 0 {
+			continue
+		}
+		stats.tiles++
+		shapes[tile.ShapeID] = struct{}{}
+		for _, texID := range tile.TextureIDs {
+			textures[texID] = struct{}{}
+		}
+	}
+	stats.textures, stats.shapes = len(textures), len(shapes)
+
+	stats.ents = len(te3File.Ents)
+	for _, ent := range te3File.Ents {
+		switch ent.Properties["type"] {
+		case "enemy":
+			stats.enemies++
+		case "item":
+			stats.items++
+		case "trigger":
+			if ent.Properties["action"] == "secret" {
+				stats.secrets++
+			}
+		}
+	}
+	return stats
+}
+
+func runStats(args []string) error {
+	flags := newFlagSet("stats", "")
+	flags.Parse(args)
+
+	return forEachMap(flags.Args(), func(mapPath string, te3File *te3.TE3File) (bool, error) {
+		stats := countStats(te3File)
+		fmt.Printf("%v:\n", mapPath)
+		fmt.Printf("\tgrid: %vx%vx%v\n", te3File.Tiles.Width, te3File.Tiles.Height, te3File.Tiles.Length)
+		fmt.Printf("\ttiles: %v (%v textures, %v shapes)\n", stats.tiles, stats.textures, stats.shapes)
+		fmt.Printf("\tents: %v\n", stats.ents)
+		fmt.Printf("\tenemies: %v\n", stats.enemies)
+		fmt.Printf("\titems: %v\n", stats.items)
+		fmt.Printf("\tsecrets: %v\n", stats.secrets)
+		return false, nil
+	})
+}
+
+func runListEnts(args []string) error {
+	flags := newFlagSet("list-ents", "[-type <type>] ")
+	entType := flags.String("type", "", "Only list ents with this type.")
+	flags.Parse(args)
+
+	return forEachMap(flags.Args(), func(mapPath string, te3File *te3.TE3File) (bool, error) {
+		for _, ent := range te3File.Ents {
+			if len(*entType) > 0 && ent.Properties["type"] != *entType {
+				continue
+			}
+			props := make([]string, 0, len(ent.Properties))
+			for _, key := range slices.Sorted(maps.Keys(ent.Properties)) {
+				if key == "type" {
+					continue
+				}
+				props = append(props, fmt.Sprintf("%v=%q", key, ent.Properties[key]))
+			}
+			fmt.Printf("%v: %v at %v %v\n", mapPath, ent.Properties["type"], ent.GridPosition(), strings.Join(props, " "))
+		}
+		return false, nil
+	})
+}
+
+// Replaces the texture path in the map's tiles and ents. Returns the number of places it was replaced.
+func renameTexture(te3File *te3.TE3File, from, to string) int {
+	count := 0
+	for i, texPath := range te3File.Tiles.Textures {
+		if texPath == from {
+			te3File.Tiles.Textures[i] = to
+			count++
+		}
+	}
+	for e := range te3File.Ents {
+		ent := &te3File.Ents[e]
+		if ent.Texture == from {
+			ent.Texture = to
+			count++
+		}
+		// Props can override the texture that the editor displays.
+		if ent.Properties["texture"] == from {
+			ent.Properties["texture"] = to
+			count++
+		}
+	}
+	return count
+}
+
+func runRenameTexture(args []string) error {
+	flags := newFlagSet("rename-texture", "-from <path> -to <path> ")
+	from := flags.String("from", "", "Texture path to replace.")
+	to := flags.String("to", "", "Texture path to replace it with.")
+	flags.Parse(args)
+	if len(*from) == 0 || len(*to) == 0 {
+		return fmt.Errorf("both -from and -to are required")
+	}
+
+	return forEachMap(flags.Args(), func(mapPath string, te3File *te3.TE3File) (bool, error) {
+		count := renameTexture(te3File, *from, *to)
+		if count > 0 {
+			fmt.Printf("%v: replaced %v references\n", mapPath, count)
+		}
+		return count > 0, nil
+	})
+}
+
+// Returns true for ents that are only used while editing, which are marked with a true "debug" property.
+// Ents without a type aren't counted, since some of them, like the level properties, are read by the game without being spawned.
+func isDebugEnt(ent *te3.Ent) bool {
+	debug, _ := ent.BoolProperty("debug")
+	return debug
+}
+
+// Removes the ents that the function returns true for. Returns the number of ents removed.
+func stripEnts(te3File *te3.TE3File, shouldStrip func(ent *te3.Ent) bool) int {
+	oldCount := len(te3File.Ents)
+	te3File.Ents = slices.DeleteFunc(te3File.Ents, func(ent te3.Ent) bool {
+		return shouldStrip(&ent)
+	})
+	return oldCount - len(te3File.Ents)
+}
+
+func runStripEnts(args []string) error {
+	flags := newFlagSet("strip-ents", "[-type <type>] ")
+	entType := flags.String("type", "", "Remove every ent with this type instead of the debug ents.")
+	flags.Parse(args)
+
+	shouldStrip := isDebugEnt
+	if len(*entType) > 0 {
+		shouldStrip = func(ent *te3.Ent) bool {
+			return ent.Properties["type"] == *entType
+		}
+	}
+	return forEachMap(flags.Args(), func(mapPath string, te3File *te3.TE3File) (bool, error) {
+		count := stripEnts(te3File, shouldStrip)
+		if count > 0 {
+			fmt.Printf("%v: removed %v ents\n", mapPath, count)
+		}
+		return count > 0, nil
+	})
+}
+
+// Changes the size of the map's grid and moves its tiles and ents by the offset, in grid units.
+// Returns the number of tiles and ents that were removed because they ended up outside of the new grid.
+func resizeGrid(te3File *te3.TE3File, size, offset [3]int) (lostTiles, lostEnts int) {
+	oldTiles := te3File.Tiles
+	newTiles := oldTiles
+	newTiles.Width, newTiles.Height, newTiles.Length = size[0], size[1], size[2]
+	newTiles.Data = make([]te3.Tile, size[0]*size[1]*size[2])
+	for i := range newTiles.Data {
+		newTiles.Data[i] = te3.Tile{ShapeID: -1}
+	}
+	for i, tile := range oldTiles.Data {
+		if tile.ShapeID < 0 {
+			continue
+		}
+		x, y, z := oldTiles.UnflattenGridPos(i)
+		x, y, z = x+offset[0], y+offset[1], z+offset[2]
+		if newTiles.OutOfBounds(x, y, z) {
+			lostTiles++
+			continue
+		}
+		newTiles.Data[newTiles.FlattenGridPos(x, y, z)] = tile
+	}
+	te3File.Tiles = newTiles
+
+	te3File.Ents = slices.DeleteFunc(te3File.Ents, func(ent te3.Ent) bool {
+		gridPos := ent.GridPosition()
+		if newTiles.OutOfBounds(gridPos[0]+offset[0], gridPos[1]+offset[1], gridPos[2]+offset[2]) {
+			lostEnts++
+			return true
+		}
+		return false
+	})
+	for e := range te3File.Ents {
+		for i := range offset {
+			te3File.Ents[e].Position[i] += float32(offset[i]) * te3.GRID_SPACING
+		}
+	}
+	return
+}
+
+// Parses three integers separated by the given character.
+func parseTriple(text, sep string) ([3]int, error) {
+	var triple [3]int
+	parts := strings.Split(text, sep)
+	if len(parts) != 3 {
+		return triple, fmt.Errorf("expected three numbers separated by %q, got %q", sep, text)
+	}
+	for i, part := range parts {
+		var err error
+		if triple[i], err = strconv.Atoi(strings.TrimSpace(part)); err != nil {
+			return triple, err
+		}
+	}
+	return triple, nil
+}
+
+func runResize(args []string) error {
+	flags := newFlagSet("resize", "-size <width>x<height>x<length> [-offset <x>,<y>,<z>] ")
+	sizeText := flags.String("size", "", "New size of the grid in tiles.")
+	offsetText := flags.String("offset", "0,0,0", "Number of tiles to move the map's contents by along each axis.")
+	flags.Parse(args)
+
+	size, err := parseTriple(*sizeText, "x")
+	if err != nil {
+		return fmt.Errorf("invalid -size: %w", err)
+	}
+	if !te3.ValidGridSize(size[0], size[1], size[2]) {
+		return fmt.Errorf("invalid grid size %vx%vx%v", size[0], size[1], size[2])
+	}
+	offset, err := parseTriple(*offsetText, ",")
+	if err != nil {
+		return fmt.Errorf("invalid -offset: %w", err)
+	}
+
+	return forEachMap(flags.Args(), func(mapPath string, te3File *te3.TE3File) (bool, error) {
+		lostTiles, lostEnts := resizeGrid(te3File, size, offset)
+		fmt.Printf("%v: resized to %vx%vx%v", mapPath, size[0], size[1], size[2])
+		if lostTiles > 0 || lostEnts > 0 {
+			fmt.Printf(", removing %v tiles and %v ents outside of the grid", lostTiles, lostEnts)
+		}
+		fmt.Println()
+		return true, nil
+	})
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: te3tool <command> [flags] [map files or directories...]")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, name := range slices.Sorted(maps.Keys(commands)) {
+		fmt.Fprintf(os.Stderr, "\t%-16v%v\n", name, commands[name].summary)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q.\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
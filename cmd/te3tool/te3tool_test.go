@@ -0,0 +1,105 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"tophatdemon.com/total-invasion-ii/cmd/internal/mapfiles"
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+)
+
+// Edits each of the game's maps in ways that cancel out, then checks that saving and loading the result gives back the original map.
+func TestUndoneEditsRoundTrip(t *testing.T) {
+	mapPaths, err := filepath.Glob(filepath.Join("..", "..", mapfiles.MAPS_DIR, "*.te3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mapPaths) == 0 {
+		t.Fatal("No maps found")
+	}
+	for _, mapPath := range mapPaths {
+		t.Run(filepath.Base(mapPath), func(t *testing.T) {
+			original, err := loadMap(mapPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			edited, err := loadMap(mapPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			size := [3]int{edited.Tiles.Width, edited.Tiles.Height, edited.Tiles.Length}
+			if lostTiles, lostEnts := resizeGrid(edited, [3]int{size[0] + 3, size[1] + 2, size[2] + 1}, [3]int{3, 2, 1}); lostTiles > 0 || lostEnts > 0 {
+				t.Fatalf("Growing the grid removed %v tiles and %v ents", lostTiles, lostEnts)
+			}
+			if lostTiles, lostEnts := resizeGrid(edited, size, [3]int{-3, -2, -1}); lostTiles > 0 || lostEnts > 0 {
+				t.Fatalf("Shrinking the grid back removed %v tiles and %v ents", lostTiles, lostEnts)
+			}
+			if len(edited.Tiles.Textures) > 0 {
+				texPath := edited.Tiles.Textures[0]
+				if renameTexture(edited, texPath, "renamed.png") == 0 {
+					t.Errorf("Texture %v wasn't renamed", texPath)
+				}
+				renameTexture(edited, "renamed.png", texPath)
+			}
+
+			savePath := filepath.Join(t.TempDir(), filepath.Base(mapPath))
+			if err := saveMap(savePath, edited); err != nil {
+				t.Fatal(err)
+			}
+			reloaded, err := loadMap(savePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(original, reloaded) {
+				t.Errorf("Map changed after saving")
+			}
+		})
+	}
+}
+
+func TestStripEnts(t *testing.T) {
+	te3File := &te3.TE3File{
+		Ents: []te3.Ent{
+			{Properties: map[string]string{"type": "enemy"}},
+			{Properties: map[string]string{"type": "item", "debug": "true"}},
+			{Properties: map[string]string{"name": "level properties"}},
+			{Properties: map[string]string{"type": "player", "debug": "false"}},
+		},
+	}
+	if count := stripEnts(te3File, isDebugEnt); count != 1 {
+		t.Errorf("Removed %v ents instead of 1", count)
+	}
+	if len(te3File.Ents) != 3 || te3File.Ents[0].Properties["type"] != "enemy" ||
+		te3File.Ents[1].Properties["name"] != "level properties" || te3File.Ents[2].Properties["type"] != "player" {
+		t.Errorf("Wrong ents left: %v", te3File.Ents)
+	}
+}
+
+// Strips the debug ents from each of the game's maps and checks that the level properties are kept.
+func TestStripEntsKeepsLevelProperties(t *testing.T) {
+	mapPaths, err := filepath.Glob(filepath.Join("..", "..", mapfiles.MAPS_DIR, "*.te3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mapPath := range mapPaths {
+		t.Run(filepath.Base(mapPath), func(t *testing.T) {
+			te3File, err := loadMap(mapPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := slices.Clone(te3File.Ents)
+			stripEnts(te3File, isDebugEnt)
+			for _, ent := range before {
+				if ent.Properties["name"] != "level properties" {
+					continue
+				}
+				if !slices.ContainsFunc(te3File.Ents, func(kept te3.Ent) bool { return reflect.DeepEqual(kept, ent) }) {
+					t.Errorf("Level properties %v were removed", ent.Properties)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Compiles the game's maps into .te3c files, which the game loads instead of the .te3 files while they are up to date.
+// Usage: ti-compile [map files or directories...]
+// With no arguments, every map in assets/maps is compiled. Each compiled map is written next to its .te3 file.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"tophatdemon.com/total-invasion-ii/cmd/internal/mapfiles"
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/game/mapdata"
+)
+
+func compileMap(mapPath string) (int, error) {
+	compiled, err := mapdata.Compile(mapPath)
+	if err != nil {
+		return 0, err
+	}
+	// Write to a buffer first so that a failure doesn't leave a partial file behind.
+	var output bytes.Buffer
+	if err := compiled.Write(&output); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(te3.CompiledPath(mapPath), output.Bytes(), 0o644); err != nil {
+		return 0, err
+	}
+	return output.Len(), nil
+}
+
+func main() {
+	// The asset loaders log every file they read, which would bury the output.
+	log.SetOutput(io.Discard)
+
+	paths, err := mapfiles.FromArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	failures := 0
+	for _, mapPath := range paths {
+		size, err := compileMap(mapPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", mapPath, err)
+			failures++
+			continue
+		}
+		fmt.Printf("Compiled %v (%v KiB)\n", te3.CompiledPath(mapPath), (size+1023)/1024)
+	}
+	fmt.Printf("Compiled %v of %v maps.\n", len(paths)-failures, len(paths))
+
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+// Exports the game's maps as Wavefront .obj files with .mtl material libraries, for viewing and editing them in other programs.
+// Usage: ti-export [-out <directory>] [-collision] [map files or directories...]
+// With no map arguments, every map in assets/maps is exported. Must be run from the game's directory so that assets can be found.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"tophatdemon.com/total-invasion-ii/cmd/internal/mapfiles"
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/game/mapdata"
+)
+
+// Writes the map's .obj and .mtl files into the output directory, named after the map.
+// The textures are referred to by their paths relative to the output directory.
+func exportMap(mapPath, outDir string, includeCollision bool) (string, error) {
+	compiled, err := mapdata.Compile(mapPath)
+	if err != nil {
+		return "", err
+	}
+
+	gameDir, err := filepath.Abs(".")
+	if err != nil {
+		return "", err
+	}
+	absOutDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return "", err
+	}
+	texturePrefix, err := filepath.Rel(absOutDir, gameDir)
+	if err != nil {
+		return "", err
+	}
+
+	baseName := strings.TrimSuffix(path.Base(mapPath), path.Ext(mapPath))
+	export := te3.OBJExport{
+		Mesh:          compiled.Mesh,
+		MTLName:       baseName + ".mtl",
+		TexturePrefix: filepath.ToSlash(texturePrefix) + "/",
+	}
+	if includeCollision {
+		export.Collision = compiled.TE3File.Tiles.CollisionTriangles(compiled.TileShapes)
+	}
+
+	// Write to buffers first so that a failure doesn't leave partial files behind.
+	var obj, mtl bytes.Buffer
+	if err := te3.WriteOBJ(&obj, &mtl, export); err != nil {
+		return "", err
+	}
+	objPath := filepath.Join(outDir, baseName+".obj")
+	if err := os.WriteFile(objPath, obj.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, export.MTLName), mtl.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+	return objPath, nil
+}
+
+func main() {
+	outDir := flag.String("out", ".", "Directory to write the .obj and .mtl files into.")
+	includeCollision := flag.Bool("collision", false, "Also write the collision shapes of the tiles as a separate object.")
+	flag.Parse()
+
+	// The asset loaders log every file they read, which would bury the output.
+	log.SetOutput(io.Discard)
+
+	paths, err := mapfiles.FromArgs(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	failures := 0
+	for _, mapPath := range paths {
+		objPath, err := exportMap(mapPath, *outDir, *includeCollision)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", mapPath, err)
+			failures++
+			continue
+		}
+		fmt.Printf("Exported %v\n", objPath)
+	}
+	fmt.Printf("Exported %v of %v maps.\n", len(paths)-failures, len(paths))
+
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,472 @@
+// Checks the game's maps and translations for references to assets and features that don't exist.
+// Usage: ti-validate [map files or directories...]
+// With no arguments, every map in assets/maps is checked. Exits with status 1 if any errors are found.
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"tophatdemon.com/total-invasion-ii/cmd/internal/mapfiles"
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/assets/locales"
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/engine/assets/textures"
+	"tophatdemon.com/total-invasion-ii/game"
+	"tophatdemon.com/total-invasion-ii/game/mapdata"
+)
+
+const (
+	TRANSLATIONS_DIR = "assets/translations"
+	MAX_POSITIONS    = 8 // Maximum number of grid positions listed for a single problem.
+)
+
+var triggerActions = []string{
+	game.TRIGGER_ACTION_TELEPORT,
+	game.TRIGGER_ACTION_DAMAGE,
+	game.TRIGGER_ACTION_END_LEVEL,
+	game.TRIGGER_ACTION_SECRET,
+	game.TRIGGER_ACTION_ACTIVATE,
+}
+
+type severity string
+
+const (
+	SEVERITY_ERROR   severity = "error"
+	SEVERITY_WARNING severity = "warning"
+)
+
+type problem struct {
+	severity severity
+	message  string
+}
+
+// Collects the problems found in one file.
+type report struct {
+	filePath string
+	problems []problem
+}
+
+func (rep *report) errorf(format string, args ...any) {
+	rep.problems = append(rep.problems, problem{SEVERITY_ERROR, fmt.Sprintf(format, args...)})
+}
+
+func (rep *report) warnf(format string, args ...any) {
+	rep.problems = append(rep.problems, problem{SEVERITY_WARNING, fmt.Sprintf(format, args...)})
+}
+
+func (rep *report) errorCount() int {
+	count := 0
+	for _, prob := range rep.problems {
+		if prob.severity == SEVERITY_ERROR {
+			count++
+		}
+	}
+	return count
+}
+
+// Formats a list of grid positions, cutting it off if there are too many.
+func formatPositions(positions [][3]int) string {
+	var builder strings.Builder
+	for i, pos := range positions {
+		if i == MAX_POSITIONS {
+			fmt.Fprintf(&builder, " and %v more", len(positions)-MAX_POSITIONS)
+			break
+		}
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		fmt.Fprintf(&builder, "(%v, %v, %v)", pos[0], pos[1], pos[2])
+	}
+	return builder.String()
+}
+
+func assetExists(assetPath string) bool {
+	_, _, err := assets.ResolvePath(assetPath)
+	return err == nil
+}
+
+// Textures can also be built from their Aseprite source files.
+func textureExists(texPath string) bool {
+	if assetExists(texPath) {
+		return true
+	}
+	for _, sourcePath := range textures.AsepritePaths(texPath) {
+		if assetExists(sourcePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Holds the loaded translations, indexed by locale.
+type translationSet map[string]*locales.Translation
+
+func loadTranslations() (translationSet, []*report) {
+	translations := make(translationSet)
+	var reports []*report
+
+	fileNames, err := filepath.Glob(filepath.Join(TRANSLATIONS_DIR, "*.json"))
+	if err != nil || len(fileNames) == 0 {
+		rep := &report{filePath: TRANSLATIONS_DIR}
+		rep.errorf("no translation files found")
+		return translations, []*report{rep}
+	}
+
+	for _, fileName := range fileNames {
+		assetPath := filepath.ToSlash(fileName)
+		rep := &report{filePath: assetPath}
+		reports = append(reports, rep)
+		trans, err := assets.LoadAndUnmarshalJSON[locales.Translation](assetPath)
+		if err != nil {
+			rep.errorf("could not parse translation: %v", err)
+			continue
+		}
+		translations[strings.TrimSuffix(path.Base(assetPath), ".json")] = trans
+	}
+
+	// Compare every other locale against English, which is the fallback.
+	english, hasEnglish := translations[locales.ENGLISH]
+	if !hasEnglish {
+		rep := &report{filePath: TRANSLATIONS_DIR}
+		rep.errorf("missing the %v translation, which is used as the fallback", locales.ENGLISH)
+		return translations, append(reports, rep)
+	}
+	for _, rep := range reports {
+		locale := strings.TrimSuffix(path.Base(rep.filePath), ".json")
+		trans, ok := translations[locale]
+		if !ok || locale == locales.ENGLISH {
+			continue
+		}
+		for _, key := range sortedKeys(*english) {
+			if _, ok := (*trans)[key]; !ok {
+				rep.warnf("key %q is missing and will fall back to English", key)
+			}
+		}
+		for _, key := range sortedKeys(*trans) {
+			if _, ok := (*english)[key]; !ok {
+				rep.warnf("key %q does not exist in the English translation", key)
+			}
+		}
+	}
+
+	return translations, reports
+}
+
+func sortedKeys(trans locales.Translation) []string {
+	keys := make([]string, 0, len(trans))
+	for key := range trans {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Reports a localization key that the map refers to if it isn't in every translation.
+func (translations translationSet) checkKey(rep *report, key, usage string) {
+	if english, ok := translations[locales.ENGLISH]; ok {
+		if _, ok := (*english)[key]; !ok {
+			rep.errorf("%v: localization key %q is not in the English translation", usage, key)
+			return
+		}
+	}
+	for locale, trans := range translations {
+		if _, ok := (*trans)[key]; !ok && locale != locales.ENGLISH {
+			rep.warnf("%v: localization key %q is not in the %v translation", usage, key, locale)
+		}
+	}
+}
+
+// Loads the map file, turning panics from malformed data into errors.
+func loadMap(mapPath string) (te3File *te3.TE3File, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed map data: %v", r)
+		}
+	}()
+	return te3.LoadTE3File(mapPath)
+}
+
+func validateMap(mapPath string, translations translationSet) *report {
+	rep := &report{filePath: mapPath}
+
+	te3File, err := loadMap(mapPath)
+	if err != nil {
+		rep.errorf("could not load map: %v", err)
+		return rep
+	}
+	if err := te3File.InstancePrefabs(); err != nil {
+		rep.errorf("could not instance prefabs: %v", err)
+		return rep
+	}
+	tiles := &te3File.Tiles
+
+	if len(tiles.Data) != tiles.Width*tiles.Height*tiles.Length {
+		rep.errorf("tile data has %v tiles, but the map's size is %vx%vx%v", len(tiles.Data), tiles.Width, tiles.Height, tiles.Length)
+		return rep
+	}
+
+	// Find where each texture and shape is used, and tiles with indices that are out of range.
+	texturePositions := make([][][3]int, len(tiles.Textures))
+	shapePositions := make([][][3]int, len(tiles.Shapes))
+	var badShapeIDs, badTextureIDs [][3]int
+	for t, tile := range tiles.Data {
+		if tile.ShapeID < 0 {
+			continue
+		}
+		x, y, z := tiles.UnflattenGridPos(t)
+		pos := [3]int{x, y, z}
+		if int(tile.ShapeID) >= len(tiles.Shapes) {
+			badShapeIDs = append(badShapeIDs, pos)
+		} else {
+			shapePositions[tile.ShapeID] = append(shapePositions[tile.ShapeID], pos)
+		}
+		for _, texID := range tile.TextureIDs {
+			if texID < 0 || int(texID) >= len(tiles.Textures) {
+				badTextureIDs = append(badTextureIDs, pos)
+			} else if len(texturePositions[texID]) == 0 || texturePositions[texID][len(texturePositions[texID])-1] != pos {
+				texturePositions[texID] = append(texturePositions[texID], pos)
+			}
+		}
+	}
+	if len(badShapeIDs) > 0 {
+		rep.errorf("tiles with an invalid shape index at %v", formatPositions(badShapeIDs))
+	}
+	if len(badTextureIDs) > 0 {
+		rep.errorf("tiles with an invalid texture index at %v", formatPositions(badTextureIDs))
+	}
+
+	for i, texPath := range tiles.Textures {
+		if len(texturePositions[i]) == 0 {
+			continue
+		}
+		if !strings.HasSuffix(texPath, ".png") {
+			rep.errorf("texture %q is not a .png file; used by tiles at %v", texPath, formatPositions(texturePositions[i]))
+		} else if !textureExists(texPath) {
+			rep.errorf("texture %q not found; used by tiles at %v", texPath, formatPositions(texturePositions[i]))
+		}
+	}
+
+	for i, shapePath := range tiles.Shapes {
+		if len(shapePositions[i]) == 0 {
+			continue
+		}
+		if !assetExists(shapePath) {
+			rep.errorf("shape %q not found; used by tiles at %v", shapePath, formatPositions(shapePositions[i]))
+			continue
+		}
+		if _, err := geom.LoadMesh(shapePath); err != nil {
+			rep.errorf("shape %q could not be loaded (%v); used by tiles at %v", shapePath, err, formatPositions(shapePositions[i]))
+		}
+		shapeCollision, err := te3.LoadShapeCollision(shapePath)
+		if err != nil {
+			rep.errorf("%v; used by tiles at %v", err, formatPositions(shapePositions[i]))
+		} else if shapeCollision.Type == te3.SHAPE_COLLISION_MESH && shapeCollision.Mesh != shapePath {
+			if _, err := geom.LoadMesh(shapeCollision.Mesh); err != nil {
+				rep.errorf("collision mesh %q of shape %q could not be loaded (%v); used by tiles at %v", shapeCollision.Mesh, shapePath, err, formatPositions(shapePositions[i]))
+			}
+		}
+	}
+
+	// The level intro shows a localized title for maps named like e1m1.
+	if levelFileName := path.Base(mapPath); len(levelFileName) >= 4 &&
+		levelFileName[0] == 'e' &&
+		levelFileName[1] >= '0' && levelFileName[1] <= '9' &&
+		levelFileName[2] == 'm' &&
+		levelFileName[3] >= '0' && levelFileName[3] <= '9' {
+
+		translations.checkKey(rep, levelFileName[0:4]+"Title", "level intro")
+	}
+
+	playerCount := 0
+	for _, ent := range te3File.Ents {
+		if ent.Properties == nil {
+			continue
+		}
+		if ent.Properties["name"] == "level properties" {
+			validateLevelProperties(rep, ent)
+			continue
+		}
+		if ent.Properties["type"] == "player" {
+			playerCount++
+		}
+		validateEnt(rep, ent, translations)
+	}
+	if playerCount == 0 {
+		rep.errorf("map has no player ent")
+	} else if playerCount > 1 {
+		rep.warnf("map has %v player ents; only the last one will be controllable", playerCount)
+	}
+
+	return rep
+}
+
+func validateLevelProperties(rep *report, ent te3.Ent) {
+	if song, ok := ent.Properties["song"]; ok {
+		if songPath := "assets/music/" + song + ".ogg"; !assetExists(songPath) {
+			rep.errorf("level properties at %v: song %q not found", formatPositions([][3]int{ent.GridPosition()}), songPath)
+		}
+	}
+	if sky, ok := ent.Properties["sky"]; ok {
+		if skyPath := "assets/textures/skies/" + sky + ".png"; !textureExists(skyPath) {
+			rep.errorf("level properties at %v: sky texture %q not found", formatPositions([][3]int{ent.GridPosition()}), skyPath)
+		}
+		if !assetExists(mapdata.SKY_MESH_PATH) {
+			rep.errorf("sky mesh %q not found", mapdata.SKY_MESH_PATH)
+		}
+	}
+}
+
+func validateEnt(rep *report, ent te3.Ent, translations translationSet) {
+	entType := ent.Properties["type"]
+	where := fmt.Sprintf("%v ent at %v", entType, formatPositions([][3]int{ent.GridPosition()}))
+
+	checkInt := func(key string, required bool) {
+		if _, err := ent.IntProperty(key); err != nil {
+			if _, notFound := err.(te3.PropNotFoundError); !notFound || required {
+				rep.errorf("%v: %v", where, err)
+			}
+		}
+	}
+	checkFloat := func(key string) {
+		if _, err := ent.FloatProperty(key); err != nil {
+			if _, notFound := err.(te3.PropNotFoundError); !notFound {
+				rep.errorf("%v: %v", where, err)
+			}
+		}
+	}
+
+	switch entType {
+	case "enemy":
+		if enemy, ok := ent.Properties["enemy"]; ok {
+			if _, known := game.EnemyTypeFromName(enemy); !known {
+				// The game silently spawns a wraith instead.
+				rep.errorf("%v: unknown enemy type %q", where, enemy)
+			}
+		}
+	case "door":
+		if unopenable, _ := ent.BoolProperty("unopenable"); !unopenable {
+			if dir, ok := ent.Properties["direction"]; !ok {
+				rep.errorf("%v: missing direction property", where)
+			} else if _, known := game.DoorDirectionFromName(dir); !known {
+				rep.errorf("%v: unknown direction %q", where, dir)
+			}
+			checkFloat("distance")
+			checkFloat("speed")
+			if wait, ok := ent.Properties["wait"]; ok {
+				if l := strings.ToLower(wait); l != "inf" && l != "infinity" && l != "-1" {
+					checkFloat("wait")
+				}
+			}
+			if key, ok := ent.Properties["key"]; ok && game.KeyTypeFromName(key) == game.KEY_TYPE_INVALID {
+				rep.errorf("%v: unknown key %q", where, key)
+			}
+			if _, err := ent.BoolProperty("blockUse"); err != nil {
+				if _, notFound := err.(te3.PropNotFoundError); !notFound {
+					rep.errorf("%v: %v", where, err)
+				}
+			}
+			checkInt("link", false)
+		}
+		if sfx := ent.Properties["activateSound"]; len(sfx) > 0 && !assetExists("assets/sounds/"+sfx) {
+			rep.errorf("%v: activate sound %q not found", where, "assets/sounds/"+sfx)
+		}
+	case "switch":
+		checkInt("link", true)
+	case "prop":
+		if ent.Display != te3.ENT_DISPLAY_SPHERE && ent.Display != te3.ENT_DISPLAY_SPRITE {
+			rep.errorf("%v: display mode should be 'sprite' or 'sphere'", where)
+		}
+		texturePath, ok := ent.Properties["texture"]
+		if !ok {
+			texturePath = ent.Texture
+		}
+		if len(texturePath) == 0 {
+			rep.errorf("%v: missing texture", where)
+		} else if !textureExists(texturePath) {
+			rep.errorf("%v: texture %q not found", where, texturePath)
+		}
+		checkFloat("radius")
+		if strings.ToLower(ent.Properties["prop"]) == "eyeball" {
+			if key, ok := ent.Properties["messageKey"]; ok {
+				translations.checkKey(rep, key, where)
+			}
+		}
+	case "trigger":
+		action := ent.Properties[game.TRIGGER_ACTION]
+		if !slices.Contains(triggerActions, action) {
+			rep.errorf("%v: unknown action %q", where, action)
+		}
+		switch action {
+		case game.TRIGGER_ACTION_DAMAGE:
+			if _, err := strconv.ParseFloat(ent.Properties[game.TRIGGER_DAMAGE_RATE], 32); err != nil {
+				rep.errorf("%v: invalid %v: %v", where, game.TRIGGER_DAMAGE_RATE, err)
+			}
+		case game.TRIGGER_ACTION_END_LEVEL:
+			if nextLevel := "assets/maps/" + ent.Properties["level"] + ".te3"; !assetExists(nextLevel) {
+				rep.errorf("%v: next level %q not found", where, nextLevel)
+			}
+		}
+		checkInt("link", false)
+	case "item":
+		if item, ok := ent.Properties["item"]; !ok {
+			rep.errorf("%v: missing item property", where)
+		} else if _, known := game.ItemTypeFromName(item); !known {
+			rep.errorf("%v: unknown item type %q", where, item)
+		}
+	case "camera":
+		checkInt("link", false)
+		checkFloat("wait")
+	case "player":
+	case te3.LIGHT_ENT_TYPE:
+		if ent.Radius <= 0.0 {
+			rep.warnf("%v: radius is %v, so it won't light anything", where, ent.Radius)
+		}
+	case "":
+		rep.warnf("ent at %v has no type and will not be spawned", formatPositions([][3]int{ent.GridPosition()}))
+	default:
+		rep.errorf("%v: unknown ent type %q", where, entType)
+	}
+}
+
+func main() {
+	// The asset loaders log every file they read, which would bury the report.
+	log.SetOutput(io.Discard)
+
+	paths, err := mapfiles.FromArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	translations, reports := loadTranslations()
+	for _, mapPath := range paths {
+		reports = append(reports, validateMap(mapPath, translations))
+	}
+
+	errorCount, warningCount := 0, 0
+	for _, rep := range reports {
+		if len(rep.problems) == 0 {
+			continue
+		}
+		fmt.Printf("%v:\n", rep.filePath)
+		for _, prob := range rep.problems {
+			fmt.Printf("\t%v: %v\n", prob.severity, prob.message)
+		}
+		errorCount += rep.errorCount()
+		warningCount += len(rep.problems) - rep.errorCount()
+	}
+	fmt.Printf("Checked %v maps: %v errors, %v warnings.\n", len(paths), errorCount, warningCount)
+
+	if errorCount > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,16 +1,29 @@
+// Converts Total Invasion II maps (.ti) into Total Editor 3 maps (.te3).
+// Usage: ti2-convert [flags] <input .ti file> <output .te3 file>
+//
+//	ti2-convert [flags] <input directory> <output directory>
+//
+// With directories, every .ti file in the input directory is converted into a .te3 file with the same name in the output directory,
+// and each level's exit leads to the next level in name order. Must be run from the root of the repository, so that the converted
+// texture paths can be checked. Textures and ents that have no equivalent in this game are listed in a report after converting.
 package main
 
 import (
 	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"image"
+	_ "image/png"
+	"io"
 	"math"
 	"os"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 
+	"tophatdemon.com/total-invasion-ii/cmd/internal/mapfiles"
 	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
 )
 
@@ -67,17 +80,151 @@ func translateTextureName(category, ti2Name string) string {
 	return fmt.Sprintf("assets/textures/%v/%v%v.png", category, folderName, ti2Name)
 }
 
-func main() {
-	if len(os.Args) != 3 {
-		panic("Need two args: input path (.ti) and output path (.te3)")
+// Item names that world.SpawnItemFromTE3 accepts, with the TI2 names that differ from them.
+var itemNames = map[string]string{
+	"medkit":          "medkit",
+	"stimpack":        "stimpack",
+	"cartonofeggs":    "cartonofeggs",
+	"grenades":        "grenades",
+	"plasmavial":      "plasmavial",
+	"plasmavials":     "plasmavial",
+	"chickencannon":   "chickencannon",
+	"grenadelauncher": "grenadelauncher",
+	"parusu":          "parusu",
+	"airhorn":         "airhorn",
+	"bluecard":        "bluecard",
+	"browncard":       "browncard",
+	"yellowcard":      "yellowcard",
+	"graycard":        "graycard",
+	"bluekey":         "bluecard",
+	"brownkey":        "browncard",
+	"yellowkey":       "yellowcard",
+	"graykey":         "graycard",
+	"boringarmor":     "boringarmor",
+	"bulletarmor":     "bulletarmor",
+}
+
+// Keys of locked doors, indexed by the door's link number in TI2.
+var doorKeys = []string{"blue", "brown", "yellow", "gray"}
+
+// TI2 enemy type numbers. The game only has some of them, so the rest become wraiths.
+var enemyTypes = map[int64]struct {
+	ti2Name, enemy, sprite string
+}{
+	4:  {"wraith", "wraith", "wraith"},
+	5:  {"fire wraith", "fire wraith", "fire_wraith"},
+	6:  {"dummkopf", "dummkopf", "dummkopf"},
+	7:  {"mother wraith", "mother wraith", "mother_wraith"},
+	8:  {"prisrak", "", ""},
+	9:  {"providence", "", ""},
+	10: {"fundie", "", ""},
+	11: {"banshee", "", ""},
+	12: {"mutant wraith", "", ""},
+	13: {"mecha wraith", "", ""},
+	14: {"dummkopf", "dummkopf", "dummkopf"},
+	15: {"tophat demon", "", ""},
+}
+
+type convertOptions struct {
+	mapName   string // Name of the converted map, relative to assets/maps, used when it has no level to go to next.
+	nextLevel string // Level that the exits lead to, relative to assets/maps.
+	song      string // Music for the level properties, relative to assets/music and without the extension.
+	sky       string // Sky texture for the level properties, used when parts of the map have no ceiling.
+}
+
+// Lists the parts of a map that couldn't be converted, counting repeats instead of listing them again.
+type report struct {
+	messages []string
+	counts   map[string]int
+}
+
+func (rep *report) addf(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if rep.counts == nil {
+		rep.counts = make(map[string]int)
+	}
+	if rep.counts[message] == 0 {
+		rep.messages = append(rep.messages, message)
 	}
+	rep.counts[message]++
+}
 
-	inputPath := os.Args[1]
-	tiFile, err := os.Open(inputPath)
+func (rep *report) print(output io.Writer, mapPath string) {
+	if len(rep.messages) == 0 {
+		return
+	}
+	fmt.Fprintf(output, "%v:\n", mapPath)
+	for _, message := range rep.messages {
+		if count := rep.counts[message]; count > 1 {
+			fmt.Fprintf(output, "\t%v (%v times)\n", message, count)
+		} else {
+			fmt.Fprintf(output, "\t%v\n", message)
+		}
+	}
+}
+
+// Reads the lines of a .ti file, keeping track of the line number for errors.
+type tiReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// Reads a line made of at least the given number of comma separated fields.
+func (r *tiReader) fields(count int) ([]string, error) {
+	r.line++
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("line %v: unexpected end of file", r.line)
+	}
+	fields := strings.Split(strings.TrimSpace(r.scanner.Text()), ",")
+	if len(fields) < count {
+		return nil, fmt.Errorf("line %v: expected %v fields, got %v", r.line, count, len(fields))
+	}
+	return fields, nil
+}
+
+// Parses the integer fields at the given indices.
+func (r *tiReader) ints(fields []string, indices ...int) ([]int64, error) {
+	values := make([]int64, len(indices))
+	for i, index := range indices {
+		var err error
+		if values[i], err = strconv.ParseInt(strings.TrimSpace(fields[index]), 10, 64); err != nil {
+			return nil, fmt.Errorf("line %v: %w", r.line, err)
+		}
+	}
+	return values, nil
+}
+
+// Reads a section header followed by the number of lines in the section.
+func (r *tiReader) section(name string) (int64, error) {
+	header, err := r.fields(1)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	defer tiFile.Close()
+	if strings.TrimSpace(header[0]) != name {
+		return 0, fmt.Errorf("line %v: expected the %v section, got %q", r.line, name, header[0])
+	}
+	countFields, err := r.fields(1)
+	if err != nil {
+		return 0, err
+	}
+	count, err := r.ints(countFields, 0)
+	if err != nil {
+		return 0, err
+	}
+	return count[0], nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Converts the contents of a .ti file. Anything that can't be converted is added to the report and left out.
+func convertMap(input io.Reader, opts convertOptions, rep *report) (*te3.TE3File, error) {
+	r := &tiReader{scanner: bufio.NewScanner(input)}
 
 	type tileToAdd struct {
 		te3.Tile
@@ -106,6 +253,9 @@ func main() {
 		if textureIndex < 0 {
 			textureIndex = len(textureList)
 			textureList = append(textureList, texturePath)
+			if !fileExists(texturePath) {
+				rep.addf("unmapped texture %v", texturePath)
+			}
 		}
 		return textureIndex
 	}
@@ -113,50 +263,30 @@ func main() {
 	tilesToAdd := make([]tileToAdd, 0, 1024)
 	entsToAdd := make([]te3.Ent, 0, 256)
 
-	tiScanner := bufio.NewScanner(tiFile)
-	tiScanner.Scan() // TILES
-	tiScanner.Scan() // Tile count
-	wallCount, err := strconv.ParseInt(tiScanner.Text(), 10, 64)
-	if err != nil {
-		panic(err)
-	}
-
 	var minX int64 = math.MaxInt64
 	var minZ int64 = math.MaxInt64
 	var maxX int64 = math.MinInt64
 	var maxZ int64 = math.MinInt64
 
+	wallCount, err := r.section("TILES")
+	if err != nil {
+		return nil, err
+	}
 	for range wallCount {
-		tiScanner.Scan()
-		tokens := strings.Split(tiScanner.Text(), ",")
-
-		x, err := strconv.ParseInt(tokens[0], 10, 64)
+		tokens, err := r.fields(6)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		x /= 16
-		minX = min(x, minX)
-		maxX = max(x, maxX)
-
-		z, err := strconv.ParseInt(tokens[1], 10, 64)
+		values, err := r.ints(tokens, 0, 1, 4, 5)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		z /= 16
-		minZ = min(z, minZ)
-		maxZ = max(z, maxZ)
+		x, z, flag, link := values[0]/16, values[1]/16, values[2], values[3]
+		minX, maxX = min(x, minX), max(x, maxX)
+		minZ, maxZ = min(z, minZ), max(z, maxZ)
 
 		textureIndex := addTexture(translateTextureName("tiles", tokens[3]))
 
-		flag, err := strconv.ParseInt(tokens[4], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-		link, err := strconv.ParseInt(tokens[5], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-
 		var yaw uint8
 		switch flag {
 		case 7: // Panel
@@ -182,169 +312,146 @@ func main() {
 			modelIndex = IDX_MARKER
 		}
 
-		if flag > 0 && flag != 6 && flag != 7 {
-			// Add dynamic tile entities
-			ent := te3.Ent{
-				Display: te3.ENT_DISPLAY_MODEL,
-				Radius:  1.0,
-				Model:   modelList[modelIndex],
-				Texture: textureList[textureIndex],
-				Angles:  [3]float32{0.0, float32(yaw * 90), 0.0},
-				Color:   [3]uint8{255, 255, 255},
-				Position: [3]float32{
-					float32(x)*te3.GRID_SPACING + te3.HALF_GRID_SPACING,
-					te3.GRID_SPACING + te3.HALF_GRID_SPACING,
-					float32(z)*te3.GRID_SPACING + te3.HALF_GRID_SPACING,
+		if flag <= 0 || flag == 6 || flag == 7 {
+			tilesToAdd = append(tilesToAdd, tileToAdd{
+				Tile: te3.Tile{
+					ShapeID:    te3.ShapeID(modelIndex),
+					TextureIDs: [2]te3.TextureID{te3.TextureID(textureIndex), te3.TextureID(textureIndex)},
+					Yaw:        yaw,
+					Pitch:      0,
 				},
-				Properties: map[string]string{},
-			}
+				coords: [3]int{int(x), 1, int(z)},
+			})
+			continue
+		}
+
+		// Add dynamic tile entities
+		ent := te3.Ent{
+			Display: te3.ENT_DISPLAY_MODEL,
+			Radius:  1.0,
+			Model:   modelList[modelIndex],
+			Texture: textureList[textureIndex],
+			Angles:  [3]float32{0.0, float32(yaw) * 90, 0.0},
+			Color:   [3]uint8{255, 255, 255},
+			Position: [3]float32{
+				float32(x)*te3.GRID_SPACING + te3.HALF_GRID_SPACING,
+				te3.GRID_SPACING + te3.HALF_GRID_SPACING,
+				float32(z)*te3.GRID_SPACING + te3.HALF_GRID_SPACING,
+			},
+			Properties: map[string]string{},
+		}
 
+		switch flag {
+		case 1, 2, 5, 8, 9, 10: // Moving door like objects
+			ent.Properties["type"] = "door"
+			if link > 0 {
+				ent.Properties["link"] = strconv.FormatInt(link, 10)
+			}
 			switch flag {
-			case 1, 2, 5, 8, 9, 10: // Moving door like objects
-				ent.Properties["type"] = "door"
-				if link > 0 {
-					ent.Properties["link"] = strconv.FormatInt(link, 10)
+			case 1, 8, 2, 9: // Doors
+				if strings.Contains(tokens[3], "spacedoor") {
+					// Space doors move up instead of to the side
+					ent.Properties["direction"] = "up"
+				} else {
+					ent.Properties["direction"] = "right"
 				}
-				switch flag {
-				case 1, 8, 2, 9: // Doors
-					if strings.Contains(tokens[3], "spacedoor") {
-						// Space doors move up instead of to the side
-						ent.Properties["direction"] = "up"
-					} else {
-						ent.Properties["direction"] = "right"
-					}
 
-					if flag == 8 || flag == 9 {
-						// Assign keys to locked doors
-						switch link {
-						case 0:
-							ent.Properties["key"] = "blue"
-						case 1:
-							ent.Properties["key"] = "brown"
-						case 2:
-							ent.Properties["key"] = "yellow"
-						case 3:
-							ent.Properties["key"] = "gray"
-						}
-						delete(ent.Properties, "link")
+				if flag == 8 || flag == 9 {
+					// Assign keys to locked doors
+					if link >= 0 && link < int64(len(doorKeys)) {
+						ent.Properties["key"] = doorKeys[link]
+					} else {
+						rep.addf("locked door with unknown key number %v", link)
 					}
-				case 5: // Push walls
-					ent.Properties["direction"] = "backward"
-					ent.Properties["distance"] = "4.0"
-					ent.Properties["wait"] = "inf"
-					ent.Properties["activateSound"] = "secretwall.wav"
 					delete(ent.Properties, "link")
-				case 10: // Disappearing walls
-					ent.Properties["direction"] = "down"
-					ent.Properties["distance"] = "4.0"
-					ent.Properties["activateSound"] = ""
-					ent.Properties["blockUse"] = "true"
-					ent.Properties["wait"] = "inf"
 				}
-			case 3: // Switch
-				ent.Properties["type"] = "switch"
-				ent.Properties["link"] = strconv.FormatInt(link, 10)
-			case 4, 11, 12, 13: // Invisible trigger volumes
-				ent.Properties["type"] = "trigger"
-				ent.Properties["link"] = strconv.FormatInt(link, 10)
-				switch flag {
-				case 4: // Teleporter
-					ent.Properties["action"] = "teleport"
-				case 11: // Trigger for doors / secrets
-					if link == 255 {
-						ent.Properties["action"] = "secret"
-						delete(ent.Properties, "link")
-					} else {
-						ent.Properties["action"] = "activate"
-					}
-				case 12: // Level exit
-					ent.Properties["action"] = "end level"
-					ent.Properties["level"] = "TODO!"
+			case 5: // Push walls
+				ent.Properties["direction"] = "backward"
+				ent.Properties["distance"] = "4.0"
+				ent.Properties["wait"] = "inf"
+				ent.Properties["activateSound"] = "secretwall.wav"
+				delete(ent.Properties, "link")
+			case 10: // Disappearing walls
+				ent.Properties["direction"] = "down"
+				ent.Properties["distance"] = "4.0"
+				ent.Properties["activateSound"] = ""
+				ent.Properties["blockUse"] = "true"
+				ent.Properties["wait"] = "inf"
+			}
+		case 3: // Switch
+			ent.Properties["type"] = "switch"
+			ent.Properties["link"] = strconv.FormatInt(link, 10)
+		case 4, 11, 12: // Invisible trigger volumes
+			ent.Properties["type"] = "trigger"
+			ent.Properties["link"] = strconv.FormatInt(link, 10)
+			switch flag {
+			case 4: // Teleporter, which sends actors to the other teleporters with the same link number.
+				ent.Properties["action"] = "teleport"
+			case 11: // Trigger for doors / secrets
+				if link == 255 {
+					ent.Properties["action"] = "secret"
 					delete(ent.Properties, "link")
-				case 13: // Conveyor belt
-					ent.Properties["action"] = "push"
-					var dir string
-					switch link {
-					case 0:
-						dir = "forward"
-					case 1:
-						dir = "right"
-					case 2:
-						dir = "backward"
-					case 3:
-						dir = "left"
-					}
-					ent.Properties["direction"] = dir
+				} else {
+					ent.Properties["action"] = "activate"
+				}
+			case 12: // Level exit
+				ent.Properties["action"] = "end level"
+				if len(opts.nextLevel) > 0 {
+					ent.Properties["level"] = opts.nextLevel
+				} else {
+					ent.Properties["level"] = opts.mapName
+					rep.addf("level exit has no next level, so it restarts the level")
 				}
+				delete(ent.Properties, "link")
 			}
-
-			entsToAdd = append(entsToAdd, ent)
-		} else {
-			tilesToAdd = append(tilesToAdd, tileToAdd{
-				Tile: te3.Tile{
-					ShapeID:    te3.ShapeID(modelIndex),
-					TextureIDs: [2]te3.TextureID{te3.TextureID(textureIndex), te3.TextureID(textureIndex)},
-					Yaw:        yaw,
-					Pitch:      0,
-				},
-				coords: [3]int{int(x), 1, int(z)},
-			})
+		case 13:
+			rep.addf("conveyor belts are not supported")
+			continue
+		default:
+			rep.addf("unmapped tile flag %v", flag)
+			continue
 		}
+
+		entsToAdd = append(entsToAdd, ent)
 	}
 
-	tiScanner.Scan() // SECTORS
-	tiScanner.Scan() // Floor count
-	floorCount, err := strconv.ParseInt(tiScanner.Text(), 10, 64)
+	floorCount, err := r.section("SECTORS")
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-
 	for range floorCount {
-		tiScanner.Scan()
-		tokens := strings.Split(tiScanner.Text(), ",")
-
-		x, err := strconv.ParseInt(tokens[0], 10, 64)
+		tokens, err := r.fields(6)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		x /= 16
-		minX = min(x, minX)
-		maxX = max(x, maxX)
-
-		z, err := strconv.ParseInt(tokens[1], 10, 64)
+		values, err := r.ints(tokens, 0, 1, 4)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		z /= 16
-		minZ = min(z, minZ)
-		maxZ = max(z, maxZ)
+		x, z, ceilingFlag := values[0]/16, values[1]/16, values[2]
+		minX, maxX = min(x, minX), max(x, maxX)
+		minZ, maxZ = min(z, minZ), max(z, maxZ)
 
 		textureIndex := addTexture(translateTextureName("tiles", tokens[5]))
 
-		ceilingFlag, err := strconv.ParseInt(tokens[4], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-
 		tilesToAdd = append(tilesToAdd, tileToAdd{
-			coords: [3]int{int(x), int(ceilingFlag * 2), int(z)},
+			coords: [3]int{int(x), int(min(max(ceilingFlag, 0), 1) * 2), int(z)},
 			Tile: te3.Tile{
 				ShapeID:    IDX_CUBE,
 				TextureIDs: [2]te3.TextureID{te3.TextureID(textureIndex), te3.TextureID(textureIndex)},
 			},
 		})
 	}
-
-	tiScanner.Scan() // THINGS
-	tiScanner.Scan() // Ent count
-	entCount, err := strconv.ParseInt(tiScanner.Text(), 10, 64)
-	if err != nil {
-		panic(err)
+	if len(tilesToAdd) == 0 {
+		return nil, fmt.Errorf("map has no tiles")
 	}
 
 	// Calculate grid bounds
 	gridWidth := int(maxX - minX + 1)
 	gridLength := int(maxZ - minZ + 1)
+	if !te3.ValidGridSize(gridWidth, 3, gridLength) {
+		return nil, fmt.Errorf("map is too large: %vx%v tiles", gridWidth, gridLength)
+	}
 	gridLayerSize := gridWidth * gridLength
 	grid := slices.Repeat([]te3.Tile{{ShapeID: -1}}, gridWidth*3*gridLength)
 	for _, pair := range tilesToAdd {
@@ -357,32 +464,26 @@ func main() {
 		entsToAdd[i].Position[2] -= float32(minZ) * te3.GRID_SPACING
 	}
 
+	entCount, err := r.section("THINGS")
+	if err != nil {
+		return nil, err
+	}
 	for range entCount {
-		tiScanner.Scan()
-		tokens := strings.Split(tiScanner.Text(), ",")
-
-		x, err := strconv.ParseInt(tokens[0], 10, 64)
+		tokens, err := r.fields(5)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		x = (x / 16) - minX
-
-		z, err := strconv.ParseInt(tokens[1], 10, 64)
+		values, err := r.ints(tokens, 0, 1, 2, 4)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		z = (z / 16) - minZ
+		x, z, entType, angleIndex := values[0]/16-minX, values[1]/16-minZ, values[2], values[3]
 
 		if x < 0 || z < 0 || x >= int64(gridWidth) || z >= int64(gridLength) {
-			fmt.Printf("Ent found out of bounds: [%v, %v]\n", x, z)
+			rep.addf("ent of type %v outside of the map at [%v, %v]", entType, x, z)
 			continue
 		}
 
-		angleIndex, err := strconv.ParseInt(tokens[4], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-
 		ent := te3.Ent{
 			Radius: 0.7,
 			Angles: [3]float32{
@@ -398,12 +499,6 @@ func main() {
 			Properties: map[string]string{},
 		}
 
-		entType, err := strconv.ParseInt(tokens[2], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-
-		shouldBeSprite := false
 		strippedTexName := removeTextureTags(tokens[3])
 
 		switch entType {
@@ -414,87 +509,75 @@ func main() {
 		case 1: // Prop
 			ent.Properties["type"] = "prop"
 			ent.Properties["prop"] = strippedTexName
-			shouldBeSprite = true
+			if !setSprite(&ent, strippedTexName) {
+				rep.addf("unmapped texture %v for prop", translateTextureName("sprites", strippedTexName))
+				continue
+			}
 		case 2, 3: // Item or weapon
+			item, ok := itemNames[strippedTexName]
+			if !ok {
+				rep.addf("unmapped item %v", strippedTexName)
+				continue
+			}
 			ent.Properties["type"] = "item"
-			ent.Properties["item"] = strippedTexName
-			shouldBeSprite = true
-		case 4: // Wraith
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "wraith"
-			ent.Display = te3.ENT_DISPLAY_SPRITE
-			ent.Texture = "assets/textures/sprites/wraith.png"
-		case 5: // Fire wraith
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "fire wraith"
-			ent.Display = te3.ENT_DISPLAY_SPRITE
-			ent.Texture = "assets/textures/sprites/fire_wraith.png"
-		case 6, 14: // Dummkopf
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "dummkopf"
-			ent.Properties["name"] = "dummkopf"
-		case 7: // Mother wraith / Caco wraith
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "mother wraith"
-			ent.Display = te3.ENT_DISPLAY_SPRITE
-			ent.Texture = "assets/textures/sprites/mother_wraith.png"
-		case 8: // Prisrak
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "prisrak"
-			ent.Properties["name"] = "prisrak"
-		case 9: // Providence
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "providence"
-			ent.Properties["name"] = "providence"
-		case 10: // Fundie
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "fundie"
-			ent.Properties["name"] = "fundie"
-		case 11: // Banshee
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "banshee"
-			ent.Properties["name"] = "banshee"
-		case 12: // Mutant wraith
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "mutant wraith"
-			ent.Properties["name"] = "mutant wraith"
-		case 13: // Mecha
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "mecha wraith"
-			ent.Properties["name"] = "mecha wraith"
-		case 15: // Tophat demon
-			ent.Properties["type"] = "enemy"
-			ent.Properties["enemy"] = "tophat demon"
-			ent.Properties["name"] = "tophat demon"
+			ent.Properties["item"] = item
+			if !setSprite(&ent, strippedTexName) {
+				ent.Properties["name"] = strippedTexName
+			}
 		default:
-			// Discard unused entity types
-			continue
-		}
-
-		if shouldBeSprite {
-			texPath := translateTextureName("sprites", strippedTexName)
-			if imgFile, err := os.Open(texPath); err == nil {
-				defer imgFile.Close()
-
-				ent.Display = te3.ENT_DISPLAY_SPRITE
-
-				// Scale according to image dimensions
-				img, _, err := image.Decode(imgFile)
-				if err != nil {
-					panic(err)
-				}
-				ent.Radius = float32(img.Bounds().Dy()) / 64.0
-
-				ent.Texture = texPath
+			enemy, ok := enemyTypes[entType]
+			if !ok {
+				rep.addf("unmapped ent type %v (%v)", entType, strippedTexName)
+				continue
+			}
+			ent.Properties["type"] = "enemy"
+			if len(enemy.enemy) == 0 {
+				// Keep the original name, so that it can be replaced once the enemy exists.
+				rep.addf("enemy %v has no equivalent, so it was replaced by a wraith", enemy.ti2Name)
+				ent.Properties["enemy"] = "wraith"
+				ent.Properties["name"] = enemy.ti2Name
 			} else {
-				ent.Properties["name"] = strippedTexName
+				ent.Properties["enemy"] = enemy.enemy
+				ent.Display = te3.ENT_DISPLAY_SPRITE
+				ent.Texture = "assets/textures/sprites/" + enemy.sprite + ".png"
 			}
 		}
 
 		entsToAdd = append(entsToAdd, ent)
 	}
 
-	te3Map := te3.TE3File{
+	// Parts of the map without a ceiling show the sky.
+	hasSky := false
+	for i := range gridLayerSize {
+		if grid[i].ShapeID >= 0 && grid[gridLayerSize+i].ShapeID < 0 && grid[2*gridLayerSize+i].ShapeID < 0 {
+			hasSky = true
+			break
+		}
+	}
+	if hasSky || len(opts.song) > 0 {
+		levelProps := te3.Ent{
+			Radius:     2.0,
+			Color:      [3]uint8{255, 255, 255},
+			Position:   [3]float32{te3.HALF_GRID_SPACING, 3*te3.GRID_SPACING + te3.HALF_GRID_SPACING, te3.HALF_GRID_SPACING},
+			Display:    te3.ENT_DISPLAY_SPHERE,
+			Properties: map[string]string{"name": "level properties"},
+		}
+		if len(opts.song) > 0 {
+			levelProps.Properties["song"] = opts.song
+			if songPath := "assets/music/" + opts.song + ".ogg"; !fileExists(songPath) {
+				rep.addf("song %v not found", songPath)
+			}
+		}
+		if hasSky {
+			levelProps.Properties["sky"] = opts.sky
+			if skyPath := "assets/textures/skies/" + opts.sky + ".png"; !fileExists(skyPath) {
+				rep.addf("sky %v not found", skyPath)
+			}
+		}
+		entsToAdd = append(entsToAdd, levelProps)
+	}
+
+	te3Map := &te3.TE3File{
 		Ents: entsToAdd,
 		Tiles: te3.Tiles{
 			Textures: textureList,
@@ -506,19 +589,118 @@ func main() {
 		},
 	}
 	te3Map.Meta.Editor = "Total Editor"
-	te3Map.Meta.Version = "3.2"
+	te3Map.Meta.Version = te3.CurrentVersion.String()
+	return te3Map, nil
+}
 
-	jsonData, err := json.Marshal(&te3Map)
+// Shows the ent as its sprite, scaled to the height of the image. Returns false if the sprite doesn't exist.
+func setSprite(ent *te3.Ent, ti2Name string) bool {
+	texPath := translateTextureName("sprites", ti2Name)
+	imgFile, err := os.Open(texPath)
 	if err != nil {
-		panic(err)
+		return false
 	}
-	te3File, err := os.Create(os.Args[2])
+	defer imgFile.Close()
+	config, _, err := image.DecodeConfig(imgFile)
 	if err != nil {
-		panic(err)
+		return false
+	}
+	ent.Display = te3.ENT_DISPLAY_SPRITE
+	ent.Radius = float32(config.Height) / 64.0
+	ent.Texture = texPath
+	return true
+}
+
+// Returns the name that the game uses for the map at the given path, relative to assets/maps and without the extension.
+func levelName(mapPath string) string {
+	name := strings.TrimSuffix(mapPath, filepath.Ext(mapPath))
+	if rel, err := filepath.Rel(mapfiles.MAPS_DIR, name); err == nil && !strings.HasPrefix(rel, "..") {
+		name = rel
+	} else {
+		name = filepath.Base(name)
 	}
-	defer te3File.Close()
-	_, err = te3File.Write(jsonData)
+	return filepath.ToSlash(name)
+}
+
+func convertFile(inputPath, outputPath string, opts convertOptions) error {
+	tiFile, err := os.Open(inputPath)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	defer tiFile.Close()
+
+	opts.mapName = levelName(outputPath)
+	var rep report
+	te3Map, err := convertMap(tiFile, opts, &rep)
+	if err != nil {
+		return fmt.Errorf("%v: %w", inputPath, err)
+	}
+	rep.print(os.Stdout, inputPath)
+
+	jsonData, err := json.Marshal(te3Map)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, jsonData, 0o644)
+}
+
+func main() {
+	var opts convertOptions
+	flag.StringVar(&opts.nextLevel, "next", "", "Level that the exits lead to, relative to assets/maps. With directories, only used for the last level.")
+	flag.StringVar(&opts.song, "song", "", "Music to play, relative to assets/music and without the extension.")
+	flag.StringVar(&opts.sky, "sky", "starry_sky", "Sky texture for levels with open areas, relative to assets/textures/skies and without the extension.")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: ti2-convert [flags] <input .ti file> <output .te3 file>")
+		fmt.Fprintln(flag.CommandLine.Output(), "       ti2-convert [flags] <input directory> <output directory>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	inputPath, outputPath := flag.Arg(0), flag.Arg(1)
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if !info.IsDir() {
+		if err := convertFile(inputPath, outputPath, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	inputPaths, err := filepath.Glob(filepath.Join(inputPath, "*.ti"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := os.MkdirAll(outputPath, 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	outputPaths := make([]string, len(inputPaths))
+	for i, path := range inputPaths {
+		outputPaths[i] = filepath.Join(outputPath, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+".te3")
+	}
+
+	failures := 0
+	for i := range inputPaths {
+		fileOpts := opts
+		if i+1 < len(inputPaths) {
+			fileOpts.nextLevel = levelName(outputPaths[i+1])
+		}
+		if err := convertFile(inputPaths[i], outputPaths[i], fileOpts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failures++
+		}
+	}
+	fmt.Printf("Converted %v of %v maps\n", len(inputPaths)-failures, len(inputPaths))
+	if failures > 0 {
+		os.Exit(1)
 	}
 }
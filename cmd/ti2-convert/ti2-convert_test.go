@@ -0,0 +1,126 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// A 3x3 room with an exit in the corner and a ceiling over only the first row.
+const testMap = `TILES
+5
+0,0,0,brickwall,0,0
+16,0,0,brickwall,0,0
+32,0,0,missingwall,0,0
+0,32,0,invisible,12,0
+32,32,0,brickwall,13,1
+SECTORS
+6
+0,16,0,0,0,concrete
+16,16,0,0,0,concrete
+32,16,0,0,0,concrete
+0,16,0,0,1,sheetrock
+16,32,0,0,0,concrete
+16,0,0,0,1,sheetrock
+THINGS
+5
+16,16,0,segan,0
+16,16,4,wraith,2
+16,32,11,banshee,0
+32,16,2,medkit,0
+0,16,3,mysteryweapon,0
+`
+
+func TestConvertMap(t *testing.T) {
+	t.Chdir("../..")
+
+	var rep report
+	te3Map, err := convertMap(strings.NewReader(testMap), convertOptions{mapName: "test", nextLevel: "e1m2", sky: "starry_sky"}, &rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tiles := te3Map.Tiles; tiles.Width != 3 || tiles.Length != 3 || tiles.Height != 3 {
+		t.Errorf("Grid is %vx%vx%v instead of 3x3x3", tiles.Width, tiles.Height, tiles.Length)
+	}
+
+	entsOfType := make(map[string][]map[string]string)
+	for _, ent := range te3Map.Ents {
+		key := ent.Properties["type"]
+		if key == "" {
+			key = ent.Properties["name"]
+		}
+		entsOfType[key] = append(entsOfType[key], ent.Properties)
+	}
+	if exits := entsOfType["trigger"]; len(exits) != 1 || exits[0]["action"] != "end level" || exits[0]["level"] != "e1m2" {
+		t.Errorf("Level exit converted to %v", exits)
+	}
+	if enemies := entsOfType["enemy"]; len(enemies) != 2 || enemies[0]["enemy"] != "wraith" || enemies[1]["name"] != "banshee" {
+		t.Errorf("Enemies converted to %v", enemies)
+	}
+	if items := entsOfType["item"]; len(items) != 1 || items[0]["item"] != "medkit" {
+		t.Errorf("Items converted to %v", items)
+	}
+	if len(entsOfType["player"]) != 1 {
+		t.Errorf("Player wasn't converted")
+	}
+	if props := entsOfType["level properties"]; len(props) != 1 || props[0]["sky"] != "starry_sky" {
+		t.Errorf("Level properties converted to %v", props)
+	}
+
+	for _, expected := range []string{
+		"unmapped texture assets/textures/tiles/missingwall.png",
+		"conveyor belts are not supported",
+		"enemy banshee has no equivalent, so it was replaced by a wraith",
+		"unmapped item mysteryweapon",
+	} {
+		if !slices.Contains(rep.messages, expected) {
+			t.Errorf("Report %q is missing %q", rep.messages, expected)
+		}
+	}
+}
+
+func TestConvertMalformedMap(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"TILES\nfive\n",
+		"TILES\n1\n0,0,0,brickwall\n",
+		"TILES\n1\n0,0,0,brickwall,x,0\n",
+		"TILES\n0\nSECTORS\n0\nTHINGS\n0\n",
+		"TILES\n2\n0,0,0,brickwall,0,0\n34359738368,34359738368,0,brickwall,0,0\nSECTORS\n0\nTHINGS\n0\n",
+	} {
+		if _, err := convertMap(strings.NewReader(input), convertOptions{}, &report{}); err == nil {
+			t.Errorf("Converting %q didn't return an error", input)
+		}
+	}
+}
+
+func TestConvertSecretWallAngles(t *testing.T) {
+	t.Chdir("../..")
+
+	// The link number of a secret wall turns it in steps of 90 degrees.
+	input := `TILES
+5
+0,16,0,brickwall,0,0
+0,0,0,brickwall,5,0
+16,0,0,brickwall,5,1
+32,0,0,brickwall,5,2
+48,0,0,brickwall,5,3
+SECTORS
+0
+THINGS
+0
+`
+	te3Map, err := convertMap(strings.NewReader(input), convertOptions{mapName: "test"}, &report{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var yaws []float32
+	for _, ent := range te3Map.Ents {
+		if ent.Properties["type"] == "door" {
+			yaws = append(yaws, ent.Angles[1])
+		}
+	}
+	if expected := []float32{90, 180, 270, 0}; !slices.Equal(yaws, expected) {
+		t.Errorf("Secret walls have yaws %v instead of %v", yaws, expected)
+	}
+}
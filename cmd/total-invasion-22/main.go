@@ -5,21 +5,39 @@ import (
 	"os"
 	"runtime"
 	"slices"
+	"strings"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
 
 	"tophatdemon.com/total-invasion-ii/engine"
+	"tophatdemon.com/total-invasion-ii/engine/assets"
 	"tophatdemon.com/total-invasion-ii/engine/assets/cache"
 	"tophatdemon.com/total-invasion-ii/engine/input"
 	"tophatdemon.com/total-invasion-ii/engine/tdaudio"
 	"tophatdemon.com/total-invasion-ii/game"
+	"tophatdemon.com/total-invasion-ii/game/hud"
 
 	"tophatdemon.com/total-invasion-ii/game/settings"
 	"tophatdemon.com/total-invasion-ii/game/world"
 )
 
+// Seconds between checks for edited asset files in debug mode.
+const HOT_RELOAD_INTERVAL = 1.0
+
 type App struct {
-	world *world.World
+	world          *world.World
+	loader         *world.Loader // Set while a map is loading.
+	loadingScreen  hud.LoadingScreen
+	keptPlayer     *playerPlacement // Where to put the player once the map has loaded, if they should stay where they were.
+	mapPath        string
+	mapStamp       assets.FileStamp // State of the map file when it was loaded, for hot reloading.
+	hotReloadTimer float32
+}
+
+type playerPlacement struct {
+	position mgl32.Vec3
+	yaw      float32
 }
 
 func (app *App) Update(deltaTime float32) {
@@ -27,10 +45,46 @@ func (app *App) Update(deltaTime float32) {
 	tdaudio.SetSfxVolume(settings.Current.SfxVolume)
 	tdaudio.SetMusicVolume(settings.Current.MusicVolume)
 
+	if app.loader != nil {
+		app.updateLoading(deltaTime)
+		return
+	}
+
 	app.world.Update(deltaTime)
+
+	if debugMode() {
+		app.hotReloadTimer += deltaTime
+		if app.hotReloadTimer >= HOT_RELOAD_INTERVAL {
+			app.hotReloadTimer = 0.0
+			reloaded := cache.ReloadChanged()
+			// Tile textures are copied into the map's atlas, so the map needs to be rebuilt to show their changes.
+			if assets.Stamp(app.mapPath) != app.mapStamp || slices.ContainsFunc(reloaded, app.world.GameMap.UsesTexture) {
+				app.reloadMap()
+			}
+		}
+	}
+}
+
+// Reloads the current map from its file while keeping the player where they are.
+func (app *App) reloadMap() {
+	log.Println("Map file changed; reloading ", app.mapPath)
+
+	if player, ok := app.world.CurrentPlayer.Get(); ok {
+		app.keptPlayer = &playerPlacement{
+			position: player.Body().Transform.Position(),
+			yaw:      player.Actor().YawAngle,
+		}
+	}
+
+	app.world.TearDown()
+	app.LoadGame(app.mapPath)
 }
 
 func (app *App) Render() {
+	if app.loader != nil {
+		app.loadingScreen.Render()
+		return
+	}
 	app.world.Render()
 }
 
@@ -44,26 +98,60 @@ func (app *App) ProcessSignal(signal any) {
 	}
 }
 
+// Starts loading the map in the background. The loading screen is shown until it is finished.
 func (app *App) LoadGame(mapPath string) {
 	log.Println("Loading game at map ", mapPath)
 
-	cache.Reset()
+	cache.BeginGeneration()
 	cache.DefaultFont, _ = cache.GetFont(world.DEFAULT_FONT_PATH)
 
-	debugMode := slices.Contains(os.Args[1:], "debug")
-	world, err := world.NewWorld(app, mapPath, debugMode)
+	app.world = nil
+	app.mapPath = mapPath
+	app.mapStamp = assets.Stamp(mapPath)
+	app.loader = world.StartLoading(app, mapPath, debugMode())
+	app.loadingScreen.Init()
+}
+
+func (app *App) updateLoading(deltaTime float32) {
+	world, err := app.loader.Update()
 	if err != nil {
+		//TODO: Handle this error gracefully by returning to the title screen.
 		panic(err)
 	}
+	app.loadingScreen.SetProgress(app.loader.Progress())
+	app.loadingScreen.Update(deltaTime)
+	if world == nil {
+		return
+	}
+
+	app.loader = nil
+	app.world = world
+
+	// Free the previous map's assets that the new map doesn't use.
+	cache.FreeUnused()
 
-	if !debugMode {
+	if app.keptPlayer != nil {
+		if player, ok := world.CurrentPlayer.Get(); ok {
+			player.Body().Transform.SetPosition(app.keptPlayer.position)
+			player.Body().Transform.SetRotation(0.0, app.keptPlayer.yaw, 0.0)
+			player.Actor().SetYaw(app.keptPlayer.yaw)
+		}
+		app.keptPlayer = nil
+	}
+
+	if !debugMode() {
 		input.TrapMouse()
+	} else {
+		log.Print(assets.DebugListing())
 	}
-	app.world = world
 
 	runtime.GC()
 }
 
+func debugMode() bool {
+	return slices.Contains(os.Args[1:], "debug")
+}
+
 func main() {
 	var err error
 	// cpuProfile, err := os.Create("cpuProfile.pprof")
@@ -78,12 +166,27 @@ func main() {
 
 	settings.LoadOrInit()
 
+	// Mods from the settings file are layered first, then mods given on the command line as "mod=<directory>".
+	mods := slices.Clone(settings.Current.Mods)
+	for _, arg := range os.Args[1:] {
+		if modDir, isMod := strings.CutPrefix(arg, "mod="); isMod {
+			mods = append(mods, modDir)
+		}
+	}
+	if err = assets.SetModLayers(mods); err != nil {
+		log.Println(err)
+	}
+
 	err = engine.Init(int(settings.Current.WindowWidth), int(settings.Current.WindowHeight), "Total Invasion 22")
 	defer engine.DeInit()
 	if err != nil {
 		panic(err)
 	}
 
+	if debugMode() {
+		cache.EnableHotReload()
+	}
+
 	// Load error sound as first sound
 	tdaudio.LoadSound("assets/sounds/error.wav", 1, false, 1.0)
 
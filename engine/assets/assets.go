@@ -3,14 +3,137 @@ package assets
 import (
 	_ "embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 )
 
-// Retrieves the asset's file from one of the available asset packs
+const BASE_LAYER_NAME = "base"
+
+// Resources that were made by the game rather than loaded from a file are named with this prefix.
+// Their names can't collide with the paths of real assets, and the asset cache never hot reloads them.
+const GENERATED_PREFIX = "!"
+
+// Returns the name of a resource generated from the given asset, such as the mesh of a map.
+// The kind tells apart the resources generated from the same asset.
+func GeneratedName(assetPath, kind string) string {
+	return GENERATED_PREFIX + assetPath + "#" + kind
+}
+
+// A directory that asset paths are resolved against.
+// Asset paths (like "assets/textures/ui/font.png") are relative to the root of each layer,
+// so a mod overriding that file would place it at "<mod root>/assets/textures/ui/font.png".
+type Layer struct {
+	Name string
+	Root string
+}
+
+var (
+	// Search path for asset files, from lowest to highest priority. The base game assets are always first.
+	layers = []Layer{{Name: BASE_LAYER_NAME, Root: "."}}
+
+	// Records which layer each opened asset was found in, for debugging.
+	loadedFrom   = make(map[string]string)
+	loadedFromMu sync.Mutex
+)
+
+// Replaces the mod layers in the search path with the given directories.
+// Directories later in the list take priority over earlier ones, and all of them take priority over the base assets.
+func SetModLayers(modDirs []string) error {
+	newLayers := []Layer{layers[0]}
+	for _, dir := range modDirs {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("could not find mod directory %v: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("mod path %v is not a directory", dir)
+		}
+		newLayers = append(newLayers, Layer{
+			Name: filepath.Base(filepath.Clean(dir)),
+			Root: dir,
+		})
+	}
+	layers = newLayers
+	return nil
+}
+
+// Returns the current asset search path, from lowest to highest priority.
+func Layers() []Layer {
+	return slices.Clone(layers)
+}
+
+func normalizePath(assetPath string) string {
+	return strings.ReplaceAll(assetPath, "\\", "/")
+}
+
+// Finds the file system path of an asset by searching through the layers from highest to lowest priority.
+// Also returns the layer the asset was found in.
+// If the file isn't in any layer, the returned error wraps fs.ErrNotExist.
+func ResolvePath(assetPath string) (string, Layer, error) {
+	assetPath = normalizePath(assetPath)
+	for _, layer := range slices.Backward(layers) {
+		fsPath := filepath.Join(layer.Root, filepath.FromSlash(assetPath))
+		if _, err := os.Stat(fsPath); err == nil {
+			return fsPath, layer, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", Layer{}, err
+		}
+	}
+	return "", Layer{}, &fs.PathError{Op: "open", Path: assetPath, Err: fs.ErrNotExist}
+}
+
+// Like ResolvePath, but records the asset as loaded in the debug listing.
+// This is for loaders that need to hand a file system path to other code instead of opening the file themselves.
+func LocateFile(assetPath string) (string, error) {
+	fsPath, layer, err := ResolvePath(assetPath)
+	if err != nil {
+		return "", err
+	}
+
+	loadedFromMu.Lock()
+	loadedFrom[normalizePath(assetPath)] = layer.Name
+	loadedFromMu.Unlock()
+
+	return fsPath, nil
+}
+
+// Retrieves the asset's file from the highest priority layer that contains it.
 func GetFile(assetPath string) (*os.File, error) {
-	return os.Open(strings.ReplaceAll(assetPath, "\\", "/"))
+	fsPath, err := LocateFile(assetPath)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fsPath)
+}
+
+// Returns a listing of every asset file opened so far and the name of the layer it was loaded from, sorted by path.
+func DebugListing() string {
+	loadedFromMu.Lock()
+	defer loadedFromMu.Unlock()
+
+	paths := make([]string, 0, len(loadedFrom))
+	for path := range loadedFrom {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Asset layers (lowest to highest priority):")
+	for _, layer := range layers {
+		fmt.Fprintf(&builder, " %v (%v)", layer.Name, layer.Root)
+	}
+	builder.WriteRune('\n')
+	for _, path := range paths {
+		fmt.Fprintf(&builder, "[%v] %v\n", loadedFrom[path], path)
+	}
+	return builder.String()
 }
 
 // Loads a JSON file from the given asset-path (relative to assets folder) and returns the json.Unmarshal result as type T.
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 
+	"tophatdemon.com/total-invasion-ii/engine/assets"
 	"tophatdemon.com/total-invasion-ii/engine/assets/audio"
 	"tophatdemon.com/total-invasion-ii/engine/assets/fonts"
 	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
@@ -15,13 +17,22 @@ import (
 )
 
 type cache[T any] struct {
+	mutex          sync.Mutex // Guards storage and lastUsed, since maps are loaded on worker goroutines.
 	storage        map[string]T
+	lastUsed       map[string]uint32 // The generation in which each resource was last requested.
 	fileExtensions []string
 	loadFunc       func(string) (T, error)
 	freeFunc       func(T)
 	resourceName   string
+	replaceFunc    func(dst, src T)              // Moves a reloaded resource's data into the live one. Resources without it are not hot reloaded.
+	dependencies   func(string) []string         // Lists the files that a resource is loaded from. If nil, it is just the resource's own path.
+	stamps         map[string][]assets.FileStamp // States of the files each resource was loaded from, for hot reloading.
+	sizeFunc       func(T) int                   // Approximates the GPU memory used by a resource, in bytes.
 }
 
+// Incremented for each map that is loaded. Resources not used in the current generation can be freed.
+var generation uint32
+
 // This map caches the loadedTextures loaded from the filesystem by their paths.
 var loadedTextures cache[*textures.Texture]
 
@@ -43,17 +54,23 @@ var loadedTranslations cache[*locales.Translation]
 func init() {
 	loadedTextures = cache[*textures.Texture]{
 		storage:        make(map[string]*textures.Texture),
-		fileExtensions: []string{".png"},
+		fileExtensions: []string{".png", ".aseprite", ".ase"},
 		loadFunc:       textures.LoadTexture,
 		freeFunc:       (*textures.Texture).Free,
 		resourceName:   "texture",
+		replaceFunc:    (*textures.Texture).Replace,
+		sizeFunc:       (*textures.Texture).GPUSize,
+		dependencies:   textures.TextureDependencies,
 	}
 	loadedMeshes = cache[*geom.Mesh]{
 		storage:        make(map[string]*geom.Mesh),
-		fileExtensions: []string{".obj"},
-		loadFunc:       geom.LoadOBJMesh,
+		fileExtensions: []string{".obj", ".gltf", ".glb"},
+		loadFunc:       geom.LoadMesh,
 		freeFunc:       (*geom.Mesh).Free,
 		resourceName:   "mesh",
+		replaceFunc:    (*geom.Mesh).Replace,
+		sizeFunc:       (*geom.Mesh).GPUSize,
+		dependencies:   geom.MeshDependencies,
 	}
 	loadedFonts = cache[*fonts.Font]{
 		storage:        make(map[string]*fonts.Font),
@@ -75,6 +92,7 @@ func init() {
 		loadFunc:       locales.LoadTranslation,
 		freeFunc:       nil,
 		resourceName:   "translation",
+		replaceFunc:    (*locales.Translation).Replace,
 	}
 }
 
@@ -82,6 +100,10 @@ func (c *cache[T]) get(assetPath string) (T, error) {
 	var err error
 	var empty T
 	assetPath = strings.ReplaceAll(assetPath, "\\", "/")
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	resource, ok := c.storage[assetPath]
 	if !ok {
 		for _, extension := range c.fileExtensions {
@@ -91,26 +113,112 @@ func (c *cache[T]) get(assetPath string) (T, error) {
 					return empty, err
 				}
 				c.storage[assetPath] = resource
+				c.markUsed(assetPath)
+				c.watch(assetPath)
 				return resource, nil
 			}
 		}
 		return empty, fmt.Errorf("unsupported file type for %v", c.resourceName)
 	}
+	c.markUsed(assetPath)
 	return resource, nil
 }
 
+// Returns the resource if it is already loaded, without loading it or marking it as used.
+func (c *cache[T]) peek(assetPath string) (T, bool) {
+	assetPath = strings.ReplaceAll(assetPath, "\\", "/")
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	resource, ok := c.storage[assetPath]
+	return resource, ok
+}
+
+func (c *cache[T]) markUsed(assetPath string) {
+	if c.lastUsed == nil {
+		c.lastUsed = make(map[string]uint32)
+	}
+	c.lastUsed[assetPath] = generation
+}
+
 func (c *cache[T]) freeAll() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	if c.freeFunc != nil {
 		for i := range c.storage {
 			c.freeFunc(c.storage[i])
 		}
 	}
 	clear(c.storage)
+	clear(c.lastUsed)
+	clear(c.stamps)
 }
 
+// Frees the resources that have not been requested during the current generation.
+// Returns the number of resources freed.
+func (c *cache[T]) freeUnused() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	freed := 0
+	for assetPath, resource := range c.storage {
+		if c.lastUsed[assetPath] == generation {
+			continue
+		}
+		if c.freeFunc != nil {
+			c.freeFunc(resource)
+		}
+		delete(c.storage, assetPath)
+		delete(c.lastUsed, assetPath)
+		delete(c.stamps, assetPath)
+		freed++
+	}
+	return freed
+}
+
+// Stores a resource that was loaded or made elsewhere, marking it as used in the current generation.
+// A different resource already stored under the name is freed, so a map's generated resources replace those from when it was last loaded,
+// while the ones it no longer has are left for freeUnused.
 func (c *cache[T]) take(assetPath string, resource T) {
 	assetPath = strings.ReplaceAll(assetPath, "\\", "/")
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Free the resource that is being replaced, unless it's the same one.
+	if old, ok := c.storage[assetPath]; ok && c.freeFunc != nil && any(old) != any(resource) {
+		c.freeFunc(old)
+	}
 	c.storage[assetPath] = resource
+	c.markUsed(assetPath)
+	if !strings.HasPrefix(assetPath, assets.GENERATED_PREFIX) {
+		c.watch(assetPath)
+	}
+}
+
+// Describes the resources of one type that are currently in the cache.
+type Usage struct {
+	ResourceName string
+	Count        int
+	GPUBytes     int // Approximate amount of video memory used by the resources.
+}
+
+func (c *cache[T]) usage() Usage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	usage := Usage{
+		ResourceName: c.resourceName,
+		Count:        len(c.storage),
+	}
+	if c.sizeFunc != nil {
+		for _, resource := range c.storage {
+			usage.GPUBytes += c.sizeFunc(resource)
+		}
+	}
+	return usage
 }
 
 func GetTexture(assetPath string) *textures.Texture {
@@ -122,11 +230,28 @@ func GetTexture(assetPath string) *textures.Texture {
 	return texture
 }
 
+// Returns the texture if it has already been loaded. Unlike GetTexture, this is safe to call from other goroutines.
+func PeekTexture(assetPath string) (*textures.Texture, bool) {
+	return loadedTextures.peek(assetPath)
+}
+
+// Takes ownership of an already loaded texture. Will dispose of its resources along with the other textures.
+// Textures that weren't loaded from the given path should be named with assets.GeneratedName.
+func TakeTexture(assetPath string, texture *textures.Texture) {
+	loadedTextures.take(assetPath, texture)
+}
+
 // Takes ownership of an already loaded mesh. Will dispose of its resources along with the other meshes.
+// Meshes that weren't loaded from the given path should be named with assets.GeneratedName.
 func TakeMesh(assetPath string, mesh *geom.Mesh) {
 	loadedMeshes.take(assetPath, mesh)
 }
 
+// Returns the mesh if it has already been loaded. Unlike GetMesh, this is safe to call from other goroutines.
+func PeekMesh(assetPath string) (*geom.Mesh, bool) {
+	return loadedMeshes.peek(assetPath)
+}
+
 func GetMesh(assetPath string) (*geom.Mesh, error) {
 	mesh, err := loadedMeshes.get(assetPath)
 	if err != nil {
@@ -163,14 +288,36 @@ func GetTranslation(assetPath string) (*locales.Translation, error) {
 }
 
 // This frees memory for all resources currently loaded and clears the cache.
-// This is done to refresh the loaded assets between levels.
 func Reset() {
 	loadedTextures.freeAll()
-	clear(loadedTextures.storage)
 	loadedMeshes.freeAll()
-	clear(loadedMeshes.storage)
 	loadedFonts.freeAll()
-	clear(loadedFonts.storage)
+}
+
+// Starts a new generation of asset usage. This is done when a new map begins loading.
+// Resources that aren't requested again before FreeUnused is called will be freed,
+// so that resources shared between levels don't have to be loaded again.
+func BeginGeneration() {
+	generation++
+}
+
+// Frees the textures, meshes, and fonts that haven't been used since BeginGeneration was called.
+func FreeUnused() {
+	freedTextures := loadedTextures.freeUnused()
+	freedMeshes := loadedMeshes.freeUnused()
+	freedFonts := loadedFonts.freeUnused()
+	log.Printf("Freed %v unused textures, %v meshes, and %v fonts.\n", freedTextures, freedMeshes, freedFonts)
+}
+
+// Reports the number of resources of each type in the cache and their approximate video memory usage.
+func Usages() []Usage {
+	return []Usage{
+		loadedTextures.usage(),
+		loadedMeshes.usage(),
+		loadedFonts.usage(),
+		loadedSfx.usage(),
+		loadedTranslations.usage(),
+	}
 }
 
 // This frees memory for all resources currently loaded.
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"testing"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+)
+
+func TestTakeGenerated(t *testing.T) {
+	var freed []string
+	c := cache[string]{
+		storage:      make(map[string]string),
+		loadFunc:     func(assetPath string) (string, error) { return "loaded " + assetPath, nil },
+		freeFunc:     func(resource string) { freed = append(freed, resource) },
+		resourceName: "test",
+		replaceFunc:  func(dst, src string) {},
+	}
+	EnableHotReload()
+	t.Cleanup(func() { hotReloadEnabled = false })
+
+	c.take("assets/models/made.obj", "file")
+	c.take(assets.GeneratedName("assets/maps/map.te3", "mesh"), "generated")
+	if _, watched := c.stamps["assets/models/made.obj"]; !watched {
+		t.Error("Resource named after a file isn't watched")
+	}
+	if _, watched := c.stamps[assets.GeneratedName("assets/maps/map.te3", "mesh")]; watched {
+		t.Error("Generated resource is watched as if it had a file")
+	}
+
+	// Taking a new version of the generated resource replaces the old one, which is kept until then.
+	BeginGeneration()
+	c.take(assets.GeneratedName("assets/maps/map.te3", "mesh"), "regenerated")
+	if len(freed) != 1 || freed[0] != "generated" {
+		t.Errorf("Freed %v instead of the old generated resource", freed)
+	}
+	if count := c.freeUnused(); count != 1 || len(freed) != 2 || freed[1] != "file" {
+		t.Errorf("Freed %v resources instead of the one that wasn't used", count)
+	}
+	if resource, ok := c.peek(assets.GeneratedName("assets/maps/map.te3", "mesh")); !ok || resource != "regenerated" {
+		t.Errorf("Generated resource is %q instead of the new version", resource)
+	}
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"log"
+	"slices"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+)
+
+var hotReloadEnabled bool
+
+// Makes the caches remember the state of the files each asset was loaded from, so that ReloadChanged can find edited assets.
+// Only assets loaded after this is called are watched.
+func EnableHotReload() {
+	hotReloadEnabled = true
+}
+
+func (c *cache[T]) fileStamps(assetPath string) []assets.FileStamp {
+	files := []string{assetPath}
+	if c.dependencies != nil {
+		files = c.dependencies(assetPath)
+	}
+	stamps := make([]assets.FileStamp, len(files))
+	for i, file := range files {
+		stamps[i] = assets.Stamp(file)
+	}
+	return stamps
+}
+
+func (c *cache[T]) watch(assetPath string) {
+	if !hotReloadEnabled || c.replaceFunc == nil {
+		return
+	}
+	if c.stamps == nil {
+		c.stamps = make(map[string][]assets.FileStamp)
+	}
+	c.stamps[assetPath] = c.fileStamps(assetPath)
+}
+
+// Reloads the resources whose files have changed and swaps the new data into the existing resources.
+// Returns the asset paths of the resources that were reloaded.
+func (c *cache[T]) reloadChanged() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var reloaded []string
+	for assetPath, oldStamps := range c.stamps {
+		newStamps := c.fileStamps(assetPath)
+		if slices.Equal(oldStamps, newStamps) {
+			continue
+		}
+		// Update the stamps even if loading fails, so that a broken file isn't reloaded again until it is saved again.
+		c.stamps[assetPath] = newStamps
+
+		resource, err := c.loadFunc(assetPath)
+		if err != nil {
+			log.Printf("could not reload %v at %v: %v\n", c.resourceName, assetPath, err)
+			continue
+		}
+		c.replaceFunc(c.storage[assetPath], resource)
+		reloaded = append(reloaded, assetPath)
+	}
+	return reloaded
+}
+
+// Reloads every watched texture, mesh, and translation whose files have been modified since they were loaded.
+// The new data is placed into the existing objects, so pointers to them remain valid.
+// Returns the asset paths of the reloaded resources.
+func ReloadChanged() []string {
+	var reloaded []string
+	reloaded = append(reloaded, loadedTextures.reloadChanged()...)
+	reloaded = append(reloaded, loadedMeshes.reloadChanged()...)
+	reloaded = append(reloaded, loadedTranslations.reloadChanged()...)
+	for _, assetPath := range reloaded {
+		log.Printf("Hot reloaded %v.\n", assetPath)
+	}
+	return reloaded
+}
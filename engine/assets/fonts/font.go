@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"path"
 	"path/filepath"
 	"strings"
@@ -20,8 +19,7 @@ type Font struct {
 
 func fileSheets(directory string) bmfont.SheetReaderFunc {
 	return func(filename string) (io.ReadCloser, error) {
-		path := filepath.Join(directory, filename)
-		f, err := os.Open(path)
+		f, err := assets.GetFile(path.Join(directory, filename))
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,580 @@
+package geom
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+)
+
+const (
+	GLTF_MODE_TRIANGLES = 4
+
+	GLTF_COMPONENT_BYTE           = 5120
+	GLTF_COMPONENT_UNSIGNED_BYTE  = 5121
+	GLTF_COMPONENT_SHORT          = 5122
+	GLTF_COMPONENT_UNSIGNED_SHORT = 5123
+	GLTF_COMPONENT_UNSIGNED_INT   = 5125
+	GLTF_COMPONENT_FLOAT          = 5126
+
+	GLB_MAGIC      = 0x46546C67 // "glTF"
+	GLB_CHUNK_JSON = 0x4E4F534A // "JSON"
+	GLB_CHUNK_BIN  = 0x004E4942 // "BIN"
+)
+
+// The parts of a glTF 2.0 document that are needed to build meshes.
+type gltfDocument struct {
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+	Nodes []struct {
+		Mesh        *int      `json:"mesh"`
+		Children    []int     `json:"children"`
+		Matrix      []float32 `json:"matrix"`
+		Translation []float32 `json:"translation"`
+		Rotation    []float32 `json:"rotation"`
+		Scale       []float32 `json:"scale"`
+	} `json:"nodes"`
+	Meshes []struct {
+		Name       string `json:"name"`
+		Primitives []struct {
+			Attributes map[string]int `json:"attributes"`
+			Indices    *int           `json:"indices"`
+			Material   *int           `json:"material"`
+			Mode       *int           `json:"mode"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+	Materials []struct {
+		Name string `json:"name"`
+	} `json:"materials"`
+	Accessors []struct {
+		BufferView    *int   `json:"bufferView"`
+		ByteOffset    int    `json:"byteOffset"`
+		ComponentType int    `json:"componentType"`
+		Normalized    bool   `json:"normalized"`
+		Count         int    `json:"count"`
+		Type          string `json:"type"`
+		Sparse        any    `json:"sparse"`
+	} `json:"accessors"`
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+		ByteStride int `json:"byteStride"`
+	} `json:"bufferViews"`
+	Buffers []struct {
+		URI        string `json:"uri"`
+		ByteLength int    `json:"byteLength"`
+	} `json:"buffers"`
+}
+
+// Holds a glTF document along with the contents of its buffers.
+type gltfFile struct {
+	gltfDocument
+	buffers [][]byte
+}
+
+// Loads a glTF 2.0 model from a .gltf or .glb file.
+// The triangles of every mesh in the default scene are combined into one mesh, transformed by their nodes.
+// Each material becomes a mesh group named after it, like the materials in .obj files. Skins and morph targets are ignored.
+func LoadGLTFMesh(assetPath string) (*Mesh, error) {
+	gltf, err := readGLTFFile(assetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	verts := Vertices{
+		Pos:      make([]mgl32.Vec3, 0),
+		TexCoord: make([]mgl32.Vec2, 0),
+		Normal:   make([]mgl32.Vec3, 0),
+		Color:    make([]mgl32.Vec4, 0),
+	}
+
+	// The indices of each group are gathered separately so that each group's triangles are contiguous.
+	groupInds := make(map[string][]uint32)
+	groupOrder := make([]string, 0)
+
+	addMesh := func(meshIndex int, transform mgl32.Mat4) error {
+		if meshIndex < 0 || meshIndex >= len(gltf.Meshes) {
+			return fmt.Errorf("mesh %v does not exist", meshIndex)
+		}
+		normalTransform := transform.Mat3().Inv().Transpose()
+		for p, primitive := range gltf.Meshes[meshIndex].Primitives {
+			if primitive.Mode != nil && *primitive.Mode != GLTF_MODE_TRIANGLES {
+				log.Printf("Skipping primitive %v of mesh %v in %v; only triangles are supported.\n", p, meshIndex, assetPath)
+				continue
+			}
+
+			posAccessor, ok := primitive.Attributes["POSITION"]
+			if !ok {
+				return fmt.Errorf("primitive %v of mesh %v has no positions", p, meshIndex)
+			}
+			positions, err := gltf.readAccessor(posAccessor, 3)
+			if err != nil {
+				return err
+			}
+			vertCount := len(positions) / 3
+
+			readAttribute := func(name string, size int) ([]float32, error) {
+				accessor, ok := primitive.Attributes[name]
+				if !ok {
+					return nil, nil
+				}
+				values, err := gltf.readAccessor(accessor, size)
+				if err == nil && len(values) != vertCount*size {
+					err = fmt.Errorf("%v attribute of primitive %v has the wrong number of elements", name, p)
+				}
+				return values, err
+			}
+			normals, err := readAttribute("NORMAL", 3)
+			if err != nil {
+				return err
+			}
+			texCoords, err := readAttribute("TEXCOORD_0", 2)
+			if err != nil {
+				return err
+			}
+			var colors []float32
+			colorSize := 4
+			if accessor, ok := primitive.Attributes["COLOR_0"]; ok && accessor >= 0 && accessor < len(gltf.Accessors) && gltf.Accessors[accessor].Type == "VEC3" {
+				colorSize = 3
+			}
+			if colors, err = readAttribute("COLOR_0", colorSize); err != nil {
+				return err
+			}
+
+			firstVert := uint32(len(verts.Pos))
+			for v := range vertCount {
+				pos := mgl32.Vec3{positions[v*3], positions[v*3+1], positions[v*3+2]}
+				verts.Pos = append(verts.Pos, mgl32.TransformCoordinate(pos, transform))
+
+				if normals != nil {
+					normal := mgl32.Vec3{normals[v*3], normals[v*3+1], normals[v*3+2]}
+					verts.Normal = append(verts.Normal, normalTransform.Mul3x1(normal).Normalize())
+				} else {
+					verts.Normal = append(verts.Normal, mgl32.Vec3{0.0, 1.0, 0.0})
+				}
+
+				if texCoords != nil {
+					// glTF puts the origin of the texture at the top left, while OpenGL (and .obj) puts it at the bottom left.
+					verts.TexCoord = append(verts.TexCoord, mgl32.Vec2{texCoords[v*2], 1.0 - texCoords[v*2+1]})
+				} else {
+					verts.TexCoord = append(verts.TexCoord, mgl32.Vec2{})
+				}
+
+				// Assign white as default vertex color.
+				vertColor := mgl32.Vec4{1.0, 1.0, 1.0, 1.0}
+				if colors != nil {
+					copy(vertColor[:], colors[v*colorSize:][:colorSize])
+				}
+				verts.Color = append(verts.Color, vertColor)
+			}
+
+			var inds []uint32
+			if primitive.Indices != nil {
+				if inds, err = gltf.readIndices(*primitive.Indices); err != nil {
+					return err
+				}
+				for i, ind := range inds {
+					if int(ind) >= vertCount {
+						return fmt.Errorf("index %v of primitive %v is out of range", i, p)
+					}
+					inds[i] = firstVert + ind
+				}
+			} else {
+				inds = make([]uint32, vertCount)
+				for i := range inds {
+					inds[i] = firstVert + uint32(i)
+				}
+			}
+			inds = inds[:len(inds)/3*3]
+
+			groupName := ""
+			if primitive.Material != nil && *primitive.Material >= 0 && *primitive.Material < len(gltf.Materials) {
+				groupName = gltf.Materials[*primitive.Material].Name
+			}
+			if _, ok := groupInds[groupName]; !ok {
+				groupOrder = append(groupOrder, groupName)
+			}
+			groupInds[groupName] = append(groupInds[groupName], inds...)
+		}
+		return nil
+	}
+
+	if len(gltf.Nodes) == 0 {
+		// Without a node hierarchy, the meshes are used as they are.
+		for m := range gltf.Meshes {
+			if err := addMesh(m, mgl32.Ident4()); err != nil {
+				return nil, fmt.Errorf("error in %v: %v", assetPath, err)
+			}
+		}
+	} else {
+		var addNode func(nodeIndex int, parentTransform mgl32.Mat4, depth int) error
+		addNode = func(nodeIndex int, parentTransform mgl32.Mat4, depth int) error {
+			if nodeIndex < 0 || nodeIndex >= len(gltf.Nodes) {
+				return fmt.Errorf("node %v does not exist", nodeIndex)
+			}
+			if depth > len(gltf.Nodes) {
+				return fmt.Errorf("node hierarchy has a cycle")
+			}
+			transform := parentTransform.Mul4(gltf.nodeTransform(nodeIndex))
+			if gltf.Nodes[nodeIndex].Mesh != nil {
+				if err := addMesh(*gltf.Nodes[nodeIndex].Mesh, transform); err != nil {
+					return err
+				}
+			}
+			for _, child := range gltf.Nodes[nodeIndex].Children {
+				if err := addNode(child, transform, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, root := range gltf.rootNodes() {
+			if err := addNode(root, mgl32.Ident4(), 0); err != nil {
+				return nil, fmt.Errorf("error in %v: %v", assetPath, err)
+			}
+		}
+	}
+
+	inds := make([]uint32, 0)
+	meshGroups := make(map[string]Group, len(groupOrder))
+	for _, name := range groupOrder {
+		meshGroups[name] = Group{Offset: len(inds), Length: len(groupInds[name])}
+		inds = append(inds, groupInds[name]...)
+	}
+	if len(verts.Pos) == 0 {
+		return nil, fmt.Errorf("no triangles found in %v", assetPath)
+	}
+
+	mesh := CreateMesh(verts, inds)
+	for name, group := range meshGroups {
+		mesh.SetGroup(name, group)
+	}
+	// The mesh is uploaded the first time it is bound, so that it can be loaded outside of the main thread.
+
+	log.Printf("Loaded glTF file at %v.\n", assetPath)
+	return mesh, nil
+}
+
+// Reads the JSON document and the buffers of a .gltf file, or of a binary .glb file.
+func readGLTFFile(assetPath string) (*gltfFile, error) {
+	file, err := assets.GetFile(assetPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Binary files hold the JSON and the first buffer in separate chunks.
+	var jsonData, binChunk []byte
+	if len(data) >= 12 && binary.LittleEndian.Uint32(data) == GLB_MAGIC {
+		if version := binary.LittleEndian.Uint32(data[4:]); version != 2 {
+			return nil, fmt.Errorf("%v has unsupported glTF version %v", assetPath, version)
+		}
+		for chunks := data[12:]; len(chunks) >= 8; {
+			chunkLength := int(binary.LittleEndian.Uint32(chunks))
+			chunkType := binary.LittleEndian.Uint32(chunks[4:])
+			if chunkLength > len(chunks)-8 {
+				return nil, fmt.Errorf("%v has a truncated chunk", assetPath)
+			}
+			switch chunkType {
+			case GLB_CHUNK_JSON:
+				jsonData = chunks[8:][:chunkLength]
+			case GLB_CHUNK_BIN:
+				binChunk = chunks[8:][:chunkLength]
+			}
+			chunks = chunks[8+chunkLength:]
+		}
+		if jsonData == nil {
+			return nil, fmt.Errorf("%v has no JSON chunk", assetPath)
+		}
+	} else {
+		jsonData = data
+	}
+
+	gltf := &gltfFile{}
+	if err := json.NewDecoder(bytes.NewReader(jsonData)).Decode(&gltf.gltfDocument); err != nil {
+		return nil, fmt.Errorf("could not parse %v: %v", assetPath, err)
+	}
+
+	gltf.buffers = make([][]byte, len(gltf.Buffers))
+	for b, buffer := range gltf.Buffers {
+		switch {
+		case len(buffer.URI) == 0:
+			if b != 0 || binChunk == nil {
+				return nil, fmt.Errorf("buffer %v in %v has no data", b, assetPath)
+			}
+			gltf.buffers[b] = binChunk
+		case strings.HasPrefix(buffer.URI, "data:"):
+			_, encoded, found := strings.Cut(buffer.URI, ";base64,")
+			if !found {
+				return nil, fmt.Errorf("buffer %v in %v has an unsupported data URI", b, assetPath)
+			}
+			if gltf.buffers[b], err = base64.StdEncoding.DecodeString(encoded); err != nil {
+				return nil, fmt.Errorf("could not decode buffer %v in %v: %v", b, assetPath, err)
+			}
+		default:
+			// External buffers are found relative to the model.
+			uri, err := url.PathUnescape(buffer.URI)
+			if err != nil {
+				uri = buffer.URI
+			}
+			bufferFile, err := assets.GetFile(path.Join(path.Dir(assetPath), uri))
+			if err != nil {
+				return nil, fmt.Errorf("buffer %v of %v not found: %v", b, assetPath, err)
+			}
+			gltf.buffers[b], err = io.ReadAll(bufferFile)
+			bufferFile.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(gltf.buffers[b]) < buffer.ByteLength {
+			return nil, fmt.Errorf("buffer %v in %v is shorter than its length of %v", b, assetPath, buffer.ByteLength)
+		}
+	}
+
+	return gltf, nil
+}
+
+// Returns the asset paths of the files that a mesh is loaded from, which includes the external buffers of .gltf files.
+func MeshDependencies(assetPath string) []string {
+	files := []string{assetPath}
+	if strings.ToLower(path.Ext(assetPath)) != ".gltf" {
+		return files
+	}
+	doc, err := assets.LoadAndUnmarshalJSON[gltfDocument](assetPath)
+	if err != nil {
+		return files
+	}
+	for _, buffer := range doc.Buffers {
+		if len(buffer.URI) == 0 || strings.HasPrefix(buffer.URI, "data:") {
+			continue
+		}
+		if uri, err := url.PathUnescape(buffer.URI); err == nil {
+			files = append(files, path.Join(path.Dir(assetPath), uri))
+		}
+	}
+	return files
+}
+
+// Returns the nodes at the root of the scene that should be shown.
+func (gltf *gltfFile) rootNodes() []int {
+	if len(gltf.Scenes) > 0 {
+		scene := 0
+		if gltf.Scene != nil && *gltf.Scene >= 0 && *gltf.Scene < len(gltf.Scenes) {
+			scene = *gltf.Scene
+		}
+		return gltf.Scenes[scene].Nodes
+	}
+
+	// Without scenes, every node that isn't a child of another node is a root.
+	isChild := make([]bool, len(gltf.Nodes))
+	for _, node := range gltf.Nodes {
+		for _, child := range node.Children {
+			if child >= 0 && child < len(isChild) {
+				isChild[child] = true
+			}
+		}
+	}
+	roots := make([]int, 0)
+	for n := range gltf.Nodes {
+		if !isChild[n] {
+			roots = append(roots, n)
+		}
+	}
+	return roots
+}
+
+// Returns the node's transform relative to its parent.
+func (gltf *gltfFile) nodeTransform(nodeIndex int) mgl32.Mat4 {
+	node := gltf.Nodes[nodeIndex]
+	if len(node.Matrix) == 16 {
+		// Both glTF and mathgl store matrices in column major order.
+		return mgl32.Mat4(node.Matrix)
+	}
+
+	transform := mgl32.Ident4()
+	if len(node.Translation) == 3 {
+		transform = mgl32.Translate3D(node.Translation[0], node.Translation[1], node.Translation[2])
+	}
+	if len(node.Rotation) == 4 {
+		rotation := mgl32.Quat{W: node.Rotation[3], V: mgl32.Vec3{node.Rotation[0], node.Rotation[1], node.Rotation[2]}}
+		transform = transform.Mul4(rotation.Normalize().Mat4())
+	}
+	if len(node.Scale) == 3 {
+		transform = transform.Mul4(mgl32.Scale3D(node.Scale[0], node.Scale[1], node.Scale[2]))
+	}
+	return transform
+}
+
+type gltfAccessorData struct {
+	data                 []byte // Starts at the first element.
+	count, stride        int
+	componentType        int
+	componentSize        int
+	normalized, zeroFill bool
+}
+
+// Finds the bytes that an accessor refers to and checks that they are in bounds.
+// The accessor must have the given number of components per element.
+func (gltf *gltfFile) accessorData(accessorIndex int, components int) (gltfAccessorData, error) {
+	if accessorIndex < 0 || accessorIndex >= len(gltf.Accessors) {
+		return gltfAccessorData{}, fmt.Errorf("accessor %v does not exist", accessorIndex)
+	}
+	accessor := gltf.Accessors[accessorIndex]
+	if accessor.Sparse != nil {
+		return gltfAccessorData{}, fmt.Errorf("accessor %v is sparse, which isn't supported", accessorIndex)
+	}
+
+	typeComponents := map[string]int{"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4}[accessor.Type]
+	if typeComponents != components {
+		return gltfAccessorData{}, fmt.Errorf("accessor %v has type %v instead of %v components", accessorIndex, accessor.Type, components)
+	}
+
+	if accessor.Count < 0 || accessor.ByteOffset < 0 {
+		return gltfAccessorData{}, fmt.Errorf("accessor %v has a negative count or byte offset", accessorIndex)
+	}
+	if accessor.Count > math.MaxUint32 {
+		// Vertices past this couldn't be indexed anyway.
+		return gltfAccessorData{}, fmt.Errorf("accessor %v has too many elements", accessorIndex)
+	}
+
+	result := gltfAccessorData{
+		count:         accessor.Count,
+		componentType: accessor.ComponentType,
+		normalized:    accessor.Normalized,
+	}
+	switch accessor.ComponentType {
+	case GLTF_COMPONENT_BYTE, GLTF_COMPONENT_UNSIGNED_BYTE:
+		result.componentSize = 1
+	case GLTF_COMPONENT_SHORT, GLTF_COMPONENT_UNSIGNED_SHORT:
+		result.componentSize = 2
+	case GLTF_COMPONENT_UNSIGNED_INT, GLTF_COMPONENT_FLOAT:
+		result.componentSize = 4
+	default:
+		return gltfAccessorData{}, fmt.Errorf("accessor %v has unknown component type %v", accessorIndex, accessor.ComponentType)
+	}
+
+	if accessor.BufferView == nil {
+		// Accessors without buffer views are filled with zeros.
+		result.zeroFill = true
+		return result, nil
+	}
+	if *accessor.BufferView < 0 || *accessor.BufferView >= len(gltf.BufferViews) {
+		return gltfAccessorData{}, fmt.Errorf("buffer view %v does not exist", *accessor.BufferView)
+	}
+	view := gltf.BufferViews[*accessor.BufferView]
+	if view.Buffer < 0 || view.Buffer >= len(gltf.buffers) {
+		return gltfAccessorData{}, fmt.Errorf("buffer %v does not exist", view.Buffer)
+	}
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteOffset > len(gltf.buffers[view.Buffer])-view.ByteLength {
+		return gltfAccessorData{}, fmt.Errorf("buffer view %v goes past the end of its buffer", *accessor.BufferView)
+	}
+
+	elementSize := result.componentSize * components
+	result.stride = view.ByteStride
+	if result.stride == 0 {
+		result.stride = elementSize
+	} else if result.stride < elementSize {
+		return gltfAccessorData{}, fmt.Errorf("buffer view %v has a stride of %v, which is smaller than the elements of accessor %v", *accessor.BufferView, result.stride, accessorIndex)
+	}
+	// Compared by dividing, so that huge counts can't overflow.
+	available := view.ByteLength - accessor.ByteOffset
+	if accessor.Count > 0 && (available < elementSize || accessor.Count-1 > (available-elementSize)/result.stride) {
+		return gltfAccessorData{}, fmt.Errorf("accessor %v goes past the end of its buffer view", accessorIndex)
+	}
+	result.data = gltf.buffers[view.Buffer][view.ByteOffset+accessor.ByteOffset:]
+
+	return result, nil
+}
+
+// Reads the elements of an accessor as floats, converting normalized integers into the range of [0, 1] or [-1, 1].
+func (gltf *gltfFile) readAccessor(accessorIndex int, components int) ([]float32, error) {
+	accessor, err := gltf.accessorData(accessorIndex, components)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]float32, accessor.count*components)
+	if accessor.zeroFill {
+		return values, nil
+	}
+	for e := range accessor.count {
+		for c := range components {
+			bytes := accessor.data[e*accessor.stride+c*accessor.componentSize:]
+			var value float32
+			switch accessor.componentType {
+			case GLTF_COMPONENT_BYTE:
+				value = float32(int8(bytes[0]))
+				if accessor.normalized {
+					value = max(value/127.0, -1.0)
+				}
+			case GLTF_COMPONENT_UNSIGNED_BYTE:
+				value = float32(bytes[0])
+				if accessor.normalized {
+					value /= 255.0
+				}
+			case GLTF_COMPONENT_SHORT:
+				value = float32(int16(binary.LittleEndian.Uint16(bytes)))
+				if accessor.normalized {
+					value = max(value/32767.0, -1.0)
+				}
+			case GLTF_COMPONENT_UNSIGNED_SHORT:
+				value = float32(binary.LittleEndian.Uint16(bytes))
+				if accessor.normalized {
+					value /= 65535.0
+				}
+			case GLTF_COMPONENT_UNSIGNED_INT:
+				value = float32(binary.LittleEndian.Uint32(bytes))
+			case GLTF_COMPONENT_FLOAT:
+				value = math.Float32frombits(binary.LittleEndian.Uint32(bytes))
+			}
+			values[e*components+c] = value
+		}
+	}
+	return values, nil
+}
+
+// Reads the elements of a scalar accessor of unsigned integers, as used for vertex indices.
+func (gltf *gltfFile) readIndices(accessorIndex int) ([]uint32, error) {
+	accessor, err := gltf.accessorData(accessorIndex, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	inds := make([]uint32, accessor.count)
+	if accessor.zeroFill {
+		return inds, nil
+	}
+	for i := range inds {
+		bytes := accessor.data[i*accessor.stride:]
+		switch accessor.componentType {
+		case GLTF_COMPONENT_UNSIGNED_BYTE:
+			inds[i] = uint32(bytes[0])
+		case GLTF_COMPONENT_UNSIGNED_SHORT:
+			inds[i] = uint32(binary.LittleEndian.Uint16(bytes))
+		case GLTF_COMPONENT_UNSIGNED_INT:
+			inds[i] = binary.LittleEndian.Uint32(bytes)
+		default:
+			return nil, fmt.Errorf("accessor %v has component type %v, which can't be used for indices", accessorIndex, accessor.componentType)
+		}
+	}
+	return inds, nil
+}
@@ -0,0 +1,122 @@
+package geom
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+)
+
+const (
+	testGLTFViews     = `[{"buffer": 0, "byteOffset": 0, "byteLength": 36}, {"buffer": 0, "byteOffset": 36, "byteLength": 6}]`
+	testGLTFAccessors = `[
+		{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"},
+		{"bufferView": 1, "componentType": 5123, "count": 3, "type": "SCALAR"}
+	]`
+)
+
+// Returns the buffer of a model with one triangle: three float positions, then three short indices and two bytes of padding.
+func testGLTFBuffer() []byte {
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.LittleEndian, []float32{0.0, 0.0, 0.0, 1.0, 0.0, 0.0, 0.0, 0.0, 1.0})
+	binary.Write(&buffer, binary.LittleEndian, []uint16{0, 1, 2, 0})
+	return buffer.Bytes()
+}
+
+// Returns the JSON of a model with one triangle, with the given buffer views and accessors.
+// The buffer is embedded in a data URI unless uri is empty, which means that it comes from the binary chunk of a .glb file.
+func testGLTFDocument(uri, views, accessors string) []byte {
+	if uri != "" {
+		uri = fmt.Sprintf(`"uri": %q, `, uri)
+	}
+	return fmt.Appendf(nil, `{
+		"asset": {"version": "2.0"},
+		"meshes": [{"primitives": [{"attributes": {"POSITION": 0}, "indices": 1, "material": 0}]}],
+		"materials": [{"name": "skin"}],
+		"buffers": [{%v"byteLength": 44}],
+		"bufferViews": %v,
+		"accessors": %v
+	}`, uri, views, accessors)
+}
+
+// Packs the JSON and the binary chunk into a .glb file.
+func testGLB(document, bin []byte) []byte {
+	for len(document)%4 != 0 {
+		document = append(document, ' ')
+	}
+	var glb bytes.Buffer
+	binary.Write(&glb, binary.LittleEndian, []uint32{GLB_MAGIC, 2, uint32(12 + 8 + len(document) + 8 + len(bin))})
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32(len(document)), GLB_CHUNK_JSON})
+	glb.Write(document)
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32(len(bin)), GLB_CHUNK_BIN})
+	glb.Write(bin)
+	return glb.Bytes()
+}
+
+func TestLoadGLTFMesh(t *testing.T) {
+	dir := t.TempDir()
+	if err := assets.SetModLayers([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { assets.SetModLayers(nil) })
+
+	dataURI := "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(testGLTFBuffer())
+	gltfWith := func(views, accessors string) []byte {
+		return testGLTFDocument(dataURI, views, accessors)
+	}
+	for _, test := range []struct {
+		name, fileName string
+		data           []byte
+		expectErr      bool
+	}{
+		{"gltf", "model.gltf", gltfWith(testGLTFViews, testGLTFAccessors), false},
+		{"glb", "model.glb", testGLB(testGLTFDocument("", testGLTFViews, testGLTFAccessors), testGLTFBuffer()), false},
+		{"truncated glb", "model.glb", testGLB(testGLTFDocument("", testGLTFViews, testGLTFAccessors), testGLTFBuffer())[:60], true},
+		{"missing buffer view", "model.gltf", gltfWith(`[]`, testGLTFAccessors), true},
+		{"negative count", "model.gltf", gltfWith(testGLTFViews, `[{"bufferView": 0, "componentType": 5126, "count": -1, "type": "VEC3"}]`), true},
+		{"negative count without buffer view", "model.gltf", gltfWith(testGLTFViews, `[{"componentType": 5126, "count": -1, "type": "VEC3"}]`), true},
+		{"huge count", "model.gltf", gltfWith(testGLTFViews, fmt.Sprintf(`[{"bufferView": 0, "componentType": 5126, "count": %v, "type": "VEC3"}]`, math.MaxInt64)), true},
+		{"count past end of view", "model.gltf", gltfWith(testGLTFViews, `[{"bufferView": 0, "componentType": 5126, "count": 4, "type": "VEC3"}]`), true},
+		{"negative accessor offset", "model.gltf", gltfWith(testGLTFViews, `[{"bufferView": 0, "byteOffset": -12, "componentType": 5126, "count": 3, "type": "VEC3"}]`), true},
+		{"accessor offset past end of view", "model.gltf", gltfWith(testGLTFViews, `[{"bufferView": 0, "byteOffset": 40, "componentType": 5126, "count": 1, "type": "VEC3"}]`), true},
+		{"negative view offset", "model.gltf", gltfWith(`[{"buffer": 0, "byteOffset": -4, "byteLength": 36}]`, testGLTFAccessors), true},
+		{"negative view length", "model.gltf", gltfWith(`[{"buffer": 0, "byteOffset": 40, "byteLength": -40}]`, testGLTFAccessors), true},
+		{"negative stride", "model.gltf", gltfWith(`[{"buffer": 0, "byteLength": 36, "byteStride": -12}]`, testGLTFAccessors), true},
+		{"stride smaller than element", "model.gltf", gltfWith(`[{"buffer": 0, "byteLength": 36, "byteStride": 4}]`, testGLTFAccessors), true},
+		{"wrong type", "model.gltf", gltfWith(testGLTFViews, `[{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC2"}]`), true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := os.WriteFile(filepath.Join(dir, test.fileName), test.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			mesh, err := LoadGLTFMesh(test.fileName)
+			if test.expectErr {
+				if err == nil {
+					t.Error("Loaded a malformed model without an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedPos := []mgl32.Vec3{{0.0, 0.0, 0.0}, {1.0, 0.0, 0.0}, {0.0, 0.0, 1.0}}
+			if pos := mesh.Verts().Pos; !slices.Equal(pos, expectedPos) {
+				t.Errorf("Positions should be %v but are %v", expectedPos, pos)
+			}
+			if inds := mesh.Inds(); !slices.Equal(inds, []uint32{0, 1, 2}) {
+				t.Errorf("Indices should be [0 1 2] but are %v", inds)
+			}
+			if group := mesh.Group("skin"); group.Offset != 0 || group.Length != 3 {
+				t.Errorf("Material group should hold the triangle, but is %+v", group)
+			}
+		})
+	}
+}
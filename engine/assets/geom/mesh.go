@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"path"
 	"slices"
+	"strings"
 	"unsafe"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
@@ -47,6 +49,16 @@ func CreateMesh(verts Vertices, inds []uint32) *Mesh {
 	return mesh
 }
 
+// Loads a mesh from an .obj, .gltf, or .glb file, depending on its extension.
+func LoadMesh(assetPath string) (*Mesh, error) {
+	switch strings.ToLower(path.Ext(assetPath)) {
+	case ".gltf", ".glb":
+		return LoadGLTFMesh(assetPath)
+	default:
+		return LoadOBJMesh(assetPath)
+	}
+}
+
 func CreateWireMesh(verts Vertices, inds []uint32) *Mesh {
 	return &Mesh{
 		verts:         verts,
@@ -279,12 +291,34 @@ func (m *Mesh) DrawGroup(name string) error {
 	if !ok {
 		return fmt.Errorf("Group not found")
 	}
-	gl.DrawElementsWithOffset(m.primitiveType, int32(group.Length), gl.UNSIGNED_INT, uintptr(group.Offset)*unsafe.Sizeof(m.inds[0]))
+	m.DrawRange(group)
 	return nil
 }
 
+// Draws the indices in the given range, which doesn't have to be one of the mesh's named groups.
+func (m *Mesh) DrawRange(group Group) {
+	gl.DrawElementsWithOffset(m.primitiveType, int32(group.Length), gl.UNSIGNED_INT, uintptr(group.Offset)*unsafe.Sizeof(m.inds[0]))
+}
+
+// Approximates the amount of video memory used by the mesh's buffers in bytes.
+func (m *Mesh) GPUSize() int {
+	if !m.uploaded {
+		return 0
+	}
+	return len(m.verts.Pos)*m.verts.Stride() + len(m.inds)*int(unsafe.Sizeof(m.inds[0]))
+}
+
 func (m *Mesh) Free() {
 	gl.DeleteBuffers(1, &m.vertBuffer)
 	gl.DeleteBuffers(1, &m.idxBuffer)
 	gl.DeleteVertexArrays(1, &m.vertArray)
 }
+
+// Frees this mesh's buffers and takes over the data of another mesh, so that existing references to this mesh draw the new one.
+// The new data is uploaded the next time the mesh is bound.
+func (m *Mesh) Replace(newMesh *Mesh) {
+	if m.uploaded {
+		m.Free()
+	}
+	*m = *newMesh
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	_ "fmt"
 	"log"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -162,7 +164,9 @@ func LoadOBJMesh(path string) (*Mesh, error) {
 	}
 
 	meshGroups := make(map[string]Group)
-	for name, group := range obj.materials {
+	// Materials are visited in order so that loading the same file always gives the same mesh.
+	for _, name := range slices.Sorted(maps.Keys(obj.materials)) {
+		group := obj.materials[name]
 		meshGroup := Group{Offset: len(inds)}
 		for _, face := range group.faces {
 			// Add indices from faces
@@ -193,7 +197,7 @@ func LoadOBJMesh(path string) (*Mesh, error) {
 	for name, group := range meshGroups {
 		mesh.SetGroup(name, group)
 	}
-	mesh.Upload()
+	// The mesh is uploaded the first time it is bound, so that it can be loaded outside of the main thread.
 
 	log.Printf("Loaded OBJ file at %v.\n", path)
 	return mesh, err
@@ -12,6 +12,7 @@ const (
 	SIZEOF_TEXCOORD = 2 * 4
 	SIZEOF_NORMAL   = 3 * 4
 	SIZEOF_COLOR    = 4 * 4
+	SIZEOF_LAYER    = 4
 )
 
 const (
@@ -19,6 +20,7 @@ const (
 	ATTR_TEXCOORD
 	ATTR_NORMAL
 	ATTR_COLOR
+	ATTR_LAYER
 )
 
 type Vertices struct {
@@ -26,6 +28,7 @@ type Vertices struct {
 	TexCoord []mgl32.Vec2
 	Normal   []mgl32.Vec3
 	Color    []mgl32.Vec4
+	Layer    []float32 // Layer of the array texture to sample from.
 }
 
 func (v *Vertices) Stride() int {
@@ -42,6 +45,9 @@ func (v *Vertices) Stride() int {
 	if v.Color != nil && len(v.Color) > 0 {
 		stride += SIZEOF_COLOR
 	}
+	if v.Layer != nil && len(v.Layer) > 0 {
+		stride += SIZEOF_LAYER
+	}
 	return stride
 }
 
@@ -62,6 +68,9 @@ func (verts *Vertices) Flatten() ([]float32, error) {
 			data = append(data,
 				verts.Color[v].X(), verts.Color[v].Y(), verts.Color[v].Z(), verts.Color[v].W())
 		}
+		if verts.Layer != nil && v < len(verts.Layer) {
+			data = append(data, verts.Layer[v])
+		}
 	}
 	return data, nil
 }
@@ -89,4 +98,9 @@ func (verts *Vertices) BindAttributes() {
 		gl.VertexAttribPointerWithOffset(ATTR_COLOR, 4, gl.FLOAT, false, stride, ofs)
 		ofs += SIZEOF_COLOR
 	}
+	if verts.Layer != nil && len(verts.Layer) > 0 {
+		gl.EnableVertexAttribArray(ATTR_LAYER)
+		gl.VertexAttribPointerWithOffset(ATTR_LAYER, 1, gl.FLOAT, false, stride, ofs)
+		ofs += SIZEOF_LAYER
+	}
 }
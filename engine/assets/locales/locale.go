@@ -55,6 +55,11 @@ func (trans *Translation) UnmarshalJSON(b []byte) error {
 	return err
 }
 
+// Swaps in the strings of another translation.
+func (trans *Translation) Replace(newTrans *Translation) {
+	*trans = *newTrans
+}
+
 func LoadTranslation(assetPath string) (*Translation, error) {
 	trans, err := assets.LoadAndUnmarshalJSON[Translation](assetPath)
 	if err != nil {
@@ -2,6 +2,9 @@
 
 in vec2 vTexCoord;
 in vec3 vNormal;
+#ifdef BAKED_LIGHT
+in vec4 vColor;
+#endif
 
 uniform vec3 uLightDir;
 uniform vec3 uAmbientColor;
@@ -21,9 +24,13 @@ void main() {
         discard;
     }
     
-    //Calulate diffuse lighting
+    //Calulate diffuse lighting, then apply the light baked into the vertex colors
     float lightFactor = (dot(-uLightDir, normalize(vNormal)) + 1.0) / 2.0;
+#ifdef BAKED_LIGHT
+    diffuse.rgb *= (uAmbientColor + (vec3(1.0) - uAmbientColor) * lightFactor) * vColor.a + vColor.rgb;
+#else
     diffuse.rgb *= uAmbientColor + (vec3(1.0) - uAmbientColor) * lightFactor;
+#endif
     
     //Apply depth based fog
     float depth = gl_FragCoord.z / gl_FragCoord.w;
@@ -3,6 +3,9 @@
 layout(location = 0) in vec3 aPos;
 layout(location = 1) in vec2 aTexCoord;
 layout(location = 2) in vec3 aNormal;
+#ifdef BAKED_LIGHT
+layout(location = 3) in vec4 aColor; // Baked light: point lights in rgb, ambient occlusion in alpha. Only map meshes have it, so models are drawn without BAKED_LIGHT.
+#endif
 
 uniform mat4 uViewMatrix;
 uniform mat4 uProjMatrix;
@@ -11,10 +14,16 @@ uniform vec4 uSourceRect;
 
 out vec2 vTexCoord;
 out vec3 vNormal;
+#ifdef BAKED_LIGHT
+out vec4 vColor;
+#endif
 
 void main() {
     vTexCoord = uSourceRect.xy + vec2(aTexCoord.x * uSourceRect.z, (aTexCoord.y * uSourceRect.w) + uSourceRect.w);
     mat3 rot = mat3(uModelMatrix[0].xyz, uModelMatrix[1].xyz, uModelMatrix[2].xyz);
     vNormal = normalize(rot * aNormal);
+#ifdef BAKED_LIGHT
+    vColor = aColor;
+#endif
     gl_Position = uProjMatrix * uViewMatrix * uModelMatrix * vec4(aPos, 1);
 }
\ No newline at end of file
@@ -0,0 +1,35 @@
+#version 330
+
+in vec3 vTexCoord;
+in vec3 vNormal;
+in vec4 vColor;
+
+uniform vec3 uLightDir;
+uniform vec3 uAmbientColor;
+uniform sampler2DArray uTex;
+
+uniform float uFogStart;
+uniform float uFogLength;
+
+out vec4 oColor;
+
+void main() {
+    //Sample texture
+    vec4 diffuse = texture(uTex, vTexCoord);
+    
+    //Discard transparent pixels
+    if (diffuse.a < 0.5) {
+        discard;
+    }
+    
+    //Calulate diffuse lighting, then apply the light baked into the vertex colors
+    float lightFactor = (dot(-uLightDir, normalize(vNormal)) + 1.0) / 2.0;
+    diffuse.rgb *= (uAmbientColor + (vec3(1.0) - uAmbientColor) * lightFactor) * vColor.a + vColor.rgb;
+    
+    //Apply depth based fog
+    float depth = gl_FragCoord.z / gl_FragCoord.w;
+    float fog = 1.0 - clamp((depth - uFogStart) / uFogLength, 0.0, 1.0);
+    diffuse.rgb *= fog;
+
+    oColor = diffuse;
+}
\ No newline at end of file
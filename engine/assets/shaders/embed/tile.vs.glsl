@@ -0,0 +1,24 @@
+#version 330
+
+layout(location = 0) in vec3 aPos;
+layout(location = 1) in vec2 aTexCoord;
+layout(location = 2) in vec3 aNormal;
+layout(location = 3) in vec4 aColor; // Baked light: point lights in rgb, ambient occlusion in alpha. Only map meshes have it, so models are drawn with the map shader without BAKED_LIGHT.
+layout(location = 4) in float aLayer;
+
+uniform mat4 uViewMatrix;
+uniform mat4 uProjMatrix;
+uniform mat4 uModelMatrix;
+uniform int uLayerOffset;
+
+out vec3 vTexCoord;
+out vec3 vNormal;
+out vec4 vColor;
+
+void main() {
+    vTexCoord = vec3(aTexCoord, aLayer + float(uLayerOffset));
+    mat3 rot = mat3(uModelMatrix[0].xyz, uModelMatrix[1].xyz, uModelMatrix[2].xyz);
+    vNormal = normalize(rot * aNormal);
+    vColor = aColor;
+    gl_Position = uProjMatrix * uViewMatrix * uModelMatrix * vec4(aPos, 1);
+}
\ No newline at end of file
@@ -39,6 +39,7 @@ var (
 	UniformSrcRect      Uniform[mgl32.Vec4] = Uniform[mgl32.Vec4]{"uSourceRect"}
 	UniformFlipHorz     Uniform[bool]       = Uniform[bool]{"uFlipHorz"}
 	UniformNoTexture    Uniform[bool]       = Uniform[bool]{"uNoTexture"}
+	UniformLayerOffset  Uniform[int]        = Uniform[int]{"uLayerOffset"}
 )
 
 var (
@@ -48,6 +49,16 @@ var (
 	//go:embed embed/map.fs.glsl
 	mapFragShaderSrc string
 
+	// The map shader compiled without BAKED_LIGHT, for models that are placed in the map.
+	// Their vertex colors are plain white instead of baked light, which would brighten them.
+	ModelShader *Shader
+
+	TileShader *Shader
+	//go:embed embed/tile.vs.glsl
+	tileVertShaderSrc string
+	//go:embed embed/tile.fs.glsl
+	tileFragShaderSrc string
+
 	SkyShader *Shader
 	//go:embed embed/sky.vs.glsl
 	skyVertShaderSrc string
@@ -88,11 +99,21 @@ type Shader struct {
 func Init() {
 	var err error
 
-	MapShader, err = CreateShader(mapVertShaderSrc, mapFragShaderSrc)
+	MapShader, err = CreateShader(addDefines(mapVertShaderSrc, "BAKED_LIGHT"), addDefines(mapFragShaderSrc, "BAKED_LIGHT"))
 	if err != nil {
 		log.Fatalln("Couldn't compile map shader: ", err)
 	}
 
+	ModelShader, err = CreateShader(mapVertShaderSrc, mapFragShaderSrc)
+	if err != nil {
+		log.Fatalln("Couldn't compile model shader: ", err)
+	}
+
+	TileShader, err = CreateShader(tileVertShaderSrc, tileFragShaderSrc)
+	if err != nil {
+		log.Fatalln("Couldn't compile tile shader: ", err)
+	}
+
 	SkyShader, err = CreateShader(skyVertShaderSrc, skyFragShaderSrc)
 	if err != nil {
 		log.Fatalln("Couldn't compile sky shader: ", err)
@@ -122,6 +143,8 @@ func Init() {
 // Free built-in shaders.
 func Free() {
 	MapShader.Free()
+	ModelShader.Free()
+	TileShader.Free()
 	DebugShader.Free()
 	SpriteShader.Free()
 	ParticlesShader.Free()
@@ -245,6 +268,18 @@ func (s *Shader) SetUniformVec4(u Uniform[mgl32.Vec4], val mgl32.Vec4) error {
 	return nil
 }
 
+// Defines the given macros in the shader source, after its #version line, which has to come first.
+func addDefines(src string, defines ...string) string {
+	version, rest, _ := strings.Cut(src, "\n")
+	var builder strings.Builder
+	builder.WriteString(version + "\n")
+	for _, define := range defines {
+		builder.WriteString("#define " + define + "\n")
+	}
+	builder.WriteString(rest)
+	return builder.String()
+}
+
 func compileShader(src string, sType uint32) (uint32, error) {
 	shader := gl.CreateShader(sType)
 
@@ -0,0 +1,38 @@
+package assets
+
+import (
+	"os"
+	"time"
+)
+
+// Identifies the version of an asset file on disk, for noticing when it has been edited.
+type FileStamp struct {
+	Path    string // File system path in the highest priority layer that has the asset.
+	Layer   string // Name of that layer.
+	ModTime time.Time
+	Size    int64
+}
+
+// Returns the stamp of the file that the asset path currently resolves to.
+// Missing files give a zero stamp, so a file appearing in (or disappearing from) any layer also counts as a change.
+func Stamp(assetPath string) FileStamp {
+	fsPath, layer, err := ResolvePath(assetPath)
+	if err != nil {
+		return FileStamp{}
+	}
+	info, err := os.Stat(fsPath)
+	if err != nil {
+		return FileStamp{}
+	}
+	return FileStamp{
+		Path:    fsPath,
+		Layer:   layer.Name,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+	}
+}
+
+// Returns true if both stamps describe the same version of a file from the same layer, regardless of where the layer's directory is.
+func (stamp FileStamp) SameVersion(other FileStamp) bool {
+	return stamp.Layer == other.Layer && stamp.ModTime.Equal(other.ModTime) && stamp.Size == other.Size
+}
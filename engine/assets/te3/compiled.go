@@ -0,0 +1,509 @@
+package te3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
+)
+
+const (
+	COMPILED_EXTENSION = ".te3c"
+	COMPILED_MAGIC     = "TE3C"
+	COMPILED_VERSION   = 6 // Increase when the layout or the mesh generation changes, so that outdated files are ignored instead of misread.
+)
+
+// Kinds of collision shapes stored in compiled maps.
+const (
+	compiledShapeBox uint8 = iota
+	compiledShapeCylinder
+	compiledShapeMesh
+)
+
+// Holds the results of the expensive parts of loading a map, so that they can be saved and loaded quickly.
+type CompiledMap struct {
+	TE3File        *TE3File          // The map with its prefabs stamped in and its invisible tiles already erased.
+	Mesh           *geom.Mesh        // Geometry of the tiles, grouped by texture path like BuildMeshFromShapes, with light baked into its vertex colors.
+	TriMap         TriMap            // Maps a flattened tile index to its triangles in the mesh.
+	TileShapes     []collision.Shape // Collision shape of each tile, indexed by flattened grid position. Nil for tiles without collision.
+	InvisibleTiles []int             // Flattened grid positions of tiles that were erased because of their invisible textures.
+	KillzoneTiles  []int             // Flattened grid positions of tiles with killzone textures.
+	PVS            *PVS              // Which parts of the map can be seen from each other. May be nil.
+	Inputs         []CompiledInput   // The files that the map was compiled from, for noticing when it is out of date.
+}
+
+// The state of one of the files that a map was compiled from when it was compiled.
+type CompiledInput struct {
+	AssetPath string
+	Stamp     assets.FileStamp // Zero if the file was missing, since some inputs are optional.
+}
+
+// Records the current state of the files at the given asset paths, sorted by path and without duplicates.
+func StampCompiledInputs(assetPaths []string) []CompiledInput {
+	assetPaths = slices.Compact(slices.Sorted(slices.Values(assetPaths)))
+	inputs := make([]CompiledInput, len(assetPaths))
+	for i, assetPath := range assetPaths {
+		inputs[i] = CompiledInput{AssetPath: assetPath, Stamp: assets.Stamp(assetPath)}
+	}
+	return inputs
+}
+
+// Returns the path of the first input that has changed since the map was compiled, or false if none have.
+func (compiled *CompiledMap) ChangedInput() (string, bool) {
+	for _, input := range compiled.Inputs {
+		if !assets.Stamp(input.AssetPath).SameVersion(input.Stamp) {
+			return input.AssetPath, true
+		}
+	}
+	return "", false
+}
+
+// Returns the path of the compiled version of the map at the given path.
+func CompiledPath(mapPath string) string {
+	return strings.TrimSuffix(mapPath, path.Ext(mapPath)) + COMPILED_EXTENSION
+}
+
+// Returns true if the map has a compiled version in the same layer as the map file or a higher priority one.
+// A compiled map in a lower layer was made from a different map file, such as the base game's version of a map that a mod changes.
+func HasCompiledMap(mapPath string) bool {
+	_, compiledLayer, err := assets.ResolvePath(CompiledPath(mapPath))
+	if err != nil {
+		return false
+	}
+	_, mapLayer, err := assets.ResolvePath(mapPath)
+	if err != nil {
+		return true
+	}
+	layers := assets.Layers()
+	return slices.Index(layers, compiledLayer) >= slices.Index(layers, mapLayer)
+}
+
+// Loads the compiled version of the map at the given path (which should be the path of the .te3 file).
+// Fails if any of the files the map was compiled from have changed since, unless the map file itself is missing,
+// in which case the compiled map is all there is.
+func LoadCompiledMap(mapPath string) (*CompiledMap, error) {
+	file, err := assets.GetFile(CompiledPath(mapPath))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled, err := ReadCompiledMap(fileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not load compiled map %v: %w", CompiledPath(mapPath), err)
+	}
+	if assets.Stamp(mapPath).Path != "" {
+		if changed, ok := compiled.ChangedInput(); ok {
+			return nil, fmt.Errorf("compiled map %v is out of date, since %v has changed", CompiledPath(mapPath), changed)
+		}
+	}
+	compiled.TE3File.filePath = mapPath
+	log.Println("Loaded compiled map", CompiledPath(mapPath))
+	return compiled, nil
+}
+
+// Writes little endian values for compiled maps, keeping the first error.
+type compiledWriter struct {
+	*bufio.Writer
+	err error
+}
+
+func (w *compiledWriter) put(values ...any) {
+	for _, value := range values {
+		if w.err == nil {
+			w.err = binary.Write(w.Writer, binary.LittleEndian, value)
+		}
+	}
+}
+
+func (w *compiledWriter) putString(text string) {
+	w.put(uint32(len(text)))
+	if w.err == nil {
+		_, w.err = w.WriteString(text)
+	}
+}
+
+func (w *compiledWriter) putInts(values []int) {
+	w.put(uint32(len(values)))
+	for _, value := range values {
+		w.put(int32(value))
+	}
+}
+
+// Writes the compiled map in a binary format. The output only depends on the map's contents and the stamps of its inputs,
+// so compiling the same files twice gives the same file.
+func (compiled *CompiledMap) Write(output io.Writer) error {
+	w := &compiledWriter{Writer: bufio.NewWriter(output)}
+	w.WriteString(COMPILED_MAGIC)
+	w.put(uint32(COMPILED_VERSION))
+
+	// Inputs come first, so that they could be checked without reading the rest.
+	w.put(uint32(len(compiled.Inputs)))
+	for _, input := range compiled.Inputs {
+		w.putString(input.AssetPath)
+		w.putString(input.Stamp.Layer)
+		var modTime int64
+		if input.Stamp.Layer != "" {
+			modTime = input.Stamp.ModTime.UnixNano()
+		}
+		w.put(modTime, input.Stamp.Size)
+	}
+
+	// Map file
+	te3 := compiled.TE3File
+	w.putString(te3.Meta.Editor)
+	w.putString(te3.Meta.Version)
+
+	w.put(int32(te3.Tiles.Width), int32(te3.Tiles.Height), int32(te3.Tiles.Length))
+	for _, paths := range [][]string{te3.Tiles.Textures, te3.Tiles.Shapes} {
+		w.put(uint32(len(paths)))
+		for _, p := range paths {
+			w.putString(p)
+		}
+	}
+	w.put(te3.Tiles.Data)
+
+	w.put(uint32(len(te3.Ents)))
+	for _, ent := range te3.Ents {
+		w.put(ent.Angles, ent.Color, ent.Position, ent.Radius, ent.Display)
+		w.putString(ent.Texture)
+		w.putString(ent.Model)
+		w.put(uint32(len(ent.Properties)))
+		for _, key := range slices.Sorted(maps.Keys(ent.Properties)) {
+			w.putString(key)
+			w.putString(ent.Properties[key])
+		}
+	}
+
+	// Mesh
+	verts := compiled.Mesh.Verts()
+	if verts.Color == nil {
+		verts.Color = make([]mgl32.Vec4, len(verts.Pos))
+		for v := range verts.Color {
+			verts.Color[v] = mgl32.Vec4{0.0, 0.0, 0.0, 1.0}
+		}
+	}
+	w.put(uint32(len(verts.Pos)), verts.Pos, verts.TexCoord, verts.Normal, verts.Color)
+	w.put(uint32(len(compiled.Mesh.Inds())), compiled.Mesh.Inds())
+	groupNames := compiled.Mesh.GroupNames()
+	slices.Sort(groupNames)
+	w.put(uint32(len(groupNames)))
+	for _, name := range groupNames {
+		group := compiled.Mesh.Group(name)
+		w.putString(name)
+		w.put(uint32(group.Offset), uint32(group.Length))
+	}
+
+	w.put(uint32(len(compiled.TriMap)))
+	for _, triangles := range compiled.TriMap {
+		w.putInts(triangles)
+	}
+
+	// Collision shapes are stored once and referred to by index, since many tiles share the same ones.
+	shapeTable := make([][]byte, 0, 8)
+	shapeIndices := make(map[string]int)
+	tileShapes := make([]int, len(compiled.TileShapes))
+	for t, shape := range compiled.TileShapes {
+		tileShapes[t] = -1
+		if shape == nil {
+			continue
+		}
+		shapeBytes, err := encodeCompiledShape(shape)
+		if err != nil {
+			return fmt.Errorf("tile %v: %w", t, err)
+		}
+		index, ok := shapeIndices[string(shapeBytes)]
+		if !ok {
+			index = len(shapeTable)
+			shapeIndices[string(shapeBytes)] = index
+			shapeTable = append(shapeTable, shapeBytes)
+		}
+		tileShapes[t] = index
+	}
+	w.put(uint32(len(shapeTable)))
+	for _, shapeBytes := range shapeTable {
+		w.put(shapeBytes)
+	}
+	w.putInts(tileShapes)
+
+	w.putInts(compiled.InvisibleTiles)
+	w.putInts(compiled.KillzoneTiles)
+
+	if compiled.PVS != nil {
+		w.put(uint32(len(compiled.PVS.bits)), compiled.PVS.bits)
+	} else {
+		w.put(uint32(0))
+	}
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.Flush()
+}
+
+func encodeCompiledShape(shape collision.Shape) ([]byte, error) {
+	var buffer bytes.Buffer
+	put := func(values ...any) {
+		for _, value := range values {
+			binary.Write(&buffer, binary.LittleEndian, value)
+		}
+	}
+	switch shape := shape.(type) {
+	case collision.Box:
+		put(compiledShapeBox, shape.Extents().Min, shape.Extents().Max)
+	case collision.Cylinder:
+		put(compiledShapeCylinder, shape.Radius(), shape.Height())
+	case collision.Mesh:
+		put(compiledShapeMesh, uint32(len(shape.Triangles())), shape.Triangles())
+	default:
+		return nil, fmt.Errorf("cannot store collision shape of type %v", shape)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Reads little endian values from compiled maps, keeping the first error.
+type compiledReader struct {
+	*bytes.Reader
+	err error
+}
+
+func (r *compiledReader) read(values ...any) {
+	for _, value := range values {
+		if r.err == nil {
+			r.err = binary.Read(r.Reader, binary.LittleEndian, value)
+		}
+	}
+}
+
+// Reads the number of elements in a list, checking that there are enough bytes left for them.
+func (r *compiledReader) count(elemSize int) int {
+	var count uint32
+	r.read(&count)
+	if r.err == nil && int64(count)*int64(elemSize) > int64(r.Len()) {
+		r.err = fmt.Errorf("list of %v elements is longer than the rest of the file", count)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(count)
+}
+
+func (r *compiledReader) string() string {
+	text := make([]byte, r.count(1))
+	r.read(text)
+	return string(text)
+}
+
+func (r *compiledReader) ints() []int {
+	values := make([]int32, r.count(4))
+	if len(values) == 0 {
+		return nil
+	}
+	r.read(values)
+	ints := make([]int, len(values))
+	for i, value := range values {
+		ints[i] = int(value)
+	}
+	return ints
+}
+
+// Parses a compiled map written by CompiledMap.Write.
+func ReadCompiledMap(data []byte) (*CompiledMap, error) {
+	r := &compiledReader{Reader: bytes.NewReader(data)}
+
+	magic := make([]byte, len(COMPILED_MAGIC))
+	var version uint32
+	r.read(magic, &version)
+	if r.err != nil || string(magic) != COMPILED_MAGIC {
+		return nil, fmt.Errorf("not a compiled map")
+	}
+	if version != COMPILED_VERSION {
+		return nil, fmt.Errorf("compiled map has format version %v instead of %v; it needs to be compiled again", version, COMPILED_VERSION)
+	}
+
+	compiled := &CompiledMap{TE3File: &TE3File{}}
+
+	compiled.Inputs = make([]CompiledInput, r.count(24))
+	for i := range compiled.Inputs {
+		input := &compiled.Inputs[i]
+		input.AssetPath = r.string()
+		input.Stamp.Layer = r.string()
+		var modTime int64
+		r.read(&modTime, &input.Stamp.Size)
+		if input.Stamp.Layer != "" {
+			input.Stamp.ModTime = time.Unix(0, modTime)
+		}
+	}
+
+	// Map file
+	te3 := compiled.TE3File
+	te3.Meta.Editor = r.string()
+	te3.Meta.Version = r.string()
+
+	var width, height, length int32
+	r.read(&width, &height, &length)
+	te3.Tiles.Width, te3.Tiles.Height, te3.Tiles.Length = int(width), int(height), int(length)
+	if r.err == nil && !ValidGridSize(te3.Tiles.Width, te3.Tiles.Height, te3.Tiles.Length) {
+		return nil, fmt.Errorf("invalid grid size %vx%vx%v", width, height, length)
+	}
+	for _, paths := range []*[]string{&te3.Tiles.Textures, &te3.Tiles.Shapes} {
+		*paths = make([]string, r.count(4))
+		for i := range *paths {
+			(*paths)[i] = r.string()
+		}
+	}
+	te3.Tiles.Data = make([]Tile, te3.Tiles.Width*te3.Tiles.Height*te3.Tiles.Length)
+	r.read(te3.Tiles.Data)
+	if r.err != nil {
+		return nil, r.err
+	}
+	for t, tile := range te3.Tiles.Data {
+		if err := te3.Tiles.checkTile(t, tile); err != nil {
+			return nil, err
+		}
+	}
+
+	te3.Ents = make([]Ent, r.count(1))
+	for e := range te3.Ents {
+		ent := &te3.Ents[e]
+		r.read(&ent.Angles, &ent.Color, &ent.Position, &ent.Radius, &ent.Display)
+		ent.Texture = r.string()
+		ent.Model = r.string()
+		propCount := r.count(8)
+		ent.Properties = make(map[string]string, propCount)
+		for range propCount {
+			key := r.string()
+			ent.Properties[key] = r.string()
+		}
+	}
+
+	// Mesh
+	var verts geom.Vertices
+	vertCount := r.count(geom.SIZEOF_POS + geom.SIZEOF_TEXCOORD + geom.SIZEOF_NORMAL + geom.SIZEOF_COLOR)
+	verts.Pos = make([]mgl32.Vec3, vertCount)
+	verts.TexCoord = make([]mgl32.Vec2, vertCount)
+	verts.Normal = make([]mgl32.Vec3, vertCount)
+	verts.Color = make([]mgl32.Vec4, vertCount)
+	r.read(verts.Pos, verts.TexCoord, verts.Normal, verts.Color)
+	inds := make([]uint32, r.count(4))
+	r.read(inds)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(inds)%3 != 0 {
+		return nil, fmt.Errorf("mesh has %v indices, which isn't a multiple of 3", len(inds))
+	}
+	for _, ind := range inds {
+		if int(ind) >= vertCount {
+			return nil, fmt.Errorf("mesh index %v is out of range", ind)
+		}
+	}
+	compiled.Mesh = geom.CreateMesh(verts, inds)
+	groupCount := r.count(12)
+	for range groupCount {
+		name := r.string()
+		var offset, length uint32
+		r.read(&offset, &length)
+		if r.err == nil && int(offset)+int(length) > len(inds) {
+			return nil, fmt.Errorf("mesh group %v is out of range", name)
+		}
+		compiled.Mesh.SetGroup(name, geom.Group{Offset: int(offset), Length: int(length)})
+	}
+
+	compiled.TriMap = make(TriMap, r.count(4))
+	for t := range compiled.TriMap {
+		compiled.TriMap[t] = r.ints()
+		for _, tri := range compiled.TriMap[t] {
+			if tri < 0 || tri >= len(inds)/3 {
+				return nil, fmt.Errorf("triangle %v of tile %v is out of range", tri, t)
+			}
+		}
+	}
+	if r.err == nil && len(compiled.TriMap) != len(te3.Tiles.Data) {
+		return nil, fmt.Errorf("triangle map has %v tiles instead of %v", len(compiled.TriMap), len(te3.Tiles.Data))
+	}
+
+	// Collision shapes
+	shapeTable := make([]collision.Shape, r.count(1))
+	for s := range shapeTable {
+		shapeTable[s] = r.shape()
+	}
+	tileShapes := r.ints()
+	if r.err == nil && len(tileShapes) != len(te3.Tiles.Data) {
+		return nil, fmt.Errorf("there are collision shapes for %v tiles instead of %v", len(tileShapes), len(te3.Tiles.Data))
+	}
+	compiled.TileShapes = make([]collision.Shape, len(tileShapes))
+	for t, index := range tileShapes {
+		if index >= len(shapeTable) {
+			return nil, fmt.Errorf("collision shape %v of tile %v is out of range", index, t)
+		}
+		if index >= 0 {
+			compiled.TileShapes[t] = shapeTable[index]
+		}
+	}
+
+	compiled.InvisibleTiles = r.ints()
+	compiled.KillzoneTiles = r.ints()
+
+	if pvsBits := make([]uint64, r.count(8)); len(pvsBits) > 0 {
+		r.read(pvsBits)
+		compiled.PVS = NewPVS(&te3.Tiles)
+		if len(pvsBits) != len(compiled.PVS.bits) {
+			return nil, fmt.Errorf("potentially visible set has %v words instead of %v", len(pvsBits), len(compiled.PVS.bits))
+		}
+		compiled.PVS.bits = pvsBits
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	for _, t := range slices.Concat(compiled.InvisibleTiles, compiled.KillzoneTiles) {
+		if t < 0 || t >= len(te3.Tiles.Data) {
+			return nil, fmt.Errorf("tile %v is out of range", t)
+		}
+	}
+
+	return compiled, nil
+}
+
+func (r *compiledReader) shape() collision.Shape {
+	var kind uint8
+	r.read(&kind)
+	if r.err != nil {
+		return nil
+	}
+	switch kind {
+	case compiledShapeBox:
+		var box math2.Box
+		r.read(&box.Min, &box.Max)
+		return collision.NewBox(box)
+	case compiledShapeCylinder:
+		var radius, height float32
+		r.read(&radius, &height)
+		return collision.NewCylinder(radius, height)
+	case compiledShapeMesh:
+		triangles := make([]math2.Triangle, r.count(9*4))
+		r.read(triangles)
+		return collision.NewMeshFromTriangles(triangles)
+	default:
+		r.err = fmt.Errorf("unknown collision shape kind %v", kind)
+		return nil
+	}
+}
@@ -0,0 +1,224 @@
+package te3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
+)
+
+const testMapPath = "assets/maps/compiled_test.te3"
+
+// Makes a compiled map of two cubes with an ent, using every kind of collision shape.
+func testCompiledMap() *CompiledMap {
+	te3File := &TE3File{
+		Tiles: Tiles{
+			Width: 3, Height: 1, Length: 1,
+			Data:     []Tile{{TextureIDs: [2]TextureID{0, 0}}, {TextureIDs: [2]TextureID{1, 1}}, {ShapeID: -1}},
+			Textures: []string{"assets/textures/wall.png", "assets/textures/floor.png"},
+			Shapes:   []string{"assets/models/shapes/cube.obj"},
+		},
+		Ents: []Ent{{
+			Angles:     [3]float32{0.0, 90.0, 0.0},
+			Color:      [3]uint8{255, 128, 0},
+			Position:   [3]float32{5.0, 1.0, 1.0},
+			Radius:     1.0,
+			Display:    ENT_DISPLAY_SPRITE,
+			Texture:    "assets/textures/sprites/medkit.png",
+			Properties: map[string]string{"type": "item", "name": "medkit"},
+		}},
+	}
+	te3File.Meta.Editor = "test"
+	te3File.Meta.Version = "0.3"
+	compiled := &CompiledMap{TE3File: te3File}
+	compiled.Mesh, compiled.TriMap = te3File.BuildMeshFromShapes([]*geom.Mesh{cubeMesh()})
+	compiled.Mesh = te3File.BakeLight(compiled.Mesh, compiled.TriMap, func(tile Tile) bool { return true })
+	compiled.PVS = te3File.ComputePVS(func(tile Tile) bool { return true })
+	compiled.TileShapes = []collision.Shape{
+		collision.NewBox(math2.BoxFromRadius(1.0)),
+		collision.NewMeshFromTriangles([]math2.Triangle{{{0.0, 0.0, 0.0}, {1.0, 0.0, 0.0}, {0.0, 0.0, 1.0}}}),
+		collision.NewCylinder(1.0, 2.0),
+	}
+	compiled.InvisibleTiles = []int{2}
+	compiled.KillzoneTiles = []int{1}
+	return compiled
+}
+
+// Puts a map file and its compiled version into a new mod layer, returning the layer's directory.
+func writeTestCompiledMap(t *testing.T, compiled *CompiledMap) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := assets.SetModLayers([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { assets.SetModLayers(nil) })
+
+	mapFile := filepath.Join(dir, filepath.FromSlash(testMapPath))
+	if err := os.MkdirAll(filepath.Dir(mapFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mapFile, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	compiled.Inputs = StampCompiledInputs([]string{testMapPath, "assets/missing.json"})
+
+	var output bytes.Buffer
+	if err := compiled.Write(&output); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(CompiledPath(testMapPath))), output.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCompiledMapRoundTrip(t *testing.T) {
+	original := testCompiledMap()
+	writeTestCompiledMap(t, original)
+	if !HasCompiledMap(testMapPath) {
+		t.Fatal("Compiled map wasn't found")
+	}
+	loaded, err := LoadCompiledMap(testMapPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded.TE3File.Tiles.Data, original.TE3File.Tiles.Data) ||
+		!slices.Equal(loaded.TE3File.Tiles.Textures, original.TE3File.Tiles.Textures) ||
+		!slices.Equal(loaded.TE3File.Tiles.Shapes, original.TE3File.Tiles.Shapes) {
+		t.Errorf("Tiles changed from %+v to %+v", original.TE3File.Tiles, loaded.TE3File.Tiles)
+	}
+	if !reflect.DeepEqual(loaded.TE3File.Ents, original.TE3File.Ents) {
+		t.Errorf("Ents changed from %+v to %+v", original.TE3File.Ents, loaded.TE3File.Ents)
+	}
+	if loaded.TE3File.Meta != original.TE3File.Meta || loaded.TE3File.FilePath() != testMapPath {
+		t.Errorf("Map file has meta %+v and path %v", loaded.TE3File.Meta, loaded.TE3File.FilePath())
+	}
+	if !reflect.DeepEqual(loaded.Mesh.Verts(), original.Mesh.Verts()) || !slices.Equal(loaded.Mesh.Inds(), original.Mesh.Inds()) {
+		t.Error("Mesh vertices or indices changed")
+	}
+	for _, name := range original.Mesh.GroupNames() {
+		if loaded.Mesh.Group(name) != original.Mesh.Group(name) {
+			t.Errorf("Mesh group %v changed from %v to %v", name, original.Mesh.Group(name), loaded.Mesh.Group(name))
+		}
+	}
+	if !reflect.DeepEqual(loaded.TriMap, original.TriMap) {
+		t.Errorf("Triangle map changed from %v to %v", original.TriMap, loaded.TriMap)
+	}
+	if !reflect.DeepEqual(loaded.TileShapes, original.TileShapes) {
+		t.Errorf("Tile shapes changed from %v to %v", original.TileShapes, loaded.TileShapes)
+	}
+	if !slices.Equal(loaded.InvisibleTiles, original.InvisibleTiles) || !slices.Equal(loaded.KillzoneTiles, original.KillzoneTiles) {
+		t.Errorf("Invisible tiles %v and killzone tiles %v changed", loaded.InvisibleTiles, loaded.KillzoneTiles)
+	}
+	if loaded.PVS == nil || !slices.Equal(loaded.PVS.bits, original.PVS.bits) {
+		t.Error("Potentially visible set changed")
+	}
+	if len(loaded.Inputs) != len(original.Inputs) {
+		t.Fatalf("Has %v inputs instead of %v", len(loaded.Inputs), len(original.Inputs))
+	}
+	for i, input := range loaded.Inputs {
+		if input.AssetPath != original.Inputs[i].AssetPath || !input.Stamp.SameVersion(original.Inputs[i].Stamp) {
+			t.Errorf("Input %+v changed to %+v", original.Inputs[i], input)
+		}
+	}
+}
+
+func TestCompiledMapOutOfDate(t *testing.T) {
+	t.Run("edited input", func(t *testing.T) {
+		dir := writeTestCompiledMap(t, testCompiledMap())
+		mapFile := filepath.Join(dir, filepath.FromSlash(testMapPath))
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(mapFile, later, later); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCompiledMap(testMapPath); err == nil {
+			t.Error("Loaded a compiled map whose map file was edited")
+		}
+	})
+
+	t.Run("added input", func(t *testing.T) {
+		dir := writeTestCompiledMap(t, testCompiledMap())
+		if err := os.WriteFile(filepath.Join(dir, "assets", "missing.json"), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCompiledMap(testMapPath); err == nil {
+			t.Error("Loaded a compiled map when one of its optional inputs was added")
+		}
+	})
+
+	t.Run("map file in higher layer", func(t *testing.T) {
+		lower := writeTestCompiledMap(t, testCompiledMap())
+		higher := t.TempDir()
+		mapFile := filepath.Join(higher, filepath.FromSlash(testMapPath))
+		if err := os.MkdirAll(filepath.Dir(mapFile), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(mapFile, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := assets.SetModLayers([]string{lower, higher}); err != nil {
+			t.Fatal(err)
+		}
+		if HasCompiledMap(testMapPath) {
+			t.Error("Used a compiled map from a lower layer than its map file")
+		}
+	})
+}
+
+func TestReadTruncatedCompiledMap(t *testing.T) {
+	var output bytes.Buffer
+	if err := testCompiledMap().Write(&output); err != nil {
+		t.Fatal(err)
+	}
+	data := output.Bytes()
+	if _, err := ReadCompiledMap(data); err != nil {
+		t.Fatalf("Could not read the whole map: %v", err)
+	}
+	for length := range len(data) {
+		if _, err := ReadCompiledMap(data[:length]); err == nil {
+			t.Fatalf("Read a compiled map cut off after %v of %v bytes", length, len(data))
+		}
+	}
+}
+
+func TestReadCompiledMapBadGridSize(t *testing.T) {
+	var output bytes.Buffer
+	if err := testCompiledMap().Write(&output); err != nil {
+		t.Fatal(err)
+	}
+	// The grid size comes right after the map's format version.
+	var header bytes.Buffer
+	binary.Write(&header, binary.LittleEndian, uint32(3))
+	header.WriteString("0.3")
+	binary.Write(&header, binary.LittleEndian, []int32{3, 1, 1})
+	offset := bytes.Index(output.Bytes(), header.Bytes())
+	if offset < 0 {
+		t.Fatal("Grid size not found")
+	}
+	offset += header.Len() - 12
+
+	for _, size := range [][3]int32{
+		{1 << 21, 1 << 21, 1 << 21}, // Overflows when multiplied
+		{1 << 30, 1 << 30, 4},
+		{3, 0, 1},
+		{-3, -1, 1},
+	} {
+		data := bytes.Clone(output.Bytes())
+		for i, side := range size {
+			binary.LittleEndian.PutUint32(data[offset+i*4:], uint32(side))
+		}
+		if _, err := ReadCompiledMap(data); err == nil {
+			t.Errorf("Read a compiled map with a grid size of %v", size)
+		}
+	}
+}
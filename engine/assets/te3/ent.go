@@ -21,8 +21,8 @@ type Ent struct {
 	Color      [3]uint8          `json:"color"`
 	Position   [3]float32        `json:"position"`
 	Radius     float32           `json:"radius"`
-	Texture    string            `json:"texture"`
-	Model      string            `json:"model"`
+	Texture    string            `json:"texture,omitempty"`
+	Model      string            `json:"model,omitempty"`
 	Display    EntDisplay        `json:"display"`
 	Properties map[string]string `json:"properties"`
 }
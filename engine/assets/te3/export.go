@@ -0,0 +1,203 @@
+package te3
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
+)
+
+const (
+	OBJ_COLLISION_OBJECT  = "collision" // Name of the object and material that collision triangles are written with.
+	CYLINDER_EXPORT_SIDES = 16          // Number of flat sides that cylinder collision shapes are approximated with.
+)
+
+// What to write with WriteOBJ.
+type OBJExport struct {
+	Mesh          *geom.Mesh       // Each of the mesh's groups becomes a material named after the group, such as those made by BuildMeshFromShapes.
+	Collision     []math2.Triangle // Written as a separate object when there are any.
+	MTLName       string           // File name of the material library, which the .obj file refers to.
+	TexturePrefix string           // Put in front of each group name to make the texture path of its material.
+}
+
+// Writes the mesh and collision triangles as a Wavefront .obj file, along with its .mtl material library.
+// Vertex colors are written after the positions, which most modeling programs understand. They hold the light baked by BakeLight
+// the way the map shader applies it to a surface facing the sun: the ambient occlusion plus the light from light ents.
+// The mesh should be grouped by texture path like CompiledMap.Mesh, rather than batched into a texture atlas by BatchMesh.
+// Nothing here needs a GL context.
+func WriteOBJ(objOut, mtlOut io.Writer, export OBJExport) error {
+	obj, mtl := bufio.NewWriter(objOut), bufio.NewWriter(mtlOut)
+
+	// Material names can't have spaces in them.
+	materialName := func(groupName string) string {
+		return strings.Join(strings.Fields(groupName), "_")
+	}
+
+	fmt.Fprintf(obj, "mtllib %v\n", export.MTLName)
+	vertCount := 0
+	if mesh := export.Mesh; mesh != nil {
+		verts := mesh.Verts()
+		hasTexCoords := len(verts.TexCoord) == len(verts.Pos)
+		hasNormals := len(verts.Normal) == len(verts.Pos)
+		hasColors := len(verts.Color) == len(verts.Pos)
+
+		fmt.Fprintln(obj, "o tiles")
+		for i, pos := range verts.Pos {
+			fmt.Fprintf(obj, "v %v %v %v", pos[0], pos[1], pos[2])
+			if hasColors {
+				ao, light := verts.Color[i].W(), verts.Color[i].Vec3()
+				fmt.Fprintf(obj, " %v %v %v", min(ao+light[0], 1.0), min(ao+light[1], 1.0), min(ao+light[2], 1.0))
+			}
+			fmt.Fprintln(obj)
+		}
+		if hasTexCoords {
+			for _, uv := range verts.TexCoord {
+				fmt.Fprintf(obj, "vt %v %v\n", uv[0], uv[1])
+			}
+		}
+		if hasNormals {
+			for _, normal := range verts.Normal {
+				fmt.Fprintf(obj, "vn %v %v %v\n", normal[0], normal[1], normal[2])
+			}
+		}
+
+		// OBJ indices start at 1, and the texture coordinate and normal of a vertex share its index.
+		faceVertex := func(index uint32) string {
+			i := index + 1
+			switch {
+			case hasTexCoords && hasNormals:
+				return fmt.Sprintf("%v/%v/%v", i, i, i)
+			case hasTexCoords:
+				return fmt.Sprintf("%v/%v", i, i)
+			case hasNormals:
+				return fmt.Sprintf("%v//%v", i, i)
+			default:
+				return fmt.Sprint(i)
+			}
+		}
+		inds := mesh.Inds()
+		// Groups are written in order so that exporting the same map always gives the same files.
+		for _, name := range slices.Sorted(slices.Values(mesh.GroupNames())) {
+			group := mesh.Group(name)
+			fmt.Fprintf(obj, "usemtl %v\n", materialName(name))
+			for t := group.Offset; t+2 < group.Offset+group.Length; t += 3 {
+				fmt.Fprintf(obj, "f %v %v %v\n", faceVertex(inds[t]), faceVertex(inds[t+1]), faceVertex(inds[t+2]))
+			}
+
+			fmt.Fprintf(mtl, "newmtl %v\n", materialName(name))
+			fmt.Fprintln(mtl, "Kd 1 1 1")
+			fmt.Fprintf(mtl, "map_Kd %v%v\n\n", export.TexturePrefix, name)
+		}
+		vertCount = len(verts.Pos)
+	}
+
+	if len(export.Collision) > 0 {
+		fmt.Fprintf(obj, "o %v\n", OBJ_COLLISION_OBJECT)
+		for _, triangle := range export.Collision {
+			for _, pos := range triangle {
+				fmt.Fprintf(obj, "v %v %v %v\n", pos[0], pos[1], pos[2])
+			}
+		}
+		fmt.Fprintf(obj, "usemtl %v\n", OBJ_COLLISION_OBJECT)
+		for t := range export.Collision {
+			first := vertCount + t*3 + 1
+			fmt.Fprintf(obj, "f %v %v %v\n", first, first+1, first+2)
+		}
+
+		fmt.Fprintf(mtl, "newmtl %v\n", OBJ_COLLISION_OBJECT)
+		fmt.Fprintln(mtl, "Kd 1 0 1")
+		fmt.Fprintln(mtl, "d 0.5")
+	}
+
+	if err := obj.Flush(); err != nil {
+		return err
+	}
+	return mtl.Flush()
+}
+
+// Returns the triangles of the tiles' collision shapes, indexed by flattened grid position like CompiledMap.TileShapes,
+// in the same place that the shapes have in the game's collision grid. Cylinders are given flat sides.
+// Shapes that aren't boxes, cylinders, or meshes are written as their bounding boxes.
+func (tiles *Tiles) CollisionTriangles(shapes []collision.Shape) []math2.Triangle {
+	var triangles []math2.Triangle
+	for t, shape := range shapes {
+		if shape == nil {
+			continue
+		}
+		x, y, z := tiles.UnflattenGridPos(t)
+		center := tiles.GridToWorldPos(x, y, z, true)
+		var shapeTriangles []math2.Triangle
+		switch shape := shape.(type) {
+		case collision.Mesh:
+			shapeTriangles = shape.Triangles()
+		case collision.Cylinder:
+			shapeTriangles = cylinderTriangles(shape.Radius(), shape.Height())
+		default:
+			shapeTriangles = boxTriangles(shape.Extents())
+		}
+		for _, triangle := range shapeTriangles {
+			for p := range triangle {
+				triangle[p] = triangle[p].Add(center)
+			}
+			triangles = append(triangles, triangle)
+		}
+	}
+	return triangles
+}
+
+// Returns the triangles of a box's faces, wound counter-clockwise when seen from outside.
+func boxTriangles(box math2.Box) []math2.Triangle {
+	corner := func(x, y, z int) mgl32.Vec3 {
+		return mgl32.Vec3{
+			[2]float32{box.Min[0], box.Max[0]}[x],
+			[2]float32{box.Min[1], box.Max[1]}[y],
+			[2]float32{box.Min[2], box.Max[2]}[z],
+		}
+	}
+	faces := [6][4][3]int{
+		{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}, {1, 0, 1}}, // East
+		{{0, 0, 0}, {0, 0, 1}, {0, 1, 1}, {0, 1, 0}}, // West
+		{{0, 1, 0}, {0, 1, 1}, {1, 1, 1}, {1, 1, 0}}, // Top
+		{{0, 0, 0}, {1, 0, 0}, {1, 0, 1}, {0, 0, 1}}, // Bottom
+		{{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}}, // South
+		{{0, 0, 0}, {0, 1, 0}, {1, 1, 0}, {1, 0, 0}}, // North
+	}
+	triangles := make([]math2.Triangle, 0, len(faces)*2)
+	for _, face := range faces {
+		var quad [4]mgl32.Vec3
+		for i, c := range face {
+			quad[i] = corner(c[0], c[1], c[2])
+		}
+		triangles = append(triangles, math2.Triangle{quad[0], quad[1], quad[2]}, math2.Triangle{quad[2], quad[3], quad[0]})
+	}
+	return triangles
+}
+
+// Returns the triangles of a vertical cylinder centered at the origin, wound counter-clockwise when seen from outside.
+func cylinderTriangles(radius, height float32) []math2.Triangle {
+	halfHeight := height / 2.0
+	rim := func(i int, y float32) mgl32.Vec3 {
+		angle := float64(i) * 2.0 * math.Pi / CYLINDER_EXPORT_SIDES
+		return mgl32.Vec3{radius * float32(math.Cos(angle)), y, radius * float32(math.Sin(angle))}
+	}
+	top, bottom := mgl32.Vec3{0.0, halfHeight, 0.0}, mgl32.Vec3{0.0, -halfHeight, 0.0}
+	triangles := make([]math2.Triangle, 0, CYLINDER_EXPORT_SIDES*4)
+	for i := range CYLINDER_EXPORT_SIDES {
+		b0, b1 := rim(i, -halfHeight), rim(i+1, -halfHeight)
+		t0, t1 := rim(i, halfHeight), rim(i+1, halfHeight)
+		triangles = append(triangles,
+			math2.Triangle{b0, t0, t1},
+			math2.Triangle{t1, b1, b0},
+			math2.Triangle{top, t1, t0},
+			math2.Triangle{bottom, b0, b1},
+		)
+	}
+	return triangles
+}
@@ -0,0 +1,87 @@
+package te3
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
+)
+
+func TestWriteOBJ(t *testing.T) {
+	// Two cubes side by side with different textures.
+	te3File := &TE3File{Tiles: Tiles{
+		Width: 2, Height: 1, Length: 1,
+		Data:     []Tile{{TextureIDs: [2]TextureID{0, 0}}, {TextureIDs: [2]TextureID{1, 1}}},
+		Textures: []string{"assets/textures/wall.png", "assets/textures/floor.png"},
+		Shapes:   []string{"cube.obj"},
+	}}
+	mesh, _ := te3File.BuildMeshFromShapes([]*geom.Mesh{cubeMesh()})
+	shapes := []collision.Shape{collision.NewBox(math2.BoxFromRadius(1.0)), collision.NewCylinder(1.0, 2.0)}
+	collisionTriangles := te3File.Tiles.CollisionTriangles(shapes)
+
+	// Every collision triangle faces away from the center of its tile.
+	boxCount := len(boxTriangles(shapes[0].Extents()))
+	if len(collisionTriangles) != boxCount+CYLINDER_EXPORT_SIDES*4 {
+		t.Fatalf("Made %v collision triangles for a box and a cylinder", len(collisionTriangles))
+	}
+	for i, triangle := range collisionTriangles {
+		tile := 0
+		if i >= boxCount {
+			tile = 1
+		}
+		center := te3File.Tiles.GridToWorldPos(tile, 0, 0, true)
+		centroid := triangle[0].Add(triangle[1]).Add(triangle[2]).Mul(1.0 / 3.0)
+		if triangle.Plane().Normal.Dot(centroid.Sub(center)) <= 0.0 {
+			t.Errorf("Collision triangle %v faces inwards", triangle)
+		}
+	}
+
+	var obj, mtl bytes.Buffer
+	err := WriteOBJ(&obj, &mtl, OBJExport{Mesh: mesh, Collision: collisionTriangles, MTLName: "test.mtl", TexturePrefix: "../"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	var materials []string
+	for line := range strings.Lines(obj.String()) {
+		tokens := strings.Fields(line)
+		counts[tokens[0]]++
+		switch tokens[0] {
+		case "usemtl":
+			materials = append(materials, tokens[1])
+		case "f":
+			for _, vertex := range tokens[1:] {
+				for _, index := range strings.Split(vertex, "/") {
+					if i, err := strconv.Atoi(index); err != nil || i < 1 || i > len(mesh.Verts().Pos)+len(collisionTriangles)*3 {
+						t.Errorf("Face %q refers to a missing vertex", line)
+					}
+				}
+			}
+		}
+	}
+	if counts["v"] != len(mesh.Verts().Pos)+len(collisionTriangles)*3 || counts["vt"] != len(mesh.Verts().Pos) || counts["vn"] != len(mesh.Verts().Pos) {
+		t.Errorf("Wrote %v positions, %v texture coordinates, and %v normals for %v vertices and %v collision triangles",
+			counts["v"], counts["vt"], counts["vn"], len(mesh.Verts().Pos), len(collisionTriangles))
+	}
+	if counts["f"] != len(mesh.Inds())/3+len(collisionTriangles) {
+		t.Errorf("Wrote %v faces instead of %v", counts["f"], len(mesh.Inds())/3+len(collisionTriangles))
+	}
+	if counts["o"] != 2 {
+		t.Errorf("Wrote %v objects instead of the tiles and their collision", counts["o"])
+	}
+	expectedMaterials := []string{"assets/textures/floor.png", "assets/textures/wall.png", OBJ_COLLISION_OBJECT}
+	if strings.Join(materials, " ") != strings.Join(expectedMaterials, " ") {
+		t.Errorf("Used materials %v instead of %v", materials, expectedMaterials)
+	}
+	if !strings.Contains(mtl.String(), "map_Kd ../assets/textures/wall.png\n") {
+		t.Errorf("Material library doesn't refer to the wall texture:\n%v", mtl.String())
+	}
+	if !strings.HasPrefix(obj.String(), "mtllib test.mtl\n") {
+		t.Errorf("OBJ file doesn't refer to its material library")
+	}
+}
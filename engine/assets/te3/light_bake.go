@@ -0,0 +1,150 @@
+package te3
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+)
+
+const (
+	AO_STRENGTH    = 0.5                 // How much a vertex is darkened when all of the tiles in front of it are solid.
+	BAKE_BIAS      = 0.01 * GRID_SPACING // Distance that vertices are pushed along their normals before looking at the tiles around them.
+	SHADOW_STEP    = GRID_SPACING / 4.0  // Distance between the points checked for solid tiles between a vertex and a light.
+	LIGHT_ENT_TYPE = "light"             // Type of the ents that are baked into the map's vertex colors.
+)
+
+// A point light taken from a light ent.
+type bakedLight struct {
+	pos    mgl32.Vec3
+	color  mgl32.Vec3
+	radius float32
+}
+
+// Returns true if the shape mesh is closed and takes up at least the whole volume of a tile, so that it can block light.
+func ShapeFillsTile(shapeMesh *geom.Mesh) bool {
+	var volume float32
+	iter := shapeMesh.IterTriangles()
+	for iter.HasNext() {
+		tri := iter.Next()
+		volume += tri[0].Dot(tri[1].Cross(tri[2])) / 6.0
+	}
+	const tileVolume = GRID_SPACING * GRID_SPACING * GRID_SPACING
+	return mgl32.Abs(volume) >= tileVolume*0.99
+}
+
+// Computes the lighting at each vertex of a mesh made from the map's tiles, and stores it in the vertex colors.
+// The alpha channel holds the ambient occlusion from neighboring solid tiles, and the color channels hold the light added by the map's light ents.
+// Tiles block light when occludes returns true for them. The triangle map is used to keep vertices from being shadowed by their own tiles.
+// Returns a new mesh that shares the old one's other vertex data and its indices.
+func (te3 *TE3File) BakeLight(mesh *geom.Mesh, triMap TriMap, occludes func(tile Tile) bool) *geom.Mesh {
+	verts, inds := mesh.Verts(), mesh.Inds()
+
+	vertTiles := make([]int, len(verts.Pos))
+	for v := range vertTiles {
+		vertTiles[v] = -1
+	}
+	for t, triangles := range triMap {
+		for _, tri := range triangles {
+			for _, ind := range inds[tri*3:][:3] {
+				vertTiles[ind] = t
+			}
+		}
+	}
+
+	solid := make([]bool, len(te3.Tiles.Data))
+	for t, tile := range te3.Tiles.Data {
+		solid[t] = tile.ShapeID >= 0 && occludes(tile)
+	}
+
+	var lights []bakedLight
+	for _, ent := range te3.Ents {
+		if ent.Properties["type"] != LIGHT_ENT_TYPE || ent.Radius <= 0.0 {
+			continue
+		}
+		lights = append(lights, bakedLight{
+			pos:    ent.Position,
+			color:  mgl32.Vec3{float32(ent.Color[0]), float32(ent.Color[1]), float32(ent.Color[2])}.Mul(1.0 / 255.0),
+			radius: ent.Radius,
+		})
+	}
+
+	verts.Color = make([]mgl32.Vec4, len(verts.Pos))
+	for v, pos := range verts.Pos {
+		normal := verts.Normal[v]
+		pos = pos.Add(normal.Mul(BAKE_BIAS))
+		var light mgl32.Vec3
+		for _, l := range lights {
+			toLight := l.pos.Sub(pos)
+			dist := toLight.Len()
+			if dist >= l.radius {
+				continue
+			}
+			facing := float32(1.0)
+			if dist > BAKE_BIAS {
+				facing = normal.Dot(toLight) / dist
+			}
+			if facing <= 0.0 || !te3.Tiles.lineOfSight(pos, l.pos, solid) {
+				continue
+			}
+			falloff := 1.0 - dist/l.radius
+			light = light.Add(l.color.Mul(facing * falloff * falloff))
+		}
+		verts.Color[v] = light.Vec4(te3.Tiles.ambientOcclusion(pos, normal, vertTiles[v], solid))
+	}
+
+	bakedMesh := geom.CreateMesh(verts, inds)
+	for _, name := range mesh.GroupNames() {
+		bakedMesh.SetGroup(name, mesh.Group(name))
+	}
+	return bakedMesh
+}
+
+// Returns how much light reaches the point from the open space in front of it, from 1 - AO_STRENGTH to 1.
+// Looks at the eight tiles around the grid corner nearest to the point, counting the solid ones on the side that the normal faces.
+// The tile at ownTile is never counted, since it is the one that the point belongs to.
+func (tiles *Tiles) ambientOcclusion(pos, normal mgl32.Vec3, ownTile int, solid []bool) float32 {
+	var corner [3]int
+	for i := range corner {
+		corner[i] = int(math.Round(float64(pos[i] / GRID_SPACING)))
+	}
+	var front, blocked int
+	for cell := range 8 {
+		offset := [3]int{cell & 1, (cell >> 1) & 1, (cell >> 2) & 1}
+		var dir mgl32.Vec3
+		for i := range dir {
+			dir[i] = float32(offset[i]) - 0.5
+		}
+		if dir.Dot(normal) <= 0.001 {
+			// Tiles beside or behind the point can't cover it.
+			continue
+		}
+		front++
+		x, y, z := corner[0]+offset[0]-1, corner[1]+offset[1]-1, corner[2]+offset[2]-1
+		if tiles.OutOfBounds(x, y, z) {
+			continue
+		}
+		if t := tiles.FlattenGridPos(x, y, z); t != ownTile && solid[t] {
+			blocked++
+		}
+	}
+	if front == 0 {
+		return 1.0
+	}
+	return 1.0 - AO_STRENGTH*float32(blocked)/float32(front)
+}
+
+// Returns true if there are no solid tiles on the line between the two points.
+func (tiles *Tiles) lineOfSight(from, to mgl32.Vec3, solid []bool) bool {
+	steps := int(math.Ceil(float64(to.Sub(from).Len() / SHADOW_STEP)))
+	for s := 1; s < steps; s++ {
+		point := from.Add(to.Sub(from).Mul(float32(s) / float32(steps)))
+		x := int(math.Floor(float64(point[0] / GRID_SPACING)))
+		y := int(math.Floor(float64(point[1] / GRID_SPACING)))
+		z := int(math.Floor(float64(point[2] / GRID_SPACING)))
+		if !tiles.OutOfBounds(x, y, z) && solid[tiles.FlattenGridPos(x, y, z)] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package te3
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+)
+
+// Makes a cube that fills a tile, with a separate quad for each side.
+func cubeMesh() *geom.Mesh {
+	var verts geom.Vertices
+	var inds []uint32
+	for axis := range 3 {
+		for _, sign := range [2]float32{-1, 1} {
+			var normal mgl32.Vec3
+			normal[axis] = sign
+			base := uint32(len(verts.Pos))
+			for _, corner := range [4][2]float32{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+				var pos mgl32.Vec3
+				pos[axis] = sign
+				pos[(axis+1)%3], pos[(axis+2)%3] = corner[0], corner[1]
+				verts.Pos = append(verts.Pos, pos)
+				verts.TexCoord = append(verts.TexCoord, mgl32.Vec2{(corner[0] + 1) / 2, (corner[1] + 1) / 2})
+				verts.Normal = append(verts.Normal, normal)
+			}
+			if sign > 0 {
+				inds = append(inds, base, base+1, base+2, base, base+2, base+3)
+			} else {
+				inds = append(inds, base, base+2, base+1, base, base+3, base+2)
+			}
+		}
+	}
+	return geom.CreateMesh(verts, inds)
+}
+
+func TestShapeFillsTile(t *testing.T) {
+	if !ShapeFillsTile(cubeMesh()) {
+		t.Errorf("A cube should fill its tile")
+	}
+	floor := geom.CreateMesh(geom.Vertices{
+		Pos: []mgl32.Vec3{{-1, -1, -1}, {1, -1, -1}, {1, -1, 1}, {-1, -1, 1}},
+	}, []uint32{0, 2, 1, 0, 3, 2})
+	if ShapeFillsTile(floor) {
+		t.Errorf("A floor shouldn't fill its tile")
+	}
+}
+
+func TestBakeLight(t *testing.T) {
+	// Two cubes side by side, with a third one on top of the left cube.
+	te3File := &TE3File{Tiles: Tiles{
+		Width: 2, Height: 2, Length: 1,
+		Data:     make([]Tile, 4),
+		Textures: []string{"wall.png"},
+		Shapes:   []string{"cube.obj"},
+	}}
+	te3File.Tiles.Data[te3File.Tiles.FlattenGridPos(1, 1, 0)] = Tile{ShapeID: -1}
+	mesh, triMap := te3File.BuildMeshFromShapes([]*geom.Mesh{cubeMesh()})
+	baked := te3File.BakeLight(mesh, triMap, func(Tile) bool { return true })
+
+	// The top of the right cube is darkened next to the cube on top of the left one.
+	verts := baked.Verts()
+	rightTile := te3File.Tiles.FlattenGridPos(1, 0, 0)
+	var checked int
+	for _, tri := range triMap[rightTile] {
+		for _, ind := range baked.Inds()[tri*3:][:3] {
+			pos := verts.Pos[ind]
+			if verts.Normal[ind].Y() < 0.5 {
+				continue
+			}
+			var expected float32 = 1.0
+			if pos.X() < 3.0 {
+				// One of the four tiles in front of the corner is solid.
+				expected = 1.0 - AO_STRENGTH/4.0
+			}
+			if ao := verts.Color[ind].W(); !mgl32.FloatEqual(ao, expected) {
+				t.Errorf("Vertex at %v has ambient occlusion %v instead of %v", pos, ao, expected)
+			}
+			if light := verts.Color[ind].Vec3(); light != (mgl32.Vec3{}) {
+				t.Errorf("Vertex at %v is lit by %v without any lights", pos, light)
+			}
+			checked++
+		}
+	}
+	if checked == 0 {
+		t.Fatalf("The top of the right cube wasn't found")
+	}
+
+	// A red light above the right cube.
+	te3File.Ents = []Ent{{
+		Position:   [3]float32{3, 3, 1},
+		Color:      [3]uint8{255, 0, 0},
+		Radius:     8,
+		Properties: map[string]string{"type": LIGHT_ENT_TYPE},
+	}}
+	baked = te3File.BakeLight(mesh, triMap, func(Tile) bool { return true })
+	verts = baked.Verts()
+	for _, tri := range triMap[rightTile] {
+		for _, ind := range baked.Inds()[tri*3:][:3] {
+			light := verts.Color[ind].Vec3()
+			if lit := light.X() > 0.0; lit != (verts.Normal[ind].Y() > 0.5) {
+				t.Errorf("Vertex at %v facing %v has light %v", verts.Pos[ind], verts.Normal[ind], light)
+			}
+			if light.Y() != 0.0 || light.Z() != 0.0 {
+				t.Errorf("Vertex at %v has light %v, which isn't red", verts.Pos[ind], light)
+			}
+		}
+	}
+
+	// The cube on top of the left one blocks the light from reaching past it.
+	solid := []bool{true, true, true, false}
+	if te3File.Tiles.lineOfSight(mgl32.Vec3{3, 3, 1}, mgl32.Vec3{-1, 3, 1}, solid) {
+		t.Errorf("Light passed through a solid tile")
+	}
+	if !te3File.Tiles.lineOfSight(mgl32.Vec3{3, 3, 1}, mgl32.Vec3{3, 3.9, 1}, solid) {
+		t.Errorf("Light was blocked in an empty tile")
+	}
+}
@@ -1,6 +1,9 @@
 package te3
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 
 	"tophatdemon.com/total-invasion-ii/engine/assets"
@@ -11,23 +14,129 @@ type TE3File struct {
 		Editor  string `json:"editor"`
 		Version string `json:"version"`
 	} `json:"meta"`
-	Ents     []Ent `json:"ents"`
-	Tiles    Tiles `json:"tiles"`
-	filePath string
+	Ents        []Ent `json:"ents"`
+	Tiles       Tiles `json:"tiles"`
+	filePath    string
+	prefabPaths []string                   // Paths of the prefab maps stamped into this one, including the ones inside of other prefabs.
+	extra       map[string]json.RawMessage // Fields that only the editor uses, such as its camera, kept so that saving the map doesn't lose them.
 }
 
-// Loads a Total Editor 3 map file into a data structure
+// Fields that every ent must have once the map is upgraded to the current version.
+var requiredEntFields = []string{"position", "angles", "radius", "color", "display", "properties"}
+
+// Loads a Total Editor 3 map file into a data structure.
+// Maps from older versions of the format are upgraded, while malformed maps and maps from newer versions return an error.
 func LoadTE3File(assetPath string) (*TE3File, error) {
-	te3, err := assets.LoadAndUnmarshalJSON[TE3File](assetPath)
+	file, err := assets.GetFile(assetPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fileBytes, err := io.ReadAll(file)
 	if err != nil {
-		//TODO: Handle this error gracefully by returning to the title screen.
 		return nil, err
 	}
+
+	te3, err := ParseTE3File(fileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not load map %v: %w", assetPath, err)
+	}
 	te3.filePath = assetPath
 	log.Println("Loaded TE3 file", assetPath)
-	return te3, err
+	return te3, nil
+}
+
+// Parses the contents of a map file, upgrading it to the current version of the format.
+func ParseTE3File(data []byte) (*TE3File, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	meta, ok := doc["meta"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("missing the 'meta' object")
+	}
+	versionText, ok := meta["version"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing the format version in 'meta'")
+	}
+	version, err := ParseVersion(versionText)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(doc, version); err != nil {
+		return nil, err
+	}
+	meta["version"] = CurrentVersion.String()
+
+	// Check for missing fields, since they would otherwise be left as zero values.
+	for _, key := range []string{"ents", "tiles"} {
+		if _, ok := doc[key]; !ok {
+			return nil, fmt.Errorf("missing the '%v' field", key)
+		}
+	}
+	ents, ok := doc["ents"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("'ents' must be an array")
+	}
+	for e, ent := range ents {
+		entObj, ok := ent.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("ent %v is not an object", e)
+		}
+		for _, key := range requiredEntFields {
+			if _, ok := entObj[key]; !ok {
+				return nil, fmt.Errorf("ent %v is missing the '%v' field", e, key)
+			}
+		}
+	}
+
+	// Decode the upgraded document
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	te3 := &TE3File{}
+	if err := json.Unmarshal(upgraded, te3); err != nil {
+		return nil, err
+	}
+	for key, value := range doc {
+		if key == "meta" || key == "ents" || key == "tiles" {
+			continue
+		}
+		if te3.extra == nil {
+			te3.extra = make(map[string]json.RawMessage)
+		}
+		if te3.extra[key], err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+	return te3, nil
+}
+
+// Encodes the map in the format that the editor saves, including the fields that the game doesn't use.
+func (te3 *TE3File) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]any, len(te3.extra)+3)
+	for key, value := range te3.extra {
+		doc[key] = value
+	}
+	doc["meta"] = te3.Meta
+	doc["tiles"] = &te3.Tiles
+	if te3.Ents != nil {
+		doc["ents"] = te3.Ents
+	} else {
+		doc["ents"] = []Ent{}
+	}
+	return json.Marshal(doc)
 }
 
 func (te3 *TE3File) FilePath() string {
 	return te3.filePath
 }
+
+// Returns the paths of the prefab maps that InstancePrefabs stamped into this map, including the ones inside of other prefabs.
+func (te3 *TE3File) PrefabPaths() []string {
+	return te3.prefabPaths
+}
@@ -0,0 +1,121 @@
+package te3
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResaveMaps(t *testing.T) {
+	mapPaths, err := filepath.Glob("../../../assets/maps/*.te3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mapPaths) == 0 {
+		t.Fatal("No maps found")
+	}
+	for _, mapPath := range mapPaths {
+		t.Run(filepath.Base(mapPath), func(t *testing.T) {
+			original, err := os.ReadFile(mapPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := ParseTE3File(original)
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved, err := json.Marshal(loaded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reloaded, err := ParseTE3File(saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(loaded.Meta, reloaded.Meta) {
+				t.Errorf("Meta changed from %+v to %+v", loaded.Meta, reloaded.Meta)
+			}
+			if !reflect.DeepEqual(loaded.Ents, reloaded.Ents) {
+				t.Errorf("Ents changed")
+			}
+			if !reflect.DeepEqual(loaded.Tiles, reloaded.Tiles) {
+				t.Errorf("Tiles changed")
+			}
+			if !reflect.DeepEqual(loaded.extra, reloaded.extra) {
+				t.Errorf("Editor fields changed from %v to %v", loaded.extra, reloaded.extra)
+			}
+
+			// Saving the map again shouldn't change anything.
+			resaved, err := json.Marshal(reloaded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(saved, resaved) {
+				t.Errorf("Saving the map twice gives different results")
+			}
+		})
+	}
+}
+
+func TestTilesRoundTrip(t *testing.T) {
+	solid := Tile{ShapeID: 1, TextureIDs: [2]TextureID{0, 1}, Yaw: 2, Pitch: 3}
+	empty := Tile{ShapeID: -1}
+	longRun := make([]Tile, 40000)
+	for i := range longRun {
+		longRun[i] = empty
+	}
+	longRun[len(longRun)-1] = solid
+
+	tests := []struct {
+		name string
+		data []Tile
+	}{
+		{"solid after empty tiles", []Tile{empty, empty, empty, solid}},
+		{"empty after solid tile", []Tile{solid, empty, solid, empty}},
+		{"all empty", []Tile{empty, empty, empty, empty}},
+		{"run longer than the limit", longRun},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tiles := Tiles{
+				Data:     test.data,
+				Width:    len(test.data),
+				Height:   1,
+				Length:   1,
+				Textures: []string{"a.png", "b.png"},
+				Shapes:   []string{"cube.obj", "wedge.obj"},
+			}
+			encoded, err := json.Marshal(&tiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Tiles
+			if err := json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tiles, decoded) {
+				t.Errorf("Tiles changed after saving and loading")
+			}
+		})
+	}
+}
+
+func TestUnmarshalTilesBadSize(t *testing.T) {
+	for _, test := range []struct {
+		name, tiles string
+	}{
+		{"size overflows", `{"width": 4294967296, "height": 4294967296, "length": 1, "textures": [], "shapes": [], "data": ""}`},
+		{"size overflows with every side", `{"width": 2097152, "height": 2097152, "length": 2097152, "textures": [], "shapes": [], "data": ""}`},
+		{"run of the most negative length", `{"width": 1, "height": 1, "length": 1, "textures": [], "shapes": [], "data": "AIA="}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var tiles Tiles
+			if err := json.Unmarshal([]byte(test.tiles), &tiles); err == nil {
+				t.Errorf("Loaded %vx%vx%v tiles without an error", tiles.Width, tiles.Height, tiles.Length)
+			}
+		})
+	}
+}
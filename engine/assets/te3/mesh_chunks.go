@@ -0,0 +1,113 @@
+package te3
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+)
+
+// Width, height, and length in tiles of the cubes that the map's mesh is split into, so that parts out of view can be skipped.
+const CHUNK_SIZE = 16
+
+// A part of the map's mesh covering a cube of tiles.
+type MeshChunk struct {
+	Box    math2.Box             // Bounds of the chunk's triangles.
+	Groups map[string]geom.Group // Range of the chunk's triangles within each of the mesh's groups that it has triangles in.
+}
+
+// Returns the number of chunks along each axis of the map.
+func (tiles *Tiles) ChunkCounts() [3]int {
+	return [3]int{
+		max(1, (tiles.Width+CHUNK_SIZE-1)/CHUNK_SIZE),
+		max(1, (tiles.Height+CHUNK_SIZE-1)/CHUNK_SIZE),
+		max(1, (tiles.Length+CHUNK_SIZE-1)/CHUNK_SIZE),
+	}
+}
+
+// Splits the map's mesh into chunks of CHUNK_SIZE tiles along each axis, putting each triangle in the chunk that contains its center.
+// Returns a new mesh that shares the old one's vertex data, with the triangles of each group sorted so that each chunk's triangles are next to each other,
+// along with the triangle map updated for the new order and the chunks that have any triangles, in order of their position in the grid.
+func (te3 *TE3File) ChunkMesh(mesh *geom.Mesh, triMap TriMap) (*geom.Mesh, TriMap, []MeshChunk) {
+	verts, oldInds := mesh.Verts(), mesh.Inds()
+	counts := te3.Tiles.ChunkCounts()
+	chunkOf := func(tri int) int {
+		var coords [3]int
+		triInds := oldInds[tri*3:][:3]
+		center := verts.Pos[triInds[0]].Add(verts.Pos[triInds[1]]).Add(verts.Pos[triInds[2]]).Mul(1.0 / 3.0)
+		for i := range coords {
+			coords[i] = min(max(int(center[i]/(CHUNK_SIZE*GRID_SPACING)), 0), counts[i]-1)
+		}
+		return coords[0] + (coords[2] * counts[0]) + (coords[1] * counts[0] * counts[2])
+	}
+
+	groupNames := mesh.GroupNames()
+	slices.Sort(groupNames)
+
+	inds := make([]uint32, 0, len(oldInds))
+	newTriangles := make([]int, len(oldInds)/3) // Maps the old triangle indices to the new ones.
+	chunks := make(map[int]*MeshChunk)
+	newGroups := make([]geom.Group, len(groupNames))
+	for g, groupName := range groupNames {
+		group := mesh.Group(groupName)
+		type chunkTriangle struct{ tri, chunk int }
+		triangles := make([]chunkTriangle, group.Length/3)
+		for i := range triangles {
+			tri := group.Offset/3 + i
+			triangles[i] = chunkTriangle{tri, chunkOf(tri)}
+		}
+		slices.SortStableFunc(triangles, func(a, b chunkTriangle) int {
+			return cmp.Compare(a.chunk, b.chunk)
+		})
+
+		newGroups[g].Offset = len(inds)
+		for _, triangle := range triangles {
+			triInds := oldInds[triangle.tri*3:][:3]
+			triBox := math2.BoxFromPoints(verts.Pos[triInds[0]], verts.Pos[triInds[1]], verts.Pos[triInds[2]])
+			chunk, ok := chunks[triangle.chunk]
+			if !ok {
+				chunk = &MeshChunk{Box: triBox, Groups: make(map[string]geom.Group)}
+				chunks[triangle.chunk] = chunk
+			}
+			chunk.Box = chunk.Box.Union(triBox)
+			chunkGroup, ok := chunk.Groups[groupName]
+			if !ok {
+				chunkGroup.Offset = len(inds)
+			}
+			newTriangles[triangle.tri] = len(inds) / 3
+			inds = append(inds, triInds...)
+			chunkGroup.Length += 3
+			chunk.Groups[groupName] = chunkGroup
+		}
+		newGroups[g].Length = len(inds) - newGroups[g].Offset
+	}
+
+	chunkedMesh := geom.CreateMesh(verts, inds)
+	for g, group := range newGroups {
+		chunkedMesh.SetGroup(groupNames[g], group)
+	}
+
+	chunkList := make([]MeshChunk, 0, len(chunks))
+	for _, c := range slices.Sorted(maps.Keys(chunks)) {
+		chunkList = append(chunkList, *chunks[c])
+	}
+
+	return chunkedMesh, remapTriMap(triMap, newTriangles), chunkList
+}
+
+// Returns a copy of the triangle map with each triangle index replaced by newTriangles[index].
+func remapTriMap(triMap TriMap, newTriangles []int) TriMap {
+	newTriMap := make(TriMap, len(triMap))
+	for t, triangles := range triMap {
+		if triangles == nil {
+			continue
+		}
+		newTriMap[t] = make([]int, len(triangles))
+		for i, tri := range triangles {
+			newTriMap[t][i] = newTriangles[tri]
+		}
+	}
+	return newTriMap
+}
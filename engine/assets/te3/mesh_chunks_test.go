@@ -0,0 +1,80 @@
+package te3
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+)
+
+func TestChunkMesh(t *testing.T) {
+	floor := geom.CreateMesh(geom.Vertices{
+		Pos:      []mgl32.Vec3{{-1, -1, -1}, {1, -1, -1}, {1, -1, 1}, {-1, -1, 1}},
+		TexCoord: []mgl32.Vec2{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+		Normal:   []mgl32.Vec3{{0, 1, 0}, {0, 1, 0}, {0, 1, 0}, {0, 1, 0}},
+	}, []uint32{0, 2, 1, 0, 3, 2})
+
+	// A strip of floor tiles that is a bit longer than one chunk.
+	const width = CHUNK_SIZE + 4
+	te3File := &TE3File{Tiles: Tiles{
+		Width: width, Height: 1, Length: 1,
+		Data:     make([]Tile, width),
+		Textures: []string{"floor.png"},
+		Shapes:   []string{"floor.obj"},
+	}}
+	mesh, triMap := te3File.BuildMeshFromShapes([]*geom.Mesh{floor})
+
+	for _, test := range []struct {
+		name      string
+		merge     bool
+		triangles [2]int // Expected number of triangles in each chunk.
+	}{
+		{"separate faces", false, [2]int{CHUNK_SIZE * 2, 4 * 2}},
+		{"merged faces", true, [2]int{2, 2}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			unchunked, unchunkedTriMap := mesh, triMap
+			if test.merge {
+				unchunked, unchunkedTriMap = te3File.MergeFaces(mesh, triMap, func(string) bool { return true })
+			}
+			chunked, chunkedTriMap, chunks := te3File.ChunkMesh(unchunked, unchunkedTriMap)
+			if len(chunks) != 2 {
+				t.Fatalf("Made %v chunks instead of 2", len(chunks))
+			}
+			if len(chunked.Inds()) != len(unchunked.Inds()) {
+				t.Errorf("Chunking changed the number of indices from %v to %v", len(unchunked.Inds()), len(chunked.Inds()))
+			}
+
+			inds, verts := chunked.Inds(), chunked.Verts()
+			for c, chunk := range chunks {
+				group, ok := chunk.Groups["floor.png"]
+				if !ok {
+					t.Fatalf("Chunk %v has no triangles", c)
+				}
+				if group.Length/3 != test.triangles[c] {
+					t.Errorf("Chunk %v has %v triangles instead of %v", c, group.Length/3, test.triangles[c])
+				}
+				for _, ind := range inds[group.Offset:][:group.Length] {
+					pos := verts.Pos[ind]
+					if pos.X() < chunk.Box.Min.X() || pos.X() > chunk.Box.Max.X() {
+						t.Errorf("Vertex %v is outside of chunk %v's box %v", pos, c, chunk.Box)
+					}
+					if pos.X() < float32(c*CHUNK_SIZE)*GRID_SPACING || pos.X() > float32((c+1)*CHUNK_SIZE)*GRID_SPACING {
+						t.Errorf("Vertex %v of chunk %v crosses the chunk's border", pos, c)
+					}
+				}
+			}
+
+			// Each tile should still map to triangles inside its own position.
+			for tile, triangles := range chunkedTriMap {
+				x, _, _ := te3File.Tiles.UnflattenGridPos(tile)
+				chunkGroup := chunks[x/CHUNK_SIZE].Groups["floor.png"]
+				for _, tri := range triangles {
+					if tri*3 < chunkGroup.Offset || tri*3 >= chunkGroup.Offset+chunkGroup.Length {
+						t.Errorf("Tile %v maps to triangle %v outside of its chunk", tile, tri)
+					}
+				}
+			}
+		})
+	}
+}
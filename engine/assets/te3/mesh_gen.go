@@ -1,11 +1,13 @@
 package te3
 
 import (
-	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/go-gl/mathgl/mgl32"
-	"tophatdemon.com/total-invasion-ii/engine/assets/cache"
+	"tophatdemon.com/total-invasion-ii/engine/assets"
 	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/assets/textures"
 	"tophatdemon.com/total-invasion-ii/engine/math2"
 )
 
@@ -83,9 +85,16 @@ func (te3 *TE3File) shouldCull(gridX, gridY, gridZ int, triangle math2.Triangle,
 	return false
 }
 
-func (te3 *TE3File) BuildMesh() (*geom.Mesh, TriMap, error) {
-	var err error
+// Returns the name the map's mesh is stored under in the asset cache.
+// It is a generated name, so that it isn't reloaded from the map file as if it were a model.
+func MeshName(mapPath string) string {
+	return assets.GeneratedName(mapPath, "mesh")
+}
 
+// Generates the map's mesh from its tiles, given the shape meshes indexed by shape ID.
+// The tiles are grouped by texture, with each group named after its texture's path.
+// The mesh isn't uploaded, so this can be called from other goroutines as long as they don't share the shape meshes.
+func (te3 *TE3File) BuildMeshFromShapes(shapeMeshes []*geom.Mesh) (*geom.Mesh, TriMap) {
 	mapVerts := geom.Vertices{
 		Pos:      make([]mgl32.Vec3, 0, len(te3.Tiles.Data)*24),
 		TexCoord: make([]mgl32.Vec2, 0, len(te3.Tiles.Data)*24),
@@ -94,14 +103,6 @@ func (te3 *TE3File) BuildMesh() (*geom.Mesh, TriMap, error) {
 	}
 	mapInds := make([]uint32, 0, len(te3.Tiles.Data)*12)
 
-	shapeMeshes := make([]*geom.Mesh, len(te3.Tiles.Shapes))
-	for i, path := range te3.Tiles.Shapes {
-		shapeMeshes[i], err = cache.GetMesh(path)
-		if err != nil {
-			return nil, nil, fmt.Errorf("shape mesh at %s not found", path)
-		}
-	}
-
 	// Groups tile data indices by their texture
 	groupTiles := make(map[TextureID][]int, len(te3.Tiles.Textures))
 
@@ -113,7 +114,11 @@ func (te3 *TE3File) BuildMesh() (*geom.Mesh, TriMap, error) {
 		}
 
 		// Assign to group(s) based on texture
-		for _, texId := range tile.TextureIDs {
+		for i, texId := range tile.TextureIDs {
+			if i > 0 && texId == tile.TextureIDs[0] {
+				// The whole shape is drawn with the first texture, so the tile shouldn't be added twice.
+				break
+			}
 			group, ok := groupTiles[texId]
 			if !ok {
 				group = make([]int, 0, 16)
@@ -122,88 +127,151 @@ func (te3 *TE3File) BuildMesh() (*geom.Mesh, TriMap, error) {
 		}
 	}
 
-	meshGroups := make([]geom.Group, 0, len(groupTiles))
-	meshGroupNames := make([]string, 0, len(groupTiles))
+	// Texture IDs with the same path share a group.
+	groupTextures := make(map[string][]TextureID, len(groupTiles))
+	for texID := range groupTiles {
+		groupName := te3.Tiles.Textures[texID]
+		groupTextures[groupName] = append(groupTextures[groupName], texID)
+	}
+	groupNames := slices.Sorted(maps.Keys(groupTextures))
+
+	meshGroups := make([]geom.Group, 0, len(groupNames))
 
 	triMap := make(TriMap, len(te3.Tiles.Data))
 
 	// Add vertex data from tiles to map mesh
-	for texID, tileIndices := range groupTiles {
+	for _, groupName := range groupNames {
 		outGroup := geom.Group{Offset: len(mapInds), Length: 0}
 
-		for _, ti := range tileIndices {
-			tile := te3.Tiles.Data[ti]
-			shapeMesh := shapeMeshes[tile.ShapeID]
-			gridX, gridY, gridZ := te3.Tiles.UnflattenGridPos(ti)
-
-			rotMatrix := tile.GetRotationMatrix()
-
-			// Create triangle map array for this tile
-			triMap[ti] = make([]int, 0, 8)
-
-			// Pick the material on the mesh used for this texture
-			var shapeGroup geom.Group
-			switch texID {
-			case tile.TextureIDs[0]:
-				shapeGroup = shapeMesh.Group("primary")
-			case tile.TextureIDs[1]:
-				shapeGroup = shapeMesh.Group("secondary")
-			}
-			shapeInds := shapeMesh.Inds()
-			if tile.TextureIDs[0] == tile.TextureIDs[1] {
-				// Both textures are the same, so use the whole mesh.
-				shapeGroup = geom.Group{}
-			} else if shapeGroup != (geom.Group{}) {
-				shapeInds = shapeInds[shapeGroup.Offset:][:shapeGroup.Length]
-			}
+		slices.Sort(groupTextures[groupName])
+		for _, texID := range groupTextures[groupName] {
+			for _, ti := range groupTiles[texID] {
+				tile := te3.Tiles.Data[ti]
+				shapeMesh := shapeMeshes[tile.ShapeID]
+				gridX, gridY, gridZ := te3.Tiles.UnflattenGridPos(ti)
+
+				rotMatrix := tile.GetRotationMatrix()
+
+				// Create triangle map array for this tile
+				triMap[ti] = make([]int, 0, 8)
+
+				// Pick the material on the mesh used for this texture
+				var shapeGroup geom.Group
+				switch texID {
+				case tile.TextureIDs[0]:
+					shapeGroup = shapeMesh.Group("primary")
+				case tile.TextureIDs[1]:
+					shapeGroup = shapeMesh.Group("secondary")
+				}
+				shapeInds := shapeMesh.Inds()
+				if tile.TextureIDs[0] == tile.TextureIDs[1] {
+					// Both textures are the same, so use the whole mesh.
+					shapeGroup = geom.Group{}
+				} else if shapeGroup != (geom.Group{}) {
+					shapeInds = shapeInds[shapeGroup.Offset:][:shapeGroup.Length]
+				}
 
-			shapeTriIter := shapeMesh.IterTriangles()
-			for range shapeGroup.Offset / 3 {
-				shapeTriIter.Next()
-			}
+				shapeTriIter := shapeMesh.IterTriangles()
+				for range shapeGroup.Offset / 3 {
+					shapeTriIter.Next()
+				}
 
-			for tri := range len(shapeInds) / 3 {
-				// Get triangle coordinates
-				triangle := transformedTileTriangle(gridX, gridY, gridZ, shapeTriIter.Next(), rotMatrix)
+				for tri := range len(shapeInds) / 3 {
+					// Get triangle coordinates
+					triangle := transformedTileTriangle(gridX, gridY, gridZ, shapeTriIter.Next(), rotMatrix)
 
-				// Skip if culling tile
-				if te3.shouldCull(gridX, gridY, gridZ, triangle, shapeMeshes) {
-					continue
-				}
+					// Skip if culling tile
+					if te3.shouldCull(gridX, gridY, gridZ, triangle, shapeMeshes) {
+						continue
+					}
 
-				// Add to triangle map
-				triMap[ti] = append(triMap[ti], len(mapInds)/3)
+					// Add to triangle map
+					triMap[ti] = append(triMap[ti], len(mapInds)/3)
 
-				// Add the triangle's indices to the map mesh
-				for i := range 3 {
-					ind := shapeInds[(tri*3)+i]
-					mapInds = append(mapInds, uint32(len(mapVerts.Pos)))
+					// Add the triangle's indices to the map mesh
+					for i := range 3 {
+						ind := shapeInds[(tri*3)+i]
+						mapInds = append(mapInds, uint32(len(mapVerts.Pos)))
 
-					// Add the shape's vertex position to the aggregate mesh, offset by the overall tile position
-					mapVerts.Pos = append(mapVerts.Pos, triangle[i])
+						// Add the shape's vertex position to the aggregate mesh, offset by the overall tile position
+						mapVerts.Pos = append(mapVerts.Pos, triangle[i])
 
-					// Append tex coordinates
-					mapVerts.TexCoord = append(mapVerts.TexCoord, shapeMesh.Verts().TexCoord[ind])
+						// Append tex coordinates
+						mapVerts.TexCoord = append(mapVerts.TexCoord, shapeMesh.Verts().TexCoord[ind])
 
-					// Append normal, rotated by the tile orientation
-					normal := mgl32.TransformNormal(shapeMesh.Verts().Normal[ind], rotMatrix)
-					mapVerts.Normal = append(mapVerts.Normal, normal)
+						// Append normal, rotated by the tile orientation
+						normal := mgl32.TransformNormal(shapeMesh.Verts().Normal[ind], rotMatrix)
+						mapVerts.Normal = append(mapVerts.Normal, normal)
+					}
+					outGroup.Length += 3
 				}
-				outGroup.Length += 3
 			}
 		}
 
 		meshGroups = append(meshGroups, outGroup)
-		meshGroupNames = append(meshGroupNames, te3.Tiles.Textures[texID])
 	}
 
 	mesh := geom.CreateMesh(mapVerts, mapInds)
-	mesh.Upload()
 
 	// Set group names to texture paths
 	for g, group := range meshGroups {
-		mesh.SetGroup(meshGroupNames[g], group)
+		mesh.SetGroup(groupNames[g], group)
+	}
+
+	return mesh, triMap
+}
+
+// Regroups a mesh made by BuildMeshFromShapes so that textures sharing a batch in the atlas are drawn together,
+// and gives each vertex the layer its texture is stored in. Textures that aren't in the atlas keep their own groups.
+// Returns a new mesh that shares the old one's vertex data, along with the triangle map updated for the new order.
+func (te3 *TE3File) BatchMesh(mesh *geom.Mesh, triMap TriMap, atlas *textures.TileAtlas) (*geom.Mesh, TriMap) {
+	if atlas == nil {
+		return mesh, triMap
+	}
+
+	batchTextures := make(map[string][]string, mesh.GroupCount())
+	for _, texPath := range mesh.GroupNames() {
+		batchName := texPath
+		if entry, ok := atlas.Entry(texPath); ok {
+			batchName = entry.Batch
+		}
+		batchTextures[batchName] = append(batchTextures[batchName], texPath)
+	}
+
+	oldInds := mesh.Inds()
+	verts := mesh.Verts()
+	verts.Layer = make([]float32, len(verts.Pos))
+	inds := make([]uint32, 0, len(oldInds))
+	newTriangles := make([]int, len(oldInds)/3) // Maps the old triangle indices to the new ones.
+
+	batchNames := slices.Sorted(maps.Keys(batchTextures))
+	batchGroups := make([]geom.Group, len(batchNames))
+	for b, batchName := range batchNames {
+		batchGroup := geom.Group{Offset: len(inds)}
+		texPaths := batchTextures[batchName]
+		slices.Sort(texPaths)
+		for _, texPath := range texPaths {
+			group := mesh.Group(texPath)
+			var layer float32
+			if entry, ok := atlas.Entry(texPath); ok {
+				layer = float32(entry.Layer)
+			}
+			for tri := range group.Length / 3 {
+				newTriangles[group.Offset/3+tri] = len(inds)/3 + tri
+			}
+			for _, ind := range oldInds[group.Offset:][:group.Length] {
+				verts.Layer[ind] = layer
+			}
+			inds = append(inds, oldInds[group.Offset:][:group.Length]...)
+		}
+		batchGroup.Length = len(inds) - batchGroup.Offset
+		batchGroups[b] = batchGroup
+	}
+
+	batchedMesh := geom.CreateMesh(verts, inds)
+	for b, group := range batchGroups {
+		batchedMesh.SetGroup(batchNames[b], group)
 	}
 
-	return mesh, triMap, nil
+	return batchedMesh, remapTriMap(triMap, newTriangles)
 }
@@ -0,0 +1,465 @@
+package te3
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+)
+
+// Tolerance for comparing vertex positions and texture coordinates when merging faces.
+const MERGE_EPSILON = 1e-4
+
+// Identifies the faces that can be merged together: those that lie on the same axis-aligned plane,
+// use the same texture layer and vertex color, and whose texture coordinates follow the same pattern across the plane.
+type mergePlane struct {
+	group       int
+	axis        int      // Index of the coordinate that is constant across the plane.
+	facingUp    bool     // True if the normal points along the positive axis.
+	coord       int64    // Quantized position of the plane along the axis.
+	layer       float32  // Texture layer of the faces.
+	color       [4]int64 // Quantized vertex color, which must be the same at every vertex of the faces.
+	uvScale     [4]int64 // Quantized change in texture coordinates along each of the plane's two directions.
+	uvRemainder [2]int64 // Quantized fractional part of the texture coordinates, extended to the plane's origin.
+}
+
+func compareMergePlanes(a, b mergePlane) int {
+	return cmp.Or(
+		cmp.Compare(a.group, b.group),
+		cmp.Compare(a.axis, b.axis),
+		cmp.Compare(boolToInt(a.facingUp), boolToInt(b.facingUp)),
+		cmp.Compare(a.coord, b.coord),
+		cmp.Compare(a.layer, b.layer),
+		slices.Compare(a.color[:], b.color[:]),
+		slices.Compare(a.uvScale[:], b.uvScale[:]),
+		slices.Compare(a.uvRemainder[:], b.uvRemainder[:]),
+	)
+}
+
+// A face of a tile that exactly covers one cell of its plane.
+type mergeCell struct {
+	triangles [2]int
+	mapping   uvMapping
+}
+
+// Texture coordinates that change linearly across a face.
+type uvMapping struct {
+	scale  [2]mgl32.Vec2 // Change in texture coordinates per unit along each of the plane's directions.
+	corner mgl32.Vec2    // Texture coordinates at the corner of the face's cell with the lowest coordinates.
+}
+
+// Returns the texture coordinates at the given offset from the cell's corner.
+func (mapping uvMapping) at(s, t float32) mgl32.Vec2 {
+	return mapping.corner.Add(mapping.scale[0].Mul(s)).Add(mapping.scale[1].Mul(t))
+}
+
+func quantize(value float32) int64 {
+	return int64(math.Round(float64(value) / MERGE_EPSILON))
+}
+
+// Returns the in-plane coordinates of the position, given the axis that the plane is perpendicular to.
+func planeCoords(pos mgl32.Vec3, axis int) (float32, float32) {
+	return pos[(axis+1)%3], pos[(axis+2)%3]
+}
+
+// Merges faces of tiles that lie next to each other on the same plane into larger quads, so that large floors and walls take fewer triangles.
+// Only faces that exactly cover the side of a tile are merged, and only in groups that canMerge returns true for,
+// since the merged quads rely on the texture repeating to look the same as the separate faces.
+// If the mesh has vertex colors, only faces with the same color at all of their vertices are merged.
+// In the returned triangle map, the triangles of a merged quad are listed under every tile that it covers.
+func (te3 *TE3File) MergeFaces(mesh *geom.Mesh, triMap TriMap, canMerge func(groupName string) bool) (*geom.Mesh, TriMap) {
+	verts, inds := mesh.Verts(), mesh.Inds()
+	groupNames := mesh.GroupNames()
+	slices.Sort(groupNames)
+
+	triGroups := make([]int, len(inds)/3)
+	for g, groupName := range groupNames {
+		group := mesh.Group(groupName)
+		for tri := group.Offset / 3; tri < (group.Offset+group.Length)/3; tri++ {
+			triGroups[tri] = g
+		}
+	}
+	layerOf := func(tri int) float32 {
+		if verts.Layer == nil {
+			return 0.0
+		}
+		return verts.Layer[inds[tri*3]]
+	}
+	colorOf := func(tri int) (mgl32.Vec4, bool) {
+		if verts.Color == nil {
+			return mgl32.Vec4{}, true
+		}
+		color := verts.Color[inds[tri*3]]
+		for _, ind := range inds[tri*3:][1:3] {
+			if !verts.Color[ind].ApproxEqualThreshold(color, MERGE_EPSILON) {
+				return color, false
+			}
+		}
+		return color, true
+	}
+
+	// Find the faces that cover one side of a tile.
+	planes := make(map[mergePlane]map[[2]int]mergeCell)
+	for _, triangles := range triMap {
+		tileFaces := make(map[mergePlane][]int, 6)
+		for _, tri := range triangles {
+			if !canMerge(groupNames[triGroups[tri]]) {
+				continue
+			}
+			plane, ok := trianglePlane(verts, inds[tri*3:][:3])
+			if !ok {
+				continue
+			}
+			color, ok := colorOf(tri)
+			if !ok {
+				continue
+			}
+			plane.group = triGroups[tri]
+			plane.layer = layerOf(tri)
+			for i := range color {
+				plane.color[i] = quantize(color[i])
+			}
+			tileFaces[plane] = append(tileFaces[plane], tri)
+		}
+		for plane, faceTris := range tileFaces {
+			if len(faceTris) != 2 {
+				continue
+			}
+			cell, mapping, ok := cellOfTriangles(verts, inds, plane.axis, faceTris[0], faceTris[1])
+			if !ok {
+				continue
+			}
+			for i, scale := range [4]float32{mapping.scale[0][0], mapping.scale[0][1], mapping.scale[1][0], mapping.scale[1][1]} {
+				plane.uvScale[i] = quantize(scale)
+			}
+			// Faces can be merged when their texture coordinates only differ by whole numbers, since the texture repeats.
+			for i := range 2 {
+				origin := float64(mapping.corner[i]) -
+					float64(plane.uvScale[i])*MERGE_EPSILON*float64(cell[0])*GRID_SPACING -
+					float64(plane.uvScale[2+i])*MERGE_EPSILON*float64(cell[1])*GRID_SPACING
+				plane.uvRemainder[i] = int64(math.Round((origin-math.Floor(origin))/MERGE_EPSILON)) % int64(math.Round(1.0/MERGE_EPSILON))
+			}
+			if planes[plane] == nil {
+				planes[plane] = make(map[[2]int]mergeCell)
+			}
+			planes[plane][cell] = mergeCell{triangles: [2]int{faceTris[0], faceTris[1]}, mapping: mapping}
+		}
+	}
+
+	// Vertex data for the merged quads of each group.
+	type mergedQuad struct {
+		pos, normal [4]mgl32.Vec3
+		texCoord    [4]mgl32.Vec2
+		layer       float32
+		color       mgl32.Vec4
+	}
+	groupQuads := make([][]mergedQuad, len(groupNames))
+	mergedInto := make(map[int]int) // Maps the triangles that were merged to the index of their quad in groupQuads.
+
+	planeKeys := make([]mergePlane, 0, len(planes))
+	for plane := range planes {
+		planeKeys = append(planeKeys, plane)
+	}
+	// Go through the planes in a fixed order so that the same map always gives the same mesh.
+	slices.SortFunc(planeKeys, compareMergePlanes)
+	for _, plane := range planeKeys {
+		cells := planes[plane]
+		cellKeys := make([][2]int, 0, len(cells))
+		for cell := range cells {
+			cellKeys = append(cellKeys, cell)
+		}
+		slices.SortFunc(cellKeys, func(a, b [2]int) int {
+			return slices.Compare([]int{a[1], a[0]}, []int{b[1], b[0]})
+		})
+
+		used := make(map[[2]int]bool, len(cells))
+		for _, start := range cellKeys {
+			if used[start] {
+				continue
+			}
+			// Grow a rectangle of cells, first along the rows and then down the columns.
+			// Rectangles stop at the borders between chunks, so that each quad lies within the chunk that ChunkMesh puts it in.
+			width := 1
+			for {
+				next := [2]int{start[0] + width, start[1]}
+				if _, ok := cells[next]; !ok || used[next] || next[0]%CHUNK_SIZE == 0 {
+					break
+				}
+				width++
+			}
+			height := 1
+		growRows:
+			for (start[1]+height)%CHUNK_SIZE != 0 {
+				for i := range width {
+					next := [2]int{start[0] + i, start[1] + height}
+					if _, ok := cells[next]; !ok || used[next] {
+						break growRows
+					}
+				}
+				height++
+			}
+			if width == 1 && height == 1 {
+				// A lone face is kept as it is.
+				used[start] = true
+				continue
+			}
+
+			first := cells[start]
+			firstInds := inds[first.triangles[0]*3:][:3]
+
+			quad := mergedQuad{layer: plane.layer}
+			quad.color, _ = colorOf(first.triangles[0])
+			minS, minT := float32(start[0])*GRID_SPACING, float32(start[1])*GRID_SPACING
+			sizeS, sizeT := float32(width)*GRID_SPACING, float32(height)*GRID_SPACING
+			planePos := verts.Pos[firstInds[0]][plane.axis]
+			for c, offset := range [4][2]float32{{0, 0}, {sizeS, 0}, {sizeS, sizeT}, {0, sizeT}} {
+				quad.pos[c][plane.axis] = planePos
+				quad.pos[c][(plane.axis+1)%3] = minS + offset[0]
+				quad.pos[c][(plane.axis+2)%3] = minT + offset[1]
+				quad.normal[c] = verts.Normal[firstInds[0]]
+
+				// Take the texture coordinates from the face at this corner, since extending the first face's coordinates
+				// across the whole quad would add up rounding errors. The first face's coordinates are only used to pick
+				// which repetition of the texture the corner should be in.
+				cornerCell := [2]int{start[0], start[1]}
+				if offset[0] > 0 {
+					cornerCell[0] += width - 1
+				}
+				if offset[1] > 0 {
+					cornerCell[1] += height - 1
+				}
+				localOffset := [2]float32{
+					offset[0] - float32(cornerCell[0]-start[0])*GRID_SPACING,
+					offset[1] - float32(cornerCell[1]-start[1])*GRID_SPACING,
+				}
+				texCoord := cells[cornerCell].mapping.at(localOffset[0], localOffset[1])
+				extended := first.mapping.at(offset[0], offset[1])
+				for i := range texCoord {
+					texCoord[i] += float32(math.Round(float64(extended[i] - texCoord[i])))
+				}
+				quad.texCoord[c] = texCoord
+			}
+			// Keep the winding order of the original faces, so that the quad faces the same way.
+			if windingSign(verts.Pos[firstInds[0]], verts.Pos[firstInds[1]], verts.Pos[firstInds[2]], quad.normal[0]) !=
+				windingSign(quad.pos[0], quad.pos[1], quad.pos[2], quad.normal[0]) {
+				quad.pos[1], quad.pos[3] = quad.pos[3], quad.pos[1]
+				quad.texCoord[1], quad.texCoord[3] = quad.texCoord[3], quad.texCoord[1]
+			}
+
+			quadIndex := len(groupQuads[plane.group])
+			groupQuads[plane.group] = append(groupQuads[plane.group], quad)
+			for j := range height {
+				for i := range width {
+					cell := [2]int{start[0] + i, start[1] + j}
+					used[cell] = true
+					for _, tri := range cells[cell].triangles {
+						mergedInto[tri] = quadIndex
+					}
+				}
+			}
+		}
+	}
+
+	if len(mergedInto) == 0 {
+		return mesh, triMap
+	}
+
+	// Copy the remaining triangles and the merged quads into a new mesh.
+	newVerts := geom.Vertices{
+		Pos:      make([]mgl32.Vec3, 0, len(verts.Pos)),
+		TexCoord: make([]mgl32.Vec2, 0, len(verts.Pos)),
+		Normal:   make([]mgl32.Vec3, 0, len(verts.Pos)),
+	}
+	if verts.Layer != nil {
+		newVerts.Layer = make([]float32, 0, len(verts.Pos))
+	}
+	if verts.Color != nil {
+		newVerts.Color = make([]mgl32.Vec4, 0, len(verts.Pos))
+	}
+	newInds := make([]uint32, 0, len(inds))
+	addVertex := func(pos, normal mgl32.Vec3, texCoord mgl32.Vec2, layer float32, color mgl32.Vec4) uint32 {
+		newVerts.Pos = append(newVerts.Pos, pos)
+		newVerts.TexCoord = append(newVerts.TexCoord, texCoord)
+		newVerts.Normal = append(newVerts.Normal, normal)
+		if newVerts.Layer != nil {
+			newVerts.Layer = append(newVerts.Layer, layer)
+		}
+		if newVerts.Color != nil {
+			newVerts.Color = append(newVerts.Color, color)
+		}
+		return uint32(len(newVerts.Pos) - 1)
+	}
+
+	newTriangles := make([]int, len(inds)/3)        // Maps the kept triangles to their new indices.
+	quadTriangles := make([][]int, len(groupNames)) // First new triangle index of each merged quad, by group.
+	newGroups := make([]geom.Group, len(groupNames))
+	for g, groupName := range groupNames {
+		group := mesh.Group(groupName)
+		newGroups[g].Offset = len(newInds)
+		for tri := group.Offset / 3; tri < (group.Offset+group.Length)/3; tri++ {
+			if _, merged := mergedInto[tri]; merged {
+				continue
+			}
+			newTriangles[tri] = len(newInds) / 3
+			for _, ind := range inds[tri*3:][:3] {
+				var color mgl32.Vec4
+				if verts.Color != nil {
+					color = verts.Color[ind]
+				}
+				newInds = append(newInds, addVertex(verts.Pos[ind], verts.Normal[ind], verts.TexCoord[ind], layerOf(tri), color))
+			}
+		}
+		quadTriangles[g] = make([]int, len(groupQuads[g]))
+		for q, quad := range groupQuads[g] {
+			quadTriangles[g][q] = len(newInds) / 3
+			var quadInds [4]uint32
+			for c := range quadInds {
+				quadInds[c] = addVertex(quad.pos[c], quad.normal[c], quad.texCoord[c], quad.layer, quad.color)
+			}
+			for _, c := range [6]int{0, 1, 2, 0, 2, 3} {
+				newInds = append(newInds, quadInds[c])
+			}
+		}
+		newGroups[g].Length = len(newInds) - newGroups[g].Offset
+	}
+
+	newMesh := geom.CreateMesh(newVerts, newInds)
+	for g, group := range newGroups {
+		newMesh.SetGroup(groupNames[g], group)
+	}
+
+	newTriMap := make(TriMap, len(triMap))
+	for t, triangles := range triMap {
+		if triangles == nil {
+			continue
+		}
+		newTriMap[t] = make([]int, 0, len(triangles))
+		for _, tri := range triangles {
+			quad, merged := mergedInto[tri]
+			if !merged {
+				newTriMap[t] = append(newTriMap[t], newTriangles[tri])
+				continue
+			}
+			first := quadTriangles[triGroups[tri]][quad]
+			if !slices.Contains(newTriMap[t], first) {
+				newTriMap[t] = append(newTriMap[t], first, first+1)
+			}
+		}
+	}
+
+	return newMesh, newTriMap
+}
+
+// Finds the axis-aligned plane that the triangle lies on. Returns false if the triangle isn't axis-aligned or its vertex normals don't match its plane.
+func trianglePlane(verts geom.Vertices, triInds []uint32) (mergePlane, bool) {
+	p0, p1, p2 := verts.Pos[triInds[0]], verts.Pos[triInds[1]], verts.Pos[triInds[2]]
+	if p1.Sub(p0).Cross(p2.Sub(p0)).Len() < MERGE_EPSILON {
+		return mergePlane{}, false
+	}
+	normal := verts.Normal[triInds[0]]
+	for axis := range 3 {
+		if !nearlyEqual(mgl32.Abs(normal[axis]), 1.0) ||
+			!nearlyEqual(normal[(axis+1)%3], 0.0) || !nearlyEqual(normal[(axis+2)%3], 0.0) {
+			continue
+		}
+		for _, ind := range triInds {
+			if !nearlyEqual(verts.Pos[ind][axis], p0[axis]) || !verts.Normal[ind].ApproxEqualThreshold(normal, MERGE_EPSILON) {
+				return mergePlane{}, false
+			}
+		}
+		return mergePlane{
+			axis:     axis,
+			facingUp: normal[axis] > 0.0,
+			coord:    quantize(p0[axis]),
+		}, true
+	}
+	return mergePlane{}, false
+}
+
+// Checks that the two triangles together cover one grid cell of their plane, with texture coordinates that change linearly across it.
+// Returns the cell's position on the plane in grid units, and the texture coordinate mapping.
+func cellOfTriangles(verts geom.Vertices, inds []uint32, axis int, triA, triB int) ([2]int, uvMapping, bool) {
+	minS, minT := float32(math.Inf(1)), float32(math.Inf(1))
+	var area float32
+	for _, tri := range [2]int{triA, triB} {
+		var points [3][2]float32
+		for i, ind := range inds[tri*3:][:3] {
+			points[i][0], points[i][1] = planeCoords(verts.Pos[ind], axis)
+			minS, minT = min(minS, points[i][0]), min(minT, points[i][1])
+		}
+		area += mgl32.Abs((points[1][0]-points[0][0])*(points[2][1]-points[0][1])-(points[2][0]-points[0][0])*(points[1][1]-points[0][1])) / 2.0
+	}
+	cell := [2]int{int(math.Round(float64(minS / GRID_SPACING))), int(math.Round(float64(minT / GRID_SPACING)))}
+	if !nearlyEqual(minS, float32(cell[0])*GRID_SPACING) ||
+		!nearlyEqual(minT, float32(cell[1])*GRID_SPACING) ||
+		!nearlyEqual(area, GRID_SPACING*GRID_SPACING) {
+		return cell, uvMapping{}, false
+	}
+
+	// Every vertex must be on a corner of the cell.
+	corners := make(map[[2]int]struct{}, 4)
+	for _, tri := range [2]int{triA, triB} {
+		for _, ind := range inds[tri*3:][:3] {
+			s, t := planeCoords(verts.Pos[ind], axis)
+			cs, ct := (s-minS)/GRID_SPACING, (t-minT)/GRID_SPACING
+			if !(nearlyEqual(cs, 0.0) || nearlyEqual(cs, 1.0)) || !(nearlyEqual(ct, 0.0) || nearlyEqual(ct, 1.0)) {
+				return cell, uvMapping{}, false
+			}
+			corners[[2]int{int(math.Round(float64(cs))), int(math.Round(float64(ct)))}] = struct{}{}
+		}
+	}
+	if len(corners) != 4 {
+		return cell, uvMapping{}, false
+	}
+
+	// Solve for the texture coordinate mapping using the first triangle, then check that the second one follows it.
+	// The coordinates are measured from the cell's corner to keep the numbers small.
+	cellCoords := func(ind uint32) (float32, float32) {
+		s, t := planeCoords(verts.Pos[ind], axis)
+		return s - minS, t - minT
+	}
+	triInds := inds[triA*3:][:3]
+	s0, t0 := cellCoords(triInds[0])
+	s1, t1 := cellCoords(triInds[1])
+	s2, t2 := cellCoords(triInds[2])
+	uv0, uv1, uv2 := verts.TexCoord[triInds[0]], verts.TexCoord[triInds[1]], verts.TexCoord[triInds[2]]
+	ds1, dt1, ds2, dt2 := s1-s0, t1-t0, s2-s0, t2-t0
+	det := ds1*dt2 - ds2*dt1
+	if mgl32.Abs(det) < MERGE_EPSILON {
+		return cell, uvMapping{}, false
+	}
+	duv1, duv2 := uv1.Sub(uv0), uv2.Sub(uv0)
+	var mapping uvMapping
+	mapping.scale[0] = duv1.Mul(dt2).Sub(duv2.Mul(dt1)).Mul(1.0 / det)
+	mapping.scale[1] = duv2.Mul(ds1).Sub(duv1.Mul(ds2)).Mul(1.0 / det)
+	mapping.corner = uv0.Sub(mapping.scale[0].Mul(s0)).Sub(mapping.scale[1].Mul(t0))
+
+	for _, tri := range [2]int{triA, triB} {
+		for _, ind := range inds[tri*3:][:3] {
+			s, t := cellCoords(ind)
+			if texCoord := mapping.at(s, t); !nearlyEqual(texCoord[0], verts.TexCoord[ind][0]) || !nearlyEqual(texCoord[1], verts.TexCoord[ind][1]) {
+				return cell, uvMapping{}, false
+			}
+		}
+	}
+	return cell, mapping, true
+}
+
+// Compares with an absolute tolerance, since mgl32's comparisons are relative to the size of the numbers.
+func nearlyEqual(a, b float32) bool {
+	return mgl32.Abs(a-b) <= MERGE_EPSILON
+}
+
+// Returns true if the triangle winds counter-clockwise around the normal.
+func windingSign(p0, p1, p2, normal mgl32.Vec3) bool {
+	return p1.Sub(p0).Cross(p2.Sub(p0)).Dot(normal) > 0.0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package te3
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+)
+
+func TestMergeFaces(t *testing.T) {
+	// A shape with only a floor, so that neighboring tiles don't cull each other's faces.
+	floor := geom.CreateMesh(geom.Vertices{
+		Pos:      []mgl32.Vec3{{-1, -1, -1}, {1, -1, -1}, {1, -1, 1}, {-1, -1, 1}},
+		TexCoord: []mgl32.Vec2{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+		Normal:   []mgl32.Vec3{{0, 1, 0}, {0, 1, 0}, {0, 1, 0}, {0, 1, 0}},
+	}, []uint32{0, 2, 1, 0, 3, 2})
+
+	te3File := &TE3File{Tiles: Tiles{
+		Width: 3, Height: 1, Length: 2,
+		Data:     make([]Tile, 6),
+		Textures: []string{"floor.png"},
+		Shapes:   []string{"floor.obj"},
+	}}
+	mesh, triMap := te3File.BuildMeshFromShapes([]*geom.Mesh{floor})
+	if count := len(mesh.Inds()) / 3; count != 12 {
+		t.Fatalf("Built %v triangles instead of 12", count)
+	}
+
+	unmerged, _ := te3File.MergeFaces(mesh, triMap, func(string) bool { return false })
+	if unmerged != mesh {
+		t.Errorf("Faces were merged in a group that can't be merged")
+	}
+
+	merged, mergedTriMap := te3File.MergeFaces(mesh, triMap, func(string) bool { return true })
+	if count := len(merged.Inds()) / 3; count != 2 {
+		t.Fatalf("Merged into %v triangles instead of 2", count)
+	}
+	for tile, triangles := range mergedTriMap {
+		if !slices.Equal(triangles, []int{0, 1}) {
+			t.Errorf("Tile %v maps to triangles %v instead of the merged quad", tile, triangles)
+		}
+	}
+
+	verts := merged.Verts()
+	minUV, maxUV := verts.TexCoord[0], verts.TexCoord[0]
+	for _, ind := range merged.Inds() {
+		for i := range 2 {
+			minUV[i], maxUV[i] = min(minUV[i], verts.TexCoord[ind][i]), max(maxUV[i], verts.TexCoord[ind][i])
+		}
+	}
+	// The texture should repeat once for each tile that the quad covers.
+	if size := maxUV.Sub(minUV); !size.ApproxEqual(mgl32.Vec2{3, 2}) {
+		t.Errorf("Texture coordinates span %v instead of [3 2]", size)
+	}
+
+	inds := merged.Inds()
+	for tri := range 2 {
+		p0, p1, p2 := verts.Pos[inds[tri*3]], verts.Pos[inds[tri*3+1]], verts.Pos[inds[tri*3+2]]
+		if !windingSign(p0, p1, p2, mgl32.Vec3{0, 1, 0}) {
+			t.Errorf("Merged triangle %v faces the wrong way", tri)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package te3
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	PREFAB_ENT_TYPE = "prefab" // Type of the ents that are replaced by the contents of another map.
+	PREFAB_MAP      = "map"    // Property of prefab ents with the name of the map to stamp, relative to assets/maps and without the extension.
+)
+
+// Returns the path of the map file that a prefab ent refers to by name.
+func PrefabPath(name string) string {
+	return "assets/maps/" + name + ".te3"
+}
+
+// Replaces the map's prefab ents with the tiles and ents of the maps they refer to, including the prefabs inside of those.
+// Each prefab is stamped with the corner of its grid at the ent's grid position, rotated by the ent's yaw rounded to 90 degrees.
+// This must happen before the map's mesh and collision shapes are made.
+func (te3 *TE3File) InstancePrefabs() error {
+	return te3.instancePrefabs(make(map[string]*TE3File), nil)
+}
+
+// Instances the prefabs, loading each map only once. The stack holds the paths of the prefabs that are being instanced, to catch prefabs that include themselves.
+func (te3 *TE3File) instancePrefabs(loaded map[string]*TE3File, stack []string) error {
+	ents := te3.Ents
+	te3.Ents = make([]Ent, 0, len(ents))
+	var prefabEnts []Ent
+	for _, ent := range ents {
+		if ent.Properties["type"] == PREFAB_ENT_TYPE {
+			prefabEnts = append(prefabEnts, ent)
+		} else {
+			te3.Ents = append(te3.Ents, ent)
+		}
+	}
+
+	for _, ent := range prefabEnts {
+		name, ok := ent.Properties[PREFAB_MAP]
+		if !ok {
+			return fmt.Errorf("prefab at %v is missing the '%v' property", ent.GridPosition(), PREFAB_MAP)
+		}
+		prefabPath := PrefabPath(name)
+		for _, p := range stack {
+			if p == prefabPath {
+				return fmt.Errorf("prefab %v includes itself", prefabPath)
+			}
+		}
+		prefab, ok := loaded[prefabPath]
+		if !ok {
+			var err error
+			if prefab, err = LoadTE3File(prefabPath); err != nil {
+				return err
+			}
+			if err := prefab.instancePrefabs(loaded, append(stack, prefabPath)); err != nil {
+				return err
+			}
+			loaded[prefabPath] = prefab
+		}
+		te3.prefabPaths = append(te3.prefabPaths, prefabPath)
+		te3.prefabPaths = append(te3.prefabPaths, prefab.prefabPaths...)
+		yawSteps := int(math.Round(float64(ent.Angles[1]) / 90.0))
+		if err := te3.StampPrefab(prefab, ent.GridPosition(), yawSteps); err != nil {
+			return fmt.Errorf("could not stamp prefab %v at %v: %w", prefabPath, ent.GridPosition(), err)
+		}
+	}
+	return nil
+}
+
+// Copies the tiles and ents of the prefab map into this one, with the corner of the prefab's grid at the given grid position.
+// The prefab is turned around the vertical axis by the given number of 90 degree steps, in the same direction as ent yaw.
+// Empty tiles in the prefab leave the map's tiles alone. The link numbers of the prefab's ents are changed so that they don't
+// collide with the ones already in the map, and its level properties are left out.
+func (te3 *TE3File) StampPrefab(prefab *TE3File, corner [3]int, yawSteps int) error {
+	yawSteps = ((yawSteps % 4) + 4) % 4
+	prefabTiles := &prefab.Tiles
+
+	// Rotate around the center of the prefab, then move its rotated corner to the destination.
+	size := mgl32.Vec3{float32(prefabTiles.Width), float32(prefabTiles.Height), float32(prefabTiles.Length)}.Mul(GRID_SPACING)
+	rotatedSize := size
+	if yawSteps%2 == 1 {
+		rotatedSize[0], rotatedSize[2] = size[2], size[0]
+	}
+	origin := te3.Tiles.GridToWorldPos(corner[0], corner[1], corner[2], false)
+	transformPoint := func(pos mgl32.Vec3) mgl32.Vec3 {
+		pos = pos.Sub(size.Mul(0.5))
+		// Swapping the axes instead of using a rotation matrix keeps grid-aligned positions exact.
+		for range yawSteps {
+			pos[0], pos[2] = pos[2], -pos[0]
+		}
+		return pos.Add(rotatedSize.Mul(0.5)).Add(origin)
+	}
+
+	// The prefab's shapes and textures are added to the map's lists if it doesn't use them already.
+	idOf := func(list *[]string, path string) int16 {
+		for i, p := range *list {
+			if p == path {
+				return int16(i)
+			}
+		}
+		*list = append(*list, path)
+		return int16(len(*list) - 1)
+	}
+	shapeIDs := make([]ShapeID, len(prefabTiles.Shapes))
+	for i, shapePath := range prefabTiles.Shapes {
+		shapeIDs[i] = ShapeID(idOf(&te3.Tiles.Shapes, shapePath))
+	}
+	textureIDs := make([]TextureID, len(prefabTiles.Textures))
+	for i, texPath := range prefabTiles.Textures {
+		textureIDs[i] = TextureID(idOf(&te3.Tiles.Textures, texPath))
+	}
+
+	for t, tile := range prefabTiles.Data {
+		if tile.ShapeID < 0 {
+			continue
+		}
+		px, py, pz := prefabTiles.UnflattenGridPos(t)
+		center := transformPoint(prefabTiles.GridToWorldPos(px, py, pz, true))
+		x, y, z := te3.Tiles.WorldToGridPos(center)
+		if center.X() < 0.0 || center.Y() < 0.0 || center.Z() < 0.0 || te3.Tiles.OutOfBounds(x, y, z) {
+			return fmt.Errorf("tile %v of the prefab lands outside of the map at %v", t, [3]int{x, y, z})
+		}
+		te3.Tiles.Data[te3.Tiles.FlattenGridPos(x, y, z)] = Tile{
+			ShapeID:    shapeIDs[tile.ShapeID],
+			TextureIDs: [2]TextureID{textureIDs[tile.TextureIDs[0]], textureIDs[tile.TextureIDs[1]]},
+			// Tiles are turned the opposite way from ents for each step of yaw.
+			Yaw:   uint8((int(tile.Yaw) + 4 - yawSteps) % 4),
+			Pitch: tile.Pitch,
+		}
+	}
+
+	linkOffset := 0
+	for _, ent := range te3.Ents {
+		if link, err := ent.IntProperty("link"); err == nil {
+			linkOffset = max(linkOffset, link)
+		}
+	}
+	for _, ent := range prefab.Ents {
+		if ent.Properties["name"] == "level properties" {
+			continue
+		}
+		ent.Properties = maps.Clone(ent.Properties)
+		ent.Position = transformPoint(ent.Position)
+		ent.Angles[1] = float32(math.Mod(float64(ent.Angles[1])+float64(yawSteps)*90.0, 360.0))
+		if link, err := ent.IntProperty("link"); err == nil && link != 0 {
+			ent.Properties["link"] = strconv.Itoa(link + linkOffset)
+		}
+		te3.Ents = append(te3.Ents, ent)
+	}
+	return nil
+}
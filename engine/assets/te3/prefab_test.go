@@ -0,0 +1,81 @@
+package te3
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestStampPrefab(t *testing.T) {
+	// A 2x1x1 prefab with a wedge on the left, and a door linked to a switch.
+	prefab := &TE3File{
+		Tiles: Tiles{
+			Width: 2, Height: 1, Length: 1,
+			Data:     []Tile{{ShapeID: 0, TextureIDs: [2]TextureID{0, 0}, Yaw: 1}, {ShapeID: -1}},
+			Textures: []string{"pad.png"},
+			Shapes:   []string{"wedge.obj"},
+		},
+		Ents: []Ent{
+			{Position: [3]float32{3, 1, 1}, Properties: map[string]string{"type": "door", "link": "1"}},
+			{Position: [3]float32{1, 1, 1}, Properties: map[string]string{"type": "switch", "link": "1"}},
+			{Properties: map[string]string{"name": "level properties"}},
+		},
+	}
+
+	te3File := &TE3File{
+		Tiles: Tiles{
+			Width: 4, Height: 1, Length: 4,
+			Data:     make([]Tile, 16),
+			Textures: []string{"wall.png"},
+			Shapes:   []string{"cube.obj"},
+		},
+		Ents: []Ent{{Properties: map[string]string{"type": "trigger", "link": "3"}}},
+	}
+	for i := range te3File.Tiles.Data {
+		te3File.Tiles.Data[i] = Tile{ShapeID: -1}
+	}
+
+	// A quarter turn puts the prefab along the Z axis, with its left tile at the far end.
+	if err := te3File.StampPrefab(prefab, [3]int{1, 0, 1}, 1); err != nil {
+		t.Fatal(err)
+	}
+	tiles := &te3File.Tiles
+	stamped := tiles.Data[tiles.FlattenGridPos(1, 0, 2)]
+	expected := Tile{ShapeID: 1, TextureIDs: [2]TextureID{1, 1}, Yaw: 0}
+	if stamped != expected {
+		t.Errorf("Stamped tile is %+v instead of %+v", stamped, expected)
+	}
+	for i, tile := range tiles.Data {
+		if i != tiles.FlattenGridPos(1, 0, 2) && tile.ShapeID >= 0 {
+			t.Errorf("Tile %v was filled in by an empty tile of the prefab", i)
+		}
+	}
+	if tiles.Shapes[1] != "wedge.obj" || tiles.Textures[1] != "pad.png" {
+		t.Errorf("The prefab's shapes and textures weren't added to the map: %v, %v", tiles.Shapes, tiles.Textures)
+	}
+
+	if len(te3File.Ents) != 3 {
+		t.Fatalf("Map has %v ents instead of 3", len(te3File.Ents))
+	}
+	door, swtch := te3File.Ents[1], te3File.Ents[2]
+	if pos := mgl32.Vec3(door.Position); !pos.ApproxEqual(mgl32.Vec3{3, 1, 3}) {
+		t.Errorf("Door is at %v instead of [3 1 3]", pos)
+	}
+	if pos := mgl32.Vec3(swtch.Position); !pos.ApproxEqual(mgl32.Vec3{3, 1, 5}) {
+		t.Errorf("Switch is at %v instead of [3 1 5]", pos)
+	}
+	if !mgl32.FloatEqual(door.Angles[1], 90) {
+		t.Errorf("Door has a yaw of %v instead of 90", door.Angles[1])
+	}
+	if door.Properties["link"] != "4" || swtch.Properties["link"] != "4" {
+		t.Errorf("Links were numbered %v and %v instead of 4", door.Properties["link"], swtch.Properties["link"])
+	}
+	if prefab.Ents[0].Properties["link"] != "1" {
+		t.Errorf("Stamping changed the prefab's own ents")
+	}
+
+	// Turned around in the corner of the map, the wedge would go past the edge.
+	if err := te3File.StampPrefab(prefab, [3]int{3, 0, 3}, 2); err == nil {
+		t.Errorf("Prefab was stamped outside of the map")
+	}
+}
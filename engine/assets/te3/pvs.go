@@ -0,0 +1,292 @@
+package te3
+
+import (
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+)
+
+const (
+	PVS_CELL_SIZE = 4 // Width, height, and length in tiles of the cells that the potentially visible set is made of.
+)
+
+// Potentially visible set: which cells of the map might be seen from each other.
+// The cells are cubes of PVS_CELL_SIZE tiles, numbered in the same order as the tiles.
+type PVS struct {
+	counts [3]int   // Number of cells along each axis.
+	bits   []uint64 // Bit matrix where bit (from * cell count + to) is set if the cell 'to' can be seen from the cell 'from'.
+}
+
+// Creates a potentially visible set for a grid of tiles, where none of the cells can see each other.
+func NewPVS(tiles *Tiles) *PVS {
+	pvs := &PVS{
+		counts: [3]int{
+			max(1, (tiles.Width+PVS_CELL_SIZE-1)/PVS_CELL_SIZE),
+			max(1, (tiles.Height+PVS_CELL_SIZE-1)/PVS_CELL_SIZE),
+			max(1, (tiles.Length+PVS_CELL_SIZE-1)/PVS_CELL_SIZE),
+		},
+	}
+	cellCount := pvs.CellCount()
+	pvs.bits = make([]uint64, (cellCount*cellCount+63)/64)
+	return pvs
+}
+
+func (pvs *PVS) CellCount() int {
+	return pvs.counts[0] * pvs.counts[1] * pvs.counts[2]
+}
+
+// Returns the index of the cell at the given coordinates.
+func (pvs *PVS) FlattenCellCoords(coords [3]int) int {
+	return coords[0] + (coords[2] * pvs.counts[0]) + (coords[1] * pvs.counts[0] * pvs.counts[2])
+}
+
+// Returns the coordinates of the cell containing the world position, clamped to the nearest cell,
+// along with false if the position is outside of the map.
+func (pvs *PVS) CellCoords(pos mgl32.Vec3) ([3]int, bool) {
+	inside := true
+	var coords [3]int
+	for i := range coords {
+		c := int(math.Floor(float64(pos[i] / (PVS_CELL_SIZE * GRID_SPACING))))
+		inside = inside && c >= 0 && c < pvs.counts[i]
+		coords[i] = min(max(c, 0), pvs.counts[i]-1)
+	}
+	return coords, inside
+}
+
+// Returns true if the cell 'to' might be seen from the cell 'from'.
+func (pvs *PVS) CanSee(from, to int) bool {
+	bit := from*pvs.CellCount() + to
+	return pvs.bits[bit/64]&(1<<(bit%64)) != 0
+}
+
+// Marks the two cells as able to see each other.
+func (pvs *PVS) SetVisible(a, b int) {
+	cellCount := pvs.CellCount()
+	for _, bit := range [2]int{a*cellCount + b, b*cellCount + a} {
+		pvs.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Returns true if any part of the box might be seen from the given position.
+// Everything is visible from outside of the map, or if the set is nil.
+func (pvs *PVS) CanSeeBox(from mgl32.Vec3, box math2.Box) bool {
+	if pvs == nil {
+		return true
+	}
+	fromCoords, inside := pvs.CellCoords(from)
+	if !inside {
+		return true
+	}
+	fromCell := pvs.FlattenCellCoords(fromCoords)
+	minCoords, _ := pvs.CellCoords(box.Min)
+	maxCoords, _ := pvs.CellCoords(box.Max)
+	for y := minCoords[1]; y <= maxCoords[1]; y++ {
+		for z := minCoords[2]; z <= maxCoords[2]; z++ {
+			for x := minCoords[0]; x <= maxCoords[0]; x++ {
+				if pvs.CanSee(fromCell, pvs.FlattenCellCoords([3]int{x, y, z})) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// A tile that sight lines are cast from or to, with the faces of its cell that it is on.
+type pvsSample struct {
+	tile  [3]int
+	faces uint8
+}
+
+// Returns the bit for the face of a cell on the given axis, in the given direction.
+func pvsFace(axis, dir int) uint8 {
+	if dir < 0 {
+		return 1 << (axis * 2)
+	}
+	return 1 << (axis*2 + 1)
+}
+
+// Finds which cells of the map might be seen from each other, with sight blocked by the tiles that blocksSight returns true for.
+// Sight lines are cast between the centers of every open tile and visible surface on the boundaries of each pair of cells,
+// so any opening of at least one tile is found.
+// Cells that touch each other can always see each other.
+func (te3 *TE3File) ComputePVS(blocksSight func(tile Tile) bool) *PVS {
+	tiles := &te3.Tiles
+	pvs := NewPVS(tiles)
+	cellCount := pvs.CellCount()
+
+	solid := make([]bool, len(tiles.Data))
+	for t, tile := range tiles.Data {
+		solid[t] = tile.ShapeID >= 0 && blocksSight(tile)
+	}
+	isSolid := func(x, y, z int) bool {
+		return !tiles.OutOfBounds(x, y, z) && solid[tiles.FlattenGridPos(x, y, z)]
+	}
+
+	// Pick the tiles to cast sight lines between: the open tiles where the camera could be, and the solid tiles next to open ones, which can be seen.
+	// Any line of sight between two cells leaves one and enters the other through the tiles on their boundaries, so only those are needed.
+	cellCoords := make([][3]int, cellCount)
+	samples := make([][]pvsSample, cellCount)
+	for cy := range pvs.counts[1] {
+		for cz := range pvs.counts[2] {
+			for cx := range pvs.counts[0] {
+				coords := [3]int{cx, cy, cz}
+				c := pvs.FlattenCellCoords(coords)
+				cellCoords[c] = coords
+
+				var minTile, maxTile [3]int
+				for i, size := range [3]int{tiles.Width, tiles.Height, tiles.Length} {
+					minTile[i] = coords[i] * PVS_CELL_SIZE
+					maxTile[i] = min((coords[i]+1)*PVS_CELL_SIZE, size) - 1
+				}
+				for y := minTile[1]; y <= maxTile[1]; y++ {
+					for z := minTile[2]; z <= maxTile[2]; z++ {
+						for x := minTile[0]; x <= maxTile[0]; x++ {
+							sample := pvsSample{tile: [3]int{x, y, z}}
+							for i := range 3 {
+								if sample.tile[i] == minTile[i] {
+									sample.faces |= pvsFace(i, -1)
+								}
+								if sample.tile[i] == maxTile[i] {
+									sample.faces |= pvsFace(i, 1)
+								}
+							}
+							if sample.faces != 0 && (!isSolid(x, y, z) ||
+								!isSolid(x-1, y, z) || !isSolid(x+1, y, z) ||
+								!isSolid(x, y-1, z) || !isSolid(x, y+1, z) ||
+								!isSolid(x, y, z-1) || !isSolid(x, y, z+1)) {
+								samples[c] = append(samples[c], sample)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Check the rows of the matrix in parallel, each one listing the later cells that its cell can see.
+	visible := make([][]int, cellCount)
+	var wg sync.WaitGroup
+	workerSlots := make(chan struct{}, runtime.NumCPU())
+	for a := range cellCount {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workerSlots <- struct{}{}
+			defer func() { <-workerSlots }()
+
+			var fromTiles, toTiles [][3]int
+			for b := a + 1; b < cellCount; b++ {
+				// A line between the cells only moves towards the other cell along each axis,
+				// so it can only pass through the faces that face the other cell on the axes that they are apart on.
+				touching := true
+				var facesOfA, facesOfB uint8
+				for i := range 3 {
+					diff := cellCoords[b][i] - cellCoords[a][i]
+					touching = touching && max(diff, -diff) <= 1
+					if diff > 0 {
+						facesOfA, facesOfB = facesOfA|pvsFace(i, 1), facesOfB|pvsFace(i, -1)
+					} else if diff < 0 {
+						facesOfA, facesOfB = facesOfA|pvsFace(i, -1), facesOfB|pvsFace(i, 1)
+					}
+				}
+				if touching {
+					visible[a] = append(visible[a], b)
+					continue
+				}
+				fromTiles, toTiles = fromTiles[:0], toTiles[:0]
+				for _, sample := range samples[a] {
+					if sample.faces&facesOfA != 0 {
+						fromTiles = append(fromTiles, sample.tile)
+					}
+				}
+				for _, sample := range samples[b] {
+					if sample.faces&facesOfB != 0 {
+						toTiles = append(toTiles, sample.tile)
+					}
+				}
+				if tiles.anySightLine(fromTiles, toTiles, solid) {
+					visible[a] = append(visible[a], b)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for a, cells := range visible {
+		pvs.SetVisible(a, a)
+		for _, b := range cells {
+			pvs.SetVisible(a, b)
+		}
+	}
+
+	return pvs
+}
+
+// Returns true if any of the tiles in one list can see any of the tiles in the other.
+// Lines between two solid tiles are skipped, since one end of a line of sight is always where the camera is.
+func (tiles *Tiles) anySightLine(fromTiles, toTiles [][3]int, solid []bool) bool {
+	for _, from := range fromTiles {
+		fromSolid := solid[tiles.FlattenGridPos(from[0], from[1], from[2])]
+		for _, to := range toTiles {
+			if fromSolid && solid[tiles.FlattenGridPos(to[0], to[1], to[2])] {
+				continue
+			}
+			if tiles.sightLine(from, to, solid) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns true if there are no solid tiles on the line between the centers of the two tiles, not counting the tiles themselves.
+// Walks through every tile that the line passes through. Where the line passes exactly through an edge or a corner, it steps diagonally.
+func (tiles *Tiles) sightLine(from, to [3]int, solid []bool) bool {
+	// The line only moves towards the end along each axis, so it never leaves the grid.
+	stride := [3]int{1, tiles.Width * tiles.Length, tiles.Width}
+	var step, dist [3]int
+	for i := range 3 {
+		dist[i] = to[i] - from[i]
+		step[i] = stride[i]
+		if dist[i] < 0 {
+			step[i], dist[i] = -stride[i], -dist[i]
+		}
+	}
+	// Since the line goes between tile centers, the crossings along each axis can be counted in whole numbers:
+	// the line crosses its n-th border along axis i at (2n + 1) / (2 * dist[i]) of the way, so the fractions are compared by cross-multiplying.
+	var crossed [3]int
+	index := tiles.FlattenGridPos(from[0], from[1], from[2])
+	end := tiles.FlattenGridPos(to[0], to[1], to[2])
+	for {
+		next := -1
+		for i := range 3 {
+			if crossed[i] == dist[i] {
+				continue
+			}
+			if next < 0 || (2*crossed[i]+1)*dist[next] < (2*crossed[next]+1)*dist[i] {
+				next = i
+			}
+		}
+		if next < 0 {
+			return true
+		}
+		// Step along every axis that crosses a border at the same point.
+		nextCrossing := [2]int{2*crossed[next] + 1, dist[next]}
+		for i := range 3 {
+			if crossed[i] < dist[i] && (2*crossed[i]+1)*nextCrossing[1] == nextCrossing[0]*dist[i] {
+				crossed[i]++
+				index += step[i]
+			}
+		}
+		if index == end {
+			return true
+		}
+		if solid[index] {
+			return false
+		}
+	}
+}
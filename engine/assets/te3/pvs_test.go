@@ -0,0 +1,92 @@
+package te3
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+)
+
+func TestComputePVS(t *testing.T) {
+	// A corridor three cells long, with a solid wall across the middle cell.
+	const width = PVS_CELL_SIZE * 3
+	te3File := &TE3File{Tiles: Tiles{
+		Width: width, Height: 1, Length: 1,
+		Data:     make([]Tile, width),
+		Textures: []string{"wall.png"},
+		Shapes:   []string{"cube.obj"},
+	}}
+	for x := range width {
+		if x < PVS_CELL_SIZE+1 || x > PVS_CELL_SIZE+2 {
+			te3File.Tiles.Data[x] = Tile{ShapeID: -1}
+		}
+	}
+	pvs := te3File.ComputePVS(func(Tile) bool { return true })
+	if count := pvs.CellCount(); count != 3 {
+		t.Fatalf("Made %v cells instead of 3", count)
+	}
+	if !pvs.CanSee(0, 1) || !pvs.CanSee(1, 2) {
+		t.Errorf("Touching cells can't see each other")
+	}
+	if pvs.CanSee(0, 2) || pvs.CanSee(2, 0) {
+		t.Errorf("Cells on both sides of the wall can see each other")
+	}
+
+	cellBox := func(c int) math2.Box {
+		const cellWidth = PVS_CELL_SIZE * GRID_SPACING
+		return math2.Box{
+			Min: mgl32.Vec3{float32(c)*cellWidth + 0.5, 0.5, 0.5},
+			Max: mgl32.Vec3{float32(c+1)*cellWidth - 0.5, 1.5, 1.5},
+		}
+	}
+	left := mgl32.Vec3{1.0, 1.0, 1.0}
+	if pvs.CanSeeBox(left, cellBox(2)) {
+		t.Errorf("A box behind the wall is visible")
+	}
+	if !pvs.CanSeeBox(left, cellBox(1)) {
+		t.Errorf("A box in the neighboring cell isn't visible")
+	}
+	if !pvs.CanSeeBox(mgl32.Vec3{-10.0, 1.0, 1.0}, cellBox(2)) {
+		t.Errorf("A box isn't visible from outside of the map")
+	}
+
+	// Without the wall, the whole corridor can be seen.
+	pvs = te3File.ComputePVS(func(Tile) bool { return false })
+	if !pvs.CanSee(0, 2) {
+		t.Errorf("Cells at both ends of an open corridor can't see each other")
+	}
+}
+
+func TestComputePVSSmallOpening(t *testing.T) {
+	// Two rooms, with a wall between them that has a single open tile in it.
+	const width, height, length = PVS_CELL_SIZE * 3, PVS_CELL_SIZE, PVS_CELL_SIZE * 3
+	te3File := &TE3File{Tiles: Tiles{
+		Width: width, Height: height, Length: length,
+		Data:     make([]Tile, width*height*length),
+		Textures: []string{"wall.png"},
+		Shapes:   []string{"cube.obj"},
+	}}
+	for t := range te3File.Tiles.Data {
+		te3File.Tiles.Data[t] = Tile{ShapeID: -1}
+	}
+	const wallX = PVS_CELL_SIZE + 2
+	for y := range height {
+		for z := range length {
+			te3File.Tiles.Data[te3File.Tiles.FlattenGridPos(wallX, y, z)] = Tile{}
+		}
+	}
+	opening := te3File.Tiles.FlattenGridPos(wallX, 1, PVS_CELL_SIZE+1)
+	te3File.Tiles.Data[opening] = Tile{ShapeID: -1}
+
+	pvs := te3File.ComputePVS(func(Tile) bool { return true })
+	left, right := pvs.FlattenCellCoords([3]int{0, 0, 1}), pvs.FlattenCellCoords([3]int{2, 0, 1})
+	if !pvs.CanSee(left, right) || !pvs.CanSee(right, left) {
+		t.Errorf("Rooms can't see each other through the opening")
+	}
+
+	te3File.Tiles.Data[opening] = Tile{}
+	pvs = te3File.ComputePVS(func(Tile) bool { return true })
+	if pvs.CanSee(left, right) {
+		t.Errorf("Rooms can see each other without the opening")
+	}
+}
@@ -0,0 +1,108 @@
+package te3
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
+)
+
+// Types of collision that tile shapes can have.
+const (
+	SHAPE_COLLISION_BOX      = "box"      // An axis aligned box, turned along with the tile.
+	SHAPE_COLLISION_CYLINDER = "cylinder" // An upright cylinder, which isn't turned with the tile.
+	SHAPE_COLLISION_MESH     = "mesh"     // The triangles of the shape's mesh or of a separate collision mesh, turned along with the tile.
+	SHAPE_COLLISION_NONE     = "none"     // Tiles with the shape can be walked through.
+)
+
+// Describes how the tiles with a certain shape collide. It is read from a .json file next to the shape's mesh,
+// such as assets/models/shapes/wedge.json for wedge.obj. Shapes without one are solid boxes that fill their tiles.
+type ShapeCollision struct {
+	Type    string     // One of the SHAPE_COLLISION_* constants.
+	Extents [3]float32 // Distance from the center of a box to its sides along each axis, before the tile is turned. Defaults to filling the tile.
+	Radius  float32    // Radius of a cylinder. Defaults to touching the sides of the tile.
+	Height  float32    // Height of a cylinder. Defaults to the height of the tile.
+	Mesh    string     // Path of the mesh whose triangles are used by mesh collision. Defaults to the shape itself.
+}
+
+// Returns the path of the file that describes the collision of the shape at the given path.
+func ShapeCollisionPath(shapePath string) string {
+	return strings.TrimSuffix(shapePath, path.Ext(shapePath)) + ".json"
+}
+
+// Reads the collision of the shape at the given path, filling in the defaults.
+// A missing collision file is not an error. When there isn't one or it can't be used, the shape is a box that fills the tile.
+func LoadShapeCollision(shapePath string) (ShapeCollision, error) {
+	solid := ShapeCollision{Type: SHAPE_COLLISION_BOX, Extents: [3]float32{HALF_GRID_SPACING, HALF_GRID_SPACING, HALF_GRID_SPACING}}
+	loaded, err := assets.LoadAndUnmarshalJSON[ShapeCollision](ShapeCollisionPath(shapePath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return solid, nil
+	} else if err != nil {
+		return solid, fmt.Errorf("could not read collision of shape %v: %w", shapePath, err)
+	}
+	shapeCollision := *loaded
+
+	switch shapeCollision.Type {
+	case SHAPE_COLLISION_BOX:
+		if shapeCollision.Extents == [3]float32{} {
+			shapeCollision.Extents = [3]float32{HALF_GRID_SPACING, HALF_GRID_SPACING, HALF_GRID_SPACING}
+		}
+	case SHAPE_COLLISION_CYLINDER:
+		if shapeCollision.Radius == 0.0 {
+			shapeCollision.Radius = HALF_GRID_SPACING
+		}
+		if shapeCollision.Height == 0.0 {
+			shapeCollision.Height = GRID_SPACING
+		}
+	case SHAPE_COLLISION_MESH:
+		if shapeCollision.Mesh == "" {
+			shapeCollision.Mesh = shapePath
+		}
+	case SHAPE_COLLISION_NONE:
+	default:
+		return solid, fmt.Errorf("shape %v has unknown collision type %q", shapePath, shapeCollision.Type)
+	}
+	return shapeCollision, nil
+}
+
+// Makes the collision shape of a tile, turned by the tile's rotation.
+// The mesh is only used by mesh collision, and should be the one at the Mesh path.
+// Returns nil if the tile shouldn't collide.
+func (shapeCollision *ShapeCollision) TileShape(tile Tile, mesh *geom.Mesh) collision.Shape {
+	rotation := tile.GetRotationMatrix()
+	switch shapeCollision.Type {
+	case SHAPE_COLLISION_BOX:
+		// Tiles only turn in 90 degree steps, so each axis of the turned box lines up with one of the original axes.
+		var extents mgl32.Vec3
+		for i := range 3 {
+			for j := range 3 {
+				extents[i] += mgl32.Abs(float32(math.Round(float64(rotation.At(i, j))))) * shapeCollision.Extents[j]
+			}
+		}
+		return collision.NewBox(math2.BoxFromExtents(extents[0], extents[1], extents[2]))
+	case SHAPE_COLLISION_CYLINDER:
+		return collision.NewCylinder(shapeCollision.Radius, shapeCollision.Height)
+	case SHAPE_COLLISION_MESH:
+		if mesh == nil {
+			return nil
+		}
+		iter := mesh.IterTriangles()
+		triangles := make([]math2.Triangle, iter.Count())
+		for i := 0; iter.HasNext(); i++ {
+			triangle := iter.Next()
+			for p := range triangle {
+				triangles[i][p] = mgl32.TransformNormal(triangle[p], rotation)
+			}
+		}
+		return collision.NewMeshFromTriangles(triangles)
+	}
+	return nil
+}
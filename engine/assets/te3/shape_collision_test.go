@@ -0,0 +1,71 @@
+package te3
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
+)
+
+func TestShapeCollisionTileShape(t *testing.T) {
+	panel := ShapeCollision{Type: SHAPE_COLLISION_BOX, Extents: [3]float32{1.0, 1.0, 0.5}}
+	for _, test := range []struct {
+		tile     Tile
+		expected math2.Box
+	}{
+		{Tile{Yaw: 0}, math2.BoxFromExtents(1.0, 1.0, 0.5)},
+		{Tile{Yaw: 1}, math2.BoxFromExtents(0.5, 1.0, 1.0)},
+		{Tile{Yaw: 2}, math2.BoxFromExtents(1.0, 1.0, 0.5)},
+		{Tile{Pitch: 1}, math2.BoxFromExtents(1.0, 0.5, 1.0)},
+	} {
+		if box := panel.TileShape(test.tile, nil).Extents(); box != test.expected {
+			t.Errorf("Panel at yaw %v and pitch %v has extents %v instead of %v", test.tile.Yaw, test.tile.Pitch, box, test.expected)
+		}
+	}
+
+	cylinder := ShapeCollision{Type: SHAPE_COLLISION_CYLINDER, Radius: 0.5, Height: 2.0}
+	if shape, ok := cylinder.TileShape(Tile{Yaw: 1}, nil).(collision.Cylinder); !ok || shape.Radius() != 0.5 || shape.Height() != 2.0 {
+		t.Errorf("Cylinder collision made %v", shape)
+	}
+
+	// The cube's collision mesh is turned with the tile, so its top face ends up on the side.
+	meshCollision := ShapeCollision{Type: SHAPE_COLLISION_MESH}
+	shape, ok := meshCollision.TileShape(Tile{Pitch: 1}, cubeMesh()).(collision.Mesh)
+	if !ok || len(shape.Triangles()) != 12 {
+		t.Fatalf("Mesh collision made %v", shape)
+	}
+	// Tile rotations are slightly off from exact quarter turns.
+	if box := shape.Extents(); !box.Min.ApproxEqualThreshold(mgl32.Vec3{-1, -1, -1}, 0.01) || !box.Max.ApproxEqualThreshold(mgl32.Vec3{1, 1, 1}, 0.01) {
+		t.Errorf("Turned cube has extents %v", box)
+	}
+
+	if shape := (&ShapeCollision{Type: SHAPE_COLLISION_NONE}).TileShape(Tile{}, nil); shape != nil {
+		t.Errorf("Shape without collision made %v", shape)
+	}
+}
+
+func TestLoadShapeCollision(t *testing.T) {
+	t.Chdir("../../..")
+
+	for shapePath, expectedType := range map[string]string{
+		"assets/models/shapes/cube.obj":     SHAPE_COLLISION_BOX,
+		"assets/models/shapes/cylinder.obj": SHAPE_COLLISION_CYLINDER,
+		"assets/models/shapes/wedge.obj":    SHAPE_COLLISION_MESH,
+	} {
+		shapeCollision, err := LoadShapeCollision(shapePath)
+		if err != nil {
+			t.Errorf("Could not load the collision of %v: %v", shapePath, err)
+		} else if shapeCollision.Type != expectedType {
+			t.Errorf("%v has %v collision instead of %v", shapePath, shapeCollision.Type, expectedType)
+		}
+	}
+
+	// Shapes without collision files fill their tiles.
+	if shapeCollision, _ := LoadShapeCollision("assets/models/shapes/cube.obj"); shapeCollision.Extents != [3]float32{1.0, 1.0, 1.0} {
+		t.Errorf("Cube has box extents %v", shapeCollision.Extents)
+	}
+	if shapeCollision, _ := LoadShapeCollision("assets/models/shapes/wedge.obj"); shapeCollision.Mesh != "assets/models/shapes/wedge.obj" {
+		t.Errorf("Wedge uses collision mesh %v instead of its own", shapeCollision.Mesh)
+	}
+}
@@ -0,0 +1,70 @@
+package te3
+
+import (
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+)
+
+// Covers the given tiles with as few boxes as it can easily find, so that they can share one entity per box instead of one per tile.
+// The tiles are given as flattened grid positions, and each box is returned in world space. The boxes don't overlap,
+// and each one only covers tiles from the list. Boxes are grown along the X axis first, then Z, then Y.
+func (tiles *Tiles) MergeIntoBoxes(tileIDs []int) []math2.Box {
+	inRegion := make([]bool, len(tiles.Data))
+	for _, id := range tileIDs {
+		if id >= 0 && id < len(inRegion) {
+			inRegion[id] = true
+		}
+	}
+	free := func(x, y, z int) bool {
+		return !tiles.OutOfBounds(x, y, z) && inRegion[tiles.FlattenGridPos(x, y, z)]
+	}
+
+	var boxes []math2.Box
+	// Visiting the tiles in flat order means that each box starts at its corner with the lowest coordinates.
+	for id := range inRegion {
+		if !inRegion[id] {
+			continue
+		}
+		x0, y0, z0 := tiles.UnflattenGridPos(id)
+
+		x1 := x0 + 1
+		for free(x1, y0, z0) {
+			x1++
+		}
+		rowFree := func(y, z int) bool {
+			for x := x0; x < x1; x++ {
+				if !free(x, y, z) {
+					return false
+				}
+			}
+			return true
+		}
+		z1 := z0 + 1
+		for rowFree(y0, z1) {
+			z1++
+		}
+		y1 := y0 + 1
+	growUp:
+		for {
+			for z := z0; z < z1; z++ {
+				if !rowFree(y1, z) {
+					break growUp
+				}
+			}
+			y1++
+		}
+
+		// Take the covered tiles out, so that the next boxes don't overlap this one.
+		for y := y0; y < y1; y++ {
+			for z := z0; z < z1; z++ {
+				for x := x0; x < x1; x++ {
+					inRegion[tiles.FlattenGridPos(x, y, z)] = false
+				}
+			}
+		}
+		boxes = append(boxes, math2.Box{
+			Min: tiles.GridToWorldPos(x0, y0, z0, false),
+			Max: tiles.GridToWorldPos(x1, y1, z1, false),
+		})
+	}
+	return boxes
+}
@@ -0,0 +1,63 @@
+package te3
+
+import (
+	"testing"
+)
+
+func TestMergeIntoBoxes(t *testing.T) {
+	tiles := &Tiles{Width: 6, Height: 3, Length: 5, Data: make([]Tile, 6*3*5)}
+	var block, lShape []int
+	// A solid block of 3x2x4 tiles.
+	for y := range 2 {
+		for z := range 4 {
+			for x := range 3 {
+				block = append(block, tiles.FlattenGridPos(x, y, z))
+			}
+		}
+	}
+	// An L of tiles on the top layer and a lone tile next to it.
+	for _, pos := range [][3]int{{3, 2, 0}, {4, 2, 0}, {5, 2, 0}, {3, 2, 1}, {3, 2, 2}, {5, 2, 4}} {
+		lShape = append(lShape, tiles.FlattenGridPos(pos[0], pos[1], pos[2]))
+	}
+
+	for _, test := range []struct {
+		name     string
+		tileIDs  []int
+		maxBoxes int
+	}{
+		{"block", block, 1},
+		{"L and a lone tile", lShape, 3},
+		{"both", append(append([]int{}, block...), lShape...), 4},
+		{"none", nil, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			boxes := tiles.MergeIntoBoxes(test.tileIDs)
+			if len(boxes) > test.maxBoxes {
+				t.Errorf("Made %v boxes instead of at most %v: %v", len(boxes), test.maxBoxes, boxes)
+			}
+
+			// Each tile in the list is covered by exactly one box, and no others are.
+			inList := make(map[int]bool)
+			for _, id := range test.tileIDs {
+				inList[id] = true
+			}
+			for id := range tiles.Data {
+				x, y, z := tiles.UnflattenGridPos(id)
+				center := tiles.GridToWorldPos(x, y, z, true)
+				covering := 0
+				for _, box := range boxes {
+					if box.Min.X() < center.X() && center.X() < box.Max.X() &&
+						box.Min.Y() < center.Y() && center.Y() < box.Max.Y() &&
+						box.Min.Z() < center.Z() && center.Z() < box.Max.Z() {
+						covering++
+					}
+				}
+				if inList[id] && covering != 1 {
+					t.Errorf("Tile at %v is covered by %v boxes", [3]int{x, y, z}, covering)
+				} else if !inList[id] && covering != 0 {
+					t.Errorf("Tile at %v isn't in the list, but is covered by %v boxes", [3]int{x, y, z}, covering)
+				}
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"io"
 	"math"
 	"unsafe"
@@ -15,6 +16,7 @@ import (
 
 const GRID_SPACING = 2.0
 const HALF_GRID_SPACING = GRID_SPACING / 2.0
+const MAX_TILE_COUNT = 1 << 24 // Limits the grid size, so that a corrupt map can't use up all of the memory.
 
 type Tiles struct {
 	Data                  []Tile
@@ -41,35 +43,41 @@ func (t *Tile) GetRotationMatrix() mgl32.Mat4 {
 
 // Parses tile data from JSON file (manually, since Tile has non-decoded fields)
 func (tiles *Tiles) UnmarshalJSON(b []byte) error {
-	// Get JSON data
-	var jData map[string]any
+	// Get JSON data. Pointers are used to tell missing fields apart from zero values.
+	var jData struct {
+		Width, Height, Length *int
+		Textures, Shapes      *[]string
+		Data                  *string
+	}
 	if err := json.Unmarshal(b, &jData); err != nil {
-		return err
+		return fmt.Errorf("could not parse tiles: %w", err)
 	}
-
-	tiles.Width = int(jData["width"].(float64))
-	tiles.Height = int(jData["height"].(float64))
-	tiles.Length = int(jData["length"].(float64))
-
-	// Parse and convert texture paths
-	textures := jData["textures"].([]any)
-	tiles.Textures = make([]string, len(textures))
-	for t, tex := range textures {
-		tiles.Textures[t] = tex.(string)
+	switch {
+	case jData.Width == nil:
+		return fmt.Errorf("tiles are missing the 'width' field")
+	case jData.Height == nil:
+		return fmt.Errorf("tiles are missing the 'height' field")
+	case jData.Length == nil:
+		return fmt.Errorf("tiles are missing the 'length' field")
+	case jData.Textures == nil:
+		return fmt.Errorf("tiles are missing the 'textures' field")
+	case jData.Shapes == nil:
+		return fmt.Errorf("tiles are missing the 'shapes' field")
+	case jData.Data == nil:
+		return fmt.Errorf("tiles are missing the 'data' field")
 	}
 
-	// Parse and convert model paths
-	shapes := jData["shapes"].([]any)
-	tiles.Shapes = make([]string, len(shapes))
-	for s, shape := range shapes {
-		tiles.Shapes[s] = shape.(string)
+	tiles.Width, tiles.Height, tiles.Length = *jData.Width, *jData.Height, *jData.Length
+	if !ValidGridSize(tiles.Width, tiles.Height, tiles.Length) {
+		return fmt.Errorf("invalid grid size %vx%vx%v", tiles.Width, tiles.Height, tiles.Length)
 	}
+	tiles.Textures = *jData.Textures
+	tiles.Shapes = *jData.Shapes
 
 	// Convert tile data from base64
-	tileString := jData["data"].(string)
-	tileBytes, err := base64.StdEncoding.DecodeString(tileString)
+	tileBytes, err := base64.StdEncoding.DecodeString(*jData.Data)
 	if err != nil {
-		return err
+		return fmt.Errorf("could not decode tile data: %w", err)
 	}
 
 	// Parse bytes as tile array
@@ -78,24 +86,63 @@ func (tiles *Tiles) UnmarshalJSON(b []byte) error {
 	tileIndex := 0
 	for tileIndex < len(tiles.Data) {
 		var tile Tile
-		if binary.Read(reader, binary.LittleEndian, &tile.ShapeID) == io.ErrUnexpectedEOF {
-			return io.ErrUnexpectedEOF
+		if err := binary.Read(reader, binary.LittleEndian, &tile.ShapeID); err == io.EOF {
+			// Trailing empty tiles can be left out when saving, so fill in the rest of the grid with them.
+			for ; tileIndex < len(tiles.Data); tileIndex++ {
+				tiles.Data[tileIndex] = Tile{ShapeID: ShapeID(-1)}
+			}
+			break
+		} else if err != nil {
+			return fmt.Errorf("tile data ends in the middle of tile %v", tileIndex)
 		}
 
 		if tile.ShapeID < 0 {
-			// Negative shape ID represents a run of empty tiles
+			// Negative shape ID represents a run of empty tiles. The most negative one has no positive length, so it's never written.
+			if tile.ShapeID == math.MinInt16 {
+				return fmt.Errorf("invalid run of empty tiles at tile %v", tileIndex)
+			}
+			if tileIndex+int(-tile.ShapeID) > len(tiles.Data) {
+				return fmt.Errorf("run of empty tiles at tile %v goes past the end of the grid", tileIndex)
+			}
 			for range -tile.ShapeID {
 				tiles.Data[tileIndex] = Tile{ShapeID: ShapeID(-1)}
 				tileIndex++
 			}
-		} else if binary.Read(reader, binary.LittleEndian, tile.TextureIDs[:]) != io.ErrUnexpectedEOF &&
-			binary.Read(reader, binary.LittleEndian, &tile.Yaw) != io.ErrUnexpectedEOF &&
-			binary.Read(reader, binary.LittleEndian, &tile.Pitch) != io.ErrUnexpectedEOF {
-
-			tiles.Data[tileIndex] = tile
-			tileIndex++
-		} else {
-			return io.ErrUnexpectedEOF
+			continue
+		}
+
+		if binary.Read(reader, binary.LittleEndian, tile.TextureIDs[:]) != nil ||
+			binary.Read(reader, binary.LittleEndian, &tile.Yaw) != nil ||
+			binary.Read(reader, binary.LittleEndian, &tile.Pitch) != nil {
+			return fmt.Errorf("tile data ends in the middle of tile %v", tileIndex)
+		}
+
+		if err := tiles.checkTile(tileIndex, tile); err != nil {
+			return err
+		}
+		tiles.Data[tileIndex] = tile
+		tileIndex++
+	}
+	return nil
+}
+
+// Returns true if a grid of the given size has at least one tile and no more than MAX_TILE_COUNT.
+// The size is divided instead of multiplied, so that the huge sizes of corrupt maps can't overflow.
+func ValidGridSize(width, height, length int) bool {
+	return width > 0 && height > 0 && length > 0 && width <= MAX_TILE_COUNT/height/length
+}
+
+// Checks that the tile's shape and textures exist, since they are used as indices later.
+func (tiles *Tiles) checkTile(index int, tile Tile) error {
+	if tile.ShapeID < 0 {
+		return nil
+	}
+	if int(tile.ShapeID) >= len(tiles.Shapes) {
+		return fmt.Errorf("tile %v has shape ID %v, but there are only %v shapes", index, tile.ShapeID, len(tiles.Shapes))
+	}
+	for _, texID := range tile.TextureIDs {
+		if texID < 0 || int(texID) >= len(tiles.Textures) {
+			return fmt.Errorf("tile %v has texture ID %v, but there are only %v textures", index, texID, len(tiles.Textures))
 		}
 	}
 	return nil
@@ -109,40 +156,34 @@ func (tiles *Tiles) MarshalJSON() ([]byte, error) {
 	props["textures"] = tiles.Textures
 	props["shapes"] = tiles.Shapes
 
-	var err error
 	tileData := make([]byte, 0, len(tiles.Data)*int(unsafe.Sizeof(tiles.Data[0])))
+
+	// Runs of empty tiles are stored as a single negative number giving the length of the run.
 	var runLength ShapeID
-	for i, tile := range tiles.Data {
-		if tile.ShapeID < 0 && i < len(tiles.Data)-1 && runLength < math.MaxInt16 {
-			// Blank tiles (except for the last tile in the grid are represented as runs)
-			runLength++
-		} else {
-			if runLength > 0 {
-				if i == len(tiles.Data)-1 {
-					// Accounts for the final tile being reached during a run of empty tiles
-					runLength++
-				}
-
-				// Insert a special value signifying the number of empty tiles preceding this one.
-				tileData, err = binary.Append(tileData, binary.NativeEndian, -runLength)
-				if err != nil {
-					return nil, err
-				}
-				if tile.ShapeID < 0 {
-					runLength = 1
-				}
+	endRun := func() {
+		if runLength > 0 {
+			tileData = binary.LittleEndian.AppendUint16(tileData, uint16(-runLength))
+			runLength = 0
+		}
+	}
+	for _, tile := range tiles.Data {
+		if tile.ShapeID < 0 {
+			if runLength == math.MaxInt16 {
+				endRun()
 			}
-			if tile.ShapeID >= 0 {
-				runLength = 0
-				for _, item := range []any{tile.ShapeID, tile.TextureIDs[0], tile.TextureIDs[1], tile.Yaw, tile.Pitch} {
-					tileData, err = binary.Append(tileData, binary.NativeEndian, item)
-					if err != nil {
-						return nil, err
-					}
-				}
+			runLength++
+			continue
+		}
+		endRun()
+		for _, item := range []any{tile.ShapeID, tile.TextureIDs[0], tile.TextureIDs[1], tile.Yaw, tile.Pitch} {
+			var err error
+			tileData, err = binary.Append(tileData, binary.LittleEndian, item)
+			if err != nil {
+				return nil, err
 			}
 		}
 	}
+	endRun()
 
 	props["data"] = base64.StdEncoding.EncodeToString(tileData)
 	return json.Marshal(props)
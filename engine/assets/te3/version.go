@@ -0,0 +1,100 @@
+package te3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Identifies a version of the Total Editor 3 map format.
+type Version struct {
+	Major, Minor int
+}
+
+var (
+	CurrentVersion = Version{3, 2} // Newest version of the map format, which the loader upgrades older maps to.
+	OldestVersion  = Version{3, 0} // Oldest version of the map format that can still be upgraded.
+)
+
+func ParseVersion(text string) (Version, error) {
+	majorText, minorText, ok := strings.Cut(strings.TrimSpace(text), ".")
+	if !ok {
+		return Version{}, fmt.Errorf("invalid version '%v'; expected <major>.<minor>", text)
+	}
+	major, errMajor := strconv.Atoi(majorText)
+	minor, errMinor := strconv.Atoi(minorText)
+	if errMajor != nil || errMinor != nil || major < 0 || minor < 0 {
+		return Version{}, fmt.Errorf("invalid version '%v'; expected <major>.<minor>", text)
+	}
+	return Version{major, minor}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Returns a negative number if v is older than other, a positive number if v is newer, or zero if they are the same.
+func (v Version) Compare(other Version) int {
+	if v.Major != other.Major {
+		return v.Major - other.Major
+	}
+	return v.Minor - other.Minor
+}
+
+// Returned when a map was saved in a version of the format that the loader can't read.
+type UnsupportedVersionError struct {
+	Version Version
+}
+
+func (err UnsupportedVersionError) Error() string {
+	if err.Version.Compare(CurrentVersion) > 0 {
+		return fmt.Sprintf("map format version %v is newer than the newest supported version %v", err.Version, CurrentVersion)
+	}
+	return fmt.Sprintf("map format version %v is older than the oldest supported version %v", err.Version, OldestVersion)
+}
+
+// Upgrades the JSON document of a map from the previous version of the format to the given one.
+type migration struct {
+	to      Version
+	upgrade func(doc map[string]any) error
+}
+
+// Migrations in order from oldest to newest. Each one is applied to maps saved before its version.
+var migrations = []migration{
+	{to: Version{3, 2}, upgrade: addEntDisplay},
+}
+
+// Upgrades the document from the given version to the current one.
+func migrate(doc map[string]any, version Version) error {
+	if version.Compare(OldestVersion) < 0 || version.Compare(CurrentVersion) > 0 {
+		return UnsupportedVersionError{version}
+	}
+	for _, mig := range migrations {
+		if version.Compare(mig.to) >= 0 {
+			continue
+		}
+		if err := mig.upgrade(doc); err != nil {
+			return fmt.Errorf("could not upgrade map from version %v to %v: %v", version, mig.to, err)
+		}
+		version = mig.to
+	}
+	return nil
+}
+
+// Maps from before version 3.2 may not say how their ents are displayed in the editor, so they are given the default sphere display.
+func addEntDisplay(doc map[string]any) error {
+	ents, ok := doc["ents"].([]any)
+	if !ok {
+		return nil
+	}
+	for e, ent := range ents {
+		entObj, ok := ent.(map[string]any)
+		if !ok {
+			return fmt.Errorf("ent %v is not an object", e)
+		}
+		if _, ok := entObj["display"]; !ok {
+			entObj["display"] = ENT_DISPLAY_SPHERE
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package te3
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Tiles with a single cube, encoded as the editor saves them.
+const testTilesJSON = `{"width": 1, "height": 1, "length": 1, "textures": ["a.png"], "shapes": ["cube.obj"], "data": "AAAAAAAAAAA="}`
+
+// Builds a map file of the given format version with the given ents and tiles.
+func testMapJSON(version, ents, tiles string) []byte {
+	return fmt.Appendf(nil, `{"meta": {"editor": "test", "version": %q}, "ents": %v, "tiles": %v}`, version, ents, tiles)
+}
+
+func TestParseVersion(t *testing.T) {
+	for _, test := range []struct {
+		text      string
+		expected  Version
+		expectErr bool
+	}{
+		{"3.2", Version{3, 2}, false},
+		{" 3.10 ", Version{3, 10}, false},
+		{"0.0", Version{0, 0}, false},
+		{"3", Version{}, true},
+		{"3.", Version{}, true},
+		{"three.two", Version{}, true},
+		{"-3.2", Version{}, true},
+		{"3.-2", Version{}, true},
+		{"3.2.1", Version{}, true},
+	} {
+		version, err := ParseVersion(test.text)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("Parsed %q as %v instead of returning an error", test.text, version)
+			}
+		} else if err != nil {
+			t.Errorf("Could not parse %q: %v", test.text, err)
+		} else if version != test.expected {
+			t.Errorf("Parsed %q as %v instead of %v", test.text, version, test.expected)
+		}
+	}
+}
+
+func TestParseUnsupportedVersion(t *testing.T) {
+	for _, version := range []Version{
+		{CurrentVersion.Major, CurrentVersion.Minor + 1},
+		{CurrentVersion.Major + 1, 0},
+		{OldestVersion.Major - 1, 9},
+	} {
+		_, err := ParseTE3File(testMapJSON(version.String(), "[]", testTilesJSON))
+		var versionErr UnsupportedVersionError
+		if !errors.As(err, &versionErr) {
+			t.Errorf("Loading a map of version %v returned %v instead of an unsupported version error", version, err)
+		} else if versionErr.Version != version {
+			t.Errorf("Error is for version %v instead of %v", versionErr.Version, version)
+		}
+	}
+}
+
+func TestParseUpgradedMap(t *testing.T) {
+	// Before version 3.2, ents didn't have to say how they are displayed.
+	ents := `[
+		{"position": [1, 2, 3], "angles": [0, 90, 0], "radius": 1, "color": [255, 0, 0], "properties": {"type": "enemy"}},
+		{"position": [1, 2, 3], "angles": [0, 90, 0], "radius": 1, "color": [255, 0, 0], "display": 1, "properties": {"type": "item"}}
+	]`
+	for _, version := range []string{"3.0", "3.1"} {
+		t.Run(version, func(t *testing.T) {
+			te3File, err := ParseTE3File(testMapJSON(version, ents, testTilesJSON))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if te3File.Meta.Version != CurrentVersion.String() {
+				t.Errorf("Map was upgraded to version %v instead of %v", te3File.Meta.Version, CurrentVersion)
+			}
+			if len(te3File.Ents) != 2 || te3File.Ents[0].Display != ENT_DISPLAY_SPHERE || te3File.Ents[1].Display != 1 {
+				t.Errorf("Ents were upgraded to %+v", te3File.Ents)
+			}
+			if te3File.Ents[0].Properties["type"] != "enemy" || te3File.Ents[0].Position != [3]float32{1, 2, 3} {
+				t.Errorf("Ent changed while upgrading: %+v", te3File.Ents[0])
+			}
+		})
+	}
+
+	if _, err := ParseTE3File(testMapJSON(CurrentVersion.String(), ents, testTilesJSON)); err == nil {
+		t.Error("Loaded an ent without a display from a map of the current version")
+	}
+}
+
+func TestParseMalformedMap(t *testing.T) {
+	version := CurrentVersion.String()
+	ent := `{"position": [1, 2, 3], "angles": [0, 0, 0], "radius": 1, "color": [255, 0, 0], "display": 0, "properties": {}}`
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"not json", []byte(`{"meta": `)},
+		{"missing meta", []byte(`{"ents": [], "tiles": ` + testTilesJSON + `}`)},
+		{"missing version", []byte(`{"meta": {"editor": "test"}, "ents": [], "tiles": ` + testTilesJSON + `}`)},
+		{"version not a string", []byte(`{"meta": {"version": 3.2}, "ents": [], "tiles": ` + testTilesJSON + `}`)},
+		{"invalid version", testMapJSON("latest", "[]", testTilesJSON)},
+		{"missing tiles", []byte(fmt.Sprintf(`{"meta": {"version": %q}, "ents": []}`, version))},
+		{"missing ents", []byte(fmt.Sprintf(`{"meta": {"version": %q}, "tiles": %v}`, version, testTilesJSON))},
+		{"ents not an array", testMapJSON(version, `{}`, testTilesJSON)},
+		{"ent not an object", testMapJSON(version, `[1]`, testTilesJSON)},
+		{"ent missing position", testMapJSON(version, `[{"angles": [0, 0, 0], "radius": 1, "color": [0, 0, 0], "display": 0, "properties": {}}]`, testTilesJSON)},
+		{"ent with wrong type", testMapJSON(version, `[{"position": "here", "angles": [0, 0, 0], "radius": 1, "color": [0, 0, 0], "display": 0, "properties": {}}]`, testTilesJSON)},
+		{"tiles not an object", testMapJSON(version, "["+ent+"]", `"tiles"`)},
+		{"missing width", testMapJSON(version, "[]", `{"height": 1, "length": 1, "textures": [], "shapes": [], "data": ""}`)},
+		{"missing height", testMapJSON(version, "[]", `{"width": 1, "length": 1, "textures": [], "shapes": [], "data": ""}`)},
+		{"missing data", testMapJSON(version, "[]", `{"width": 1, "height": 1, "length": 1, "textures": [], "shapes": []}`)},
+		{"width not a number", testMapJSON(version, "[]", `{"width": "1", "height": 1, "length": 1, "textures": [], "shapes": [], "data": ""}`)},
+		{"height not an integer", testMapJSON(version, "[]", `{"width": 1, "height": 1.5, "length": 1, "textures": [], "shapes": [], "data": ""}`)},
+		{"textures not strings", testMapJSON(version, "[]", `{"width": 1, "height": 1, "length": 1, "textures": [1], "shapes": [], "data": ""}`)},
+		{"zero height", testMapJSON(version, "[]", `{"width": 1, "height": 0, "length": 1, "textures": [], "shapes": [], "data": ""}`)},
+		{"negative width", testMapJSON(version, "[]", `{"width": -1, "height": -1, "length": 1, "textures": [], "shapes": [], "data": ""}`)},
+		{"grid too large", testMapJSON(version, "[]", `{"width": 4096, "height": 4096, "length": 4096, "textures": [], "shapes": [], "data": ""}`)},
+		{"data not base64", testMapJSON(version, "[]", `{"width": 1, "height": 1, "length": 1, "textures": [], "shapes": [], "data": "!!"}`)},
+		{"data ends in a tile", testMapJSON(version, "[]", `{"width": 1, "height": 1, "length": 1, "textures": ["a.png"], "shapes": ["cube.obj"], "data": "AAA="}`)},
+		{"run past the end", testMapJSON(version, "[]", `{"width": 1, "height": 1, "length": 1, "textures": [], "shapes": [], "data": "/v8="}`)},
+		{"missing shape", testMapJSON(version, "[]", `{"width": 1, "height": 1, "length": 1, "textures": ["a.png"], "shapes": ["cube.obj"], "data": "AQAAAAAAAAA="}`)},
+		{"missing texture", testMapJSON(version, "[]", `{"width": 1, "height": 1, "length": 1, "textures": [], "shapes": ["cube.obj"], "data": "AAAAAAAAAAA="}`)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseTE3File(test.data); err == nil {
+				t.Error("Loaded a malformed map without an error")
+			}
+		})
+	}
+
+	// The same map without the mistakes loads.
+	if _, err := ParseTE3File(testMapJSON(version, "["+ent+"]", testTilesJSON)); err != nil {
+		t.Errorf("Could not load a valid map: %v", err)
+	}
+}
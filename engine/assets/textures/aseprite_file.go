@@ -0,0 +1,584 @@
+package textures
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+)
+
+// Values from the Aseprite file format specification: https://github.com/aseprite/aseprite/blob/main/docs/ase-file-specs.md
+const (
+	ASE_FILE_MAGIC  = 0xA5E0
+	ASE_FRAME_MAGIC = 0xF1FA
+
+	ASE_CHUNK_OLD_PALETTE   = 0x0004
+	ASE_CHUNK_OLD_PALETTE_2 = 0x0011
+	ASE_CHUNK_LAYER         = 0x2004
+	ASE_CHUNK_CEL           = 0x2005
+	ASE_CHUNK_TAGS          = 0x2018
+	ASE_CHUNK_PALETTE       = 0x2019
+	ASE_CHUNK_USER_DATA     = 0x2020
+	ASE_CHUNK_SLICE         = 0x2022
+
+	ASE_LAYER_FLAG_VISIBLE    = 1
+	ASE_LAYER_FLAG_BACKGROUND = 8
+	ASE_LAYER_FLAG_REFERENCE  = 64
+
+	ASE_LAYER_TYPE_IMAGE = 0
+	ASE_LAYER_TYPE_GROUP = 1
+
+	ASE_CEL_RAW        = 0
+	ASE_CEL_LINKED     = 1
+	ASE_CEL_COMPRESSED = 2
+
+	ASE_HEADER_FLAG_LAYER_OPACITY = 1
+
+	ASE_USER_DATA_HAS_TEXT = 1
+
+	ASE_SLICE_NINE_PATCH = 1
+	ASE_SLICE_PIVOT      = 2
+)
+
+// The file extensions of Aseprite source files, in order of preference.
+var AsepriteExtensions = []string{".aseprite", ".ase"}
+
+// Holds the contents of an .aseprite file that are used for building textures.
+type aseFile struct {
+	width, height    int
+	colorDepth       int
+	transparentIndex uint8
+	layerOpacity     bool // Whether the layers' opacity values are valid.
+	palette          []color.NRGBA
+	hasNewPalette    bool // Whether the palette came from a new palette chunk, which takes precedence over the old ones.
+	layers           []aseFileLayer
+	frames           []aseFileFrame
+	tags             []aseTag
+	slices           []aseSlice
+}
+
+type aseFileLayer struct {
+	flags      uint16
+	layerType  uint16
+	childLevel int
+	opacity    uint8
+	name, data string
+}
+
+type aseFileFrame struct {
+	duration uint
+	cels     map[int]*aseCel // Indexed by layer
+}
+
+type aseCel struct {
+	x, y          int
+	opacity       uint8
+	width, height int
+	linkedFrame   int    // The frame whose cel on the same layer is shown instead, or -1.
+	pixels        []byte // Raw pixels in the file's color depth.
+}
+
+// Reads the little endian values that make up Aseprite files.
+type aseReader struct {
+	*bytes.Reader
+	err error
+}
+
+func (r *aseReader) read(value any) {
+	if r.err == nil {
+		r.err = binary.Read(r.Reader, binary.LittleEndian, value)
+	}
+}
+
+func (r *aseReader) byte() uint8 {
+	var value uint8
+	r.read(&value)
+	return value
+}
+
+func (r *aseReader) word() uint16 {
+	var value uint16
+	r.read(&value)
+	return value
+}
+
+func (r *aseReader) short() int16 {
+	var value int16
+	r.read(&value)
+	return value
+}
+
+func (r *aseReader) dword() uint32 {
+	var value uint32
+	r.read(&value)
+	return value
+}
+
+func (r *aseReader) long() int32 {
+	var value int32
+	r.read(&value)
+	return value
+}
+
+func (r *aseReader) skip(count int) {
+	if r.err == nil {
+		_, r.err = r.Seek(int64(count), io.SeekCurrent)
+	}
+}
+
+func (r *aseReader) string() string {
+	length := r.word()
+	if r.err != nil {
+		return ""
+	}
+	buf := make([]byte, length)
+	_, r.err = io.ReadFull(r.Reader, buf)
+	return string(buf)
+}
+
+// Parses an Aseprite file. Tilemap layers, external files, and blend modes other than 'normal' aren't supported.
+func readAsepriteFile(input io.Reader) (*aseFile, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	r := &aseReader{Reader: bytes.NewReader(data)}
+
+	// Header
+	r.dword() // File size
+	if magic := r.word(); magic != ASE_FILE_MAGIC {
+		return nil, fmt.Errorf("not an Aseprite file")
+	}
+	file := &aseFile{}
+	frameCount := int(r.word())
+	file.width = int(r.word())
+	file.height = int(r.word())
+	file.colorDepth = int(r.word())
+	file.layerOpacity = r.dword()&ASE_HEADER_FLAG_LAYER_OPACITY != 0
+	r.skip(2 + 4 + 4) // Speed and reserved values
+	file.transparentIndex = r.byte()
+	r.skip(3)
+	colorCount := int(r.word())
+	r.skip(128 - 34) // The rest of the header
+	if r.err != nil {
+		return nil, fmt.Errorf("could not read header: %v", r.err)
+	}
+	switch file.colorDepth {
+	case 32, 16, 8:
+	default:
+		return nil, fmt.Errorf("unsupported color depth %v", file.colorDepth)
+	}
+	if colorCount == 0 {
+		colorCount = 256
+	}
+	file.palette = make([]color.NRGBA, colorCount)
+
+	// The user data chunk applies to whatever was read before it.
+	var setUserData func(text string)
+	tagsWithUserData := 0
+
+	file.frames = make([]aseFileFrame, frameCount)
+	for f := range file.frames {
+		frameStart, _ := r.Seek(0, io.SeekCurrent)
+		frameSize := int64(r.dword())
+		if magic := r.word(); magic != ASE_FRAME_MAGIC {
+			return nil, fmt.Errorf("frame %v has the wrong magic number", f)
+		}
+		chunkCount := int(r.word())
+		file.frames[f].duration = uint(r.word())
+		r.skip(2)
+		if newChunkCount := int(r.dword()); newChunkCount != 0 {
+			chunkCount = newChunkCount
+		}
+		file.frames[f].cels = make(map[int]*aseCel)
+
+		for range chunkCount {
+			chunkStart, _ := r.Seek(0, io.SeekCurrent)
+			chunkSize := int64(r.dword())
+			chunkType := r.word()
+			if r.err != nil {
+				return nil, fmt.Errorf("could not read chunk in frame %v: %v", f, r.err)
+			}
+			if chunkSize < 6 || chunkStart+chunkSize > int64(len(data)) {
+				return nil, fmt.Errorf("chunk in frame %v has an invalid size", f)
+			}
+			chunk := &aseReader{Reader: bytes.NewReader(data[chunkStart+6 : chunkStart+chunkSize])}
+
+			switch chunkType {
+			case ASE_CHUNK_LAYER:
+				layer := aseFileLayer{
+					flags:      chunk.word(),
+					layerType:  chunk.word(),
+					childLevel: int(chunk.word()),
+				}
+				chunk.skip(2 + 2 + 2) // Default size and blend mode
+				layer.opacity = chunk.byte()
+				chunk.skip(3)
+				layer.name = chunk.string()
+				file.layers = append(file.layers, layer)
+				setUserData = func(text string) { file.layers[len(file.layers)-1].data = text }
+			case ASE_CHUNK_CEL:
+				layerIndex := int(chunk.word())
+				cel := &aseCel{
+					x:           int(chunk.short()),
+					y:           int(chunk.short()),
+					opacity:     chunk.byte(),
+					linkedFrame: -1,
+				}
+				celType := chunk.word()
+				chunk.skip(2 + 5) // Z index and reserved
+				switch celType {
+				case ASE_CEL_RAW, ASE_CEL_COMPRESSED:
+					cel.width = int(chunk.word())
+					cel.height = int(chunk.word())
+					pixelSize := cel.width * cel.height * file.colorDepth / 8
+					cel.pixels = make([]byte, pixelSize)
+					if celType == ASE_CEL_RAW {
+						if chunk.err == nil {
+							_, chunk.err = io.ReadFull(chunk.Reader, cel.pixels)
+						}
+					} else if chunk.err == nil {
+						var zr io.ReadCloser
+						if zr, chunk.err = zlib.NewReader(chunk.Reader); chunk.err == nil {
+							_, chunk.err = io.ReadFull(zr, cel.pixels)
+							zr.Close()
+						}
+					}
+				case ASE_CEL_LINKED:
+					cel.linkedFrame = int(chunk.word())
+				default:
+					log.Printf("Skipping unsupported cel type %v in frame %v.\n", celType, f)
+					cel = nil
+				}
+				if cel != nil {
+					file.frames[f].cels[layerIndex] = cel
+				}
+				setUserData = nil
+			case ASE_CHUNK_TAGS:
+				tagCount := int(chunk.word())
+				chunk.skip(8)
+				for range tagCount {
+					tag := aseTag{
+						From: uint(chunk.word()),
+						To:   uint(chunk.word()),
+					}
+					switch chunk.byte() {
+					case 0:
+						tag.Direction = "forward"
+					case 1:
+						tag.Direction = "reverse"
+					case 2:
+						tag.Direction = "pingpong"
+					case 3:
+						tag.Direction = "pingpong_reverse"
+					}
+					// Like the exported JSON, the repeat count is left out when it is zero (infinite).
+					if repeat := chunk.word(); repeat > 0 {
+						tag.Repeat = strconv.Itoa(int(repeat))
+					}
+					chunk.skip(6 + 3 + 1) // Reserved and deprecated color
+					tag.Name = chunk.string()
+					file.tags = append(file.tags, tag)
+				}
+				// The user data chunks after the tags chunk are given to each tag in order.
+				tagsWithUserData = 0
+				setUserData = func(text string) {
+					if tagsWithUserData < len(file.tags) {
+						file.tags[tagsWithUserData].Data = text
+					}
+					tagsWithUserData++
+				}
+			case ASE_CHUNK_USER_DATA:
+				flags := chunk.dword()
+				if setUserData != nil {
+					text := ""
+					if flags&ASE_USER_DATA_HAS_TEXT != 0 {
+						text = chunk.string()
+					}
+					setUserData(text)
+				}
+			case ASE_CHUNK_SLICE:
+				keyCount := int(chunk.dword())
+				sliceFlags := chunk.dword()
+				chunk.skip(4)
+				slice := aseSlice{Name: chunk.string()}
+				for range keyCount {
+					key := aseSliceKey{Frame: int(chunk.dword())}
+					key.Bounds.X = int(chunk.long())
+					key.Bounds.Y = int(chunk.long())
+					key.Bounds.W = int(chunk.dword())
+					key.Bounds.H = int(chunk.dword())
+					if sliceFlags&ASE_SLICE_NINE_PATCH != 0 {
+						chunk.skip(16)
+					}
+					if sliceFlags&ASE_SLICE_PIVOT != 0 {
+						chunk.skip(8)
+					}
+					slice.Keys = append(slice.Keys, key)
+				}
+				file.slices = append(file.slices, slice)
+				setUserData = func(text string) { file.slices[len(file.slices)-1].Data = text }
+			case ASE_CHUNK_PALETTE:
+				newSize := int(chunk.dword())
+				first, last := int(chunk.dword()), int(chunk.dword())
+				chunk.skip(8)
+				if newSize > len(file.palette) {
+					file.palette = append(file.palette, make([]color.NRGBA, newSize-len(file.palette))...)
+				}
+				for i := first; i <= last && chunk.err == nil; i++ {
+					entryFlags := chunk.word()
+					entry := color.NRGBA{R: chunk.byte(), G: chunk.byte(), B: chunk.byte(), A: chunk.byte()}
+					if entryFlags&1 != 0 {
+						chunk.string() // Color name
+					}
+					if i < len(file.palette) {
+						file.palette[i] = entry
+					}
+				}
+				file.hasNewPalette = true
+				setUserData = nil
+			case ASE_CHUNK_OLD_PALETTE, ASE_CHUNK_OLD_PALETTE_2:
+				// Only used when there is no new palette chunk, which comes first in newer files.
+				if file.hasNewPalette {
+					break
+				}
+				index := 0
+				packetCount := int(chunk.word())
+				for range packetCount {
+					index += int(chunk.byte())
+					count := int(chunk.byte())
+					if count == 0 {
+						count = 256
+					}
+					for range count {
+						entry := color.NRGBA{R: chunk.byte(), G: chunk.byte(), B: chunk.byte(), A: 255}
+						if chunkType == ASE_CHUNK_OLD_PALETTE_2 {
+							// Values range from 0 to 63
+							entry.R, entry.G, entry.B = uint8(int(entry.R)*255/63), uint8(int(entry.G)*255/63), uint8(int(entry.B)*255/63)
+						}
+						if index < len(file.palette) {
+							file.palette[index] = entry
+						}
+						index++
+					}
+				}
+			default:
+				// Chunks for color profiles, tilesets, etc. aren't needed.
+			}
+
+			if chunk.err != nil {
+				return nil, fmt.Errorf("could not read chunk 0x%04x in frame %v: %v", chunkType, f, chunk.err)
+			}
+			r.Seek(chunkStart+chunkSize, io.SeekStart)
+		}
+
+		r.Seek(frameStart+frameSize, io.SeekStart)
+	}
+
+	return file, nil
+}
+
+// Returns the color of the pixel at the given index of a cel, converted from the file's color depth.
+func (file *aseFile) pixel(cel *aseCel, index int, isBackground bool) color.NRGBA {
+	switch file.colorDepth {
+	case 32:
+		p := cel.pixels[index*4:]
+		return color.NRGBA{R: p[0], G: p[1], B: p[2], A: p[3]}
+	case 16:
+		p := cel.pixels[index*2:]
+		return color.NRGBA{R: p[0], G: p[0], B: p[0], A: p[1]}
+	default:
+		paletteIndex := cel.pixels[index]
+		if paletteIndex == file.transparentIndex && !isBackground {
+			return color.NRGBA{}
+		}
+		if int(paletteIndex) < len(file.palette) {
+			return file.palette[paletteIndex]
+		}
+		return color.NRGBA{}
+	}
+}
+
+// Returns the indices of the image layers that are shown, from bottom to top. Layers inside of hidden groups are hidden.
+func (file *aseFile) visibleLayers() []int {
+	visible := make([]int, 0, len(file.layers))
+	// Whether each level of group nesting above the current layer is visible.
+	groupsVisible := make([]bool, 0, 4)
+	for l, layer := range file.layers {
+		groupsVisible = groupsVisible[:min(layer.childLevel, len(groupsVisible))]
+		shown := layer.flags&ASE_LAYER_FLAG_VISIBLE != 0 && layer.flags&ASE_LAYER_FLAG_REFERENCE == 0
+		for _, groupVisible := range groupsVisible {
+			shown = shown && groupVisible
+		}
+		switch layer.layerType {
+		case ASE_LAYER_TYPE_IMAGE:
+			if shown {
+				visible = append(visible, l)
+			}
+		case ASE_LAYER_TYPE_GROUP:
+			for len(groupsVisible) < layer.childLevel {
+				groupsVisible = append(groupsVisible, true)
+			}
+			groupsVisible = append(groupsVisible, shown)
+		}
+	}
+	return visible
+}
+
+// Blends the given layers of a frame onto the destination image, with the top left corner of the frame at the given position.
+func (file *aseFile) drawFrame(dst *image.NRGBA, frame int, layers []int, originX, originY int) {
+	for _, l := range layers {
+		cel, ok := file.frames[frame].cels[l]
+		if !ok {
+			continue
+		}
+		if cel.linkedFrame >= 0 && cel.linkedFrame < len(file.frames) {
+			position := *cel
+			if cel, ok = file.frames[cel.linkedFrame].cels[l]; !ok || cel.linkedFrame >= 0 {
+				continue
+			}
+			// Linked cels share the image, but they have their own position and opacity.
+			cel = &aseCel{x: position.x, y: position.y, opacity: position.opacity, width: cel.width, height: cel.height, pixels: cel.pixels, linkedFrame: -1}
+		}
+
+		opacity := uint32(cel.opacity)
+		if file.layerOpacity {
+			opacity = opacity * uint32(file.layers[l].opacity) / 255
+		}
+		isBackground := file.layers[l].flags&ASE_LAYER_FLAG_BACKGROUND != 0
+
+		for y := range cel.height {
+			spriteY := cel.y + y
+			if spriteY < 0 || spriteY >= file.height {
+				continue
+			}
+			for x := range cel.width {
+				spriteX := cel.x + x
+				if spriteX < 0 || spriteX >= file.width {
+					continue
+				}
+				src := file.pixel(cel, y*cel.width+x, isBackground)
+				srcAlpha := uint32(src.A) * opacity / 255
+				if srcAlpha == 0 {
+					continue
+				}
+				dstOffset := dst.PixOffset(originX+spriteX, originY+spriteY)
+				d := dst.Pix[dstOffset : dstOffset+4 : dstOffset+4]
+
+				// Normal 'over' blending with straight alpha
+				dstAlpha := uint32(d[3]) * (255 - srcAlpha) / 255
+				outAlpha := srcAlpha + dstAlpha
+				blend := func(s, d uint8) uint8 {
+					return uint8((uint32(s)*srcAlpha + uint32(d)*dstAlpha) / outAlpha)
+				}
+				d[0], d[1], d[2], d[3] = blend(src.R, d[0]), blend(src.G, d[1]), blend(src.B, d[2]), uint8(outAlpha)
+			}
+		}
+	}
+}
+
+// Composes the frames into an atlas image and describes it in the same way as an exported sprite sheet, so that it can be loaded like one.
+// If any visible layer has user data, each layer gets its own frames, like when exporting with the 'split layers' option.
+// Otherwise, the layers are merged.
+func (file *aseFile) spriteSheet(imageName string) (*aseSpriteSheet, *image.NRGBA) {
+	layers := file.visibleLayers()
+	splitLayers := false
+	for _, l := range layers {
+		if len(strings.TrimSpace(file.layers[l].data)) > 0 {
+			splitLayers = true
+		}
+	}
+
+	sheet := &aseSpriteSheet{}
+	sheet.Meta.Image = imageName
+	sheet.Meta.FrameTags = file.tags
+	sheet.Meta.Slices = file.slices
+
+	// Frames go in columns. When the layers are split, each layer gets its own row.
+	var columns, rows int
+	if splitLayers {
+		columns, rows = len(file.frames), len(layers)
+	} else {
+		columns = int(math.Ceil(math.Sqrt(float64(len(file.frames)))))
+		rows = (len(file.frames) + columns - 1) / columns
+	}
+	sheet.Meta.Size.W, sheet.Meta.Size.H = uint(columns*file.width), uint(rows*file.height)
+	atlas := image.NewNRGBA(image.Rect(0, 0, columns*file.width, rows*file.height))
+
+	addFrame := func(fileName string, frame, cell int, frameLayers []int) {
+		x, y := (cell%columns)*file.width, (cell/columns)*file.height
+		file.drawFrame(atlas, frame, frameLayers, x, y)
+		sheet.Frames = append(sheet.Frames, aseFrame{
+			FileName: fileName,
+			Frame:    Rect{X: x, Y: y, W: file.width, H: file.height},
+			Duration: file.frames[frame].duration,
+		})
+	}
+	if splitLayers {
+		for row, l := range layers {
+			sheet.Meta.Layers = append(sheet.Meta.Layers, aseLayer{Name: file.layers[l].name, Data: file.layers[l].data})
+			for f := range file.frames {
+				addFrame(fmt.Sprintf("%v;%v;%v", imageName, file.layers[l].name, f), f, row*columns+f, []int{l})
+			}
+		}
+	} else {
+		for f := range file.frames {
+			addFrame(fmt.Sprintf("%v;%v", imageName, f), f, f, layers)
+		}
+	}
+
+	return sheet, atlas
+}
+
+// Returns the Aseprite source files that a texture could be built from, in order of preference.
+// If the path is already an Aseprite file, then only that path is returned.
+func AsepritePaths(assetPath string) []string {
+	ext := path.Ext(assetPath)
+	for _, aseExt := range AsepriteExtensions {
+		if strings.EqualFold(ext, aseExt) {
+			return []string{assetPath}
+		}
+	}
+	base := strings.TrimSuffix(assetPath, ext)
+	paths := make([]string, len(AsepriteExtensions))
+	for i, aseExt := range AsepriteExtensions {
+		paths[i] = base + aseExt
+	}
+	return paths
+}
+
+// Returns the Aseprite source file that the texture at the given path should be built from, if there is one.
+func findAsepriteSource(assetPath string) (string, bool) {
+	for _, sourcePath := range AsepritePaths(assetPath) {
+		if _, _, err := assets.ResolvePath(sourcePath); err == nil {
+			return sourcePath, true
+		}
+	}
+	return "", false
+}
+
+// Reads an Aseprite file and composes its frames into an atlas, along with the metadata for its animations, layers, and slices.
+func loadAsepriteSource(assetPath string) (*aseSpriteSheet, image.Image, error) {
+	file, err := assets.GetFile(assetPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	aseFile, err := readAsepriteFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %v: %v", assetPath, err)
+	}
+	sheet, atlas := aseFile.spriteSheet(path.Base(assetPath))
+	return sheet, atlas, nil
+}
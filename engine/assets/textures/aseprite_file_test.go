@@ -0,0 +1,179 @@
+package textures
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+// Writes Aseprite chunks for tests.
+type aseWriter struct {
+	bytes.Buffer
+}
+
+func (w *aseWriter) put(values ...any) {
+	for _, value := range values {
+		binary.Write(&w.Buffer, binary.LittleEndian, value)
+	}
+}
+
+func (w *aseWriter) putString(text string) {
+	w.put(uint16(len(text)))
+	w.WriteString(text)
+}
+
+func (w *aseWriter) chunk(chunkType uint16, body func(c *aseWriter)) {
+	var c aseWriter
+	body(&c)
+	w.put(uint32(c.Len()+6), chunkType)
+	w.Write(c.Bytes())
+}
+
+// Builds a 2x2 RGBA sprite with two frames of one color each, a tag, a slice, and a layer with view range settings.
+func testAsepriteFile(t *testing.T) []byte {
+	var frames [2]aseWriter
+	frames[0].chunk(ASE_CHUNK_LAYER, func(c *aseWriter) {
+		c.put(uint16(ASE_LAYER_FLAG_VISIBLE), uint16(ASE_LAYER_TYPE_IMAGE), uint16(0), uint16(0), uint16(0), uint16(0), uint8(255), [3]byte{})
+		c.putString("front")
+	})
+	frames[0].chunk(ASE_CHUNK_USER_DATA, func(c *aseWriter) {
+		c.put(uint32(ASE_USER_DATA_HAS_TEXT))
+		c.putString("viewRange:-45,45")
+	})
+	frames[0].chunk(ASE_CHUNK_TAGS, func(c *aseWriter) {
+		c.put(uint16(1), [8]byte{})
+		c.put(uint16(0), uint16(1), uint8(1), uint16(0), [6]byte{}, [3]byte{}, uint8(0))
+		c.putString("walk")
+	})
+	frames[0].chunk(ASE_CHUNK_USER_DATA, func(c *aseWriter) {
+		c.put(uint32(ASE_USER_DATA_HAS_TEXT))
+		c.putString("default")
+	})
+	frames[0].chunk(ASE_CHUNK_SLICE, func(c *aseWriter) {
+		c.put(uint32(1), uint32(0), uint32(0))
+		c.putString(META_SLICE_NAME)
+		c.put(uint32(0), int32(0), int32(0), uint32(2), uint32(2))
+	})
+	frames[0].chunk(ASE_CHUNK_USER_DATA, func(c *aseWriter) {
+		c.put(uint32(ASE_USER_DATA_HAS_TEXT))
+		c.putString("clampBorder")
+	})
+	// The first frame is red and stored raw, while the second is blue and compressed.
+	frames[0].chunk(ASE_CHUNK_CEL, func(c *aseWriter) {
+		c.put(uint16(0), int16(0), int16(0), uint8(255), uint16(ASE_CEL_RAW), int16(0), [5]byte{}, uint16(2), uint16(2))
+		for range 4 {
+			c.put([4]byte{255, 0, 0, 255})
+		}
+	})
+	frames[1].chunk(ASE_CHUNK_CEL, func(c *aseWriter) {
+		c.put(uint16(0), int16(0), int16(0), uint8(255), uint16(ASE_CEL_COMPRESSED), int16(0), [5]byte{}, uint16(2), uint16(2))
+		zw := zlib.NewWriter(c)
+		for range 4 {
+			zw.Write([]byte{0, 0, 255, 255})
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	return testAsepriteFileFrom(frames[:], 32)
+}
+
+// Puts the frames' chunks together into a 2x2 sprite with the given color depth.
+func testAsepriteFileFrom(frames []aseWriter, colorDepth uint16) []byte {
+	var body aseWriter
+	for f := range frames {
+		// Count the chunks by walking their sizes.
+		chunkCount := 0
+		for data := frames[f].Bytes(); len(data) > 0; chunkCount++ {
+			data = data[binary.LittleEndian.Uint32(data):]
+		}
+		body.put(uint32(frames[f].Len()+16), uint16(ASE_FRAME_MAGIC), uint16(chunkCount), uint16(100*(f+1)), [2]byte{}, uint32(0))
+		body.Write(frames[f].Bytes())
+	}
+
+	var file aseWriter
+	file.put(uint32(128+body.Len()), uint16(ASE_FILE_MAGIC), uint16(len(frames)), uint16(2), uint16(2), colorDepth, uint32(ASE_HEADER_FLAG_LAYER_OPACITY))
+	file.put(uint16(0), uint32(0), uint32(0), uint8(0), [3]byte{}, uint16(0))
+	file.Write(make([]byte, 128-file.Len()))
+	file.Write(body.Bytes())
+	return file.Bytes()
+}
+
+func TestReadAsepriteFile(t *testing.T) {
+	aseFile, err := readAsepriteFile(bytes.NewReader(testAsepriteFile(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aseFile.width != 2 || aseFile.height != 2 || len(aseFile.frames) != 2 {
+		t.Fatalf("Wrong size: %vx%v with %v frames", aseFile.width, aseFile.height, len(aseFile.frames))
+	}
+	if len(aseFile.layers) != 1 || aseFile.layers[0].name != "front" || aseFile.layers[0].data != "viewRange:-45,45" {
+		t.Errorf("Wrong layers: %+v", aseFile.layers)
+	}
+	if len(aseFile.tags) != 1 || aseFile.tags[0].Name != "walk" || aseFile.tags[0].Direction != "reverse" || aseFile.tags[0].Data != "default" {
+		t.Errorf("Wrong tags: %+v", aseFile.tags)
+	}
+	if len(aseFile.slices) != 1 || aseFile.slices[0].Data != "clampBorder" || aseFile.slices[0].Keys[0].Bounds.W != 2 {
+		t.Errorf("Wrong slices: %+v", aseFile.slices)
+	}
+
+	sheet, atlas := aseFile.spriteSheet("test.png")
+	if atlas.Bounds().Dx() != 4 || atlas.Bounds().Dy() != 2 {
+		t.Fatalf("Split layers should put the frames in one row, but the atlas is %v", atlas.Bounds())
+	}
+	if red := atlas.NRGBAAt(1, 1); red.R != 255 || red.B != 0 {
+		t.Errorf("First frame has the wrong color: %v", red)
+	}
+	if blue := atlas.NRGBAAt(3, 1); blue.B != 255 || blue.R != 0 {
+		t.Errorf("Second frame has the wrong color: %v", blue)
+	}
+
+	anims, err := sheet.loadAnimations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, ok := anims["walk;front"]
+	if !ok {
+		t.Fatalf("Missing animation for the layer: %v", anims)
+	}
+	if !anim.Default || !anim.Loop || len(anim.Frames) != 2 {
+		t.Errorf("Wrong animation: %+v", anim)
+	}
+	// The tag is reversed, so the second frame comes first.
+	if anim.Frames[0].Rect.X != 2 || anim.Frames[0].Duration != 0.2 {
+		t.Errorf("Wrong first frame: %+v", anim.Frames[0])
+	}
+
+	layers, err := sheet.loadLayers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layers["front"].ViewRange != [2]int{-45, 45} {
+		t.Errorf("Wrong view range: %v", layers["front"].ViewRange)
+	}
+	if flags := sheet.loadFlags(); len(flags) != 1 || flags[0] != FLAG_CLAMP_BORDER {
+		t.Errorf("Wrong flags: %v", flags)
+	}
+}
+
+func TestReadAsepriteOldPalette(t *testing.T) {
+	// Old palettes of this type store each channel from 0 to 63.
+	frames := make([]aseWriter, 1)
+	frames[0].chunk(ASE_CHUNK_OLD_PALETTE_2, func(c *aseWriter) {
+		c.put(uint16(1), uint8(1), uint8(2))
+		c.put([3]byte{63, 32, 0}, [3]byte{1, 62, 63})
+	})
+	aseFile, err := readAsepriteFile(bytes.NewReader(testAsepriteFileFrom(frames, 8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []color.NRGBA{{0, 0, 0, 0}, {255, 129, 0, 255}, {4, 250, 255, 255}}
+	for i, entry := range expected {
+		if aseFile.palette[i] != entry {
+			t.Errorf("Palette entry %v should be %v but is %v", i, entry, aseFile.palette[i])
+		}
+	}
+}
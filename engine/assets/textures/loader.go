@@ -31,45 +31,95 @@ func loadImage(assetPath string) (*image.RGBA, error) {
 		return nil, fmt.Errorf("error decoding image at %s", assetPath)
 	}
 
-	// Convert image to RGBA
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
+	rgba, err := flippedRGBA(img)
+	if err != nil {
 		return nil, fmt.Errorf("error converting image at %s", assetPath)
 	}
+	return rgba, nil
+}
+
+// Converts the image to RGBA and flips it vertically, so that it can be loaded into a texture.
+func flippedRGBA(img image.Image) (*image.RGBA, error) {
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	if rgba.Stride != rgba.Rect.Size().X*4 {
+		return nil, fmt.Errorf("unexpected stride")
+	}
 	// Flip vertically
 	for x := 0; x < rgba.Bounds().Dx(); x++ {
 		for y := 0; y < rgba.Bounds().Dy(); y++ {
-			rgba.Set(x, y, img.At(x, rgba.Bounds().Dy()-y-1))
+			rgba.Set(x, y, img.At(img.Bounds().Min.X+x, img.Bounds().Max.Y-y-1))
 		}
 	}
-
 	return rgba, nil
 }
 
-func LoadTexture(assetPath string) (*Texture, error) {
+// Returns the path of the Aseprite JSON file holding the metadata for the texture at the given path.
+func MetadataPath(assetPath string) string {
+	return strings.TrimSuffix(assetPath, ".png") + ".json"
+}
+
+// Returns the paths of the files that a texture can be decoded from, including the ones that don't exist.
+func TextureDependencies(assetPath string) []string {
+	return append([]string{assetPath, MetadataPath(assetPath)}, AsepritePaths(assetPath)...)
+}
 
-	// Look for metadata file
-	metaPath := strings.TrimSuffix(assetPath, ".png") + ".json"
-	metadata, err := assets.LoadAndUnmarshalJSON[aseSpriteSheet](metaPath)
-	if _, ok := err.(*os.PathError); err != nil && !ok {
-		// The file is optional, so print errors that aren't 'file not found'.
-		log.Printf("Could not parse metadata for %s; %s\n", assetPath, err)
+// Loads a texture from a .png file and its optional Aseprite metadata, then uploads it to the GPU.
+func LoadTexture(assetPath string) (*Texture, error) {
+	texture, err := DecodeTexture(assetPath)
+	if err != nil {
+		return ErrorTexture(), err
 	}
+	texture.Upload()
+
+	log.Printf("Texture loaded at %v.\n", assetPath)
 
-	// Load atlas image file listed in the metadata, or else use the image itself.
+	return texture, nil
+}
+
+// Reads and decodes a texture's image and metadata without uploading it to the GPU.
+// If there is an Aseprite file next to the image with the same name, the texture is built from it instead of the exported files.
+// This doesn't use OpenGL, so it can be called from other goroutines. Upload must be called on the main thread before the texture is used for rendering.
+func DecodeTexture(assetPath string) (*Texture, error) {
+	var metadata *aseSpriteSheet
 	var img *image.RGBA
-	if metadata != nil {
-		img, err = loadImage(filepath.Join(filepath.Dir(assetPath), metadata.atlasPath()))
-	} else {
-		img, err = loadImage(assetPath)
+	var err error
+
+	if sourcePath, ok := findAsepriteSource(assetPath); ok {
+		var sourceImg image.Image
+		metadata, sourceImg, err = loadAsepriteSource(sourcePath)
+		if err == nil {
+			img, err = flippedRGBA(sourceImg)
+		}
+		if err != nil {
+			// Fall back to the exported files.
+			log.Printf("Could not load Aseprite source for %s; %s\n", assetPath, err)
+			metadata, img = nil, nil
+		}
 	}
-	if err != nil {
-		return ErrorTexture(), err
+
+	if img == nil {
+		// Look for metadata file
+		metadata, err = assets.LoadAndUnmarshalJSON[aseSpriteSheet](MetadataPath(assetPath))
+		if _, ok := err.(*os.PathError); err != nil && !ok {
+			// The file is optional, so print errors that aren't 'file not found'.
+			log.Printf("Could not parse metadata for %s; %s\n", assetPath, err)
+		}
+
+		// Load atlas image file listed in the metadata, or else use the image itself.
+		if metadata != nil {
+			img, err = loadImage(filepath.Join(filepath.Dir(assetPath), metadata.atlasPath()))
+		} else {
+			img, err = loadImage(assetPath)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	texture := &Texture{
 		width:  uint32(img.Bounds().Dx()),
 		height: uint32(img.Bounds().Dy()),
+		pixels: img,
 	}
 
 	if metadata != nil {
@@ -99,27 +149,42 @@ func LoadTexture(assetPath string) (*Texture, error) {
 		}
 	}
 
+	return texture, nil
+}
+
+// Sends the decoded image data of the texture to the GPU. Does nothing if the texture has already been uploaded.
+func (tex *Texture) Upload() {
+	if tex.glID != 0 || tex.pixels == nil {
+		return
+	}
+
 	// Set texture data as whole image
-	texture.target = gl.TEXTURE_2D
-	texture.glUnit = gl.TEXTURE0
+	tex.glUnit = gl.TEXTURE0
 	gl.ActiveTexture(gl.TEXTURE0)
-	gl.GenTextures(1, &texture.glID)
-	gl.BindTexture(texture.target, texture.glID)
-	gl.TexImage2D(texture.target, 0, gl.RGBA, int32(texture.width), int32(texture.height), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	gl.GenTextures(1, &tex.glID)
+	if tex.depth > 0 {
+		tex.target = gl.TEXTURE_2D_ARRAY
+		gl.BindTexture(tex.target, tex.glID)
+		gl.TexImage3D(tex.target, 0, gl.RGBA, int32(tex.width), int32(tex.height), int32(tex.depth), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(tex.pixels.Pix))
+	} else {
+		tex.target = gl.TEXTURE_2D
+		gl.BindTexture(tex.target, tex.glID)
+		gl.TexImage2D(tex.target, 0, gl.RGBA, int32(tex.width), int32(tex.height), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(tex.pixels.Pix))
+	}
 
 	// Apply filtering and mipmapping
-	gl.TexParameteri(texture.Target(), gl.TEXTURE_MAG_FILTER, gl.NEAREST)
-	gl.TexParameteri(texture.Target(), gl.TEXTURE_MIN_FILTER, gl.NEAREST_MIPMAP_NEAREST)
-	if texture.HasFlag(FLAG_CLAMP_BORDER) {
-		gl.TexParameteri(texture.Target(), gl.TEXTURE_WRAP_S, gl.CLAMP_TO_BORDER)
-		gl.TexParameteri(texture.Target(), gl.TEXTURE_WRAP_T, gl.CLAMP_TO_BORDER)
+	gl.TexParameteri(tex.Target(), gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(tex.Target(), gl.TEXTURE_MIN_FILTER, gl.NEAREST_MIPMAP_NEAREST)
+	if tex.HasFlag(FLAG_CLAMP_BORDER) {
+		gl.TexParameteri(tex.Target(), gl.TEXTURE_WRAP_S, gl.CLAMP_TO_BORDER)
+		gl.TexParameteri(tex.Target(), gl.TEXTURE_WRAP_T, gl.CLAMP_TO_BORDER)
 	} else {
-		gl.TexParameteri(texture.Target(), gl.TEXTURE_WRAP_S, gl.REPEAT)
-		gl.TexParameteri(texture.Target(), gl.TEXTURE_WRAP_T, gl.REPEAT)
+		gl.TexParameteri(tex.Target(), gl.TEXTURE_WRAP_S, gl.REPEAT)
+		gl.TexParameteri(tex.Target(), gl.TEXTURE_WRAP_T, gl.REPEAT)
 	}
-	gl.GenerateMipmap(texture.Target())
-
-	log.Printf("Texture loaded at %v.\n", assetPath)
+	gl.GenerateMipmap(tex.Target())
 
-	return texture, nil
+	if !tex.keepPixels {
+		tex.pixels = nil
+	}
 }
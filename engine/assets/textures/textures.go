@@ -1,6 +1,7 @@
 package textures
 
 import (
+	"image"
 	_ "image/png"
 	"strings"
 
@@ -18,10 +19,13 @@ type Texture struct {
 	glUnit     uint32               // Texture unit (gl.TEXTURE0 for regular, gl.TEXTURE1 for atlas)
 	width      uint32               // Size of entire texture
 	height     uint32               // Size of the entire texture
+	depth      uint32               // Number of layers in an array texture (gl.TEXTURE_2D_ARRAY). Zero for other textures.
 	flags      []string             // Flags indicate the in-game properties of the texture
 	slices     map[string]Slice     // Holds the slices defined in Aseprite (excluding the meta slice). Indexed by name.
 	animations map[string]Animation // Map of animations by name. If layers are present, the names will be in the format animName;layerName
 	layers     map[string]Layer
+	pixels     *image.RGBA // Decoded image data. Nil once uploaded, unless keepPixels is set. Array textures stack their layers vertically.
+	keepPixels bool        // Whether the pixels stay in memory after uploading, for textures whose images are needed again.
 }
 
 type Layer struct {
@@ -44,6 +48,36 @@ func (tex *Texture) Height() int {
 	return int(tex.height)
 }
 
+// Returns the number of layers in an array texture, or 1 for other textures.
+func (tex *Texture) Depth() int {
+	return int(max(tex.depth, 1))
+}
+
+// Returns true if the texture's image data is available on the CPU side, either because it hasn't been uploaded yet or because it keeps its pixels.
+func (tex *Texture) HasPixels() bool {
+	return tex.pixels != nil
+}
+
+// Makes the texture keep its image data after uploading, so that it can be read again later.
+// Used for map tile textures, which are packed into the atlas of each map that uses them.
+func (tex *Texture) KeepPixels() {
+	tex.keepPixels = true
+}
+
+// Returns true if any of the texture's pixels are transparent enough for the shaders to discard them, so that things behind can be seen.
+// Always false once the texture has been uploaded, unless it keeps its pixels.
+func (tex *Texture) HasTransparentPixels() bool {
+	if tex.pixels == nil {
+		return false
+	}
+	for i := 3; i < len(tex.pixels.Pix); i += 4 {
+		if tex.pixels.Pix[i] < 128 {
+			return true
+		}
+	}
+	return false
+}
+
 func (tex *Texture) ID() uint32 {
 	return tex.glID
 }
@@ -75,11 +109,25 @@ func (tex *Texture) Rect() math2.Rect {
 	}
 }
 
+// Approximates the amount of video memory used by the texture in bytes, including mipmaps.
+func (tex *Texture) GPUSize() int {
+	if tex.glID == 0 {
+		return 0
+	}
+	return int(tex.width*tex.height*4) * tex.Depth() * 4 / 3
+}
+
 func (tex *Texture) Free() {
 	id := tex.glID
 	gl.DeleteTextures(1, &id)
 }
 
+// Frees this texture's data and takes over the data of another texture, so that existing references to this texture show the new one.
+func (tex *Texture) Replace(newTex *Texture) {
+	tex.Free()
+	*tex = *newTex
+}
+
 func (tex *Texture) GetDefaultAnimation() Animation {
 	for _, anim := range tex.animations {
 		if anim.Default || len(tex.animations) == 1 {
@@ -0,0 +1,208 @@
+package textures
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"slices"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets"
+)
+
+// Minimum number of array texture layers that OpenGL 3.3 guarantees, which limits the size of each atlas page.
+const MAX_ATLAS_LAYERS = 256
+
+// Holds the images of map tile textures in the layers of array textures, so that tiles with different textures can be drawn together.
+// Each page holds images of a single size, with each frame of an animated texture taking its own layer.
+type TileAtlas struct {
+	pages   []*Texture
+	entries map[string]AtlasEntry
+	batches map[string]AtlasEntry
+}
+
+// Locates a texture inside of a tile atlas.
+type AtlasEntry struct {
+	Page      int
+	Layer     int       // The layer holding the texture's image, or the first frame of its animation.
+	Animation Animation // For animated textures, the frames are stored in consecutive layers starting from Layer.
+	Batch     string    // Name of the group of tiles that are drawn together with this texture.
+}
+
+func (entry AtlasEntry) IsAnimated() bool {
+	return len(entry.Animation.Frames) > 1
+}
+
+type atlasPageKey struct {
+	width, height int
+	clampBorder   bool
+}
+
+// Copies the images of the given textures into the layers of new atlas pages.
+// Textures that have already been uploaded, or whose animation frames differ in size, are left out.
+// The pages still need to be uploaded before rendering.
+func PackTileAtlas(tileTextures map[string]*Texture) *TileAtlas {
+	atlas := &TileAtlas{
+		pages:   make([]*Texture, 0, 1),
+		entries: make(map[string]AtlasEntry, len(tileTextures)),
+		batches: make(map[string]AtlasEntry),
+	}
+
+	// Page index currently being filled for each image size.
+	openPages := make(map[atlasPageKey]int)
+	pageLayers := make([][]*image.RGBA, 0, 1)
+
+	// Sort the names so that the same textures are always packed the same way.
+	names := make([]string, 0, len(tileTextures))
+	for name := range tileTextures {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		tex := tileTextures[name]
+		if tex == nil || !tex.HasPixels() || tex.depth > 0 {
+			continue
+		}
+
+		frames, anim, err := tex.tileFrames()
+		if err != nil {
+			log.Printf("Could not pack %v into tile atlas: %v\n", name, err)
+			continue
+		}
+
+		key := atlasPageKey{
+			width:       frames[0].Bounds().Dx(),
+			height:      frames[0].Bounds().Dy(),
+			clampBorder: tex.HasFlag(FLAG_CLAMP_BORDER),
+		}
+		page, ok := openPages[key]
+		if !ok || len(pageLayers[page])+len(frames) > MAX_ATLAS_LAYERS {
+			// Start a new page, since animations shouldn't be split between pages.
+			page = len(atlas.pages)
+			openPages[key] = page
+			atlas.pages = append(atlas.pages, &Texture{
+				width:  uint32(key.width),
+				height: uint32(key.height),
+			})
+			if key.clampBorder {
+				atlas.pages[page].flags = []string{FLAG_CLAMP_BORDER}
+			}
+			pageLayers = append(pageLayers, make([]*image.RGBA, 0, len(frames)))
+		}
+
+		entry := AtlasEntry{
+			Page:      page,
+			Layer:     len(pageLayers[page]),
+			Animation: anim,
+		}
+		if entry.IsAnimated() {
+			// Animated textures are drawn separately, since each one shows a different frame.
+			entry.Batch = name
+		} else {
+			entry.Batch = fmt.Sprintf("atlas page %d", page)
+		}
+		atlas.entries[name] = entry
+		if _, ok := atlas.batches[entry.Batch]; !ok {
+			atlas.batches[entry.Batch] = AtlasEntry{Page: page, Animation: entry.Animation, Batch: entry.Batch}
+		}
+
+		pageLayers[page] = append(pageLayers[page], frames...)
+	}
+
+	// Stack the layers of each page into one image, which is the layout that glTexImage3D expects.
+	for p, page := range atlas.pages {
+		page.depth = uint32(len(pageLayers[p]))
+		page.pixels = image.NewRGBA(image.Rect(0, 0, int(page.width), int(page.height*page.depth)))
+		layerSize := int(page.width * page.height * 4)
+		for l, layer := range pageLayers[p] {
+			copy(page.pixels.Pix[l*layerSize:], layer.Pix)
+		}
+	}
+
+	return atlas
+}
+
+// Splits the texture's image into one image for each frame of its animation, or returns the whole image if it has no animation.
+func (tex *Texture) tileFrames() ([]*image.RGBA, Animation, error) {
+	anim := tex.GetDefaultAnimation()
+	if anim.IsNil() && tex.IsAtlas() {
+		names := tex.GetAnimationNames()
+		slices.Sort(names)
+		anim, _ = tex.GetAnimation(names[0])
+	}
+	if len(anim.Frames) == 0 {
+		return []*image.RGBA{cloneRGBA(tex.pixels, tex.pixels.Bounds())}, anim, nil
+	}
+
+	frames := make([]*image.RGBA, len(anim.Frames))
+	width, height := int(anim.Frames[0].Rect.Width), int(anim.Frames[0].Rect.Height)
+	for f, frame := range anim.Frames {
+		if int(frame.Rect.Width) != width || int(frame.Rect.Height) != height {
+			return nil, anim, fmt.Errorf("frame %v is %vx%v instead of %vx%v", f, frame.Rect.Width, frame.Rect.Height, width, height)
+		}
+		// The image is flipped vertically when loaded, while the frame rectangles are measured from the top.
+		x, y := int(frame.Rect.X), int(tex.height)-int(frame.Rect.Y)-height
+		bounds := image.Rect(x, y, x+width, y+height)
+		if !bounds.In(tex.pixels.Bounds()) {
+			return nil, anim, fmt.Errorf("frame %v is outside of the image", f)
+		}
+		frames[f] = cloneRGBA(tex.pixels, bounds)
+	}
+	return frames, anim, nil
+}
+
+// Copies part of an image into a new image starting at the origin.
+func cloneRGBA(src *image.RGBA, bounds image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := range bounds.Dy() {
+		srcStart := src.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		copy(dst.Pix[y*dst.Stride:][:bounds.Dx()*4], src.Pix[srcStart:])
+	}
+	return dst
+}
+
+// Returns where the texture with the given name was packed.
+func (atlas *TileAtlas) Entry(name string) (AtlasEntry, bool) {
+	if atlas == nil {
+		return AtlasEntry{}, false
+	}
+	entry, ok := atlas.entries[name]
+	return entry, ok
+}
+
+// Returns the page and animation used to draw the batch with the given name.
+// The layer of the returned entry is always zero, since the vertices store their own layers.
+func (atlas *TileAtlas) Batch(name string) (AtlasEntry, bool) {
+	if atlas == nil {
+		return AtlasEntry{}, false
+	}
+	entry, ok := atlas.batches[name]
+	return entry, ok
+}
+
+// Returns true if the textures drawn in the batch repeat past the edges of their images,
+// so that a face using them can be stretched across several tiles.
+func (atlas *TileAtlas) Repeats(batchName string) bool {
+	batch, ok := atlas.Batch(batchName)
+	return ok && !atlas.pages[batch.Page].HasFlag(FLAG_CLAMP_BORDER)
+}
+
+func (atlas *TileAtlas) Pages() []*Texture {
+	if atlas == nil {
+		return nil
+	}
+	return atlas.pages
+}
+
+// Sends the pages to the GPU. Must be called on the main thread.
+func (atlas *TileAtlas) Upload() {
+	for _, page := range atlas.Pages() {
+		page.Upload()
+	}
+}
+
+// Returns the name the given page is stored under in the asset cache, based on the map it was made for.
+// It is a generated name, so that it isn't mistaken for a file.
+func AtlasPageName(mapPath string, page int) string {
+	return assets.GeneratedName(mapPath, fmt.Sprintf("atlas%d", page))
+}
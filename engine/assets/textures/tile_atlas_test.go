@@ -0,0 +1,157 @@
+package textures
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+)
+
+// Makes a decoded texture whose pixels are all filled with the given shade.
+func solidTexture(width, height int, shade uint8) *Texture {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetRGBA(x, y, color.RGBA{shade, shade, shade, 255})
+		}
+	}
+	return &Texture{
+		width:  uint32(width),
+		height: uint32(height),
+		pixels: img,
+	}
+}
+
+// Returns the shade of the top left pixel of the given layer of an atlas page.
+func layerShade(page *Texture, layer int) uint8 {
+	return page.pixels.RGBAAt(0, layer*int(page.height)).R
+}
+
+func TestPackTileAtlas(t *testing.T) {
+	t.Run("same size", func(t *testing.T) {
+		atlas := PackTileAtlas(map[string]*Texture{
+			"a.png": solidTexture(64, 64, 10),
+			"b.png": solidTexture(64, 64, 20),
+			"c.png": solidTexture(64, 64, 30),
+		})
+		if len(atlas.Pages()) != 1 {
+			t.Fatalf("Expected 1 page, got %v", len(atlas.Pages()))
+		}
+		page := atlas.Pages()[0]
+		if page.Depth() != 3 || page.Width() != 64 || page.Height() != 64 {
+			t.Errorf("Page has the wrong size: %vx%vx%v", page.Width(), page.Height(), page.Depth())
+		}
+		for name, shade := range map[string]uint8{"a.png": 10, "b.png": 20, "c.png": 30} {
+			entry, ok := atlas.Entry(name)
+			if !ok {
+				t.Fatalf("%v is missing from the atlas", name)
+			}
+			if entry.Page != 0 || entry.IsAnimated() {
+				t.Errorf("%v has the wrong entry: %+v", name, entry)
+			}
+			if layerShade(page, entry.Layer) != shade {
+				t.Errorf("%v is stored in layer %v, which has the wrong pixels", name, entry.Layer)
+			}
+			if entry.Batch != "atlas page 0" {
+				t.Errorf("%v should be in the page's batch, not %q", name, entry.Batch)
+			}
+		}
+	})
+	t.Run("different sizes", func(t *testing.T) {
+		atlas := PackTileAtlas(map[string]*Texture{
+			"small.png": solidTexture(64, 64, 10),
+			"big.png":   solidTexture(128, 128, 20),
+			"wide.png":  solidTexture(128, 64, 30),
+		})
+		if len(atlas.Pages()) != 3 {
+			t.Fatalf("Expected 3 pages, got %v", len(atlas.Pages()))
+		}
+		big, _ := atlas.Entry("big.png")
+		if atlas.Pages()[big.Page].Width() != 128 || atlas.Pages()[big.Page].Height() != 128 {
+			t.Errorf("big.png was put in a page of the wrong size")
+		}
+	})
+	t.Run("full page", func(t *testing.T) {
+		tileTextures := make(map[string]*Texture, MAX_ATLAS_LAYERS+1)
+		for i := range MAX_ATLAS_LAYERS + 1 {
+			tileTextures[string(rune('A'+i/26))+string(rune('a'+i%26))] = solidTexture(4, 4, uint8(i))
+		}
+		atlas := PackTileAtlas(tileTextures)
+		if len(atlas.Pages()) != 2 {
+			t.Fatalf("Expected 2 pages, got %v", len(atlas.Pages()))
+		}
+		if atlas.Pages()[0].Depth() != MAX_ATLAS_LAYERS || atlas.Pages()[1].Depth() != 1 {
+			t.Errorf("Pages have the wrong number of layers: %v and %v", atlas.Pages()[0].Depth(), atlas.Pages()[1].Depth())
+		}
+	})
+	t.Run("already uploaded", func(t *testing.T) {
+		uploaded := solidTexture(64, 64, 10)
+		uploaded.pixels = nil
+		atlas := PackTileAtlas(map[string]*Texture{"uploaded.png": uploaded})
+		if _, ok := atlas.Entry("uploaded.png"); ok || len(atlas.Pages()) != 0 {
+			t.Errorf("Textures without pixels shouldn't be packed")
+		}
+	})
+}
+
+func TestPackTileAtlasAnimation(t *testing.T) {
+	// A strip of three frames, with the first frame on the left.
+	sheet := solidTexture(192, 64, 0)
+	for x := range 192 {
+		for y := range 64 {
+			shade := uint8(x/64+1) * 10
+			sheet.pixels.SetRGBA(x, y, color.RGBA{shade, shade, shade, 255})
+		}
+	}
+	anim := Animation{
+		Name:      "lava",
+		Loop:      true,
+		AtlasSize: [2]uint{192, 64},
+	}
+	for f := range 3 {
+		anim.Frames = append(anim.Frames, Frame{
+			Rect:     math2.Rect{X: float32(f * 64), Y: 0.0, Width: 64.0, Height: 64.0},
+			Duration: 0.1,
+		})
+	}
+	sheet.animations = map[string]Animation{anim.Name: anim}
+
+	atlas := PackTileAtlas(map[string]*Texture{
+		"brick.png": solidTexture(64, 64, 100),
+		"lava.png":  sheet,
+	})
+	if len(atlas.Pages()) != 1 || atlas.Pages()[0].Depth() != 4 {
+		t.Fatalf("Expected 1 page with 4 layers")
+	}
+
+	entry, ok := atlas.Entry("lava.png")
+	if !ok {
+		t.Fatalf("lava.png is missing from the atlas")
+	}
+	if !entry.IsAnimated() || entry.Batch != "lava.png" {
+		t.Errorf("lava.png should be animated in its own batch: %+v", entry)
+	}
+	for f := range 3 {
+		if shade := layerShade(atlas.Pages()[0], entry.Layer+f); shade != uint8(f+1)*10 {
+			t.Errorf("Frame %v has the wrong pixels (shade %v)", f, shade)
+		}
+	}
+
+	batch, ok := atlas.Batch("lava.png")
+	if !ok || !batch.IsAnimated() || batch.Page != entry.Page {
+		t.Errorf("lava.png's batch is wrong: %+v", batch)
+	}
+	if _, ok := atlas.Batch("atlas page 0"); !ok {
+		t.Errorf("The static batch is missing")
+	}
+
+	t.Run("mismatched frames", func(t *testing.T) {
+		anim.Frames[1].Rect.Width = 32.0
+		sheet.animations = map[string]Animation{anim.Name: anim}
+		atlas := PackTileAtlas(map[string]*Texture{"lava.png": sheet})
+		if _, ok := atlas.Entry("lava.png"); ok {
+			t.Errorf("Animations with frames of different sizes shouldn't be packed")
+		}
+	})
+}
@@ -194,3 +194,54 @@ func ResolveSphereTriangles(spherePos, meshPos mgl32.Vec3, sphere Sphere, mesh M
 	}
 	return
 }
+
+func ResolveCapsuleTriangles(capsulePos, meshPos mgl32.Vec3, capsule Capsule, mesh Mesh, filter TriParts) (result Result) {
+	if filter == TRI_PART_NONE {
+		return
+	}
+	bottom, top := capsule.Axis(capsulePos)
+	for _, triangle := range mesh.triangles {
+		center := closestPointOnSegmentToTriangle(bottom, top, triangle.OffsetBy(meshPos))
+		hit, col := SphereTriangleCollision(center, capsule.radius, triangle, meshPos)
+		if hit&filter != 0 {
+			result = col
+			result.Penetration += mgl32.Epsilon
+			return
+		}
+	}
+	return
+}
+
+// Pushes a shape out of the centers of a mesh's triangles first, then out of any part of them, and combines the two pushes.
+// The resolve function is called with where the shape is and which parts of the triangles to push it out of.
+// Checking the centers first prevents triangle edges from stopping smooth movement along neighboring triangles.
+func resolveTrianglesCentersFirst(myNextPosition mgl32.Vec3, resolve func(position mgl32.Vec3, filter TriParts) Result) Result {
+	var res1, res2 Result
+	var firstHitPosition, originalPosition, overallMovement, overallNormal mgl32.Vec3
+	var overallDistance float32
+
+	originalPosition = myNextPosition
+	res1 = resolve(myNextPosition, TRI_PART_CENTER)
+	if res1.Hit {
+		firstHitPosition = res1.Position
+		myNextPosition = myNextPosition.Add(res1.Normal.Mul(res1.Penetration))
+	}
+	res2 = resolve(myNextPosition, TRI_PART_ALL)
+	if res2.Hit {
+		if !res1.Hit {
+			firstHitPosition = res2.Position
+		}
+		myNextPosition = myNextPosition.Add(res2.Normal.Mul(res2.Penetration))
+	}
+	overallMovement = myNextPosition.Sub(originalPosition)
+	overallDistance = overallMovement.Len()
+	if overallDistance > 0.0 {
+		overallNormal = overallMovement.Mul(1.0 / overallDistance)
+	}
+	return Result{
+		Hit:         res1.Hit || res2.Hit,
+		Position:    firstHitPosition,
+		Normal:      overallNormal,
+		Penetration: overallDistance,
+	}
+}
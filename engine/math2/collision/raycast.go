@@ -221,6 +221,34 @@ func RayCylinderCollision(rayOrigin, rayDir, cylinderPos mgl32.Vec3, cylinderRad
 	return RaycastResult{}
 }
 
+// Finds where the ray hits the outside of an upright capsule. Rays that start inside of the capsule hit it where they leave it.
+func RayCapsuleCollision(rayOrigin, rayDir, capsulePos mgl32.Vec3, capsuleRadius, capsuleHalfHeight float32) RaycastResult {
+	bottom, top := capsuleAxis(capsulePos, capsuleRadius, capsuleHalfHeight)
+	origin, dir := rayOrigin, rayDir
+	// No two points of the capsule are farther apart than its height.
+	reverseLength := capsuleHalfHeight*2.0 + 1.0
+	inside := rayOrigin.Sub(closestPointOnSegment(bottom, top, rayOrigin)).LenSqr() < capsuleRadius*capsuleRadius
+	if inside {
+		// Find where the ray leaves by casting it back from the other side of the capsule.
+		origin, dir = rayOrigin.Add(rayDir.Mul(reverseLength)), rayDir.Mul(-1.0)
+	}
+
+	t, ok := rayEntersCapsule(origin, dir, bottom, top, capsuleRadius)
+	if !ok {
+		return RaycastResult{}
+	}
+	pos := origin.Add(dir.Mul(t))
+	if inside {
+		t = reverseLength - t
+	}
+	return RaycastResult{
+		Hit:      true,
+		Position: pos,
+		Normal:   pos.Sub(closestPointOnSegment(bottom, top, pos)).Normalize(), // Normal points outwards
+		Distance: t,
+	}
+}
+
 func RayTriangleCollision(rayOrigin, rayDir mgl32.Vec3, tri math2.Triangle) RaycastResult {
 	// Uses the Trumbore intersection algorithm
 	// https://en.wikipedia.org/wiki/M%C3%B6ller%E2%80%93Trumbore_intersection_algorithm
@@ -252,10 +280,15 @@ func RayTriangleCollision(rayOrigin, rayDir mgl32.Vec3, tri math2.Triangle) Rayc
 	t := invDet * edge2.Dot(sEdge1Cross)
 
 	if t > mgl32.Epsilon {
+		// The normal faces the side that the ray came from.
+		faceNormal := edge1.Cross(edge2).Normalize()
+		if faceNormal.Dot(rayDir) > 0.0 {
+			faceNormal = faceNormal.Mul(-1.0)
+		}
 		return RaycastResult{
 			Hit:      true,
 			Position: rayOrigin.Add(rayDir.Mul(t)),
-			Normal:   triNormal,
+			Normal:   faceNormal,
 			Distance: t,
 		}
 	}
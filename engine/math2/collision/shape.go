@@ -17,6 +17,12 @@ type MovingShape interface {
 	Touches(myPosition, theirPosition mgl32.Vec3, theirShape Shape) bool
 }
 
+// A moving shape that can be swept along its movement to find the first thing in its way, instead of only checking where it ends up.
+type SweepingShape interface {
+	MovingShape
+	Sweep(myPosition, myMovement, theirPosition mgl32.Vec3, theirShape Shape) RaycastResult
+}
+
 type shape struct {
 	extents math2.Box
 }
@@ -47,6 +47,9 @@ func (box Box) ResolveCollision(myPosition, myMovement, theirPosition mgl32.Vec3
 		//TODO: Box to mesh collision
 	case Cylinder:
 		//TODO: Box to cylinder collision
+	case Capsule:
+		center := otherShape.innerSphereCenter(theirPosition, box.extents.Translate(nextPosition).Center().Y())
+		return ResolveSphereBox(center, nextPosition, NewSphere(otherShape.radius), box)
 	case Grid:
 		return otherShape.ResolveOtherBodysCollision(theirPosition, myPosition, myMovement, box)
 	default:
@@ -61,6 +64,8 @@ func (box Box) Touches(myPosition, theirPosition mgl32.Vec3, theirShape Shape) b
 		return SphereTouchesBox(theirPosition, otherShape.radius, box.extents.Translate(myPosition))
 	case Box:
 		return box.extents.Translate(myPosition).Intersects(otherShape.extents.Translate(theirPosition))
+	case Capsule:
+		return otherShape.Touches(theirPosition, myPosition, box)
 	case Mesh:
 		//TODO: Box to mesh touching
 	case Grid:
@@ -0,0 +1,152 @@
+package collision
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+)
+
+// An upright capsule, which is a cylinder with a half sphere on each end. It is centered on its position.
+// Collisions are found using the sphere inside of the capsule that is closest to the other shape.
+type Capsule struct {
+	shape
+	radius, halfHeight float32
+}
+
+var _ MovingShape = (*Capsule)(nil)
+
+// Makes a capsule with the given total height, including the rounded ends. Heights smaller than the capsule's width make it a sphere.
+func NewCapsule(radius, height float32) Capsule {
+	halfHeight := max(height/2.0, radius)
+	return Capsule{
+		shape: shape{
+			extents: math2.Box{
+				Max: mgl32.Vec3{radius, halfHeight, radius},
+				Min: mgl32.Vec3{-radius, -halfHeight, -radius},
+			},
+		},
+		radius:     radius,
+		halfHeight: halfHeight,
+	}
+}
+
+func (capsule Capsule) String() string {
+	return "Capsule"
+}
+
+func (capsule Capsule) Extents() math2.Box {
+	return capsule.extents
+}
+
+func (capsule Capsule) Radius() float32 {
+	return capsule.radius
+}
+
+func (capsule Capsule) Height() float32 {
+	return capsule.halfHeight * 2.0
+}
+
+func (capsule Capsule) Raycast(rayOrigin, rayDir, shapeOffset mgl32.Vec3, maxDist float32) RaycastResult {
+	var res RaycastResult = RayCapsuleCollision(rayOrigin, rayDir, shapeOffset, capsule.radius, capsule.halfHeight)
+	if res.Distance <= maxDist {
+		return res
+	} else {
+		return RaycastResult{}
+	}
+}
+
+// Returns the centers of the spheres on the bottom and top ends of the capsule.
+func (capsule Capsule) Axis(position mgl32.Vec3) (bottom, top mgl32.Vec3) {
+	return capsuleAxis(position, capsule.radius, capsule.halfHeight)
+}
+
+// Returns the center of the sphere inside of the capsule that is closest to the given height.
+func (capsule Capsule) innerSphereCenter(position mgl32.Vec3, height float32) mgl32.Vec3 {
+	bottom, top := capsule.Axis(position)
+	return math2.Vec3WithY(position, math2.Clamp(height, bottom.Y(), top.Y()))
+}
+
+func (capsule Capsule) ResolveCollision(myPosition, myMovement, theirPosition mgl32.Vec3, theirShape Shape) Result {
+	myNextPosition := myPosition.Add(myMovement)
+	sphere := NewSphere(capsule.radius)
+	switch otherShape := theirShape.(type) {
+	case Sphere:
+		return ResolveSphereSphere(capsule.innerSphereCenter(myNextPosition, theirPosition.Y()), theirPosition, sphere, otherShape)
+	case Capsule:
+		myCenter := capsule.innerSphereCenter(myNextPosition, theirPosition.Y())
+		theirCenter := otherShape.innerSphereCenter(theirPosition, myCenter.Y())
+		return ResolveSphereSphere(myCenter, theirCenter, sphere, NewSphere(otherShape.radius))
+	case Box:
+		// Any point of the axis that is level with the box's center is as close to it as the axis gets.
+		boxCenter := otherShape.Extents().Translate(theirPosition).Center()
+		return ResolveSphereBox(capsule.innerSphereCenter(myNextPosition, boxCenter.Y()), theirPosition, sphere, otherShape)
+	case Cylinder:
+		return ResolveSphereCylinder(capsule.innerSphereCenter(myNextPosition, theirPosition.Y()), theirPosition, sphere, otherShape)
+	case Mesh:
+		return resolveTrianglesCentersFirst(myNextPosition, func(position mgl32.Vec3, filter TriParts) Result {
+			return ResolveCapsuleTriangles(position, theirPosition, capsule, otherShape, filter)
+		})
+	case Grid:
+		return otherShape.ResolveOtherBodysCollision(theirPosition, myNextPosition, mgl32.Vec3{}, capsule)
+	default:
+		panic("collision resolution not implemented for capsules and " + otherShape.String())
+	}
+}
+
+func (capsule Capsule) Touches(myPosition, theirPosition mgl32.Vec3, theirShape Shape) bool {
+	switch otherShape := theirShape.(type) {
+	case Sphere:
+		return SphereTouchesSphere(capsule.innerSphereCenter(myPosition, theirPosition.Y()), capsule.radius, theirPosition, otherShape.radius)
+	case Capsule:
+		myCenter := capsule.innerSphereCenter(myPosition, theirPosition.Y())
+		theirCenter := otherShape.innerSphereCenter(theirPosition, myCenter.Y())
+		return SphereTouchesSphere(myCenter, capsule.radius, theirCenter, otherShape.radius)
+	case Box:
+		box := otherShape.Extents().Translate(theirPosition)
+		return SphereTouchesBox(capsule.innerSphereCenter(myPosition, box.Center().Y()), capsule.radius, box)
+	case Cylinder:
+		center := capsule.innerSphereCenter(myPosition, theirPosition.Y())
+		return SphereTouchesCylinder(center, capsule.radius, theirPosition, otherShape.radius, otherShape.halfHeight)
+	case Mesh:
+		bottom, top := capsule.Axis(myPosition)
+		for _, triangle := range otherShape.triangles {
+			center := closestPointOnSegmentToTriangle(bottom, top, triangle.OffsetBy(theirPosition))
+			if hit, _ := SphereTriangleCollision(center, capsule.radius, triangle, theirPosition); hit != TRI_PART_NONE {
+				return true
+			}
+		}
+	case Grid:
+		return otherShape.OtherBodyTouches(theirPosition, myPosition, capsule)
+	default:
+		panic("Capsule.Touches must be implemented for shape " + otherShape.String())
+	}
+	return false
+}
+
+func capsuleAxis(position mgl32.Vec3, radius, halfHeight float32) (bottom, top mgl32.Vec3) {
+	halfLength := max(halfHeight-radius, 0.0)
+	return position.Sub(mgl32.Vec3{0.0, halfLength, 0.0}), position.Add(mgl32.Vec3{0.0, halfLength, 0.0})
+}
+
+// Returns the point on the line segment that is closest to the triangle, or one that is just as close.
+func closestPointOnSegmentToTriangle(start, end mgl32.Vec3, triangle math2.Triangle) mgl32.Vec3 {
+	plane := triangle.Plane()
+	dir := end.Sub(start)
+	var reference mgl32.Vec3
+	if along := plane.Normal.Dot(dir); mgl32.Abs(along) > mgl32.Epsilon {
+		// Find the part of the triangle closest to where the segment's line crosses its plane.
+		t := plane.Normal.Dot(triangle[0].Sub(start)) / along
+		reference = closestPointOnTriangle(start.Add(dir.Mul(t)), triangle)
+	} else {
+		// The segment is parallel to the plane, so its middle is as good of a place to look from as any.
+		reference = closestPointOnTriangle(start.Add(dir.Mul(0.5)), triangle)
+	}
+	return closestPointOnSegment(start, end, reference)
+}
+
+// Like math2.ClosestPointOnLine, but also works when the segment has no length.
+func closestPointOnSegment(start, end, point mgl32.Vec3) mgl32.Vec3 {
+	if start == end {
+		return start
+	}
+	return math2.ClosestPointOnLine(start, end, point)
+}
@@ -0,0 +1,129 @@
+package collision
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+)
+
+func TestRayCapsuleCollision(t *testing.T) {
+	// The capsule is 2 units tall, so the centers of its ends are at -0.5 and 0.5.
+	for _, test := range []struct {
+		name              string
+		rayOrigin, rayDir mgl32.Vec3
+		expected          RaycastResult
+	}{
+		{"hit side", mgl32.Vec3{5.0, 0.0, 0.0}, mgl32.Vec3{-1.0, 0.0, 0.0}, RaycastResult{
+			Hit:      true,
+			Distance: 4.5,
+			Normal:   mgl32.Vec3{1.0, 0.0, 0.0},
+			Position: mgl32.Vec3{0.5, 0.0, 0.0},
+		}},
+		{"hit top", mgl32.Vec3{0.0, 5.0, 0.0}, mgl32.Vec3{0.0, -1.0, 0.0}, RaycastResult{
+			Hit:      true,
+			Distance: 4.0,
+			Normal:   mgl32.Vec3{0.0, 1.0, 0.0},
+			Position: mgl32.Vec3{0.0, 1.0, 0.0},
+		}},
+		{"hit rounded bottom", mgl32.Vec3{0.3, -5.0, 0.0}, mgl32.Vec3{0.0, 1.0, 0.0}, RaycastResult{
+			Hit:      true,
+			Distance: 4.1,
+			Normal:   mgl32.Vec3{0.6, -0.8, 0.0},
+			Position: mgl32.Vec3{0.3, -0.9, 0.0},
+		}},
+		{"hit from inside", mgl32.Vec3{0.0, 0.2, 0.0}, mgl32.Vec3{1.0, 0.0, 0.0}, RaycastResult{
+			Hit:      true,
+			Distance: 0.5,
+			Normal:   mgl32.Vec3{1.0, 0.0, 0.0},
+			Position: mgl32.Vec3{0.5, 0.2, 0.0},
+		}},
+		{"pass by side", mgl32.Vec3{5.0, 0.0, 0.6}, mgl32.Vec3{-1.0, 0.0, 0.0}, RaycastResult{}},
+		{"pass over top", mgl32.Vec3{5.0, 1.2, 0.0}, mgl32.Vec3{-1.0, 0.0, 0.0}, RaycastResult{}},
+		{"pass by rounded top", mgl32.Vec3{0.45, 5.0, 0.45}, mgl32.Vec3{0.0, -1.0, 0.0}, RaycastResult{}},
+		{"point away", mgl32.Vec3{5.0, 0.0, 0.0}, mgl32.Vec3{1.0, 0.0, 0.0}, RaycastResult{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			res := RayCapsuleCollision(test.rayOrigin, test.rayDir, mgl32.Vec3{}, 0.5, 1.0)
+			checkSweepResult(t, res, test.expected)
+		})
+	}
+}
+
+func TestCapsuleCollision(t *testing.T) {
+	capsule := NewCapsule(0.5, 2.0)
+	grid := NewGrid(4, 1, 1, 2.0)
+	grid.SetShapeAt(1, 0, 0, NewBox(math2.BoxFromRadius(1.0)))
+
+	for _, test := range []struct {
+		name                 string
+		myPosition, theirPos mgl32.Vec3
+		theirShape           Shape
+		hit                  bool
+		normal               mgl32.Vec3
+		penetration          float32
+	}{
+		{"sphere at side", mgl32.Vec3{}, mgl32.Vec3{0.8, 0.3, 0.0}, NewSphere(0.5), true, mgl32.Vec3{-1.0, 0.0, 0.0}, 0.2},
+		{"sphere on top", mgl32.Vec3{}, mgl32.Vec3{0.0, 1.3, 0.0}, NewSphere(0.5), true, mgl32.Vec3{0.0, -1.0, 0.0}, 0.2},
+		{"sphere out of reach", mgl32.Vec3{}, mgl32.Vec3{0.7, 1.3, 0.0}, NewSphere(0.5), false, mgl32.Vec3{}, 0.0},
+		{"taller capsule", mgl32.Vec3{}, mgl32.Vec3{0.9, 1.0, 0.0}, NewCapsule(0.5, 2.0), true, mgl32.Vec3{-1.0, 0.0, 0.0}, 0.1},
+		{"capsule below", mgl32.Vec3{}, mgl32.Vec3{0.0, -2.0, 0.0}, NewCapsule(0.5, 2.5), true, mgl32.Vec3{0.0, 1.0, 0.0}, 0.25},
+		{"floor box", mgl32.Vec3{}, mgl32.Vec3{0.0, -1.3, 0.0}, NewBox(math2.BoxFromExtents(2.0, 0.5, 2.0)), true, mgl32.Vec3{0.0, 1.0, 0.0}, 0.2},
+		{"box below", mgl32.Vec3{}, mgl32.Vec3{0.0, -1.6, 0.0}, NewBox(math2.BoxFromExtents(2.0, 0.5, 2.0)), false, mgl32.Vec3{}, 0.0},
+		{"cylinder", mgl32.Vec3{}, mgl32.Vec3{0.9, 0.0, 0.0}, NewCylinder(0.5, 2.0), true, mgl32.Vec3{-1.0, 0.0, 0.0}, 0.1},
+		{"floor mesh", mgl32.Vec3{-0.5, 0.0, -0.5}, mgl32.Vec3{0.0, -0.9, 0.0}, NewMeshFromTriangles([]math2.Triangle{
+			{{-1.0, 0.0, -1.0}, {-1.0, 0.0, 1.0}, {1.0, 0.0, -1.0}},
+		}), true, mgl32.Vec3{0.0, 1.0, 0.0}, 0.1},
+		{"wall mesh", mgl32.Vec3{}, mgl32.Vec3{0.4, 0.0, 0.0}, NewMeshFromTriangles([]math2.Triangle{
+			{{0.0, -2.0, -2.0}, {0.0, -2.0, 4.0}, {0.0, 4.0, -2.0}},
+		}), true, mgl32.Vec3{-1.0, 0.0, 0.0}, 0.1},
+		{"grid", mgl32.Vec3{1.6, 1.0, 1.0}, mgl32.Vec3{}, grid, true, mgl32.Vec3{-1.0, 0.0, 0.0}, 0.1},
+		{"grid out of reach", mgl32.Vec3{1.4, 1.0, 1.0}, mgl32.Vec3{}, grid, false, mgl32.Vec3{}, 0.0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if touches := capsule.Touches(test.myPosition, test.theirPos, test.theirShape); touches != test.hit {
+				t.Errorf("capsule touching should be %v but is %v", test.hit, touches)
+			}
+			res := capsule.ResolveCollision(test.myPosition, mgl32.Vec3{}, test.theirPos, test.theirShape)
+			if res.Hit != test.hit {
+				t.Fatalf("collision hit should be %v but is %v", test.hit, res.Hit)
+			}
+			if !res.Normal.ApproxEqualThreshold(test.normal, 0.001) {
+				t.Errorf("normal should be %v but is %v", test.normal, res.Normal)
+			}
+			if !mgl32.FloatEqualThreshold(res.Penetration, test.penetration, 0.001) {
+				t.Errorf("penetration should be %v but is %v", test.penetration, res.Penetration)
+			}
+		})
+	}
+}
+
+func TestSphereSweepCapsuleCollision(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		start, end mgl32.Vec3
+		expected   RaycastResult
+	}{
+		{"hit side", mgl32.Vec3{-5.0, 0.0, 0.0}, mgl32.Vec3{5.0, 0.0, 0.0}, RaycastResult{
+			Hit:      true,
+			Distance: 4.0,
+			Normal:   mgl32.Vec3{-1.0, 0.0, 0.0},
+			Position: mgl32.Vec3{-0.5, 0.0, 0.0},
+		}},
+		{"hit rounded top", mgl32.Vec3{-5.0, 1.1, 0.0}, mgl32.Vec3{5.0, 1.1, 0.0}, RaycastResult{
+			// Touches when the center is 0.8 to the left of and 0.6 above the center of the top end.
+			Hit:      true,
+			Distance: 4.2,
+			Normal:   mgl32.Vec3{-0.8, 0.6, 0.0},
+			Position: mgl32.Vec3{-0.4, 0.8, 0.0},
+		}},
+		{"stop short", mgl32.Vec3{-5.0, 0.0, 0.0}, mgl32.Vec3{-2.0, 0.0, 0.0}, RaycastResult{}},
+		{"pass over", mgl32.Vec3{-5.0, 1.6, 0.0}, mgl32.Vec3{5.0, 1.6, 0.0}, RaycastResult{}},
+		{"move out from inside", mgl32.Vec3{0.8, 0.0, 0.0}, mgl32.Vec3{5.0, 0.0, 0.0}, RaycastResult{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			res := SphereSweep(test.start, test.end, 0.5, mgl32.Vec3{}, NewCapsule(0.5, 2.0))
+			checkSweepResult(t, res, test.expected)
+		})
+	}
+}
@@ -11,7 +11,7 @@ type Sphere struct {
 	continuous bool
 }
 
-var _ MovingShape = (*Sphere)(nil)
+var _ SweepingShape = (*Sphere)(nil)
 
 func NewSphere(radius float32) Sphere {
 	return Sphere{
@@ -88,42 +88,23 @@ func (sphere Sphere) ResolveCollisionDiscrete(myNextPosition, theirPosition mgl3
 	case Cylinder:
 		return ResolveSphereCylinder(myNextPosition, theirPosition, sphere, otherShape)
 	case Mesh:
-		// Check for triangle hits in the center first, then the edges.
-		// This prevents triangle edges from stopping smooth movement along neighboring triangles.
-		var res1, res2 Result
-		var firstHitPosition, originalPosition, overallMovement, overallNormal mgl32.Vec3
-		var overallDistance float32
-
-		originalPosition = myNextPosition
-		res1 = ResolveSphereTriangles(myNextPosition, theirPosition, sphere, otherShape, TRI_PART_CENTER)
-		if res1.Hit {
-			firstHitPosition = res1.Position
-			myNextPosition = myNextPosition.Add(res1.Normal.Mul(res1.Penetration))
-		}
-		res2 = ResolveSphereTriangles(myNextPosition, theirPosition, sphere, otherShape, TRI_PART_ALL)
-		if res2.Hit {
-			if !res1.Hit {
-				firstHitPosition = res2.Position
-			}
-			myNextPosition = myNextPosition.Add(res2.Normal.Mul(res2.Penetration))
-		}
-		overallMovement = myNextPosition.Sub(originalPosition)
-		overallDistance = overallMovement.Len()
-		if overallDistance > 0.0 {
-			overallNormal = overallMovement.Mul(1.0 / overallDistance)
-		}
-		return Result{
-			Hit:         res1.Hit || res2.Hit,
-			Position:    firstHitPosition,
-			Normal:      overallNormal,
-			Penetration: overallDistance,
-		}
+		return resolveTrianglesCentersFirst(myNextPosition, func(position mgl32.Vec3, filter TriParts) Result {
+			return ResolveSphereTriangles(position, theirPosition, sphere, otherShape, filter)
+		})
+	case Capsule:
+		center := otherShape.innerSphereCenter(theirPosition, myNextPosition.Y())
+		return ResolveSphereSphere(myNextPosition, center, sphere, NewSphere(otherShape.radius))
 	case Grid:
 		return otherShape.ResolveOtherBodysCollision(theirPosition, myNextPosition, mgl32.Vec3{}, sphere)
 	}
 	return Result{}
 }
 
+// Finds where the sphere first touches the other shape as it moves. See SphereSweep.
+func (sphere Sphere) Sweep(myPosition, myMovement, theirPosition mgl32.Vec3, theirShape Shape) RaycastResult {
+	return SphereSweep(myPosition, myPosition.Add(myMovement), sphere.radius, theirPosition, theirShape)
+}
+
 func (sphere Sphere) Touches(myPosition, theirPosition mgl32.Vec3, theirShape Shape) bool {
 	switch otherShape := theirShape.(type) {
 	case Sphere:
@@ -138,6 +119,8 @@ func (sphere Sphere) Touches(myPosition, theirPosition mgl32.Vec3, theirShape Sh
 				return true
 			}
 		}
+	case Capsule:
+		return SphereTouchesSphere(myPosition, sphere.radius, otherShape.innerSphereCenter(theirPosition, myPosition.Y()), otherShape.radius)
 	case Grid:
 		if otherShape.OtherBodyTouches(theirPosition, myPosition, sphere) {
 			return true
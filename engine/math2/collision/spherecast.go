@@ -7,55 +7,382 @@ import (
 	"tophatdemon.com/total-invasion-ii/engine/math2"
 )
 
+// The sphere casts in this file move a sphere from a start point to an end point and find where it first touches something.
+// In their results, Distance is how far the sphere's center travels before touching, Position is the point of contact
+// on the other shape, and Normal points from that point towards the sphere's center.
+// A sphere that already touches the other shape at the start doesn't hit it, so that it can move out again.
+
+// Limits the steps taken to find where a sphere touches the rounded rim of a cylinder.
+const SPHERE_SWEEP_MAX_STEPS = 32
+
+// Sweeps a sphere against any shape that has a position. Shapes that sweeps aren't supported for are never hit.
+func SphereSweep(start, end mgl32.Vec3, sphereRadius float32, theirPosition mgl32.Vec3, theirShape Shape) RaycastResult {
+	switch otherShape := theirShape.(type) {
+	case Sphere:
+		return SphereSweepSphereCollision(start, end, sphereRadius, theirPosition, otherShape.radius)
+	case Box:
+		return SphereSweepBoxCollision(start, end, sphereRadius, otherShape.Extents().Translate(theirPosition))
+	case Cylinder:
+		return SphereSweepCylinderCollision(start, end, sphereRadius, theirPosition, otherShape.radius, otherShape.halfHeight)
+	case Capsule:
+		return SphereSweepCapsuleCollision(start, end, sphereRadius, theirPosition, otherShape.radius, otherShape.halfHeight)
+	case Mesh:
+		return SphereSweepTrianglesCollision(start, end, sphereRadius, otherShape.triangles, theirPosition)
+	case Grid:
+		return otherShape.SphereSweep(theirPosition, start, end, sphereRadius)
+	}
+	return RaycastResult{}
+}
+
+func SphereSweepSphereCollision(start, end mgl32.Vec3, sphereRadius float32, otherPos mgl32.Vec3, otherRadius float32) RaycastResult {
+	dir, maxDist := sweepDirection(start, end)
+	if maxDist == 0.0 {
+		return RaycastResult{}
+	}
+	// The sphere touches the other one when its center is as far from the other's center as their radii combined.
+	if t, ok := rayEntersSphere(start, dir, otherPos, sphereRadius+otherRadius); ok && t <= maxDist {
+		center := start.Add(dir.Mul(t))
+		return sweepResult(t, center, otherPos.Add(center.Sub(otherPos).Normalize().Mul(otherRadius)), dir)
+	}
+	return RaycastResult{}
+}
+
 func SphereSweepBoxCollision(start, end mgl32.Vec3, sphereRadius float32, box math2.Box) RaycastResult {
-	var result RaycastResult
-	var nearestPlaneDist float32 = math.MaxFloat32
+	dir, maxDist := sweepDirection(start, end)
+	if maxDist == 0.0 || start.Sub(closestPointOnBox(start, box)).LenSqr() <= sphereRadius*sphereRadius {
+		return RaycastResult{}
+	}
 
-	sweepDir := end.Sub(start)
-	if sweepDir.LenSqr() > 0.0 {
-		sweepDir = sweepDir.Normalize()
+	// The places where the sphere's center could be when it touches the box form a box with rounded edges.
+	// The first one the center reaches is on either a face, an edge, or a corner of the box.
+	var nearest float32 = math.MaxFloat32
+	for axis := range 3 {
+		otherAxis, otherOtherAxis := (axis+1)%3, (axis+2)%3
+		for _, side := range [...]float32{box.Min[axis] - sphereRadius, box.Max[axis] + sphereRadius} {
+			if dir[axis] == 0.0 {
+				continue
+			}
+			t := (side - start[axis]) / dir[axis]
+			if t < 0.0 || t >= nearest {
+				continue
+			}
+			center := start.Add(dir.Mul(t))
+			if center[otherAxis] >= box.Min[otherAxis] && center[otherAxis] <= box.Max[otherAxis] &&
+				center[otherOtherAxis] >= box.Min[otherOtherAxis] && center[otherOtherAxis] <= box.Max[otherOtherAxis] {
+				nearest = t
+			}
+		}
+	}
+	corners := box.Corners()
+	for c, corner := range corners {
+		if t, ok := rayEntersSphere(start, dir, corner, sphereRadius); ok && t < nearest {
+			nearest = t
+		}
+		for _, neighbor := range corners[c+1:] {
+			// Corners that differ along only one axis share an edge.
+			same := 0
+			for axis := range 3 {
+				if corner[axis] == neighbor[axis] {
+					same++
+				}
+			}
+			if same != 2 {
+				continue
+			}
+			if t, ok := rayEntersCapsuleSide(start, dir, corner, neighbor, sphereRadius); ok && t < nearest {
+				nearest = t
+			}
+		}
 	}
 
-	boxPlanes := [...]math2.Plane{
-		math2.PlaneFromPointAndNormal(box.Min, mgl32.Vec3{-1.0, 0.0, 0.0}), // Left side
-		math2.PlaneFromPointAndNormal(box.Min, mgl32.Vec3{0.0, -1.0, 0.0}), // Bottom side
-		math2.PlaneFromPointAndNormal(box.Min, mgl32.Vec3{0.0, 0.0, -1.0}), // Front side
-		math2.PlaneFromPointAndNormal(box.Max, mgl32.Vec3{1.0, 0.0, 0.0}),  // Right side
-		math2.PlaneFromPointAndNormal(box.Max, mgl32.Vec3{0.0, 1.0, 0.0}),  // Top side
-		math2.PlaneFromPointAndNormal(box.Max, mgl32.Vec3{0.0, 0.0, 1.0}),  // Back side
+	if nearest > maxDist {
+		return RaycastResult{}
 	}
+	center := start.Add(dir.Mul(nearest))
+	return sweepResult(nearest, center, closestPointOnBox(center, box), dir)
+}
 
-	for _, plane := range boxPlanes {
-		if cast := RayPlaneCollision(start, sweepDir, plane, false); cast.Hit && cast.Distance < nearestPlaneDist {
-			nearestPlaneDist = cast.Distance
-			result = cast
+func SphereSweepCylinderCollision(start, end mgl32.Vec3, sphereRadius float32, cylinderPos mgl32.Vec3, cylinderRadius, cylinderHalfHeight float32) RaycastResult {
+	dir, maxDist := sweepDirection(start, end)
+	closestPoint := func(point mgl32.Vec3) mgl32.Vec3 {
+		return closestPointOnCylinder(point, cylinderPos, cylinderRadius, cylinderHalfHeight)
+	}
+	if maxDist == 0.0 || start.Sub(closestPoint(start)).LenSqr() <= sphereRadius*sphereRadius {
+		return RaycastResult{}
+	}
+
+	// The center first reaches either the widened side of the cylinder, one of its raised ends, or the rounded rim in between.
+	var nearest float32 = math.MaxFloat32
+	bottom := cylinderPos.Sub(mgl32.Vec3{0.0, cylinderHalfHeight, 0.0})
+	top := cylinderPos.Add(mgl32.Vec3{0.0, cylinderHalfHeight, 0.0})
+	if t, ok := rayEntersCapsuleSide(start, dir, bottom, top, cylinderRadius+sphereRadius); ok {
+		nearest = t
+	}
+	for _, flatEnd := range [...]struct {
+		y, facing float32
+	}{
+		{top[1] + sphereRadius, 1.0},
+		{bottom[1] - sphereRadius, -1.0},
+	} {
+		if dir[1]*flatEnd.facing >= 0.0 || (start[1]-flatEnd.y)*flatEnd.facing < 0.0 {
+			continue
+		}
+		t := (flatEnd.y - start[1]) / dir[1]
+		center := start.Add(dir.Mul(t))
+		dx, dz := center[0]-cylinderPos[0], center[2]-cylinderPos[2]
+		if dx*dx+dz*dz <= cylinderRadius*cylinderRadius && t < nearest {
+			nearest = t
 		}
 	}
 
-	if result.Hit {
-		spherePos := math2.ClosestPointOnLine(start, end, result.Position)
-		projectedPoint := math2.Vec3Max(math2.Vec3Min(spherePos, box.Max), box.Min)
-		diff := spherePos.Sub(projectedPoint)
-		distSq := diff.LenSqr()
-		if distSq > 0.0 && distSq < sphereRadius*sphereRadius {
-			dist := math2.Sqrt(distSq)
-			return RaycastResult{
-				Hit:      true,
-				Position: spherePos.Add(sweepDir.Mul(-sphereRadius)),
-				Normal:   diff.Mul(1.0 / dist),
-				Distance: spherePos.Sub(start).Len(),
-			}
-		} else if distSq == 0.0 {
-			diffToCenter := spherePos.Sub(box.Center())
-			distToCenter := diffToCenter.Len()
-			return RaycastResult{
-				Hit:      true,
-				Position: spherePos.Add(sweepDir.Mul(-sphereRadius)),
-				Normal:   diffToCenter.Mul(1.0 / distToCenter),
-				Distance: spherePos.Sub(start).Len(),
+	if nearest == math.MaxFloat32 {
+		// Check the rim by stepping along the sweep by the distance to the cylinder, which never steps past it.
+		// This starts where the center would touch the cylinder if its rim wasn't rounded, since the real contact can't be any sooner.
+		boxyCast := RayCylinderCollision(start, dir, cylinderPos, cylinderRadius+sphereRadius, cylinderHalfHeight+sphereRadius)
+		if !boxyCast.Hit || boxyCast.Distance < 0.0 || boxyCast.Distance > maxDist {
+			return RaycastResult{}
+		}
+		t := boxyCast.Distance
+		for range SPHERE_SWEEP_MAX_STEPS {
+			center := start.Add(dir.Mul(t))
+			gap := center.Sub(closestPoint(center)).Len() - sphereRadius
+			if gap < 0.0001 {
+				nearest = t
+				break
+			}
+			if t += gap; t > maxDist {
+				break
 			}
 		}
 	}
 
+	if nearest > maxDist {
+		return RaycastResult{}
+	}
+	center := start.Add(dir.Mul(nearest))
+	return sweepResult(nearest, center, closestPoint(center), dir)
+}
+
+func SphereSweepCapsuleCollision(start, end mgl32.Vec3, sphereRadius float32, capsulePos mgl32.Vec3, capsuleRadius, capsuleHalfHeight float32) RaycastResult {
+	dir, maxDist := sweepDirection(start, end)
+	bottom, top := capsuleAxis(capsulePos, capsuleRadius, capsuleHalfHeight)
+	combinedRadius := sphereRadius + capsuleRadius
+	if maxDist == 0.0 || start.Sub(closestPointOnSegment(bottom, top, start)).LenSqr() <= combinedRadius*combinedRadius {
+		return RaycastResult{}
+	}
+	// The sphere touches the capsule when its center reaches a capsule with both of their radii.
+	if t, ok := rayEntersCapsule(start, dir, bottom, top, combinedRadius); ok && t <= maxDist {
+		center := start.Add(dir.Mul(t))
+		axisPoint := closestPointOnSegment(bottom, top, center)
+		return sweepResult(t, center, axisPoint.Add(center.Sub(axisPoint).Normalize().Mul(capsuleRadius)), dir)
+	}
 	return RaycastResult{}
 }
+
+// Only the front sides of triangles are hit, just like when resolving collisions against them.
+func SphereSweepTriangleCollision(start, end mgl32.Vec3, sphereRadius float32, triangle math2.Triangle) RaycastResult {
+	dir, maxDist := sweepDirection(start, end)
+	plane := triangle.Plane()
+	startDist := plane.Normal.Dot(start.Sub(triangle[0]))
+	if maxDist == 0.0 || startDist < 0.0 || start.Sub(closestPointOnTriangle(start, triangle)).LenSqr() <= sphereRadius*sphereRadius {
+		return RaycastResult{}
+	}
+
+	var nearest float32 = math.MaxFloat32
+	if approach := -plane.Normal.Dot(dir); approach > 0.0 && startDist >= sphereRadius {
+		// The center reaches the plane raised off the triangle by the sphere's radius.
+		t := (startDist - sphereRadius) / approach
+		if center := start.Add(dir.Mul(t)); pointIsOverTriangle(center, triangle, plane) {
+			nearest = t
+		}
+	}
+	if nearest == math.MaxFloat32 {
+		// Otherwise, the first contact is on an edge or a corner.
+		for e := range 3 {
+			edgeStart, edgeEnd := triangle[e], triangle[(e+1)%3]
+			if t, ok := rayEntersSphere(start, dir, edgeStart, sphereRadius); ok && t < nearest {
+				nearest = t
+			}
+			if t, ok := rayEntersCapsuleSide(start, dir, edgeStart, edgeEnd, sphereRadius); ok && t < nearest {
+				nearest = t
+			}
+		}
+	}
+
+	if nearest > maxDist {
+		return RaycastResult{}
+	}
+	center := start.Add(dir.Mul(nearest))
+	if plane.Normal.Dot(center.Sub(triangle[0])) < 0.0 {
+		// Reached the triangle from behind.
+		return RaycastResult{}
+	}
+	return sweepResult(nearest, center, closestPointOnTriangle(center, triangle), dir)
+}
+
+// Sweeps against the triangles of a mesh, which are offset by the mesh's position.
+func SphereSweepTrianglesCollision(start, end mgl32.Vec3, sphereRadius float32, triangles []math2.Triangle, trianglesOffset mgl32.Vec3) RaycastResult {
+	var result RaycastResult
+	for _, triangle := range triangles {
+		res := SphereSweepTriangleCollision(start, end, sphereRadius, triangle.OffsetBy(trianglesOffset))
+		if res.Hit && (!result.Hit || res.Distance < result.Distance) {
+			result = res
+		}
+	}
+	return result
+}
+
+// Sweeps a sphere against the shapes of the cels it passes through.
+func (grid *Grid) SphereSweep(myPosition, start, end mgl32.Vec3, sphereRadius float32) RaycastResult {
+	startRelative, endRelative := start.Sub(myPosition), end.Sub(myPosition)
+	bbox := math2.BoxFromRadius(sphereRadius).Translate(startRelative).Union(math2.BoxFromRadius(sphereRadius).Translate(endRelative))
+	i, j, k := grid.WorldToGridPos(bbox.Max)
+	l, m, n := grid.WorldToGridPos(bbox.Min)
+	minX, minY, minZ := max(0, min(i, l)), max(0, min(j, m)), max(0, min(k, n))
+	maxX, maxY, maxZ := min(max(i, l), grid.width-1), min(max(j, m), grid.height-1), min(max(k, n), grid.length-1)
+
+	var result RaycastResult
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			for z := minZ; z <= maxZ; z++ {
+				tileShape := grid.cels[grid.FlattenGridPos(x, y, z)]
+				if tileShape == nil {
+					continue
+				}
+				res := SphereSweep(startRelative, endRelative, sphereRadius, grid.GridToWorldPos(x, y, z, true), tileShape)
+				if res.Hit && (!result.Hit || res.Distance < result.Distance) {
+					result = res
+				}
+			}
+		}
+	}
+	if result.Hit {
+		result.Position = result.Position.Add(myPosition)
+	}
+	return result
+}
+
+// Returns the normalized direction and the length of the sweep.
+func sweepDirection(start, end mgl32.Vec3) (mgl32.Vec3, float32) {
+	diff := end.Sub(start)
+	length := diff.Len()
+	if length == 0.0 {
+		return mgl32.Vec3{}, 0.0
+	}
+	return diff.Mul(1.0 / length), length
+}
+
+func sweepResult(distance float32, center, contact, sweepDir mgl32.Vec3) RaycastResult {
+	normal := center.Sub(contact)
+	if normal.LenSqr() > 0.0 {
+		normal = normal.Normalize()
+	} else {
+		normal = sweepDir.Mul(-1.0)
+	}
+	return RaycastResult{
+		Hit:      true,
+		Position: contact,
+		Normal:   normal,
+		Distance: distance,
+	}
+}
+
+// Returns how far along the ray it enters the sphere. Rays that start inside of the sphere don't enter it.
+func rayEntersSphere(rayOrigin, rayDir, spherePos mgl32.Vec3, sphereRadius float32) (float32, bool) {
+	diff := rayOrigin.Sub(spherePos)
+	b := diff.Dot(rayDir)
+	c := diff.LenSqr() - sphereRadius*sphereRadius
+	if c < 0.0 || b > 0.0 {
+		return 0.0, false
+	}
+	d := b*b - c
+	if d < 0.0 {
+		return 0.0, false
+	}
+	return max(-b-math2.Sqrt(d), 0.0), true
+}
+
+// Returns how far along the ray it enters the round side of a capsule between the two points, leaving out its ends.
+// Rays that start inside of the capsule don't enter it.
+func rayEntersCapsuleSide(rayOrigin, rayDir, capsuleStart, capsuleEnd mgl32.Vec3, capsuleRadius float32) (float32, bool) {
+	axis := capsuleEnd.Sub(capsuleStart)
+	length := axis.Len()
+	if length == 0.0 {
+		return 0.0, false
+	}
+	axis = axis.Mul(1.0 / length)
+
+	// Project the ray onto the plane perpendicular to the capsule, where the side becomes a circle.
+	diff := rayOrigin.Sub(capsuleStart)
+	diffFlat := diff.Sub(axis.Mul(diff.Dot(axis)))
+	dirFlat := rayDir.Sub(axis.Mul(rayDir.Dot(axis)))
+	a := dirFlat.LenSqr()
+	b := diffFlat.Dot(dirFlat)
+	c := diffFlat.LenSqr() - capsuleRadius*capsuleRadius
+	if a < mgl32.Epsilon || c < 0.0 || b > 0.0 {
+		return 0.0, false
+	}
+	d := b*b - a*c
+	if d < 0.0 {
+		return 0.0, false
+	}
+	t := max((-b-math2.Sqrt(d))/a, 0.0)
+	if along := diff.Add(rayDir.Mul(t)).Dot(axis); along < 0.0 || along > length {
+		return 0.0, false
+	}
+	return t, true
+}
+
+// Returns how far along the ray it enters the capsule between the two points. Rays that start inside of the capsule don't enter it.
+func rayEntersCapsule(rayOrigin, rayDir, capsuleStart, capsuleEnd mgl32.Vec3, capsuleRadius float32) (float32, bool) {
+	var nearest float32 = math.MaxFloat32
+	if t, ok := rayEntersCapsuleSide(rayOrigin, rayDir, capsuleStart, capsuleEnd, capsuleRadius); ok {
+		nearest = t
+	}
+	for _, end := range [...]mgl32.Vec3{capsuleStart, capsuleEnd} {
+		if t, ok := rayEntersSphere(rayOrigin, rayDir, end, capsuleRadius); ok {
+			nearest = min(nearest, t)
+		}
+	}
+	return nearest, nearest != math.MaxFloat32
+}
+
+func closestPointOnBox(point mgl32.Vec3, box math2.Box) mgl32.Vec3 {
+	return math2.Vec3Max(math2.Vec3Min(point, box.Max), box.Min)
+}
+
+func closestPointOnCylinder(point, cylinderPos mgl32.Vec3, cylinderRadius, cylinderHalfHeight float32) mgl32.Vec3 {
+	horizontalDiff := mgl32.Vec3{point[0] - cylinderPos[0], 0.0, point[2] - cylinderPos[2]}
+	closest := point
+	if horizontalDist := horizontalDiff.Len(); horizontalDist > cylinderRadius {
+		closest = cylinderPos.Add(horizontalDiff.Mul(cylinderRadius / horizontalDist))
+	}
+	closest[1] = math2.Clamp(point[1], cylinderPos[1]-cylinderHalfHeight, cylinderPos[1]+cylinderHalfHeight)
+	return closest
+}
+
+func closestPointOnTriangle(point mgl32.Vec3, triangle math2.Triangle) mgl32.Vec3 {
+	plane := triangle.Plane()
+	if proj := point.Sub(plane.Normal.Mul(plane.Normal.Dot(point.Sub(triangle[0])))); pointIsOverTriangle(proj, triangle, plane) {
+		return proj
+	}
+	var closest mgl32.Vec3
+	var closestDistSqr float32 = math.MaxFloat32
+	for e := range 3 {
+		edgePoint := math2.ClosestPointOnLine(triangle[e], triangle[(e+1)%3], point)
+		if distSqr := point.Sub(edgePoint).LenSqr(); distSqr < closestDistSqr {
+			closest, closestDistSqr = edgePoint, distSqr
+		}
+	}
+	return closest
+}
+
+// Returns true if the point lies inside of the triangle's edges when projected onto its plane.
+func pointIsOverTriangle(point mgl32.Vec3, triangle math2.Triangle, plane math2.Plane) bool {
+	for e := range 3 {
+		if point.Sub(triangle[e]).Cross(triangle[(e+1)%3].Sub(triangle[e])).Dot(plane.Normal) > 0.0 {
+			return false
+		}
+	}
+	return true
+}
@@ -18,3 +18,200 @@ func TestSphereSweepBoxCollision(t *testing.T) {
 		})
 	})
 }
+
+func TestSphereSweepSphereCollision(t *testing.T) {
+	t.Run("direct hit", func(t *testing.T) {
+		res := SphereSweepSphereCollision(mgl32.Vec3{}, mgl32.Vec3{4.0}, 0.5, mgl32.Vec3{3.0}, 0.5)
+		checkRaycastResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 2.0,
+			Normal:   mgl32.Vec3{-1.0, 0.0, 0.0},
+			Position: mgl32.Vec3{2.5, 0.0, 0.0},
+		})
+	})
+
+	t.Run("stop short", func(t *testing.T) {
+		res := SphereSweepSphereCollision(mgl32.Vec3{}, mgl32.Vec3{1.5}, 0.5, mgl32.Vec3{3.0}, 0.5)
+		checkRaycastResult(t, res, RaycastResult{Hit: false})
+	})
+
+	t.Run("pass by", func(t *testing.T) {
+		res := SphereSweepSphereCollision(mgl32.Vec3{0.0, 1.5, 0.0}, mgl32.Vec3{6.0, 1.5, 0.0}, 0.5, mgl32.Vec3{3.0}, 0.5)
+		checkRaycastResult(t, res, RaycastResult{Hit: false})
+	})
+
+	t.Run("move out from inside", func(t *testing.T) {
+		res := SphereSweepSphereCollision(mgl32.Vec3{2.5}, mgl32.Vec3{4.0}, 0.5, mgl32.Vec3{3.0}, 0.5)
+		checkRaycastResult(t, res, RaycastResult{Hit: false})
+	})
+}
+
+func TestSphereSweepBoxEdges(t *testing.T) {
+	box := math2.BoxFromRadius(1.0)
+
+	t.Run("hit edge", func(t *testing.T) {
+		// Passes over the top edge on the left side, touching it when the center is 0.8 to the left and 0.6 above it.
+		res := SphereSweepBoxCollision(mgl32.Vec3{-5.0, 1.6, 0.0}, mgl32.Vec3{5.0, 1.6, 0.0}, 1.0, box)
+		checkSweepResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 3.2,
+			Normal:   mgl32.Vec3{-0.8, 0.6, 0.0},
+			Position: mgl32.Vec3{-1.0, 1.0, 0.0},
+		})
+	})
+
+	t.Run("hit corner", func(t *testing.T) {
+		res := SphereSweepBoxCollision(mgl32.Vec3{5.0, 5.0, 5.0}, mgl32.Vec3{}, 0.5, box)
+		checkSweepResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: mgl32.Vec3{4.0, 4.0, 4.0}.Len() - 0.5,
+			Normal:   mgl32.Vec3{1.0, 1.0, 1.0}.Normalize(),
+			Position: mgl32.Vec3{1.0, 1.0, 1.0},
+		})
+	})
+
+	t.Run("pass by corner", func(t *testing.T) {
+		res := SphereSweepBoxCollision(mgl32.Vec3{-5.0, 1.4, 1.4}, mgl32.Vec3{5.0, 1.4, 1.4}, 0.5, box)
+		checkRaycastResult(t, res, RaycastResult{Hit: false})
+	})
+
+	t.Run("move out from inside", func(t *testing.T) {
+		res := SphereSweepBoxCollision(mgl32.Vec3{1.2, 0.0, 0.0}, mgl32.Vec3{-5.0, 0.0, 0.0}, 0.5, box)
+		checkRaycastResult(t, res, RaycastResult{Hit: false})
+	})
+}
+
+func TestSphereSweepCylinderCollision(t *testing.T) {
+	t.Run("hit side", func(t *testing.T) {
+		res := SphereSweepCylinderCollision(mgl32.Vec3{-5.0, 0.0, 0.0}, mgl32.Vec3{5.0, 0.0, 0.0}, 0.5, mgl32.Vec3{}, 1.0, 1.0)
+		checkRaycastResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 3.5,
+			Normal:   mgl32.Vec3{-1.0, 0.0, 0.0},
+			Position: mgl32.Vec3{-1.0, 0.0, 0.0},
+		})
+	})
+
+	t.Run("hit top", func(t *testing.T) {
+		res := SphereSweepCylinderCollision(mgl32.Vec3{0.5, 5.0, 0.0}, mgl32.Vec3{0.5, -5.0, 0.0}, 0.5, mgl32.Vec3{}, 1.0, 1.0)
+		checkRaycastResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 3.5,
+			Normal:   mgl32.Vec3{0.0, 1.0, 0.0},
+			Position: mgl32.Vec3{0.5, 1.0, 0.0},
+		})
+	})
+
+	t.Run("hit rim", func(t *testing.T) {
+		// Falls just outside of the cylinder's radius, touching the rim when the center is 0.25 out and about 0.433 above it.
+		rimHeight := math2.Sqrt[float32](0.5*0.5 - 0.25*0.25)
+		res := SphereSweepCylinderCollision(mgl32.Vec3{-1.25, 5.0, 0.0}, mgl32.Vec3{-1.25, -5.0, 0.0}, 0.5, mgl32.Vec3{}, 1.0, 1.0)
+		checkSweepResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 4.0 - rimHeight,
+			Normal:   mgl32.Vec3{-0.25, rimHeight, 0.0}.Mul(2.0),
+			Position: mgl32.Vec3{-1.0, 1.0, 0.0},
+		})
+	})
+
+	t.Run("pass by rim", func(t *testing.T) {
+		res := SphereSweepCylinderCollision(mgl32.Vec3{-1.4, 5.0, -1.4}, mgl32.Vec3{-1.4, -5.0, -1.4}, 0.5, mgl32.Vec3{}, 1.0, 1.0)
+		checkRaycastResult(t, res, RaycastResult{Hit: false})
+	})
+}
+
+func TestSphereSweepTriangleCollision(t *testing.T) {
+	// Lies flat, facing upwards.
+	triangle := math2.Triangle{{-1.0, 0.0, -1.0}, {-1.0, 0.0, 1.0}, {1.0, 0.0, -1.0}}
+
+	t.Run("hit face", func(t *testing.T) {
+		res := SphereSweepTriangleCollision(mgl32.Vec3{-0.5, 3.0, -0.5}, mgl32.Vec3{-0.5, -3.0, -0.5}, 0.5, triangle)
+		checkRaycastResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 2.5,
+			Normal:   mgl32.Vec3{0.0, 1.0, 0.0},
+			Position: mgl32.Vec3{-0.5, 0.0, -0.5},
+		})
+	})
+
+	t.Run("hit edge", func(t *testing.T) {
+		res := SphereSweepTriangleCollision(mgl32.Vec3{-5.0, 0.0, 0.0}, mgl32.Vec3{5.0, 0.0, 0.0}, 0.5, triangle)
+		checkRaycastResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 3.5,
+			Normal:   mgl32.Vec3{-1.0, 0.0, 0.0},
+			Position: mgl32.Vec3{-1.0, 0.0, 0.0},
+		})
+	})
+
+	t.Run("don't hit from behind", func(t *testing.T) {
+		res := SphereSweepTriangleCollision(mgl32.Vec3{-0.5, -3.0, -0.5}, mgl32.Vec3{-0.5, 3.0, -0.5}, 0.5, triangle)
+		checkRaycastResult(t, res, RaycastResult{Hit: false})
+	})
+
+	t.Run("hit nearest triangle of mesh", func(t *testing.T) {
+		higher := triangle.OffsetBy(mgl32.Vec3{0.0, 1.0, 0.0})
+		mesh := NewMeshFromTriangles([]math2.Triangle{triangle, higher})
+		res := SphereSweep(mgl32.Vec3{-0.5, 13.0, -0.5}, mgl32.Vec3{-0.5, 7.0, -0.5}, 0.5, mgl32.Vec3{0.0, 10.0, 0.0}, mesh)
+		checkRaycastResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 1.5,
+			Normal:   mgl32.Vec3{0.0, 1.0, 0.0},
+			Position: mgl32.Vec3{-0.5, 11.0, -0.5},
+		})
+	})
+}
+
+func TestGridSphereSweep(t *testing.T) {
+	grid := NewGrid(4, 1, 1, 2.0)
+	grid.SetShapeAt(2, 0, 0, NewBox(math2.BoxFromRadius(1.0)))
+	gridPos := mgl32.Vec3{10.0, 0.0, 0.0}
+
+	t.Run("hit cel", func(t *testing.T) {
+		res := SphereSweep(mgl32.Vec3{11.0, 1.0, 1.0}, mgl32.Vec3{19.0, 1.0, 1.0}, 0.5, gridPos, grid)
+		checkRaycastResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 2.5,
+			Normal:   mgl32.Vec3{-1.0, 0.0, 0.0},
+			Position: mgl32.Vec3{14.0, 1.0, 1.0},
+		})
+	})
+
+	t.Run("don't pass through thin cel", func(t *testing.T) {
+		// Moves far enough in one step to skip over the panel entirely.
+		grid.SetShapeAt(1, 0, 0, NewBox(math2.BoxFromExtents(0.1, 1.0, 1.0)))
+		defer grid.SetShapeAt(1, 0, 0, nil)
+		start, end := mgl32.Vec3{10.5, 1.0, 1.0}, mgl32.Vec3{18.5, 1.0, 1.0}
+		if NewSphere(0.25).Touches(end, gridPos, grid) {
+			t.Fatal("sphere should be past the panel at the end of the sweep")
+		}
+		res := SphereSweep(start, end, 0.25, gridPos, grid)
+		checkSweepResult(t, res, RaycastResult{
+			Hit:      true,
+			Distance: 2.15,
+			Normal:   mgl32.Vec3{-1.0, 0.0, 0.0},
+			Position: mgl32.Vec3{12.9, 1.0, 1.0},
+		})
+	})
+}
+
+// Like checkRaycastResult, but allows small differences in the distance, since some sweeps can't find it exactly.
+func checkSweepResult(t *testing.T, actual, expected RaycastResult) {
+	t.Helper()
+	if actual.Hit != expected.Hit {
+		if expected.Hit {
+			t.Error("sweep should have hit")
+		} else {
+			t.Error("sweep should not have hit")
+		}
+	}
+	if !mgl32.FloatEqualThreshold(actual.Distance, expected.Distance, 0.001) {
+		t.Errorf("distance should be %v but is %v", expected.Distance, actual.Distance)
+	}
+	if !actual.Normal.ApproxEqualThreshold(expected.Normal, 0.001) {
+		t.Errorf("normal should be %v but is %v", expected.Normal, actual.Normal)
+	}
+	if !actual.Position.ApproxEqualThreshold(expected.Position, 0.001) {
+		t.Errorf("position should be %v but is %v", expected.Position, actual.Position)
+	}
+}
@@ -21,6 +21,7 @@ type Context struct {
 	AspectRatio                                           float32
 	DrawnSpriteCount, DrawnWallCount, DrawnParticlesCount uint32
 	DrawingTranslucent                                    bool
+	VisibleArea                                           func(box math2.Box) bool // Optional check for whether a box might be seen from the camera's position, such as the map's potentially visible set.
 
 	viewProjection   mgl32.Mat4
 	cameraFrustum    math2.Frustum
@@ -58,11 +59,16 @@ func (context *Context) CameraFrustum() math2.Frustum {
 }
 
 func (context *Context) IsBoxVisible(box math2.Box) bool {
-	return context.CameraFrustum().IntersectsBox(box)
+	return context.CameraFrustum().IntersectsBox(box) && context.IsBoxInVisibleArea(box)
 }
 
 func (context *Context) IsSphereVisible(point mgl32.Vec3, radius float32) bool {
-	return context.CameraFrustum().IntersectsSphere(point, radius)
+	return context.CameraFrustum().IntersectsSphere(point, radius) && context.IsBoxInVisibleArea(math2.BoxFromRadius(radius).Translate(point))
+}
+
+// Returns true if the box might be seen from the camera's position according to VisibleArea, regardless of where the camera is looking.
+func (context *Context) IsBoxInVisibleArea(box math2.Box) bool {
+	return context.VisibleArea == nil || context.VisibleArea(box)
 }
 
 func (context *Context) EnqueueTranslucentRender(item TranslucentRender) {
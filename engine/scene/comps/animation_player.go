@@ -83,6 +83,11 @@ func (ap *AnimationPlayer) Frame() textures.Frame {
 	return ap.animation.Frames[ap.currentIndex]
 }
 
+// Returns the index of the current frame in the animation.
+func (ap *AnimationPlayer) FrameIndex() int {
+	return ap.currentIndex
+}
+
 // Returns the current frame's position as UV coordinates in the range of [0, 1)
 func (ap *AnimationPlayer) FrameUV() math2.Rect {
 	if ap.animation.Frames == nil || ap.currentIndex >= len(ap.animation.Frames) {
@@ -3,10 +3,16 @@ package comps
 import (
 	"github.com/go-gl/mathgl/mgl32"
 	"tophatdemon.com/total-invasion-ii/engine/containers"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
 	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
 	"tophatdemon.com/total-invasion-ii/engine/scene"
 )
 
+const (
+	MIN_GROUND_NORMAL_Y = 0.7  // Surfaces that face upwards less steeply than this are too steep to stand on. Wedge tiles are just flat enough.
+	GROUND_PROBE_MARGIN = 0.05 // Extra distance to look for the ground below where a body would stand on it.
+)
+
 type Body struct {
 	Transform Transform
 	Velocity  mgl32.Vec3
@@ -14,6 +20,22 @@ type Body struct {
 	Filter    collision.Mask // Determines which collision layers this body will respond to collisions with
 	Layer     collision.Mask // The collision layer(s) that this body resides on
 	LockY     bool           // When true, the body will not move on the Y axis in response to collisions.
+	// When true, the body is swept along its movement and stops at the first body in its way, so that it can't pass through things between frames.
+	// Only has an effect for shapes that support sweeping. Bodies that it already touches at the start of the movement are resolved as usual.
+	Continuous bool
+	// Decides whether a continuous body stops at a body that doesn't pass its collision filter, which it is only notified about through OnIntersect.
+	// When nil, it stops at all of them. Bodies that pass the filter are always stopped at.
+	ShouldSweep func(otherEnt HasBody) bool
+
+	Gravity      float32 // Downward acceleration in units per second squared. Bodies without gravity never fall or look for the ground.
+	MaxFallSpeed float32 // Limits how fast a body with gravity falls. Zero means no limit.
+	// A body with gravity floats this far above the ground, so that it passes over ledges up to this high and then steps onto them.
+	// While on the ground, it also follows it down drops up to this high instead of falling.
+	StepHeight float32
+	OnGround   bool // Whether a body with gravity was standing on the ground when it last moved.
+
+	// Called when a body with gravity lands on the ground, with how fast it was falling. Can be nil.
+	OnLand func(fallSpeed float32)
 
 	// Called when the body intersects another body, include those that don't pass the collision filter. Can be nil.
 	OnIntersect func(
@@ -29,9 +51,29 @@ func (body *Body) Body() *Body {
 
 func (body *Body) MoveAndCollide(deltaTime float32, bodies containers.Set[scene.Handle]) {
 	before := body.Transform.Position()
+	if body.Gravity != 0.0 {
+		body.fall(deltaTime)
+	}
 
 	movement := body.Velocity.Mul(deltaTime)
+	var sweptHandle scene.Handle
+	var swept bool
+	if body.Continuous {
+		var sweptEnt HasBody
+		var sweep collision.RaycastResult
+		if sweptHandle, sweptEnt, sweep, swept = body.Sweep(movement, bodies); swept {
+			// Stop where it touches, which is treated as the only collision with that body this frame.
+			movement = movement.Normalize().Mul(sweep.Distance)
+			if body.OnIntersect != nil {
+				body.OnIntersect(sweptEnt, collision.Result{Hit: true, Position: sweep.Position, Normal: sweep.Normal}, deltaTime)
+			}
+		}
+	}
+
 	for handle := range bodies {
+		if swept && handle == sweptHandle {
+			continue
+		}
 		if collidingEnt, ok := scene.Get[HasBody](handle); ok {
 			body.ResolveCollision(movement, collidingEnt, deltaTime)
 		}
@@ -44,6 +86,142 @@ func (body *Body) MoveAndCollide(deltaTime float32, bodies containers.Set[scene.
 		after := body.Transform.Position()
 		body.Transform.SetPosition(mgl32.Vec3{after.X(), before.Y(), after.Z()})
 	}
+
+	if body.Gravity != 0.0 {
+		body.standOnGround(before, bodies)
+	}
+}
+
+// Speeds up the body's fall, unless it is standing on the ground.
+func (body *Body) fall(deltaTime float32) {
+	if body.OnGround && body.Velocity.Y() <= 0.0 {
+		body.Velocity[1] = 0.0
+		return
+	}
+	body.Velocity[1] -= body.Gravity * deltaTime
+	if body.MaxFallSpeed > 0.0 {
+		body.Velocity[1] = max(body.Velocity[1], -body.MaxFallSpeed)
+	}
+}
+
+// Looks for the ground under the body after it has moved, and puts the body on top of it if it's close enough.
+func (body *Body) standOnGround(before mgl32.Vec3, bodies containers.Set[scene.Handle]) {
+	wasOnGround := body.OnGround
+	body.OnGround = false
+	if body.Velocity.Y() > 0.0 {
+		// Leaving the ground, like when jumping.
+		return
+	}
+
+	// Cast from the height the body fell from, so that it can't fall through the ground between frames.
+	position := body.Transform.Position()
+	origin := math2.Vec3WithY(position, max(position.Y(), before.Y()))
+	ground, ok := body.groundBelow(origin, origin.Y()-position.Y()+body.groundReach(wasOnGround), bodies)
+	if !ok {
+		return
+	}
+
+	// On slopes, the shape has to be higher up to keep the same distance from the ground.
+	standHeight := body.StepHeight - body.Shape.Extents().Min.Y()/ground.Normal.Y()
+	maxDrop := standHeight + GROUND_PROBE_MARGIN
+	if wasOnGround {
+		maxDrop += body.StepHeight
+	}
+	if position.Y()-ground.Position.Y() > maxDrop {
+		return
+	}
+
+	body.Transform.SetPosition(math2.Vec3WithY(position, ground.Position.Y()+standHeight))
+	body.OnGround = true
+	if !wasOnGround {
+		fallSpeed := -body.Velocity.Y()
+		body.Velocity[1] = 0.0
+		if body.OnLand != nil {
+			body.OnLand(fallSpeed)
+		}
+	}
+}
+
+// Returns how far below the body's position the ground can be while it still stands on it.
+func (body *Body) groundReach(onGround bool) float32 {
+	reach := body.StepHeight - body.Shape.Extents().Min.Y()/MIN_GROUND_NORMAL_Y + GROUND_PROBE_MARGIN
+	if onGround {
+		reach += body.StepHeight
+	}
+	return reach
+}
+
+// Casts a ray straight down against the bodies this body collides with, and returns where it hits if that can be stood on.
+func (body *Body) groundBelow(origin mgl32.Vec3, maxDist float32, bodies containers.Set[scene.Handle]) (collision.RaycastResult, bool) {
+	down := math2.Vec3Down()
+	var nearest collision.RaycastResult
+	for handle := range bodies {
+		otherEnt, ok := scene.Get[HasBody](handle)
+		if !ok {
+			continue
+		}
+		otherBody := otherEnt.Body()
+		if otherBody == nil || body == otherBody || body.Filter&otherBody.Layer == 0 {
+			continue
+		}
+		res := otherBody.Shape.Raycast(origin, down, otherBody.Transform.Position(), maxDist)
+		if res.Hit && res.Distance >= 0.0 && res.Distance <= maxDist && (!nearest.Hit || res.Distance < nearest.Distance) {
+			nearest = res
+		}
+	}
+	return nearest, nearest.Hit && nearest.Normal.Y() >= MIN_GROUND_NORMAL_Y
+}
+
+// Finds the first of the bodies that this body would touch while making the given movement.
+// Only bodies that it would collide with or be notified about are considered, like in ResolveCollision,
+// and those that it would only be notified about must also pass ShouldSweep.
+func (body *Body) Sweep(movement mgl32.Vec3, bodies containers.Set[scene.Handle]) (scene.Handle, HasBody, collision.RaycastResult, bool) {
+	sweepingShape, isSweepingShape := body.Shape.(collision.SweepingShape)
+	if !isSweepingShape || movement.LenSqr() == 0.0 {
+		return scene.Handle{}, nil, collision.RaycastResult{}, false
+	}
+
+	sweptBounds := body.SweptExtents(movement).Translate(body.Transform.Position())
+	var nearestHandle scene.Handle
+	var nearestEnt HasBody
+	var nearest collision.RaycastResult
+	for handle := range bodies {
+		otherEnt, ok := scene.Get[HasBody](handle)
+		if !ok {
+			continue
+		}
+		otherBody := otherEnt.Body()
+		if otherBody == nil || body == otherBody || otherBody.Layer == 0 {
+			continue
+		}
+		if body.Filter&otherBody.Layer == 0 && (body.OnIntersect == nil || (body.ShouldSweep != nil && !body.ShouldSweep(otherEnt))) {
+			continue
+		}
+		if !sweptBounds.Intersects(otherBody.Shape.Extents().Translate(otherBody.Transform.Position())) {
+			continue
+		}
+		res := sweepingShape.Sweep(body.Transform.Position(), movement, otherBody.Transform.Position(), otherBody.Shape)
+		if res.Hit && (nearestEnt == nil || res.Distance < nearest.Distance) {
+			nearestHandle, nearestEnt, nearest = handle, otherEnt, res
+		}
+	}
+	return nearestHandle, nearestEnt, nearest, nearestEnt != nil
+}
+
+// Returns the bounding box of the body's shape over the whole movement, relative to where it starts.
+func (body *Body) SweptExtents(movement mgl32.Vec3) math2.Box {
+	extents := body.Shape.Extents()
+	return extents.Union(extents.Translate(movement))
+}
+
+// Returns the bounding box of everything the body could touch while moving for the given time, relative to its position.
+// This includes the ground that it looks for below itself if it has gravity.
+func (body *Body) ReachExtents(deltaTime float32) math2.Box {
+	reach := body.SweptExtents(body.Velocity.Mul(deltaTime))
+	if body.Gravity != 0.0 {
+		reach.Min[1] = min(reach.Min[1], -body.groundReach(true)-body.Gravity*deltaTime*deltaTime)
+	}
+	return reach
 }
 
 // Change the position of this body so that it doesn't collide with the other body.
@@ -0,0 +1,178 @@
+package comps
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/containers"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
+	"tophatdemon.com/total-invasion-ii/engine/scene"
+)
+
+func TestContinuousBody(t *testing.T) {
+	// Two thin walls in the way of a body that moves far past both of them in one step.
+	storage := scene.NewStorage[Body](4)
+	bodies := containers.NewSet[scene.Handle](0)
+	var walls []*Body
+	for _, x := range []float32{5.0, 8.0} {
+		id, wall, _ := storage.New(Body{
+			Transform: TransformFromTranslation(mgl32.Vec3{x, 0.0, 0.0}),
+			Shape:     collision.NewBox(math2.BoxFromExtents(0.1, 1.0, 1.0)),
+			Layer:     1,
+		})
+		bodies.Add(id.Handle)
+		walls = append(walls, wall)
+	}
+
+	for _, continuous := range []bool{false, true} {
+		var touched []*Body
+		mover := Body{
+			Shape:      collision.NewSphere(0.25),
+			Velocity:   mgl32.Vec3{100.0, 0.0, 0.0},
+			Continuous: continuous,
+			OnIntersect: func(collidingEntity HasBody, result collision.Result, deltaTime float32) {
+				touched = append(touched, collidingEntity.Body())
+			},
+		}
+		mover.MoveAndCollide(0.1, bodies)
+
+		if !continuous {
+			if len(touched) != 0 || mover.Transform.Position().X() != 10.0 {
+				t.Errorf("Discrete body should pass through the walls, but ended at %v and touched %v bodies", mover.Transform.Position(), len(touched))
+			}
+			continue
+		}
+		if len(touched) != 1 || touched[0] != walls[0] {
+			t.Errorf("Continuous body should only touch the first wall, but touched %v bodies", len(touched))
+		}
+		if position := mover.Transform.Position(); !position.ApproxEqualThreshold(mgl32.Vec3{4.65, 0.0, 0.0}, 0.001) {
+			t.Errorf("Continuous body should stop in front of the first wall, but ended at %v", position)
+		}
+	}
+}
+
+func TestContinuousBodyShouldSweep(t *testing.T) {
+	// A projectile that ignores its owner, which is in the way, but not the wall behind it.
+	storage := scene.NewStorage[Body](4)
+	bodies := containers.NewSet[scene.Handle](0)
+	ownerId, owner, _ := storage.New(Body{
+		Transform: TransformFromTranslation(mgl32.Vec3{5.0, 0.0, 0.0}),
+		Shape:     collision.NewSphere(0.5),
+		Layer:     2,
+	})
+	bodies.Add(ownerId.Handle)
+	wallId, wall, _ := storage.New(Body{
+		Transform: TransformFromTranslation(mgl32.Vec3{8.0, 0.0, 0.0}),
+		Shape:     collision.NewBox(math2.BoxFromExtents(0.1, 1.0, 1.0)),
+		Layer:     1,
+	})
+	bodies.Add(wallId.Handle)
+
+	var touched []*Body
+	projectile := Body{
+		Shape:      collision.NewSphere(0.25),
+		Velocity:   mgl32.Vec3{100.0, 0.0, 0.0},
+		Continuous: true,
+		OnIntersect: func(collidingEntity HasBody, result collision.Result, deltaTime float32) {
+			touched = append(touched, collidingEntity.Body())
+		},
+		ShouldSweep: func(otherEnt HasBody) bool {
+			return otherEnt.Body() != owner
+		},
+	}
+	projectile.MoveAndCollide(0.1, bodies)
+
+	if len(touched) != 1 || touched[0] != wall {
+		t.Errorf("Projectile should only touch the wall, but touched %v bodies", len(touched))
+	}
+	if position := projectile.Transform.Position(); !position.ApproxEqualThreshold(mgl32.Vec3{7.65, 0.0, 0.0}, 0.001) {
+		t.Errorf("Projectile should pass its owner and stop in front of the wall, but ended at %v", position)
+	}
+}
+
+func TestBodyGravity(t *testing.T) {
+	// A floor of solid tiles with its top at Y=2, a low and a high ledge on it, and a slope rising along the X axis.
+	grid := collision.NewGrid(8, 3, 3, 2.0)
+	for x := range 8 {
+		for z := range 3 {
+			grid.SetShapeAt(x, 0, z, collision.NewBox(math2.BoxFromRadius(1.0)))
+		}
+	}
+	ledge := func(height float32) collision.Shape {
+		return collision.NewBox(math2.Box{Min: mgl32.Vec3{-1.0, -1.0, -1.0}, Max: mgl32.Vec3{1.0, height - 1.0, 1.0}})
+	}
+	grid.SetShapeAt(2, 1, 0, ledge(0.25))
+	grid.SetShapeAt(2, 1, 2, ledge(0.6))
+	grid.SetShapeAt(6, 1, 1, collision.NewMeshFromTriangles([]math2.Triangle{
+		{{-1.0, -1.0, -1.0}, {-1.0, -1.0, 1.0}, {1.0, 1.0, 1.0}},
+		{{-1.0, -1.0, -1.0}, {1.0, 1.0, 1.0}, {1.0, 1.0, -1.0}},
+	}))
+
+	storage := scene.NewStorage[Body](1)
+	id, _, _ := storage.New(Body{Shape: grid, Layer: 1})
+	bodies := containers.NewSet[scene.Handle](0)
+	bodies.Add(id.Handle)
+
+	const radius, stepHeight float32 = 0.5, 0.3
+	newBody := func(position, velocity mgl32.Vec3) *Body {
+		return &Body{
+			Transform:  TransformFromTranslation(position),
+			Velocity:   velocity,
+			Shape:      collision.NewSphere(radius),
+			Filter:     1,
+			Gravity:    50.0,
+			StepHeight: stepHeight,
+		}
+	}
+	simulate := func(body *Body, seconds float32) {
+		velocity := math2.Vec3WithY(body.Velocity, 0.0)
+		for range int(seconds * 60.0) {
+			body.Velocity = math2.Vec3WithY(velocity, body.Velocity.Y())
+			body.MoveAndCollide(1.0/60.0, bodies)
+		}
+	}
+
+	t.Run("fall and land", func(t *testing.T) {
+		body := newBody(mgl32.Vec3{1.0, 6.0, 1.0}, mgl32.Vec3{})
+		var landings []float32
+		body.OnLand = func(fallSpeed float32) {
+			landings = append(landings, fallSpeed)
+		}
+		simulate(body, 2.0)
+		if !body.OnGround || !mgl32.FloatEqualThreshold(body.Transform.Position().Y(), 2.0+radius+stepHeight, 0.001) {
+			t.Errorf("Body should stand on the floor, but is at %v", body.Transform.Position())
+		}
+		// Falling 3.2 units with this gravity takes it to about 17.9 units per second.
+		if len(landings) != 1 || landings[0] < 16.0 || landings[0] > 19.0 {
+			t.Errorf("Body should land once at about 17.9 units per second, but landed at %v", landings)
+		}
+	})
+
+	t.Run("step onto low ledge", func(t *testing.T) {
+		body := newBody(mgl32.Vec3{1.0, 2.0 + radius + stepHeight, 1.0}, mgl32.Vec3{5.0, 0.0, 0.0})
+		simulate(body, 0.9)
+		if position := body.Transform.Position(); position.X() < 5.0 || !mgl32.FloatEqualThreshold(position.Y(), 2.25+radius+stepHeight, 0.001) {
+			t.Errorf("Body should have stepped onto the ledge, but is at %v", position)
+		}
+	})
+
+	t.Run("blocked by high ledge", func(t *testing.T) {
+		body := newBody(mgl32.Vec3{1.0, 2.0 + radius + stepHeight, 5.0}, mgl32.Vec3{5.0, 0.0, 0.0})
+		simulate(body, 0.9)
+		if position := body.Transform.Position(); position.X() > 4.0 || !mgl32.FloatEqualThreshold(position.Y(), 2.0+radius+stepHeight, 0.001) {
+			t.Errorf("Body should have stopped in front of the ledge, but is at %v", position)
+		}
+	})
+
+	t.Run("stand on slope", func(t *testing.T) {
+		body := newBody(mgl32.Vec3{13.0, 5.0, 3.0}, mgl32.Vec3{})
+		simulate(body, 1.0)
+		position := body.Transform.Position()
+		// The slope is 3 units high under the body's center.
+		standHeight := stepHeight + radius*math2.Sqrt[float32](2.0)
+		if !body.OnGround || !position.ApproxEqualThreshold(mgl32.Vec3{13.0, 3.0 + standHeight, 3.0}, 0.01) {
+			t.Errorf("Body should stand still on the slope, but is at %v", position)
+		}
+	})
+}
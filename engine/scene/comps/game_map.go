@@ -1,10 +1,15 @@
 package comps
 
 import (
+	"slices"
+
+	"github.com/go-gl/mathgl/mgl32"
 	"tophatdemon.com/total-invasion-ii/engine/assets/cache"
 	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
 	"tophatdemon.com/total-invasion-ii/engine/assets/shaders"
 	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/engine/assets/textures"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
 	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
 	"tophatdemon.com/total-invasion-ii/engine/render"
 )
@@ -15,19 +20,24 @@ type Map struct {
 	body           Body
 	tiles          te3.Tiles
 	mesh           *geom.Mesh
-	triMap         te3.TriMap        // Maps a flattened tile index to its indices in the mesh's triangles array.
-	tileAnims      []AnimationPlayer // Animates each texture group of tiles
-	groupRenderers []MeshRender      // Renders each texture group of tiles
+	triMap         te3.TriMap          // Maps a flattened tile index to its indices in the mesh's triangles array.
+	chunks         []te3.MeshChunk     // Parts of the mesh that are skipped when they are out of view.
+	pvs            *te3.PVS            // Which parts of the map can be seen from each other. May be nil.
+	atlas          *textures.TileAtlas // Holds the tile textures that the mesh's vertices refer to. May be nil.
+	tileAnims      []AnimationPlayer   // Animates each texture group of tiles
+	groupRenderers []MeshRender        // Renders each texture group of tiles
+	visibleRanges  [][]geom.Group      // Ranges of each texture group's triangles in the chunks that are visible this frame.
 }
 
 var _ HasBody = (*Map)(nil)
 
-func NewMap(te3File *te3.TE3File, collisionLayer collision.Mask) (Map, error) {
-	mesh, triMap, err := te3File.BuildMesh()
-	if err != nil {
-		return Map{}, err
+// Creates the map from a mesh that was already built from the TE3 file's tiles, along with its chunks, its potentially visible set,
+// and the atlas used to build it. The set and the atlas may be nil. The cache takes ownership of the mesh and the atlas pages.
+func NewMapFromMesh(te3File *te3.TE3File, mesh *geom.Mesh, triMap te3.TriMap, chunks []te3.MeshChunk, pvs *te3.PVS, atlas *textures.TileAtlas, collisionLayer collision.Mask) Map {
+	cache.TakeMesh(te3.MeshName(te3File.FilePath()), mesh)
+	for p, page := range atlas.Pages() {
+		cache.TakeTexture(textures.AtlasPageName(te3File.FilePath(), p), page)
 	}
-	cache.TakeMesh(te3File.FilePath(), mesh)
 
 	var gridShape collision.Grid = collision.NewGrid(te3File.Tiles.Width, te3File.Tiles.Height, te3File.Tiles.Length, te3File.Tiles.GridSpacing())
 
@@ -41,11 +51,24 @@ func NewMap(te3File *te3.TE3File, collisionLayer collision.Mask) (Map, error) {
 		tiles:          te3File.Tiles,
 		mesh:           mesh,
 		triMap:         triMap,
+		chunks:         chunks,
+		pvs:            pvs,
+		atlas:          atlas,
 		tileAnims:      make([]AnimationPlayer, mesh.GroupCount()),
 		groupRenderers: make([]MeshRender, mesh.GroupCount()),
+		visibleRanges:  make([][]geom.Group, mesh.GroupCount()),
 	}
 
 	for g, groupName := range mesh.GroupNames() {
+		if batch, ok := atlas.Batch(groupName); ok {
+			// Tiles packed into the atlas choose their animation frame with a layer offset instead of a source rectangle.
+			if batch.IsAnimated() {
+				gameMap.tileAnims[g] = NewAnimationPlayer(batch.Animation, true)
+			}
+			gameMap.groupRenderers[g] = NewMeshRenderGroup(mesh, shaders.TileShader, atlas.Pages()[batch.Page], groupName)
+			continue
+		}
+
 		tex := cache.GetTexture(groupName)
 		// Add animations if applicable
 		if tex.IsAtlas() {
@@ -57,7 +80,12 @@ func NewMap(te3File *te3.TE3File, collisionLayer collision.Mask) (Map, error) {
 		gameMap.groupRenderers[g] = NewMeshRenderGroup(mesh, shaders.MapShader, tex, groupName)
 	}
 
-	return gameMap, nil
+	return gameMap
+}
+
+// Returns true if any of the map's tiles are drawn with the texture at the given path.
+func (gameMap *Map) UsesTexture(assetPath string) bool {
+	return slices.Contains(gameMap.tiles.Textures, assetPath)
 }
 
 func (gameMap *Map) Name() string {
@@ -77,8 +105,39 @@ func (gm *Map) Update(deltaTime float32) {
 	}
 }
 
+// Returns true if any part of the box might be seen from the given position, according to the map's potentially visible set.
+func (gm *Map) CanSeeBox(from mgl32.Vec3, box math2.Box) bool {
+	return gm.pvs.CanSeeBox(from, box)
+}
+
 func (gm *Map) Render(context *render.Context) {
+	gm.selectVisibleChunks(context.CameraFrustum(), context.ViewInverse.Col(3).Vec3())
 	for i := range gm.groupRenderers {
-		gm.groupRenderers[i].Render(nil, &gm.tileAnims[i], context)
+		gm.groupRenderers[i].RenderRanges(nil, &gm.tileAnims[i], context, gm.visibleRanges[i])
+	}
+}
+
+// Fills visibleRanges with the triangles of the chunks that intersect the frustum and might be seen from the camera's position.
+// Ranges of neighboring chunks that follow each other in the mesh are joined, so that they are drawn together.
+func (gm *Map) selectVisibleChunks(frustum math2.Frustum, cameraPos mgl32.Vec3) {
+	for g := range gm.visibleRanges {
+		gm.visibleRanges[g] = gm.visibleRanges[g][:0]
+	}
+	for c := range gm.chunks {
+		if !frustum.IntersectsBox(gm.chunks[c].Box) || !gm.pvs.CanSeeBox(cameraPos, gm.chunks[c].Box) {
+			continue
+		}
+		for g := range gm.groupRenderers {
+			chunkGroup, ok := gm.chunks[c].Groups[gm.groupRenderers[g].Group]
+			if !ok {
+				continue
+			}
+			ranges := gm.visibleRanges[g]
+			if last := len(ranges) - 1; last >= 0 && ranges[last].Offset+ranges[last].Length == chunkGroup.Offset {
+				ranges[last].Length += chunkGroup.Length
+			} else {
+				gm.visibleRanges[g] = append(ranges, chunkGroup)
+			}
+		}
 	}
 }
@@ -0,0 +1,39 @@
+package comps
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+)
+
+func TestSelectVisibleChunks(t *testing.T) {
+	// The camera is at the origin looking down the negative Z axis.
+	frustum := math2.FrustumFromMatrices(mgl32.Perspective(math.Pi/2.0, 1.0, 0.1, 100.0).Inv())
+	chunkBox := func(z float32) math2.Box {
+		return math2.BoxFromRadius(1.0).Translate(mgl32.Vec3{0.0, 0.0, z})
+	}
+	gm := Map{
+		chunks: []te3.MeshChunk{
+			{Box: chunkBox(-5.0), Groups: map[string]geom.Group{"a": {Offset: 0, Length: 6}, "b": {Offset: 12, Length: 3}}},
+			{Box: chunkBox(-10.0), Groups: map[string]geom.Group{"a": {Offset: 6, Length: 6}}},
+			{Box: chunkBox(10.0), Groups: map[string]geom.Group{"b": {Offset: 15, Length: 3}}},
+			{Box: chunkBox(-20.0), Groups: map[string]geom.Group{"b": {Offset: 18, Length: 3}}},
+		},
+		groupRenderers: []MeshRender{{Group: "a"}, {Group: "b"}},
+		visibleRanges:  make([][]geom.Group, 2),
+	}
+	gm.selectVisibleChunks(frustum, mgl32.Vec3{})
+
+	// The chunk behind the camera is skipped, and the ranges of the first two chunks are joined.
+	if expected := []geom.Group{{Offset: 0, Length: 12}}; !slices.Equal(gm.visibleRanges[0], expected) {
+		t.Errorf("Group a has ranges %v instead of %v", gm.visibleRanges[0], expected)
+	}
+	if expected := []geom.Group{{Offset: 12, Length: 3}, {Offset: 18, Length: 3}}; !slices.Equal(gm.visibleRanges[1], expected) {
+		t.Errorf("Group b has ranges %v instead of %v", gm.visibleRanges[1], expected)
+	}
+}
@@ -35,10 +35,43 @@ func (mr *MeshRender) Render(
 	animPlayer *AnimationPlayer,
 	context *render.Context,
 ) {
-	if mr.Mesh == nil || mr.Shader == nil {
+	if !mr.bind(transform, animPlayer, context) {
+		return
+	}
+
+	if len(mr.Group) == 0 {
+		mr.Mesh.DrawAll()
+	} else {
+		mr.Mesh.DrawGroup(mr.Group)
+	}
+}
+
+// Renders only the given ranges of the mesh's indices, like Render otherwise.
+func (mr *MeshRender) RenderRanges(
+	transform *Transform,
+	animPlayer *AnimationPlayer,
+	context *render.Context,
+	ranges []geom.Group,
+) {
+	if len(ranges) == 0 || !mr.bind(transform, animPlayer, context) {
 		return
 	}
 
+	for _, indRange := range ranges {
+		mr.Mesh.DrawRange(indRange)
+	}
+}
+
+// Binds the mesh, shader, and texture and sets the shader's uniforms. Returns false if there is nothing to render.
+func (mr *MeshRender) bind(
+	transform *Transform,
+	animPlayer *AnimationPlayer,
+	context *render.Context,
+) bool {
+	if mr.Mesh == nil || mr.Shader == nil {
+		return false
+	}
+
 	var modelMatrix mgl32.Mat4
 	if transform != nil {
 		modelMatrix = transform.Matrix()
@@ -59,13 +92,10 @@ func (mr *MeshRender) Render(
 	_ = mr.Shader.SetUniformMatrix(shaders.UniformModelMatrix, modelMatrix)
 	if animPlayer != nil {
 		_ = mr.Shader.SetUniformVec4(shaders.UniformSrcRect, animPlayer.FrameUV().Vec4())
+		_ = mr.Shader.SetUniformInt(shaders.UniformLayerOffset, animPlayer.FrameIndex())
 	} else {
 		_ = mr.Shader.SetUniformVec4(shaders.UniformSrcRect, mgl32.Vec4{0.0, 1.0, 1.0, 1.0})
+		_ = mr.Shader.SetUniformInt(shaders.UniformLayerOffset, 0)
 	}
-
-	if len(mr.Group) == 0 {
-		mr.Mesh.DrawAll()
-	} else {
-		mr.Mesh.DrawGroup(mr.Group)
-	}
+	return true
 }
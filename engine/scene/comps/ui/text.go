@@ -127,6 +127,7 @@ func (txt *Text) generateBoxes() ([]math2.Rect, []bmfont.Char) {
 		}
 
 		numCharsInLine = 0
+		prevRune = scanner.EOF
 	}
 
 	for token := scan.Scan(); token != scanner.EOF; token = scan.Scan() {
@@ -136,6 +137,7 @@ func (txt *Text) generateBoxes() ([]math2.Rect, []bmfont.Char) {
 			continue
 		} else if unicode.IsSpace(token) {
 			cursorX += 16
+			prevRune = scanner.EOF
 			continue
 		}
 
@@ -151,9 +153,17 @@ func (txt *Text) generateBoxes() ([]math2.Rect, []bmfont.Char) {
 			if !ok {
 				// Add blank space for unknown character
 				cursorX += 16
+				prevRune = scanner.EOF
 				continue
 			}
 
+			// Add kerning between this character and the previous one
+			if prevRune != scanner.EOF {
+				if kerning, ok := txt.Settings.Font.Kerning[bmfont.CharPair{First: prevRune, Second: rn}]; ok {
+					cursorX += float32(kerning.Amount)
+				}
+			}
+
 			// Find character position
 			charRect := math2.Rect{
 				X:      originX + cursorX + float32(char.XOffset),
@@ -196,16 +206,6 @@ func (txt *Text) generateBoxes() ([]math2.Rect, []bmfont.Char) {
 			chars = append(chars, char)
 
 			cursorX += float32(char.XAdvance)
-
-			// Add kerning
-			if prevRune != scanner.EOF {
-				pair := bmfont.CharPair{First: prevRune, Second: rn}
-				kerning, ok := txt.Settings.Font.Kerning[pair]
-				if ok {
-					cursorX += float32(kerning.Amount)
-				}
-			}
-
 			prevRune = rn
 			runeIndex += 1
 			numCharsInLine += 1
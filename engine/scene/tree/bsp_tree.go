@@ -35,12 +35,13 @@ func (node bspNode) TouchesChild(shape collision.Shape, shapePosition mgl32.Vec3
 	}
 
 	switch sh := shape.(type) {
+	case collision.Box, collision.Grid, collision.Capsule:
+		// Capsules have a radius too, but it doesn't cover their height
+		touchesRight = shapePosition[node.splitAxis]+sh.Extents().Max[node.splitAxis] >= node.planeOffset
+		touchesLeft = shapePosition[node.splitAxis]+sh.Extents().Min[node.splitAxis] <= node.planeOffset
 	case ShapeWithRadius:
 		touchesRight = shapePosition[node.splitAxis]+sh.Radius() >= node.planeOffset
 		touchesLeft = shapePosition[node.splitAxis]-sh.Radius() <= node.planeOffset
-	case collision.Box, collision.Grid:
-		touchesRight = shapePosition[node.splitAxis]+sh.Extents().Max[node.splitAxis] >= node.planeOffset
-		touchesLeft = shapePosition[node.splitAxis]+sh.Extents().Min[node.splitAxis] <= node.planeOffset
 	case collision.Mesh:
 		for _, tri := range sh.Triangles() {
 			if touchesLeft && touchesRight {
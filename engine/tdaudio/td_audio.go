@@ -1,6 +1,8 @@
 package tdaudio
 
 /*
+// miniaudio loads its backends at runtime on Linux, so programs that don't also link GLFW need these.
+#cgo linux LDFLAGS: -lm -ldl -lpthread
 #include "./td_audio.h"
 #include <stdlib.h>
 */
@@ -8,6 +10,8 @@ import "C"
 import (
 	"log"
 	"unsafe"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets"
 )
 
 type (
@@ -17,9 +21,19 @@ type (
 
 // Sound functions
 
+// Returns the file system path of an audio asset, taking mod layers into account.
+// If the asset can't be found, the path is returned as is so that the audio backend can report the error.
+func resolveAssetPath(assetPath string) string {
+	fsPath, err := assets.LocateFile(assetPath)
+	if err != nil {
+		return assetPath
+	}
+	return fsPath
+}
+
 func LoadSound(path string, polyphony uint8, looping bool, rolloff float32) SoundId {
 	log.Println("Loading sound at ", path)
-	cPath := C.CString(path)
+	cPath := C.CString(resolveAssetPath(path))
 	defer C.free(unsafe.Pointer(cPath))
 	cSound := C.td_audio_load_sound(cPath, C.uint8_t(polyphony), C.bool(looping), C.float(rolloff))
 	return SoundId(cSound)
@@ -111,7 +125,7 @@ func QueueSong(path string, looping bool, fadeoutMillis uint64) bool {
 		return bool(C.td_audio_queue_song(nil, C.bool(looping), C.uint64_t(fadeoutMillis)))
 	}
 	log.Println("Loading song at ", path)
-	cPath := C.CString(path)
+	cPath := C.CString(resolveAssetPath(path))
 	defer C.free(unsafe.Pointer(cPath))
 	return bool(C.td_audio_queue_song(cPath, C.bool(looping), C.uint64_t(fadeoutMillis)))
 }
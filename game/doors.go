@@ -0,0 +1,35 @@
+package game
+
+type DoorDirection uint8
+
+const (
+	DOOR_DIRECTION_DOWN DoorDirection = iota
+	DOOR_DIRECTION_UP
+	DOOR_DIRECTION_RIGHT
+	DOOR_DIRECTION_LEFT
+	DOOR_DIRECTION_FORWARD
+	DOOR_DIRECTION_BACKWARD
+	DOOR_DIRECTION_COUNT
+)
+
+// Names that the 'direction' property of door ents can have for each direction.
+var DoorDirectionNames = [DOOR_DIRECTION_COUNT][]string{
+	DOOR_DIRECTION_DOWN:     {"down", "dn", "d"},
+	DOOR_DIRECTION_UP:       {"up", "u"},
+	DOOR_DIRECTION_RIGHT:    {"right", "rg", "r"},
+	DOOR_DIRECTION_LEFT:     {"left", "lf", "l"},
+	DOOR_DIRECTION_FORWARD:  {"forward", "fw", "f"},
+	DOOR_DIRECTION_BACKWARD: {"backward", "back", "b"},
+}
+
+// Returns the door direction with the given name, or false if there isn't one.
+func DoorDirectionFromName(name string) (DoorDirection, bool) {
+	for i, names := range DoorDirectionNames {
+		for _, v := range names {
+			if v == name {
+				return DoorDirection(i), true
+			}
+		}
+	}
+	return DOOR_DIRECTION_DOWN, false
+}
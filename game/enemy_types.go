@@ -9,3 +9,21 @@ const (
 	ENEMY_TYPE_DUMMKOPF
 	ENEMY_TYPE_COUNT
 )
+
+// Names of the enemy types in the 'enemy' property of map ents.
+var EnemyNames = [ENEMY_TYPE_COUNT]string{
+	ENEMY_TYPE_WRAITH:        "wraith",
+	ENEMY_TYPE_FIRE_WRAITH:   "fire wraith",
+	ENEMY_TYPE_MOTHER_WRAITH: "mother wraith",
+	ENEMY_TYPE_DUMMKOPF:      "dummkopf",
+}
+
+// Returns the enemy type with the given name, or false if there isn't one.
+func EnemyTypeFromName(name string) (EnemyType, bool) {
+	for i, v := range EnemyNames {
+		if v == name {
+			return EnemyType(i), true
+		}
+	}
+	return ENEMY_TYPE_WRAITH, false
+}
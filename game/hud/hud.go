@@ -99,7 +99,7 @@ func (hud *Hud) Init(debug bool) {
 
 		var spriteCounter *ui.Text
 		hud.SpriteCounter, spriteCounter, _ = hud.UI.Texts.New()
-		spriteCounter.Dest = math2.Rect{X: 4.0, Y: 56.0, Width: 480.0, Height: 128.0}
+		spriteCounter.Dest = math2.Rect{X: 4.0, Y: 56.0, Width: 480.0, Height: 288.0}
 		spriteCounter.Color = color.Blue
 	}
 
@@ -503,12 +503,20 @@ func (hud *Hud) Update(deltaTime float32) {
 
 func (hud *Hud) UpdateDebugCounters(renderContext *render.Context, avgCollisionTime int64) {
 	if sprCountTxt, ok := hud.SpriteCounter.Get(); ok {
-		sprCountTxt.SetText(
-			fmt.Sprintf("Sprites drawn: %v\nWalls drawn: %v\nParticles drawn: %v\nAvg. Collision MS: %v",
-				renderContext.DrawnSpriteCount,
-				renderContext.DrawnWallCount,
-				renderContext.DrawnParticlesCount,
-				avgCollisionTime))
+		var counters strings.Builder
+		fmt.Fprintf(&counters, "Sprites drawn: %v\nWalls drawn: %v\nParticles drawn: %v\nAvg. Collision MS: %v",
+			renderContext.DrawnSpriteCount,
+			renderContext.DrawnWallCount,
+			renderContext.DrawnParticlesCount,
+			avgCollisionTime)
+		// Show the contents of the asset cache
+		for _, usage := range cache.Usages() {
+			fmt.Fprintf(&counters, "\nCached %v: %v", usage.ResourceName, usage.Count)
+			if usage.GPUBytes > 0 {
+				fmt.Fprintf(&counters, " (%.2f MiB)", float32(usage.GPUBytes)/(1024.0*1024.0))
+			}
+		}
+		sprCountTxt.SetText(counters.String())
 	}
 }
 
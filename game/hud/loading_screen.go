@@ -0,0 +1,99 @@
+package hud
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"tophatdemon.com/total-invasion-ii/engine/assets/cache"
+	"tophatdemon.com/total-invasion-ii/engine/color"
+	"tophatdemon.com/total-invasion-ii/engine/math2"
+	"tophatdemon.com/total-invasion-ii/engine/render"
+	"tophatdemon.com/total-invasion-ii/engine/scene"
+	"tophatdemon.com/total-invasion-ii/engine/scene/comps/ui"
+	"tophatdemon.com/total-invasion-ii/game/settings"
+)
+
+const (
+	LOADING_BAR_WIDTH  = 320.0
+	LOADING_BAR_HEIGHT = 16.0
+)
+
+// Shown while a map is being loaded.
+type LoadingScreen struct {
+	UI  *ui.Scene
+	bar scene.Id[*ui.Box]
+}
+
+func (ls *LoadingScreen) Init() {
+	ls.UI = ui.NewUIScene(4, 1)
+
+	ls.UI.Boxes.New(ui.Box{
+		Color: color.Black,
+		Src:   math2.Rect{Width: 1.0, Height: 1.0},
+		Transform: ui.Transform{
+			Dest: math2.Rect{Width: settings.UIWidth(), Height: settings.UIHeight()},
+		},
+	})
+
+	_, loadingText, _ := ls.UI.Texts.New()
+	loadingText.Settings = ui.TextSettings{
+		Text:         settings.Localize("loading"),
+		Alignment:    ui.TEXT_ALIGN_CENTER,
+		ShadowColor:  settings.Current.TextShadowColor,
+		ShadowOffset: mgl32.Vec2{2.0, 2.0},
+		Font:         cache.DefaultFont,
+	}
+	loadingText.Transform = ui.Transform{
+		Dest: math2.Rect{
+			Y:      settings.UIHeight()/2.0 - 64.0,
+			Width:  settings.UIWidth(),
+			Height: 64.0,
+		},
+		Scale: 2.0,
+		Depth: 1.0,
+	}
+
+	barDest := math2.Rect{
+		X:      settings.UIWidth()/2.0 - LOADING_BAR_WIDTH/2.0,
+		Y:      settings.UIHeight()/2.0 + 16.0,
+		Width:  LOADING_BAR_WIDTH,
+		Height: LOADING_BAR_HEIGHT,
+	}
+	ls.UI.Boxes.New(ui.Box{
+		Color: color.Color{R: 0.2, G: 0.2, B: 0.2, A: 1.0},
+		Src:   math2.Rect{Width: 1.0, Height: 1.0},
+		Transform: ui.Transform{
+			Dest:  barDest,
+			Depth: 1.0,
+		},
+	})
+
+	barDest.Width = 0.0
+	ls.bar, _, _ = ls.UI.Boxes.New(ui.Box{
+		Color: color.Blue,
+		Src:   math2.Rect{Width: 1.0, Height: 1.0},
+		Transform: ui.Transform{
+			Dest:  barDest,
+			Depth: 2.0,
+		},
+	})
+}
+
+// Fills the progress bar to the given fraction, from 0 to 1.
+func (ls *LoadingScreen) SetProgress(progress float32) {
+	if bar, ok := ls.bar.Get(); ok {
+		bar.Dest.Width = LOADING_BAR_WIDTH * math2.Clamp(progress, 0.0, 1.0)
+	}
+}
+
+func (ls *LoadingScreen) Update(deltaTime float32) {
+	ls.UI.Update(deltaTime)
+}
+
+func (ls *LoadingScreen) Render() {
+	// Setup 2D render context
+	renderContext := render.Context{
+		View:       mgl32.Ident4(),
+		Projection: mgl32.Ortho(0.0, float32(settings.Current.WindowWidth), float32(settings.Current.WindowHeight), 0.0, -10.0, 10.0),
+	}
+
+	ls.UI.Render(&renderContext)
+}
@@ -0,0 +1,61 @@
+package game
+
+type ItemType uint8
+
+const (
+	ITEM_TYPE_MEDKIT ItemType = iota
+	ITEM_TYPE_STIMPACK
+	ITEM_TYPE_EGG_CARTON
+	ITEM_TYPE_GRENADES
+	ITEM_TYPE_PLASMA_VIAL
+	ITEM_TYPE_CHICKEN_CANNON
+	ITEM_TYPE_GRENADE_LAUNCHER
+	ITEM_TYPE_PARUSU
+	ITEM_TYPE_AIRHORN
+	ITEM_TYPE_BLUE_CARD
+	ITEM_TYPE_GRAY_CARD
+	ITEM_TYPE_YELLOW_CARD
+	ITEM_TYPE_BROWN_CARD
+	ITEM_TYPE_BORING_ARMOR
+	ITEM_TYPE_BULLET_ARMOR
+	ITEM_TYPE_COUNT
+)
+
+// Names that the 'item' property of map ents can have for each item type. The first one is the one that tools should write.
+var ItemNames = [ITEM_TYPE_COUNT][]string{
+	ITEM_TYPE_MEDKIT:           {"medkit"},
+	ITEM_TYPE_STIMPACK:         {"stimpack"},
+	ITEM_TYPE_EGG_CARTON:       {"cartonofeggs", "egg_carton"},
+	ITEM_TYPE_GRENADES:         {"grenades"},
+	ITEM_TYPE_PLASMA_VIAL:      {"plasmavial", "plasma_vial", "plasma vial"},
+	ITEM_TYPE_CHICKEN_CANNON:   {"chickencannon", "chickengun", "chicken_cannon", "chicken_gun"},
+	ITEM_TYPE_GRENADE_LAUNCHER: {"grenadelauncher", "grenade_launcher", "grenade launcher"},
+	ITEM_TYPE_PARUSU:           {"parusu"},
+	ITEM_TYPE_AIRHORN:          {"airhorn"},
+	ITEM_TYPE_BLUE_CARD:        {"bluecard"},
+	ITEM_TYPE_GRAY_CARD:        {"graycard"},
+	ITEM_TYPE_YELLOW_CARD:      {"yellowcard"},
+	ITEM_TYPE_BROWN_CARD:       {"browncard"},
+	ITEM_TYPE_BORING_ARMOR:     {"boringarmor", "boring armor", "boring_armor"},
+	ITEM_TYPE_BULLET_ARMOR:     {"bulletarmor", "bullet armor", "bullet_armor"},
+}
+
+// The key given by each keycard item type. KEY_TYPE_INVALID for the other items.
+var ItemKeys = [ITEM_TYPE_COUNT]KeyType{
+	ITEM_TYPE_BLUE_CARD:   KEY_TYPE_BLUE,
+	ITEM_TYPE_GRAY_CARD:   KEY_TYPE_GRAY,
+	ITEM_TYPE_YELLOW_CARD: KEY_TYPE_YELLOW,
+	ITEM_TYPE_BROWN_CARD:  KEY_TYPE_BROWN,
+}
+
+// Returns the item type with the given name, or false if there isn't one.
+func ItemTypeFromName(name string) (ItemType, bool) {
+	for i, names := range ItemNames {
+		for _, v := range names {
+			if v == name {
+				return ItemType(i), true
+			}
+		}
+	}
+	return ITEM_TYPE_MEDKIT, false
+}
@@ -0,0 +1,157 @@
+// Reads maps and does the parts of loading them that need neither the GPU nor the game world,
+// so that the command line tools can compile and export maps without opening a window.
+package mapdata
+
+import (
+	"log"
+	"runtime"
+	"sync"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/engine/assets/textures"
+)
+
+const SKY_MESH_PATH = "assets/models/sky.obj"
+
+const (
+	TEX_FLAG_INVISIBLE string = "invisible"
+	TEX_FLAG_KILLZONE         = "killzone"
+	TEX_FLAG_LIQUID           = "liquid"
+)
+
+// The files used by a map, decoded but not uploaded.
+type Assets struct {
+	TE3File        *te3.TE3File
+	TexturePaths   []string                      // Every texture used by the map, including its sky.
+	MeshPaths      []string                      // Every mesh used by the map, including its tile shapes if they were asked for.
+	Textures       map[string]*textures.Texture  // Filled in by Decode. Includes the textures that were already loaded. Tile textures always have their pixels.
+	Meshes         map[string]*geom.Mesh         // Filled in by Decode. Shape meshes and other models used by the map.
+	ShapeCollision map[string]te3.ShapeCollision // Collision of each tile shape, indexed by the shape's path.
+}
+
+// Lists the textures and meshes used by the map and reads the collision files of its tile shapes.
+// The shape meshes are only needed for generating the map's geometry, so they can be left out.
+func FindAssets(te3File *te3.TE3File, includeShapes bool) *Assets {
+	assets := &Assets{
+		TE3File:        te3File,
+		Textures:       make(map[string]*textures.Texture, len(te3File.Tiles.Textures)),
+		Meshes:         make(map[string]*geom.Mesh, len(te3File.Tiles.Shapes)),
+		ShapeCollision: make(map[string]te3.ShapeCollision, len(te3File.Tiles.Shapes)),
+	}
+
+	texturePaths := make(map[string]struct{}, len(te3File.Tiles.Textures))
+	for _, texPath := range te3File.Tiles.Textures {
+		texturePaths[texPath] = struct{}{}
+	}
+	meshPaths := make(map[string]struct{}, len(te3File.Tiles.Shapes))
+	if includeShapes {
+		for _, shapePath := range te3File.Tiles.Shapes {
+			meshPaths[shapePath] = struct{}{}
+
+			// Shapes with broken collision files are still solid, like the shapes that don't have them.
+			shapeCollision, err := te3.LoadShapeCollision(shapePath)
+			if err != nil {
+				log.Println(err)
+			}
+			assets.ShapeCollision[shapePath] = shapeCollision
+			if shapeCollision.Type == te3.SHAPE_COLLISION_MESH {
+				meshPaths[shapeCollision.Mesh] = struct{}{}
+			}
+		}
+	}
+	for _, ent := range te3File.Ents {
+		if skyPath, hasSky := ent.Properties["sky"]; hasSky && ent.Properties["name"] == "level properties" {
+			texturePaths["assets/textures/skies/"+skyPath+".png"] = struct{}{}
+			meshPaths[SKY_MESH_PATH] = struct{}{}
+		}
+	}
+
+	for texPath := range texturePaths {
+		assets.TexturePaths = append(assets.TexturePaths, texPath)
+	}
+	for meshPath := range meshPaths {
+		assets.MeshPaths = append(assets.MeshPaths, meshPath)
+	}
+	return assets
+}
+
+// Decodes the listed textures and meshes in parallel.
+// The ones that peekTexture and peekMesh find are reused instead of being decoded again. Either function may be nil.
+// Tile textures keep their pixels after uploading, since every map that uses them packs them into its atlas and checks them for transparency,
+// so ones that were loaded for something else without their pixels are decoded again.
+// If finishTask isn't nil, it's called from the worker goroutines after each file.
+func (assets *Assets) Decode(
+	peekTexture func(assetPath string) (*textures.Texture, bool),
+	peekMesh func(assetPath string) (*geom.Mesh, bool),
+	finishTask func(),
+) {
+	isTileTexture := make(map[string]bool, len(assets.TE3File.Tiles.Textures))
+	for _, texPath := range assets.TE3File.Tiles.Textures {
+		isTileTexture[texPath] = true
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	workerSlots := make(chan struct{}, runtime.NumCPU())
+	for _, texPath := range assets.TexturePaths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workerSlots <- struct{}{}
+			defer func() { <-workerSlots }()
+
+			var tex *textures.Texture
+			ok := false
+			if peekTexture != nil {
+				tex, ok = peekTexture(texPath)
+			}
+			var err error
+			if !ok || (isTileTexture[texPath] && !tex.HasPixels()) {
+				tex, err = textures.DecodeTexture(texPath)
+				if err == nil && isTileTexture[texPath] {
+					tex.KeepPixels()
+				}
+			}
+			if err != nil {
+				log.Printf("could not decode texture at %v: %v\n", texPath, err)
+			} else {
+				mutex.Lock()
+				assets.Textures[texPath] = tex
+				mutex.Unlock()
+			}
+			if finishTask != nil {
+				finishTask()
+			}
+		}()
+	}
+	for _, meshPath := range assets.MeshPaths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workerSlots <- struct{}{}
+			defer func() { <-workerSlots }()
+
+			var mesh *geom.Mesh
+			ok := false
+			if peekMesh != nil {
+				mesh, ok = peekMesh(meshPath)
+			}
+			var err error
+			if !ok {
+				mesh, err = geom.LoadMesh(meshPath)
+			}
+			if err != nil {
+				log.Printf("could not load mesh at %v: %v\n", meshPath, err)
+			} else {
+				mutex.Lock()
+				assets.Meshes[meshPath] = mesh
+				mutex.Unlock()
+			}
+			if finishTask != nil {
+				finishTask()
+			}
+		}()
+	}
+	wg.Wait()
+}
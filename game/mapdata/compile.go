@@ -0,0 +1,139 @@
+package mapdata
+
+import (
+	"fmt"
+
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/engine/assets/textures"
+	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
+)
+
+// Loads the map at the given path and does the work that can be saved in a compiled map.
+// Only reads the map file, never an existing compiled version of it.
+func Compile(mapPath string) (*te3.CompiledMap, error) {
+	te3File, err := te3.LoadTE3File(mapPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := te3File.InstancePrefabs(); err != nil {
+		return nil, err
+	}
+	assets := FindAssets(te3File, true)
+	assets.Decode(nil, nil, nil)
+	return CompileAssets(assets)
+}
+
+// Does the work of loading a map that only depends on the map file and the flags of its textures,
+// which is what gets saved in compiled maps. The assets must include the tile shapes. The invisible tiles are erased from the map.
+// Light is baked into the mesh and the potentially visible set is computed, with tiles blocking light and sight
+// when their shapes fill them and their textures can't be seen through.
+func CompileAssets(assets *Assets) (*te3.CompiledMap, error) {
+	te3File := assets.TE3File
+	compiled := &te3.CompiledMap{TE3File: te3File}
+
+	shapeMeshes := make([]*geom.Mesh, len(te3File.Tiles.Shapes))
+	for i, shapePath := range te3File.Tiles.Shapes {
+		var ok bool
+		if shapeMeshes[i], ok = assets.Meshes[shapePath]; !ok {
+			return nil, fmt.Errorf("shape mesh at %s not found", shapePath)
+		}
+	}
+
+	// Pre process tiles before mesh is generated.
+	for texID, texPath := range te3File.Tiles.Textures {
+		tex, ok := assets.Textures[texPath]
+		if !ok {
+			continue
+		}
+		for id, tile := range te3File.Tiles.Data {
+			if tile.TextureIDs[0] != te3.TextureID(texID) {
+				continue
+			}
+			// Remove invisible tiles so that entities can be spawned in their place
+			if tex.HasFlag(TEX_FLAG_INVISIBLE) {
+				te3File.Tiles.EraseTile(id)
+				compiled.InvisibleTiles = append(compiled.InvisibleTiles, id)
+			}
+			if tex.HasFlag(TEX_FLAG_KILLZONE) {
+				compiled.KillzoneTiles = append(compiled.KillzoneTiles, id)
+			}
+		}
+	}
+
+	fillsTile := make([]bool, len(shapeMeshes))
+	for i, shapeMesh := range shapeMeshes {
+		fillsTile[i] = te3.ShapeFillsTile(shapeMesh)
+	}
+	seeThrough := make([]bool, len(te3File.Tiles.Textures))
+	for texID, texPath := range te3File.Tiles.Textures {
+		tex, ok := assets.Textures[texPath]
+		if !ok {
+			continue
+		}
+		seeThrough[texID] = tex.HasFlag(TEX_FLAG_LIQUID) || tex.HasTransparentPixels()
+	}
+	blocksSight := func(tile te3.Tile) bool {
+		return fillsTile[tile.ShapeID] && !seeThrough[tile.TextureIDs[0]] && !seeThrough[tile.TextureIDs[1]]
+	}
+
+	compiled.Mesh, compiled.TriMap = te3File.BuildMeshFromShapes(shapeMeshes)
+	compiled.Mesh = te3File.BakeLight(compiled.Mesh, compiled.TriMap, blocksSight)
+	compiled.PVS = te3File.ComputePVS(blocksSight)
+	compiled.TileShapes = tileCollisionShapes(te3File, assets.Textures, assets.ShapeCollision, assets.Meshes)
+	compiled.Inputs = te3.StampCompiledInputs(compiledMapInputs(assets))
+	return compiled, nil
+}
+
+// Lists the files that the compiled version of the map depends on: the map and its prefabs, the files of its textures
+// (which give their flags and transparency), and its shapes' meshes and collision files.
+func compiledMapInputs(assets *Assets) []string {
+	te3File := assets.TE3File
+	inputs := append([]string{te3File.FilePath()}, te3File.PrefabPaths()...)
+	for _, texPath := range te3File.Tiles.Textures {
+		inputs = append(inputs, textures.TextureDependencies(texPath)...)
+	}
+	for _, shapePath := range te3File.Tiles.Shapes {
+		inputs = append(inputs, geom.MeshDependencies(shapePath)...)
+		inputs = append(inputs, te3.ShapeCollisionPath(shapePath))
+		if shapeCollision := assets.ShapeCollision[shapePath]; shapeCollision.Type == te3.SHAPE_COLLISION_MESH {
+			inputs = append(inputs, geom.MeshDependencies(shapeCollision.Mesh)...)
+		}
+	}
+	return inputs
+}
+
+// Determines the collision shape of each tile in the map from the collision of its shape and its texture.
+// The meshes are indexed by path, and must include the ones used by mesh collision.
+func tileCollisionShapes(te3File *te3.TE3File, tileTextures map[string]*textures.Texture, shapeCollision map[string]te3.ShapeCollision, meshes map[string]*geom.Mesh) []collision.Shape {
+	shapes := make([]collision.Shape, len(te3File.Tiles.Data))
+
+	type transformedShape struct {
+		shapeID    te3.ShapeID
+		yaw, pitch uint8
+	}
+	// Contains the collision shapes at various rotations for reuse.
+	transformedShapesCache := make(map[transformedShape]collision.Shape)
+
+	for id, tile := range te3File.Tiles.Data {
+		if tile.ShapeID < 0 {
+			continue
+		}
+
+		if tex, ok := tileTextures[te3File.Tiles.Textures[tile.TextureIDs[0]]]; ok && tex.HasFlag(TEX_FLAG_LIQUID) {
+			// Remove collision from liquid tiles.
+			continue
+		}
+
+		cacheKey := transformedShape{tile.ShapeID, tile.Yaw, tile.Pitch}
+		shape, ok := transformedShapesCache[cacheKey]
+		if !ok {
+			tileCollision := shapeCollision[te3File.Tiles.Shapes[tile.ShapeID]]
+			shape = tileCollision.TileShape(tile, meshes[tileCollision.Mesh])
+			transformedShapesCache[cacheKey] = shape
+		}
+		shapes[id] = shape
+	}
+
+	return shapes
+}
@@ -69,7 +69,9 @@ type Data struct {
 	TextShadowColor           color.Color
 	SfxVolume, MusicVolume    float32
 	Locale                    string
-	Fov                       float32 // Measured in degrees
+	Fov                       float32  // Measured in degrees
+	Mods                      []string // Mod directories layered over the base assets, from lowest to highest priority
+	MergeMapFaces             bool     // Merges neighboring faces of the map's tiles into larger quads, so that there are fewer triangles to draw
 	Debug                     struct {
 		StartMap string
 	}
@@ -90,6 +92,7 @@ func init() {
 		SfxVolume:        1.0, MusicVolume: 1.0,
 		Locale:          locales.ENGLISH,
 		Fov:             70.0,
+		MergeMapFaces:   true,
 		DifficultyIndex: len(Difficulties) - 1,
 	}
 	Current = Default
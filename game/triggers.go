@@ -0,0 +1,16 @@
+package game
+
+// Values of the 'action' property of trigger ents.
+const (
+	TRIGGER_ACTION_TELEPORT  = "teleport"
+	TRIGGER_ACTION_DAMAGE    = "damage"
+	TRIGGER_ACTION_END_LEVEL = "end level"
+	TRIGGER_ACTION_SECRET    = "secret"
+	TRIGGER_ACTION_ACTIVATE  = "activate"
+)
+
+// Properties of trigger ents.
+const (
+	TRIGGER_ACTION      = "action"
+	TRIGGER_DAMAGE_RATE = "damagePerSecond"
+)
@@ -8,15 +8,19 @@ import (
 
 const KNOCKBACK_FRICTION = 40.0
 
+const (
+	ACTOR_GRAVITY         = 50.0
+	ACTOR_MAX_FALL_SPEED  = 30.0
+	FALL_DAMAGE_MIN_SPEED = 21.0 // Landing any faster than this hurts, which takes a fall of about three tiles.
+	FALL_DAMAGE_PER_SPEED = 5.0  // Damage taken for each unit per second of falling speed over the minimum.
+)
+
 type Actor struct {
 	MaxSpeed, AccelRate, Friction   float32
-	GravityAccel                    float32
-	MaxFallSpeed                    float32
 	YawAngle                        float32 // Radians
 	Health, MaxHealth, TargetHealth float32 // Health cannot be more than MaxHealth but will gradually drop to TargetHealth if overhealed.
 	body                            comps.Body
 	inputForward, inputStrafe       float32
-	onGround                        bool
 	world                           *World
 	knockbackForce                  mgl32.Vec3
 	noisyTimer                      float32 // While this timer is > 0, enemies will be able to 'hear' the actor
@@ -26,12 +30,6 @@ func (actor *Actor) Update(deltaTime float32) {
 	// Diminish noise level
 	actor.noisyTimer = max(0.0, actor.noisyTimer-deltaTime)
 
-	if actor.GravityAccel != 0.0 && actor.body.Filter&COL_LAYER_MAP != 0 {
-		distToBottom := (actor.body.Shape.Extents().Max.Y()) + 0.01
-		downCast, _ := actor.world.Raycast(actor.body.Transform.Position(), mgl32.Vec3{0.0, -1.0, 0.0}, COL_LAYER_MAP, distToBottom, nil)
-		actor.onGround = downCast.Hit
-	}
-
 	input := mgl32.Vec3{actor.inputStrafe, 0.0, -actor.inputForward}
 	if input.LenSqr() != 0.0 {
 		input = input.Normalize()
@@ -40,25 +38,22 @@ func (actor *Actor) Update(deltaTime float32) {
 
 	// Apply acceleration
 	actor.body.Velocity = actor.body.Velocity.Add(moveDir.Mul(actor.AccelRate * deltaTime))
-	// Apply gravity
-	actor.body.Velocity = actor.body.Velocity.Add(mgl32.Vec3{0.0, -actor.GravityAccel * deltaTime, 0.0})
-	// Limit falling speed
-	if actor.onGround {
-		actor.body.Velocity = math2.Vec3WithY(actor.body.Velocity, 0.0)
-	} else if actor.body.Velocity.Y() < -actor.MaxFallSpeed {
-		actor.body.Velocity = math2.Vec3WithY(actor.body.Velocity, -actor.MaxFallSpeed)
-	}
+
+	// Friction and the speed limit only apply to walking, leaving falling to the body's gravity.
+	fallVelocity := actor.body.Velocity.Y()
+	walkVelocity := math2.Vec3WithY(actor.body.Velocity, 0.0)
 
 	// Apply friction
-	if speed := actor.body.Velocity.Len(); speed > mgl32.Epsilon {
-		frictionVec := actor.body.Velocity.Mul(-min(speed, actor.Friction*deltaTime) / speed)
-		actor.body.Velocity = actor.body.Velocity.Add(frictionVec)
+	if speed := walkVelocity.Len(); speed > mgl32.Epsilon {
+		frictionVec := walkVelocity.Mul(-min(speed, actor.Friction*deltaTime) / speed)
+		walkVelocity = walkVelocity.Add(frictionVec)
 	}
 
 	// Limit moving speed
-	if speed := actor.body.Velocity.Len(); speed > actor.MaxSpeed && actor.MaxSpeed > mgl32.Epsilon {
-		actor.body.Velocity = actor.body.Velocity.Mul(actor.MaxSpeed / speed)
+	if speed := walkVelocity.Len(); speed > actor.MaxSpeed && actor.MaxSpeed > mgl32.Epsilon {
+		walkVelocity = walkVelocity.Mul(actor.MaxSpeed / speed)
 	}
+	actor.body.Velocity = math2.Vec3WithY(walkVelocity, fallVelocity)
 
 	// Apply knockback
 	if !actor.knockbackForce.ApproxEqual(mgl32.Vec3{}) {
@@ -94,6 +89,11 @@ func (actor *Actor) Position() mgl32.Vec3 {
 	return actor.body.Transform.Position()
 }
 
+// Returns how much damage an actor takes from landing on the ground at the given falling speed.
+func fallDamage(fallSpeed float32) float32 {
+	return max(0.0, fallSpeed-FALL_DAMAGE_MIN_SPEED) * FALL_DAMAGE_PER_SPEED
+}
+
 func (actor *Actor) ApplyKnockback(force mgl32.Vec3) {
 	actor.knockbackForce = force
 }
@@ -65,18 +65,18 @@ func SpawnChicken(world *World, position, angles mgl32.Vec3) (id scene.Id[*Chick
 			Transform: comps.TransformFromTranslationAnglesScale(
 				mgl32.Vec3(position), mgl32.Vec3{}, mgl32.Vec3{0.5, 0.5, 0.5},
 			),
-			Shape:  collision.NewSphere(0.5),
-			Layer:  COL_LAYER_ACTORS | COL_LAYER_NPCS,
-			Filter: COL_LAYER_MAP | COL_LAYER_ACTORS,
-			LockY:  false,
+			Shape:        collision.NewSphere(0.5),
+			Layer:        COL_LAYER_ACTORS | COL_LAYER_NPCS,
+			Filter:       COL_LAYER_MAP | COL_LAYER_ACTORS,
+			LockY:        false,
+			Gravity:      80.0,
+			MaxFallSpeed: 15.0,
 		},
-		YawAngle:     mgl32.DegToRad(angles[1]),
-		AccelRate:    80.0,
-		Friction:     20.0,
-		MaxSpeed:     2.5,
-		GravityAccel: 80.0,
-		MaxFallSpeed: 15.0,
-		world:        world,
+		YawAngle:  mgl32.DegToRad(angles[1]),
+		AccelRate: 80.0,
+		Friction:  20.0,
+		MaxSpeed:  2.5,
+		world:     world,
 	}
 	chk.SpriteRender = comps.NewSpriteRender(tex)
 	chk.AnimPlayer = comps.NewAnimationPlayer(chk.walkAnim, false)
@@ -109,7 +109,7 @@ func (chk *Chicken) Update(deltaTime float32) {
 	if chk.actor.Health > 0 {
 		chk.actor.inputForward = 1.0
 		chk.actor.inputStrafe = 0.0
-		if chk.actor.onGround {
+		if chk.actor.body.OnGround {
 			chk.AnimPlayer.SwapAnimation(chk.walkAnim)
 		} else {
 			chk.AnimPlayer.SwapAnimation(chk.flyAnim)
@@ -143,6 +143,7 @@ func (chk *Chicken) Update(deltaTime float32) {
 		if chk.actor.body.Velocity.ApproxEqual(mgl32.Vec3{}) {
 			chk.actor.body.Layer = COL_LAYER_NONE
 			chk.actor.body.Filter = COL_LAYER_NONE
+			chk.actor.body.Gravity = 0.0 // Without a filter, it would fall through the floor.
 		}
 	}
 }
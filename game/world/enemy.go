@@ -24,6 +24,7 @@ const (
 	ENEMY_WAKE_PROXIMITY   = 1.7
 	ENEMY_COL_LAYERS       = COL_LAYER_ACTORS | COL_LAYER_NPCS
 	ENEMY_NOTICE_PROXIMITY = 25.0
+	ENEMY_STEP_HEIGHT      = 0.2 // Keeps the center of an enemy's sphere 0.9 units above the ground, where it spawns.
 )
 
 type Enemy struct {
@@ -82,17 +83,8 @@ var _ HasActor = (*Enemy)(nil)
 var _ comps.HasBody = (*Enemy)(nil)
 
 func SpawnEnemyFromTE3(world *World, ent te3.Ent) (scene.Id[*Enemy], *Enemy, error) {
-	var variant game.EnemyType
-	switch ent.Properties["enemy"] {
-	case "fire wraith":
-		variant = game.ENEMY_TYPE_FIRE_WRAITH
-	case "mother wraith":
-		variant = game.ENEMY_TYPE_MOTHER_WRAITH
-	case "dummkopf":
-		variant = game.ENEMY_TYPE_DUMMKOPF
-	default:
-		variant = game.ENEMY_TYPE_WRAITH
-	}
+	// Unknown names become wraiths.
+	variant, _ := game.EnemyTypeFromName(ent.Properties["enemy"])
 	return SpawnEnemy(world, ent.Position, ent.AnglesInRadians(), variant)
 }
 
@@ -121,10 +113,13 @@ func SpawnEnemy(world *World, position, angles mgl32.Vec3, variant game.EnemyTyp
 			Transform: comps.TransformFromTranslationAnglesScale(
 				mgl32.Vec3(position).Add(mgl32.Vec3{0.0, -0.1, 0.0}), mgl32.Vec3{}, mgl32.Vec3{0.9, 0.9, 0.9},
 			),
-			Shape:  collision.NewSphere(0.7),
-			Layer:  ENEMY_COL_LAYERS,
-			Filter: COL_FILTER_FOR_ACTORS,
-			LockY:  true,
+			Shape:        collision.NewSphere(0.7),
+			Layer:        ENEMY_COL_LAYERS,
+			Filter:       COL_FILTER_FOR_ACTORS,
+			Gravity:      ACTOR_GRAVITY,
+			MaxFallSpeed: ACTOR_MAX_FALL_SPEED,
+			StepHeight:   ENEMY_STEP_HEIGHT,
+			OnLand:       enemy.onLand,
 		},
 		YawAngle:  angles[1],
 		AccelRate: 80.0,
@@ -336,6 +331,12 @@ func (enemy *Enemy) changeState(newState *enemyState) {
 	enemy.state = newState
 }
 
+func (enemy *Enemy) onLand(fallSpeed float32) {
+	if damage := fallDamage(fallSpeed); damage > 0.0 {
+		enemy.OnDamage(nil, damage)
+	}
+}
+
 func (enemy *Enemy) OnDamage(sourceEntity any, damage float32) bool {
 	if enemy.state == &enemy.dieState {
 		return false
@@ -53,60 +53,44 @@ type Item struct {
 
 var _ comps.HasBody = (*Item)(nil)
 
+// How each type of item is spawned from map ents.
+type itemSpawner struct {
+	spawn  func(world *World, position mgl32.Vec3) (scene.Id[*Item], *Item, error)
+	placed bool // If true, the item stays where its ent is instead of being put on the floor.
+}
+
+var itemSpawners = [game.ITEM_TYPE_COUNT]itemSpawner{
+	game.ITEM_TYPE_MEDKIT:           {spawn: SpawnMedkit},
+	game.ITEM_TYPE_STIMPACK:         {spawn: SpawnStimpack},
+	game.ITEM_TYPE_EGG_CARTON:       {spawn: SpawnEggCarton},
+	game.ITEM_TYPE_GRENADES:         {spawn: SpawnGrenades},
+	game.ITEM_TYPE_PLASMA_VIAL:      {spawn: SpawnPlasmaVials},
+	game.ITEM_TYPE_CHICKEN_CANNON:   {spawn: SpawnChickenCannon},
+	game.ITEM_TYPE_GRENADE_LAUNCHER: {spawn: SpawnGrenadeLauncher},
+	game.ITEM_TYPE_PARUSU:           {spawn: SpawnParusu},
+	game.ITEM_TYPE_AIRHORN:          {spawn: SpawnAirhorn},
+	game.ITEM_TYPE_BLUE_CARD:        keycardSpawner(game.KEY_TYPE_BLUE),
+	game.ITEM_TYPE_GRAY_CARD:        keycardSpawner(game.KEY_TYPE_GRAY),
+	game.ITEM_TYPE_YELLOW_CARD:      keycardSpawner(game.KEY_TYPE_YELLOW),
+	game.ITEM_TYPE_BROWN_CARD:       keycardSpawner(game.KEY_TYPE_BROWN),
+	game.ITEM_TYPE_BORING_ARMOR:     {spawn: spawnBoringArmor, placed: true},
+	game.ITEM_TYPE_BULLET_ARMOR:     {spawn: spawnBulletArmor, placed: true},
+}
+
 func SpawnItemFromTE3(world *World, ent te3.Ent) (id scene.Id[*Item], item *Item, err error) {
-	itemType, isItem := ent.Properties["item"]
+	itemName, isItem := ent.Properties["item"]
 	if !isItem {
 		return scene.Id[*Item]{}, nil, fmt.Errorf("item is missing 'item' property")
 	}
 
-	switch itemType {
-	case "medkit":
-		id, item, err = SpawnMedkit(world, ent.Position)
-	case "stimpack":
-		id, item, err = SpawnStimpack(world, ent.Position)
-	case "cartonofeggs", "egg_carton":
-		id, item, err = SpawnEggCarton(world, ent.Position)
-	case "grenades":
-		id, item, err = SpawnGrenades(world, ent.Position)
-	case "plasmavial", "plasma_vial", "plasma vial":
-		id, item, err = SpawnPlasmaVials(world, ent.Position)
-	case "chickencannon", "chickengun", "chicken_cannon", "chicken_gun":
-		id, item, err = SpawnChickenCannon(world, ent.Position)
-	case "grenadelauncher", "grenade_launcher", "grenade launcher":
-		id, item, err = SpawnGrenadeLauncher(world, ent.Position)
-	case "parusu":
-		id, item, err = SpawnParusu(world, ent.Position)
-	case "airhorn":
-		id, item, err = SpawnAirhorn(world, ent.Position)
-	case "bluecard":
-		id, item, err = SpawnKeycard(world, ent.Position, game.KEY_TYPE_BLUE)
-		return
-	case "graycard":
-		id, item, err = SpawnKeycard(world, ent.Position, game.KEY_TYPE_GRAY)
-		return
-	case "yellowcard":
-		id, item, err = SpawnKeycard(world, ent.Position, game.KEY_TYPE_YELLOW)
-		return
-	case "browncard":
-		id, item, err = SpawnKeycard(world, ent.Position, game.KEY_TYPE_BROWN)
-		return
-	case "boringarmor", "boring armor", "boring_armor":
-		id, item, err = SpawnArmorStand(world, ent.Position, game.ARMOR_TYPE_BORING)
-		item.armorAmount = 100
-		item.flashColor = color.FromBytes(170, 85, 0, 180)
-		return
-	case "bulletarmor", "bullet armor", "bullet_armor":
-		id, item, err = SpawnArmorStand(world, ent.Position, game.ARMOR_TYPE_BULLET)
-		item.armorAmount = 120
-		item.giveAmmo = [game.AMMO_TYPE_COUNT]int{
-			game.AMMO_TYPE_EGG:     12,
-			game.AMMO_TYPE_GRENADE: 5,
-			game.AMMO_TYPE_PLASMA:  30,
-		}
-		item.flashColor = color.FromBytes(0, 113, 0, 180)
+	itemType, known := game.ItemTypeFromName(itemName)
+	if !known {
+		return scene.Id[*Item]{}, nil, fmt.Errorf("item type '%v' is not implemented yet", itemName)
+	}
+	spawner := itemSpawners[itemType]
+	id, item, err = spawner.spawn(world, ent.Position)
+	if spawner.placed {
 		return
-	default:
-		return scene.Id[*Item]{}, nil, fmt.Errorf("item type '%v' is not implemented yet", itemType)
 	}
 
 	if err != nil {
@@ -387,3 +371,31 @@ func (item *Item) OnUse(player *Player) {
 		item.animPlayer.PlayNewAnim(item.collectAnim)
 	}
 }
+
+func keycardSpawner(keyType game.KeyType) itemSpawner {
+	return itemSpawner{
+		spawn: func(world *World, position mgl32.Vec3) (scene.Id[*Item], *Item, error) {
+			return SpawnKeycard(world, position, keyType)
+		},
+		placed: true,
+	}
+}
+
+func spawnBoringArmor(world *World, position mgl32.Vec3) (id scene.Id[*Item], item *Item, err error) {
+	id, item, err = SpawnArmorStand(world, position, game.ARMOR_TYPE_BORING)
+	item.armorAmount = 100
+	item.flashColor = color.FromBytes(170, 85, 0, 180)
+	return
+}
+
+func spawnBulletArmor(world *World, position mgl32.Vec3) (id scene.Id[*Item], item *Item, err error) {
+	id, item, err = SpawnArmorStand(world, position, game.ARMOR_TYPE_BULLET)
+	item.armorAmount = 120
+	item.giveAmmo = [game.AMMO_TYPE_COUNT]int{
+		game.AMMO_TYPE_EGG:     12,
+		game.AMMO_TYPE_GRENADE: 5,
+		game.AMMO_TYPE_PLASMA:  30,
+	}
+	item.flashColor = color.FromBytes(0, 113, 0, 180)
+	return
+}
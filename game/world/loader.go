@@ -0,0 +1,230 @@
+package world
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"tophatdemon.com/total-invasion-ii/engine"
+	"tophatdemon.com/total-invasion-ii/engine/assets/cache"
+	"tophatdemon.com/total-invasion-ii/engine/assets/geom"
+	"tophatdemon.com/total-invasion-ii/engine/assets/te3"
+	"tophatdemon.com/total-invasion-ii/engine/assets/textures"
+	"tophatdemon.com/total-invasion-ii/engine/math2/collision"
+	"tophatdemon.com/total-invasion-ii/game/mapdata"
+	"tophatdemon.com/total-invasion-ii/game/settings"
+)
+
+// Maximum time spent on GPU uploads per frame while a map is loading.
+const LOAD_UPLOAD_BUDGET = 8 * time.Millisecond
+
+// Holds the results of the CPU side of loading a map, which can be done outside of the main thread.
+type mapData struct {
+	*mapdata.Assets                     // Textures aren't uploaded until uploadTasks are run.
+	mesh            *geom.Mesh          // Geometry of the map's tiles.
+	atlas           *textures.TileAtlas // Images of the map's tile textures, packed so that the mesh can be drawn in fewer batches.
+	triMap          te3.TriMap
+	pvs             *te3.PVS          // Which parts of the map can be seen from each other.
+	chunks          []te3.MeshChunk   // Parts of the mesh that can be skipped when out of view.
+	tileShapes      []collision.Shape // Collision shape of each tile, indexed by flattened grid position.
+	invisibleTiles  []int             // Flattened grid positions of tiles that were removed from the mesh because of their invisible texture.
+	killzoneTiles   []int             // Flattened grid positions of tiles with killzone textures.
+}
+
+// Counts finished loading tasks. Safe to use from multiple goroutines.
+type loadProgress struct {
+	done, total atomic.Int32
+}
+
+func (progress *loadProgress) addTasks(count int) {
+	if progress != nil {
+		progress.total.Add(int32(count))
+	}
+}
+
+func (progress *loadProgress) finishTask() {
+	if progress != nil {
+		progress.done.Add(1)
+	}
+}
+
+// Returns the fraction of tasks that have finished, from 0 to 1.
+func (progress *loadProgress) fraction() float32 {
+	total := progress.total.Load()
+	if total <= 0 {
+		return 0.0
+	}
+	return min(float32(progress.done.Load())/float32(total), 1.0)
+}
+
+// Reads the map file, decodes the textures and meshes it uses, and generates its geometry and collision shapes.
+// Prefabs are stamped into the map first, so that they become part of its geometry.
+// If the map has a compiled version that is up to date with the files it was made from, the geometry and collision shapes are read from it instead.
+// Nothing here touches OpenGL or modifies the asset cache, so it can run on a worker goroutine.
+func loadMapData(mapPath string, progress *loadProgress) (*mapData, error) {
+	progress.addTasks(1)
+	var compiled *te3.CompiledMap
+	if te3.HasCompiledMap(mapPath) {
+		var err error
+		if compiled, err = te3.LoadCompiledMap(mapPath); err != nil {
+			log.Printf("%v; loading the map file instead\n", err)
+		}
+	}
+	var te3File *te3.TE3File
+	if compiled != nil {
+		te3File = compiled.TE3File
+	} else {
+		var err error
+		if te3File, err = te3.LoadTE3File(mapPath); err != nil {
+			return nil, err
+		}
+		if err = te3File.InstancePrefabs(); err != nil {
+			return nil, err
+		}
+	}
+
+	data := decodeMapAssets(te3File, compiled == nil, progress)
+	if compiled == nil {
+		var err error
+		if compiled, err = mapdata.CompileAssets(data.Assets); err != nil {
+			return nil, err
+		}
+	}
+	data.invisibleTiles = compiled.InvisibleTiles
+	data.killzoneTiles = compiled.KillzoneTiles
+	data.pvs = compiled.PVS
+	data.tileShapes = compiled.TileShapes
+	progress.finishTask()
+
+	tileTextures := make(map[string]*textures.Texture, len(te3File.Tiles.Textures))
+	for _, texPath := range te3File.Tiles.Textures {
+		if tex, ok := data.Textures[texPath]; ok {
+			tileTextures[texPath] = tex
+		}
+	}
+	data.atlas = textures.PackTileAtlas(tileTextures)
+	progress.finishTask()
+
+	data.mesh, data.triMap = te3File.BatchMesh(compiled.Mesh, compiled.TriMap, data.atlas)
+	if settings.Current.MergeMapFaces {
+		triangleCount := len(data.mesh.Inds()) / 3
+		data.mesh, data.triMap = te3File.MergeFaces(data.mesh, data.triMap, data.atlas.Repeats)
+		log.Printf("Merged the faces of %v from %v triangles into %v\n", mapPath, triangleCount, len(data.mesh.Inds())/3)
+	}
+	data.mesh, data.triMap, data.chunks = te3File.ChunkMesh(data.mesh, data.triMap)
+	progress.finishTask()
+
+	return data, nil
+}
+
+// Decodes the textures and meshes used by the map in parallel, reusing the ones that are still loaded from the previous map.
+// The shape meshes are only needed for generating the map's geometry, so they can be left out.
+func decodeMapAssets(te3File *te3.TE3File, includeShapes bool, progress *loadProgress) *mapData {
+	assets := mapdata.FindAssets(te3File, includeShapes)
+
+	// The work on the main thread is counted up front as well, so that the progress doesn't jump backwards.
+	workerTasks := len(assets.TexturePaths) + len(assets.MeshPaths) + 3 // Decoding, mesh generation and collision, atlas packing, and batching
+	mainTasks := len(assets.TexturePaths) + 4                           // Uploads and creating the world
+	progress.addTasks(workerTasks + mainTasks)
+	progress.finishTask()
+
+	assets.Decode(cache.PeekTexture, cache.PeekMesh, progress.finishTask)
+	return &mapData{Assets: assets}
+}
+
+// Returns the steps that send the map data to the GPU and hand it over to the asset cache.
+// These must be run on the main thread, in order.
+func (data *mapData) uploadTasks() []func() {
+	tasks := make([]func(), 0, len(data.Textures)+3)
+	for texPath, tex := range data.Textures {
+		tasks = append(tasks, func() {
+			tex.Upload()
+			if old, ok := cache.PeekTexture(texPath); ok && old != tex {
+				// The texture was decoded again to get its pixels, so the loaded one takes over the new data to keep its existing references working.
+				old.Replace(tex)
+				tex = old
+			}
+			cache.TakeTexture(texPath, tex)
+		})
+	}
+	tasks = append(tasks, func() {
+		for meshPath, mesh := range data.Meshes {
+			cache.TakeMesh(meshPath, mesh)
+		}
+	})
+	tasks = append(tasks, data.atlas.Upload)
+	tasks = append(tasks, data.mesh.Upload)
+	return tasks
+}
+
+// Loads a map in the background.
+// The CPU work is done on worker goroutines, while uploads to the GPU are spread out over several frames on the main thread.
+type Loader struct {
+	app      engine.Observer
+	mapPath  string
+	debug    bool
+	progress loadProgress
+	results  chan loadResult
+	data     *mapData
+	uploads  []func()
+}
+
+type loadResult struct {
+	data *mapData
+	err  error
+}
+
+// Starts loading the map at the given path. Loader.Update must be called every frame to finish loading.
+func StartLoading(app engine.Observer, mapPath string, debug bool) *Loader {
+	loader := &Loader{
+		app:     app,
+		mapPath: mapPath,
+		debug:   debug,
+		results: make(chan loadResult, 1),
+	}
+	go func() {
+		data, err := loadMapData(mapPath, &loader.progress)
+		loader.results <- loadResult{data, err}
+	}()
+	return loader
+}
+
+// Performs the queued GPU uploads, up to a time limit. Must be called on the main thread.
+// Returns the world once it has finished loading, or nil if it isn't ready yet.
+func (loader *Loader) Update() (*World, error) {
+	if loader.data == nil {
+		select {
+		case result := <-loader.results:
+			if result.err != nil {
+				return nil, result.err
+			}
+			loader.data = result.data
+			loader.uploads = result.data.uploadTasks()
+		default:
+			return nil, nil
+		}
+	}
+
+	startTime := time.Now()
+	for len(loader.uploads) > 0 && time.Since(startTime) < LOAD_UPLOAD_BUDGET {
+		loader.uploads[0]()
+		loader.uploads = loader.uploads[1:]
+		loader.progress.finishTask()
+	}
+	if len(loader.uploads) > 0 {
+		return nil, nil
+	}
+
+	world, err := newWorldFromMapData(loader.app, loader.data, loader.debug)
+	loader.progress.finishTask()
+	return world, err
+}
+
+// Returns how much of the map has been loaded, from 0 to 1.
+func (loader *Loader) Progress() float32 {
+	return loader.progress.fraction()
+}
+
+func (loader *Loader) MapPath() string {
+	return loader.mapPath
+}
@@ -19,7 +19,8 @@ import (
 )
 
 const (
-	USE_DIST float32 = 3.0
+	USE_DIST           float32 = 3.0
+	PLAYER_STEP_HEIGHT float32 = 0.3 // Keeps the center of the player's sphere, and the camera, a unit above the ground.
 )
 
 const (
@@ -69,11 +70,14 @@ func SpawnPlayer(world *World, position, angles mgl32.Vec3, camera scene.Id[*Cam
 			Transform: comps.TransformFromTranslationAngles(
 				position, angles,
 			),
-			Shape:       collision.NewSphere(0.7),
-			Layer:       player.initialCollisionLayers,
-			Filter:      COL_FILTER_FOR_ACTORS,
-			LockY:       true,
-			OnIntersect: player.onIntersect,
+			Shape:        collision.NewSphere(0.7),
+			Layer:        player.initialCollisionLayers,
+			Filter:       COL_FILTER_FOR_ACTORS,
+			Gravity:      ACTOR_GRAVITY,
+			MaxFallSpeed: ACTOR_MAX_FALL_SPEED,
+			StepHeight:   PLAYER_STEP_HEIGHT,
+			OnIntersect:  player.onIntersect,
+			OnLand:       player.onLand,
 		},
 		YawAngle:     mgl32.DegToRad(angles[1]),
 		AccelRate:    100.0,
@@ -223,9 +227,13 @@ func (player *Player) takeUserInput(deltaTime float32) {
 		if player.Body().Layer != COL_LAYER_NONE {
 			player.Body().Layer = COL_LAYER_NONE
 			player.Body().Filter = COL_LAYER_NONE
+			// Without a filter, the player would fall through the floor.
+			player.Body().Gravity = 0.0
+			player.Body().Velocity[1] = 0.0
 		} else {
 			player.Body().Layer = player.initialCollisionLayers
 			player.Body().Filter = COL_FILTER_FOR_ACTORS
+			player.Body().Gravity = ACTOR_GRAVITY
 			message = settings.Localize("noclipDeactivate")
 		}
 		player.world.Hud.ShowMessage(message, 4.0, 100, color.Red)
@@ -329,6 +337,12 @@ func (player *Player) onIntersect(otherEnt comps.HasBody, result collision.Resul
 	}
 }
 
+func (player *Player) onLand(fallSpeed float32) {
+	if damage := fallDamage(fallSpeed); damage > 0.0 {
+		player.OnDamage(nil, damage)
+	}
+}
+
 func (player *Player) OnDamage(sourceEntity any, damage float32) bool {
 	if player.godMode {
 		return false
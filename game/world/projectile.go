@@ -90,7 +90,10 @@ func (proj *Projectile) playAnimOnDie(deltaTime float32) {
 		proj.body.Layer = 0
 		proj.body.Filter = 0
 		proj.body.OnIntersect = nil
-		proj.body.Transform.TranslateV(proj.body.Velocity.Mul(-deltaTime))
+		if !proj.body.Continuous {
+			// Back out of whatever it hit. Continuous bodies already stopped where they touched it.
+			proj.body.Transform.TranslateV(proj.body.Velocity.Mul(-deltaTime))
+		}
 		proj.body.Velocity = mgl32.Vec3{}
 		proj.moveFunc = nil
 	}
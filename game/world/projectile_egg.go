@@ -30,11 +30,12 @@ func SpawnEgg(world *World, position, rotation mgl32.Vec3, owner scene.Handle) (
 	proj.owner = owner
 
 	proj.body = comps.Body{
-		Transform: comps.TransformFromTranslationAnglesScale(position, rotation, mgl32.Vec3{0.4, 0.4, 0.4}),
-		Shape:     collision.NewSphere(0.1),
-		Layer:     COL_LAYER_PROJECTILES,
-		Filter:    COL_LAYER_NONE,
-		LockY:     true,
+		Transform:  comps.TransformFromTranslationAnglesScale(position, rotation, mgl32.Vec3{0.4, 0.4, 0.4}),
+		Shape:      collision.NewSphere(0.1),
+		Layer:      COL_LAYER_PROJECTILES,
+		Filter:     COL_LAYER_NONE,
+		LockY:      true,
+		Continuous: true,
 	}
 
 	eggTex := cache.GetTexture("assets/textures/sprites/egg.png")
@@ -45,6 +46,7 @@ func SpawnEgg(world *World, position, rotation mgl32.Vec3, owner scene.Handle) (
 
 	proj.moveFunc = proj.moveForward
 	proj.body.OnIntersect = proj.eggIntersect
+	proj.body.ShouldSweep = proj.shouldIntersect
 
 	return
 }
@@ -23,11 +23,12 @@ func SpawnFireball(world *World, position, rotation mgl32.Vec3, owner scene.Hand
 	proj.owner = owner
 
 	proj.body = comps.Body{
-		Transform: comps.TransformFromTranslationAnglesScale(position, rotation, mgl32.Vec3{0.375, 0.375, 0.375}),
-		Shape:     collision.NewSphere(0.25),
-		Layer:     COL_LAYER_PROJECTILES,
-		Filter:    COL_LAYER_NONE,
-		LockY:     true,
+		Transform:  comps.TransformFromTranslationAnglesScale(position, rotation, mgl32.Vec3{0.375, 0.375, 0.375}),
+		Shape:      collision.NewSphere(0.25),
+		Layer:      COL_LAYER_PROJECTILES,
+		Filter:     COL_LAYER_NONE,
+		LockY:      true,
+		Continuous: true,
 	}
 
 	tex := cache.GetTexture("assets/textures/sprites/fireball.png")
@@ -40,6 +41,7 @@ func SpawnFireball(world *World, position, rotation mgl32.Vec3, owner scene.Hand
 
 	proj.moveFunc = proj.moveForward
 	proj.body.OnIntersect = proj.dieOnHit
+	proj.body.ShouldSweep = proj.shouldIntersect
 
 	return
 }
@@ -20,9 +20,10 @@ func SpawnPlasmaBall(world *World, position, rotation mgl32.Vec3, owner scene.Ha
 	proj.owner = owner
 
 	proj.body = comps.Body{
-		Layer:  COL_LAYER_PROJECTILES,
-		Filter: COL_LAYER_NONE,
-		LockY:  true,
+		Layer:      COL_LAYER_PROJECTILES,
+		Filter:     COL_LAYER_NONE,
+		LockY:      true,
+		Continuous: true,
 	}
 	var tex *textures.Texture
 	if bigShot {
@@ -47,6 +48,7 @@ func SpawnPlasmaBall(world *World, position, rotation mgl32.Vec3, owner scene.Ha
 
 	proj.moveFunc = proj.moveForward
 	proj.body.OnIntersect = proj.dieOnHit
+	proj.body.ShouldSweep = proj.shouldIntersect
 	proj.onDie = proj.playAnimOnDie
 
 	return
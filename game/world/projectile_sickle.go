@@ -31,11 +31,12 @@ func SpawnSickle(world *World, position, rotation mgl32.Vec3, owner scene.Handle
 	proj.owner = owner
 
 	proj.body = comps.Body{
-		Transform: comps.TransformFromTranslationAngles(position, rotation),
-		Shape:     collision.NewSphere(0.5),
-		Layer:     COL_LAYER_PROJECTILES,
-		Filter:    COL_LAYER_NONE,
-		LockY:     true,
+		Transform:  comps.TransformFromTranslationAngles(position, rotation),
+		Shape:      collision.NewSphere(0.5),
+		Layer:      COL_LAYER_PROJECTILES,
+		Filter:     COL_LAYER_NONE,
+		LockY:      true,
+		Continuous: true,
 	}
 
 	sickleTex := cache.GetTexture("assets/textures/sprites/sickle_thrown.png")
@@ -53,6 +54,7 @@ func SpawnSickle(world *World, position, rotation mgl32.Vec3, owner scene.Handle
 
 	proj.moveFunc = proj.sickleMove
 	proj.body.OnIntersect = proj.sickleIntersect
+	proj.body.ShouldSweep = proj.sickleShouldSweep
 
 	return
 }
@@ -123,3 +125,15 @@ func (proj *Projectile) sickleIntersect(otherEnt comps.HasBody, result collision
 		}
 	}
 }
+
+// The sickle cuts through whatever it passes, so it only stops at walls, and at its owner once it's coming back.
+func (proj *Projectile) sickleShouldSweep(otherEnt comps.HasBody) bool {
+	if !proj.body.OnLayer(COL_LAYER_PROJECTILES) {
+		return false
+	}
+	otherBody := otherEnt.Body()
+	if owner, hasOwner := scene.Get[HasActor](proj.owner); hasOwner && otherBody == owner.Body() {
+		return proj.forwardSpeed <= -1.0
+	}
+	return otherBody.OnLayer(COL_LAYER_MAP)
+}
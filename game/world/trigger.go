@@ -18,25 +18,11 @@ import (
 )
 
 const (
-	TRIGGER_TOUCH_MAX = 3
-	SFX_TELEPORT      = "assets/sounds/teleport.wav"
-)
-
-const (
-	TRIGGER_ACTION_TELEPORT  = "teleport"
-	TRIGGER_ACTION_DAMAGE    = "damage"
-	TRIGGER_ACTION_END_LEVEL = "end level"
-	TRIGGER_ACTION_SECRET    = "secret"
-	TRIGGER_ACTION_ACTIVATE  = "activate"
-)
-
-const (
-	TRIGGER_ACTION      = "action"
-	TRIGGER_DAMAGE_RATE = "damagePerSecond"
+	SFX_TELEPORT = "assets/sounds/teleport.wav"
 )
 
 type Trigger struct {
-	Sphere          collision.Sphere
+	Shape           collision.MovingShape // A sphere with the ent's radius, unless the trigger covers a box of tiles.
 	Transform       comps.Transform
 	id              scene.Id[*Trigger]
 	particles       comps.ParticleRender
@@ -46,7 +32,7 @@ type Trigger struct {
 	onExit          func(trigger *Trigger, entHandle scene.Handle)
 	world           *World
 	linkNumber      int
-	touching        [TRIGGER_TOUCH_MAX]scene.Handle
+	touching        []scene.Handle // Grows as needed, since triggers made from regions of tiles can cover large areas with many actors in them.
 	damagePerSecond float32
 	nextLevel       string
 }
@@ -61,33 +47,33 @@ func SpawnTriggerFromTE3(world *World, ent te3.Ent) (id scene.Id[*Trigger], tr *
 
 	tr.world = world
 	tr.id = id
-	tr.Sphere = collision.NewSphere(ent.Radius)
+	tr.Shape = collision.NewSphere(ent.Radius)
 	tr.Transform = comps.TransformFromTE3Ent(ent, false, false)
 	tr.linkNumber, _ = ent.IntProperty("link")
 
-	switch ent.Properties[TRIGGER_ACTION] {
-	case TRIGGER_ACTION_TELEPORT:
+	switch ent.Properties[game.TRIGGER_ACTION] {
+	case game.TRIGGER_ACTION_TELEPORT:
 		tr.filter = liveActorsOnlyFilter
 		tr.onEnter = teleportAction
 		tr.particles = effects.Teleport(0.5)
 		tr.particles.Init()
-	case TRIGGER_ACTION_DAMAGE:
+	case game.TRIGGER_ACTION_DAMAGE:
 		tr.filter = liveActorsOnlyFilter
 		tr.whileTouching = damageWhileTouching
-		damageRate, err := strconv.ParseFloat(ent.Properties[TRIGGER_DAMAGE_RATE], 32)
+		damageRate, err := strconv.ParseFloat(ent.Properties[game.TRIGGER_DAMAGE_RATE], 32)
 		if err != nil || math.IsNaN(damageRate) {
 			damageRate = 0.0
 		}
 		tr.damagePerSecond = float32(damageRate)
-	case TRIGGER_ACTION_END_LEVEL:
+	case game.TRIGGER_ACTION_END_LEVEL:
 		tr.filter = playerOnlyFilter
 		tr.onEnter = exitLevelAction
 		tr.nextLevel = "assets/maps/" + ent.Properties["level"] + ".te3"
-	case TRIGGER_ACTION_SECRET:
+	case game.TRIGGER_ACTION_SECRET:
 		tr.filter = playerOnlyFilter
 		tr.onEnter = secretAreaAction
 		world.Hud.SecretsTotal++
-	case TRIGGER_ACTION_ACTIVATE:
+	case game.TRIGGER_ACTION_ACTIVATE:
 		tr.filter = playerOnlyFilter
 		tr.onEnter = activateAction
 	}
@@ -95,21 +81,27 @@ func SpawnTriggerFromTE3(world *World, ent te3.Ent) (id scene.Id[*Trigger], tr *
 	return
 }
 
-func SpawnKillzone(world *World, position mgl32.Vec3, radius float32, damagePerSecond float32) (id scene.Id[*Trigger], tr *Trigger, err error) {
-	return SpawnTriggerFromTE3(world, te3.Ent{
-		Radius:   radius,
+// Spawns a trigger that damages the actors inside of the box, which is in world space.
+func SpawnKillzone(world *World, box math2.Box, damagePerSecond float32) (id scene.Id[*Trigger], tr *Trigger, err error) {
+	position := box.Center()
+	id, tr, err = SpawnTriggerFromTE3(world, te3.Ent{
 		Position: position,
 		Properties: map[string]string{
-			TRIGGER_ACTION:      TRIGGER_ACTION_DAMAGE,
-			TRIGGER_DAMAGE_RATE: fmt.Sprintf("%f", damagePerSecond),
+			game.TRIGGER_ACTION:      game.TRIGGER_ACTION_DAMAGE,
+			game.TRIGGER_DAMAGE_RATE: fmt.Sprintf("%f", damagePerSecond),
 		},
 	})
+	if err == nil {
+		tr.Shape = collision.NewBox(box.Translate(position.Mul(-1.0)))
+	}
+	return
 }
 
 func (tr *Trigger) Update(deltaTime float32) {
 	// Call callbacks for new & already touching entities
-	touchingNow := tr.world.BodiesInSphere(tr.Transform.Position(), tr.Sphere.Radius(), nil)
-	var stillTouching [TRIGGER_TOUCH_MAX]bool
+	touchingNow := tr.world.BodiesTouching(tr.Transform.Position(), tr.Shape, nil)
+	// Each entity that wasn't touching before takes a free slot or a new one at the end.
+	stillTouching := make([]bool, len(tr.touching)+len(touchingNow))
 	for _, handle := range touchingNow {
 		bodyHaver, _ := scene.Get[comps.HasBody](handle)
 		if tr.filter == nil || tr.filter(bodyHaver) {
@@ -118,7 +110,7 @@ func (tr *Trigger) Update(deltaTime float32) {
 					tr.onEnter(tr, handle)
 				}
 				stillTouching[index] = true
-			} else if index >= 0 {
+			} else {
 				if tr.whileTouching != nil {
 					tr.whileTouching(tr, handle, deltaTime)
 				}
@@ -127,7 +119,7 @@ func (tr *Trigger) Update(deltaTime float32) {
 		}
 	}
 	// Remove entities no longer being touched
-	for i := range stillTouching {
+	for i := range tr.touching {
 		if !stillTouching[i] && !tr.touching[i].IsNil() {
 			if tr.onExit != nil && tr.touching[i].Exists() {
 				tr.onExit(tr, tr.touching[i])
@@ -158,7 +150,7 @@ func (tr *Trigger) OnLinkDeactivate(source Linkable) {
 }
 
 // Returns a bool that is true if the handle was added to a new slot.
-// The int returned is the index of the handle in the array if found, or -1.
+// The int returned is the index of the handle in the slice.
 func (tr *Trigger) addToTouching(handle scene.Handle) (bool, int) {
 	for i := range tr.touching {
 		if !tr.touching[i].IsNil() && tr.touching[i].Equals(handle) {
@@ -171,7 +163,8 @@ func (tr *Trigger) addToTouching(handle scene.Handle) (bool, int) {
 			return true, i
 		}
 	}
-	return false, -1
+	tr.touching = append(tr.touching, handle)
+	return true, len(tr.touching) - 1
 }
 
 func teleportAction(tr *Trigger, handle scene.Handle) {
@@ -189,13 +182,11 @@ func teleportAction(tr *Trigger, handle scene.Handle) {
 		if link != tr {
 			if trOther, isTrigger := link.(*Trigger); isTrigger {
 				// If there are NPCs standing on the other side, kill them.
-				actorsIter := tr.world.IterActorsInSphere(trOther.Transform.Position(), trOther.Sphere.Radius(), nil)
-				for {
-					_, actorHandle := actorsIter.Next()
-					if actorHandle.IsNil() {
-						break
+				for _, bodyHandle := range tr.world.BodiesTouching(trOther.Transform.Position(), trOther.Shape, nil) {
+					victimEnt, isActor := scene.Get[HasActor](bodyHandle)
+					if !isActor {
+						continue
 					}
-					victimEnt, _ := scene.Get[HasActor](actorHandle)
 					if player, isPlayer := victimEnt.(*Player); isPlayer && player != teleportingEnt {
 						// If the player is on the other side, kill the NPC instead.
 						teleportingEnt.(Damageable).OnDamage(tr, math2.Inf32())
@@ -81,7 +81,7 @@ func SpawnWallFromTE3(world *World, ent te3.Ent) (id scene.Id[*Wall], wall *Wall
 			return scene.Id[*Wall]{}, nil, err
 		}
 		bbox = wall.MeshRender.Mesh.TransformedAABB(transform.Matrix().Mat3().Mat4())
-		wall.MeshRender.Shader = shaders.MapShader
+		wall.MeshRender.Shader = shaders.ModelShader
 	} else {
 		bbox = math2.BoxFromRadius(1.0)
 	}
@@ -117,6 +117,16 @@ func SpawnWallFromTE3(world *World, ent te3.Ent) (id scene.Id[*Wall], wall *Wall
 	return
 }
 
+// How far doors move in each direction. Directions other than up and down are turned along with the door.
+var doorOffsets = [game.DOOR_DIRECTION_COUNT]mgl32.Vec3{
+	game.DOOR_DIRECTION_DOWN:     {0.0, -1.0, 0.0},
+	game.DOOR_DIRECTION_UP:       {0.0, 1.0, 0.0},
+	game.DOOR_DIRECTION_RIGHT:    {1.0, 0.0, 0.0},
+	game.DOOR_DIRECTION_LEFT:     {-1.0, 0.0, 0.0},
+	game.DOOR_DIRECTION_FORWARD:  {0.0, 0.0, -1.0},
+	game.DOOR_DIRECTION_BACKWARD: {0.0, 0.0, 1.0},
+}
+
 func (wall *Wall) configureForDoor(ent te3.Ent) error {
 	// Determine the door's destination position
 	unopenable, _ := ent.BoolProperty("unopenable")
@@ -134,19 +144,11 @@ func (wall *Wall) configureForDoor(ent te3.Ent) error {
 		}
 
 		var moveOffset mgl32.Vec3
-		switch dirStr {
-		case "down", "dn", "d":
-			moveOffset = mgl32.Vec3{0.0, -dist, 0.0}
-		case "up", "u":
-			moveOffset = mgl32.Vec3{0.0, dist, 0.0}
-		case "right", "rg", "r":
-			moveOffset = mgl32.TransformNormal(mgl32.Vec3{dist, 0.0, 0.0}, wall.body.Transform.Matrix())
-		case "left", "lf", "l":
-			moveOffset = mgl32.TransformNormal(mgl32.Vec3{-dist, 0.0, 0.0}, wall.body.Transform.Matrix())
-		case "forward", "fw", "f":
-			moveOffset = mgl32.TransformNormal(mgl32.Vec3{0.0, 0.0, -dist}, wall.body.Transform.Matrix())
-		case "backward", "back", "b":
-			moveOffset = mgl32.TransformNormal(mgl32.Vec3{0.0, 0.0, dist}, wall.body.Transform.Matrix())
+		if dir, known := game.DoorDirectionFromName(dirStr); known {
+			moveOffset = doorOffsets[dir].Mul(dist)
+			if dir != game.DOOR_DIRECTION_DOWN && dir != game.DOOR_DIRECTION_UP {
+				moveOffset = mgl32.TransformNormal(moveOffset, wall.body.Transform.Matrix())
+			}
 		}
 		wall.Destination = wall.Origin.Add(moveOffset)
 
@@ -21,6 +21,7 @@ import (
 	"tophatdemon.com/total-invasion-ii/engine/tdaudio"
 	"tophatdemon.com/total-invasion-ii/game"
 	"tophatdemon.com/total-invasion-ii/game/hud"
+	"tophatdemon.com/total-invasion-ii/game/mapdata"
 	"tophatdemon.com/total-invasion-ii/game/settings"
 )
 
@@ -43,12 +44,6 @@ const (
 	COL_FILTER_FOR_ACTORS collision.Mask = COL_LAYER_MAP | COL_LAYER_ACTORS | COL_LAYER_INVISIBLE
 )
 
-const (
-	TEX_FLAG_INVISIBLE string = "invisible"
-	TEX_FLAG_KILLZONE         = "killzone"
-	TEX_FLAG_LIQUID           = "liquid"
-)
-
 //go:generate go run ../../cmd/world_gen_iters/world_gen_iters.go
 type World struct {
 	Hud              hud.Hud
@@ -76,7 +71,20 @@ type World struct {
 	skyRender        comps.SkyRender
 }
 
+// Loads the map and creates the world, doing all of the work on the current goroutine.
 func NewWorld(app engine.Observer, mapPath string, debug bool) (*World, error) {
+	data, err := loadMapData(mapPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, upload := range data.uploadTasks() {
+		upload()
+	}
+	return newWorldFromMapData(app, data, debug)
+}
+
+// Creates the world from map data that has already been loaded and uploaded. Must be called on the main thread.
+func newWorldFromMapData(app engine.Observer, data *mapData, debug bool) (*World, error) {
 	world := &World{
 		removalQueue: make([]scene.Handle, 0, 8),
 		app:          app,
@@ -97,111 +105,30 @@ func NewWorld(app engine.Observer, mapPath string, debug bool) (*World, error) {
 	world.Cameras = scene.NewStorageWithFuncs(64, (*Camera).Update, nil)
 	world.GameMaps = scene.NewStorageWithFuncs(1, (*comps.Map).Update, (*comps.Map).Render)
 
-	te3File, err := te3.LoadTE3File(mapPath)
-	if err != nil {
-		return nil, err
-	}
+	te3File := data.TE3File
+	mapPath := te3File.FilePath()
 
-	// Pre process tiles before mesh is generated.
-	for texID, texPath := range te3File.Tiles.Textures {
-		tex := cache.GetTexture(texPath)
-		for id, tile := range te3File.Tiles.Data {
-			if tile.TextureIDs[0] != te3.TextureID(texID) {
-				continue
-			}
-			box := te3File.Tiles.BBoxOfTile(te3File.Tiles.UnflattenGridPos(id))
-			pos := box.Center()
-			// Remove invisible tiles and spawn entities in their place
-			if tex.HasFlag(TEX_FLAG_INVISIBLE) {
-				te3File.Tiles.EraseTile(id)
-				SpawnInvisibleWall(world, pos, collision.NewBox(box.Translate(pos.Mul(-1.0))))
-			}
-			if tex.HasFlag(TEX_FLAG_KILLZONE) {
-				//TODO: Spawning an entity for every tile is really not efficient...
-				SpawnKillzone(world, pos, box.Size().Len()/2.0, 9999.0)
-			}
-		}
+	// Spawn entities in place of the tiles that were removed from the mesh.
+	// Neighboring tiles are merged into boxes, so that large areas don't need an entity for every tile.
+	for _, box := range te3File.Tiles.MergeIntoBoxes(data.invisibleTiles) {
+		pos := box.Center()
+		SpawnInvisibleWall(world, pos, collision.NewBox(box.Translate(pos.Mul(-1.0))))
+	}
+	for _, box := range te3File.Tiles.MergeIntoBoxes(data.killzoneTiles) {
+		SpawnKillzone(world, box, 9999.0)
 	}
 
+	var err error
 	_, world.GameMap, err = world.GameMaps.New()
 	if err != nil {
 		return nil, err
 	}
-	*world.GameMap, err = comps.NewMap(te3File, COL_LAYER_MAP)
-	if err != nil {
-		return nil, err
-	}
+	*world.GameMap = comps.NewMapFromMesh(te3File, data.mesh, data.triMap, data.chunks, data.pvs, data.atlas, COL_LAYER_MAP)
 
-	type transformedShape struct {
-		shapeName  string
-		yaw, pitch uint8
-	}
-	// Contains the collision meshes of shapes at various rotations for reuse.
-	transformedShapesCache := make(map[transformedShape]collision.Mesh)
-
-	// Process tiles after mesh is generated.
+	// Set collision shapes
 	for id, tile := range te3File.Tiles.Data {
-		if tile.ShapeID < 0 {
-			continue
-		}
-
-		if cache.GetTexture(te3File.Tiles.Textures[tile.TextureIDs[0]]).HasFlag(TEX_FLAG_LIQUID) {
-			// Remove collision from liquid tiles.
-			world.GameMap.GridShape.SetShapeAtFlatIndex(id, nil)
-			continue
-		}
-
-		// Set collision shapes
-		switch shapeName := te3File.Tiles.Shapes[tile.ShapeID]; shapeName {
-		case "assets/models/shapes/cylinder.obj":
-			// Cylinder
-			world.GameMap.GridShape.SetShapeAtFlatIndex(id, collision.NewCylinder(1.0, 2.0))
-		case "assets/models/shapes/corner.obj",
-			"assets/models/shapes/right_tetrahedron.obj",
-			"assets/models/shapes/tetrahedron_transition.obj",
-			"assets/models/shapes/wedge_corner_inner.obj",
-			"assets/models/shapes/wedge_corner_outer.obj",
-			"assets/models/shapes/wedge.obj":
-
-			// Triangles
-			cacheKey := transformedShape{shapeName, tile.Yaw, tile.Pitch}
-			trianglesShape, ok := transformedShapesCache[cacheKey]
-			if !ok {
-				shapeMesh, err := cache.GetMesh(shapeName)
-				if err != nil {
-					log.Printf("error loading mesh for collisions shape of %v: %v\n", shapeName, err)
-					continue
-				}
-				transform := tile.GetRotationMatrix()
-				rawTrianglesIter := shapeMesh.IterTriangles()
-				transformedTriangles := make([]math2.Triangle, rawTrianglesIter.Count())
-				// Transform the vertices of the triangle according to the tile's orientation.
-				for i := 0; rawTrianglesIter.HasNext(); i++ {
-					rawTriangle := rawTrianglesIter.Next()
-					for p := range rawTriangle {
-						transformedTriangles[i][p] = mgl32.TransformNormal(rawTriangle[p], transform)
-					}
-				}
-				trianglesShape = collision.NewMeshFromTriangles(transformedTriangles)
-				transformedShapesCache[cacheKey] = trianglesShape
-			}
-
-			world.GameMap.GridShape.SetShapeAtFlatIndex(id, trianglesShape)
-		case "assets/models/shapes/bars.obj",
-			"assets/models/shapes/panel.obj":
-
-			// Panel
-			var panelShape collision.Shape
-			switch tile.Yaw {
-			case 0, 2:
-				panelShape = collision.NewBox(math2.BoxFromExtents(1.0, 1.0, 0.5))
-			case 1, 3:
-				panelShape = collision.NewBox(math2.BoxFromExtents(0.5, 1.0, 1.0))
-			}
-			world.GameMap.GridShape.SetShapeAtFlatIndex(id, panelShape)
-		default:
-			// Box
-			world.GameMap.GridShape.SetShapeAtFlatIndex(id, collision.NewBox(math2.BoxFromRadius(1.0)))
+		if tile.ShapeID >= 0 {
+			world.GameMap.GridShape.SetShapeAtFlatIndex(id, data.tileShapes[id])
 		}
 	}
 
@@ -232,7 +159,7 @@ func NewWorld(app engine.Observer, mapPath string, debug bool) (*World, error) {
 
 			if skyPath, hasSky := ent.Properties["sky"]; hasSky {
 				// Create sky model
-				skyMesh, meshErr := cache.GetMesh("assets/models/sky.obj")
+				skyMesh, meshErr := cache.GetMesh(mapdata.SKY_MESH_PATH)
 				skyTex := cache.GetTexture("assets/textures/skies/" + skyPath + ".png")
 				if meshErr != nil {
 					log.Printf("Error loading sky: %v\n", meshErr)
@@ -265,6 +192,8 @@ func NewWorld(app engine.Observer, mapPath string, debug bool) (*World, error) {
 				log.Printf("error spawning player camera: %v\n", err)
 			}
 			world.CurrentPlayer, _, err = SpawnPlayer(world, ent.Position, ent.Angles, world.CurrentCamera)
+		case te3.LIGHT_ENT_TYPE:
+			// Already baked into the map's mesh.
 		}
 		if err != nil {
 			log.Printf("%v entity at %v caused an error: %v\n", entType, ent.GridPosition(), err)
@@ -321,9 +250,15 @@ func (world *World) Update(deltaTime float32) {
 	world.bspTree = tree.BuildBspTree(&it, world.GameMap)
 	it = world.IterBodies()
 	for bodyEnt, _ := it.Next(); bodyEnt != nil; bodyEnt, _ = it.Next() {
-		collidableBodies := world.bspTree.PotentiallyTouchingEnts(bodyEnt.Body().Transform.Position(), bodyEnt.Body().Shape)
+		body := bodyEnt.Body()
+		queryShape := body.Shape
+		if body.Continuous || body.Gravity != 0.0 {
+			// Look for bodies along the whole movement and below it, since it will be swept or look for the ground
+			queryShape = collision.NewBox(body.ReachExtents(deltaTime))
+		}
+		collidableBodies := world.bspTree.PotentiallyTouchingEnts(body.Transform.Position(), queryShape)
 		collidableBodies.Add(scene.NewHandle(0, 1, &world.GameMaps))
-		bodyEnt.Body().MoveAndCollide(deltaTime, collidableBodies)
+		body.MoveAndCollide(deltaTime, collidableBodies)
 	}
 
 	duration := time.Now().Sub(startTime).Milliseconds()
@@ -360,6 +295,11 @@ func (world *World) Render() {
 		LightDirection: mgl32.Vec3{1.0, 0.0, 1.0}.Normalize(),
 		AmbientColor:   mgl32.Vec3{0.5, 0.5, 0.5},
 	}
+	// Skip anything the map's potentially visible set says can't be seen from the camera.
+	cameraPos := camera.Transform.Position()
+	renderContext.VisibleArea = func(box math2.Box) bool {
+		return world.GameMap.CanSeeBox(cameraPos, box)
+	}
 
 	if world.Hud.IntroTimeLeft() < 2.0 {
 		// Render sky
@@ -46,6 +46,11 @@ func (world *World) IterActorsInSphere(spherePos mgl32.Vec3, sphereRadius float3
 
 // TODO: Replace with iterator.
 func (world *World) BodiesInSphere(spherePos mgl32.Vec3, sphereRadius float32, exception comps.HasBody) []scene.Handle {
+	return world.BodiesTouching(spherePos, collision.NewSphere(sphereRadius), exception)
+}
+
+// Returns the bodies that touch the shape at the given position.
+func (world *World) BodiesTouching(shapePos mgl32.Vec3, shape collision.MovingShape, exception comps.HasBody) []scene.Handle {
 	result := make([]scene.Handle, 0)
 	iter := world.IterBodies()
 	for bodyEnt, bodyId := iter.Next(); bodyEnt != nil; bodyEnt, bodyId = iter.Next() {
@@ -54,7 +59,7 @@ func (world *World) BodiesInSphere(spherePos mgl32.Vec3, sphereRadius float32, e
 		}
 		body := bodyEnt.Body()
 
-		if collision.NewSphere(sphereRadius).Touches(spherePos, body.Transform.Position(), body.Shape) {
+		if shape.Touches(shapePos, body.Transform.Position(), body.Shape) {
 			result = append(result, bodyId)
 		}
 	}